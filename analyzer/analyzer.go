@@ -0,0 +1,24 @@
+package analyzer
+
+import (
+	"context"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// File is a document file or photo passed to an analyzer
+type File struct {
+	Data     []byte
+	MimeType string
+}
+
+// DocumentAnalyzer compares an original document with copies provided for verification
+type DocumentAnalyzer interface {
+	// Compare analyzes the provided files (a PDF or photos of the document) against the original.
+	// Analyzers backed by a model send prompt with the files; the local analyzer ignores it.
+	Compare(ctx context.Context, prompt Prompt, original File, provided []File) (*entity.DocumentAnalysisResult, error)
+	// Extract proposes the type, name, summary, expiration date and fields of an uploaded document
+	Extract(ctx context.Context, file File) (*entity.DocumentExtraction, error)
+	// Name identifies the provider and model, e.g. "gemini:gemini-1.5-flash"
+	Name() string
+}
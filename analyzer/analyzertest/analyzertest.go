@@ -0,0 +1,76 @@
+// Package analyzertest provides a document analyzer test double
+package analyzertest
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// Call records the arguments of a Compare call
+type Call struct {
+	Prompt   analyzer.Prompt
+	Original analyzer.File
+	Provided []analyzer.File
+}
+
+// Analyzer returns a fixed result or error and records every call. Comparing without a result fails.
+type Analyzer struct {
+	Result     *entity.DocumentAnalysisResult
+	Extraction *entity.DocumentExtraction // returned by Extract, an empty proposal if nil
+	Err        error
+	Calls      []Call
+}
+
+// New returns an analyzer that reports result for every comparison
+func New(result *entity.DocumentAnalysisResult) *Analyzer {
+	return &Analyzer{Result: result}
+}
+
+// NewMatching returns an analyzer that reports a high confidence match
+func NewMatching() *Analyzer {
+	return New(&entity.DocumentAnalysisResult{
+		Score:       0.98,
+		IsAuthentic: true,
+		Confidence:  "high",
+		Differences: []entity.DocumentDifference{},
+		Findings: []entity.AnalysisFinding{
+			{
+				Category:    "visual",
+				Description: "Both documents are high-quality PDFs with matching content",
+				Severity:    "info",
+			},
+		},
+		Summary: "Documents match with 98% confidence. Documents are nearly identical.",
+	})
+}
+
+func (a *Analyzer) Name() string {
+	return "test"
+}
+
+func (a *Analyzer) Compare(ctx context.Context, prompt analyzer.Prompt, original analyzer.File, provided []analyzer.File) (*entity.DocumentAnalysisResult, error) {
+	a.Calls = append(a.Calls, Call{Prompt: prompt, Original: original, Provided: provided})
+	if a.Err != nil {
+		return nil, a.Err
+	}
+	if a.Result == nil {
+		return nil, errors.New("analyzertest: no result to compare with")
+	}
+	result := *a.Result
+	result.PromptVersion = prompt.Version
+	return &result, nil
+}
+
+func (a *Analyzer) Extract(ctx context.Context, file analyzer.File) (*entity.DocumentExtraction, error) {
+	if a.Err != nil {
+		return nil, a.Err
+	}
+	if a.Extraction == nil {
+		return &entity.DocumentExtraction{Fields: []entity.DocumentFieldValue{}, Provider: a.Name()}, nil
+	}
+	extraction := *a.Extraction
+	return &extraction, nil
+}
@@ -0,0 +1,348 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrProviderUnavailable is returned when the provider keeps failing or the circuit breaker is open
+	ErrProviderUnavailable = errors.New("analyzer provider unavailable")
+	// ErrProviderRateLimited is returned when the provider or the client-side rate limit rejects a request
+	ErrProviderRateLimited = errors.New("analyzer provider rate limited")
+)
+
+// ProviderError describes a failed provider request. It unwraps to ErrProviderUnavailable or
+// ErrProviderRateLimited when the failure is temporary.
+type ProviderError struct {
+	Provider   string
+	StatusCode int           // HTTP status of the last attempt, 0 if no response was received
+	RetryAfter time.Duration // how long the provider asked to wait, if it did
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: status %d: %v", e.Provider, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// ClientOptions configures how analyzers call their provider
+type ClientOptions struct {
+	Timeout          time.Duration // per attempt
+	MaxRetries       int           // retries after the first attempt for 429, 5xx and network errors
+	BaseBackoff      time.Duration // delay before the first retry, doubled for every further retry
+	MaxBackoff       time.Duration
+	RateLimit        float64       // requests per second, 0 disables the client-side limit
+	Burst            int           // requests allowed at once by the client-side limit
+	BreakerThreshold int           // consecutive failures that open the circuit, 0 disables the breaker
+	BreakerCooldown  time.Duration // how long the circuit stays open before a probe request
+}
+
+// DefaultClientOptions returns the options used when none are configured
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout:          60 * time.Second,
+		MaxRetries:       3,
+		BaseBackoff:      time.Second,
+		MaxBackoff:       30 * time.Second,
+		RateLimit:        1,
+		Burst:            5,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// providerClient sends requests to an AI provider with per-attempt timeouts, retries with
+// exponential backoff that honor Retry-After, a circuit breaker and a client-side token bucket
+type providerClient struct {
+	name       string
+	opts       ClientOptions
+	httpClient *http.Client
+	limiter    *tokenBucket
+	breaker    *circuitBreaker
+}
+
+func newProviderClient(name string, opts ClientOptions) *providerClient {
+	c := &providerClient{
+		name:       name,
+		opts:       opts,
+		httpClient: &http.Client{},
+	}
+	if opts.RateLimit > 0 {
+		c.limiter = newTokenBucket(opts.RateLimit, max(opts.Burst, 1))
+	}
+	if opts.BreakerThreshold > 0 {
+		c.breaker = &circuitBreaker{threshold: opts.BreakerThreshold, cooldown: opts.BreakerCooldown}
+	}
+	return c
+}
+
+// do sends the request built by newRequest until it succeeds or fails permanently, and returns the
+// body of the 200 response. newRequest is called for every attempt since request bodies are consumed.
+func (c *providerClient) do(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error)) ([]byte, error) {
+	var lastErr *ProviderError
+	for attempt := 0; ; attempt++ {
+		if c.breaker != nil && !c.breaker.allow(time.Now()) {
+			if lastErr != nil {
+				// The failures of this request opened the circuit
+				return nil, lastErr
+			}
+			return nil, &ProviderError{Provider: c.name, Err: fmt.Errorf("%w: circuit breaker is open", ErrProviderUnavailable)}
+		}
+		if err := c.wait(ctx); err != nil {
+			if c.breaker != nil {
+				c.breaker.release()
+			}
+			return nil, err
+		}
+
+		body, err := c.attempt(ctx, newRequest)
+		c.recordOutcome(ctx, err)
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var providerErr *ProviderError
+		if !errors.As(err, &providerErr) {
+			return nil, err
+		}
+		lastErr = providerErr
+		if !isTemporary(providerErr) || attempt >= c.opts.MaxRetries {
+			return nil, lastErr
+		}
+
+		delay := c.backoff(attempt, providerErr.RetryAfter)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// Waiting would outlast the caller, so report the failure now
+			return nil, lastErr
+		}
+		slog.Warn("analyzer provider request failed, retrying", "provider", c.name, "err", providerErr, "attempt", attempt+1, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt sends one request bounded by the per-attempt timeout
+func (c *providerClient) attempt(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error)) ([]byte, error) {
+	if c.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+
+	req, err := newRequest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &ProviderError{Provider: c.name, Err: fmt.Errorf("%w: %v", ErrProviderUnavailable, err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ProviderError{Provider: c.name, StatusCode: resp.StatusCode, Err: fmt.Errorf("%w: failed to read response: %v", ErrProviderUnavailable, err)}
+	}
+	if resp.StatusCode == http.StatusOK {
+		return body, nil
+	}
+
+	slog.Error("analyzer provider error", "provider", c.name, "status", resp.StatusCode, "body", string(body))
+	providerErr := &ProviderError{Provider: c.name, StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		providerErr.Err = ErrProviderRateLimited
+	case resp.StatusCode >= 500:
+		providerErr.Err = ErrProviderUnavailable
+	default:
+		providerErr.Err = fmt.Errorf("request rejected with status %d", resp.StatusCode)
+	}
+	return nil, providerErr
+}
+
+// wait takes a token from the client-side bucket, failing right away if the caller cannot wait long enough
+func (c *providerClient) wait(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+	var deadline time.Time
+	if d, ok := ctx.Deadline(); ok {
+		deadline = d
+	}
+	delay, ok := c.limiter.reserve(time.Now(), deadline)
+	if !ok {
+		return &ProviderError{Provider: c.name, Err: fmt.Errorf("%w: client-side rate limit reached", ErrProviderRateLimited)}
+	}
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// recordOutcome feeds the result of an attempt to the circuit breaker. Only failures that suggest the
+// provider is down count; a rate limited or abandoned attempt says nothing about its health.
+func (c *providerClient) recordOutcome(ctx context.Context, err error) {
+	if c.breaker == nil {
+		return
+	}
+	var providerErr *ProviderError
+	switch {
+	case ctx.Err() != nil || errors.Is(err, ErrProviderRateLimited):
+		c.breaker.release()
+	case errors.Is(err, ErrProviderUnavailable):
+		if c.breaker.record(false, time.Now()) {
+			slog.Error("analyzer provider circuit breaker opened", "provider", c.name, "cooldown", c.opts.BreakerCooldown)
+		}
+	case err == nil || errors.As(err, &providerErr):
+		// The provider answered, even if it rejected the request
+		c.breaker.record(true, time.Now())
+	default:
+		c.breaker.release()
+	}
+}
+
+// backoff returns the delay before retry number attempt+1. A Retry-After longer than the
+// exponential delay wins; otherwise up to 20% jitter spreads out concurrent retries.
+func (c *providerClient) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := c.opts.BaseBackoff
+	for i := 0; i < attempt && delay < c.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if c.opts.MaxBackoff > 0 {
+		delay = min(delay, c.opts.MaxBackoff)
+	}
+	if delay > 0 {
+		delay += time.Duration(rand.Int64N(int64(delay)/5 + 1))
+	}
+	return max(delay, retryAfter)
+}
+
+func isTemporary(err error) bool {
+	return errors.Is(err, ErrProviderUnavailable) || errors.Is(err, ErrProviderRateLimited)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// tokenBucket allows rate requests per second on average and burst at once
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// reserve takes a token and returns how long to wait until it is available. When the token would
+// only be available after deadline, nothing is taken and ok is false. A zero deadline means none.
+func (b *tokenBucket) reserve(now, deadline time.Time) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	var delay time.Duration
+	if b.tokens < 1 {
+		delay = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	if !deadline.IsZero() && now.Add(delay).After(deadline) {
+		return 0, false
+	}
+	b.tokens--
+	return delay, true
+}
+
+// circuitBreaker opens after threshold consecutive failures and rejects requests until cooldown
+// passed. Then a single probe request is let through: its success closes the circuit, its failure
+// opens it again.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record counts the outcome of a request and reports whether it opened the circuit
+func (b *circuitBreaker) record(success bool, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openUntil = now.Add(b.cooldown)
+	return true
+}
+
+// release ends a request without counting it, letting another probe through if it was one
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,303 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testProvider is a provider stand-in answering with the statuses in order, then with 200
+type testProvider struct {
+	*httptest.Server
+	requests   atomic.Int32
+	statuses   []int
+	retryAfter string
+}
+
+func newTestProvider(t *testing.T, statuses ...int) *testProvider {
+	t.Helper()
+	p := &testProvider{statuses: statuses}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(p.requests.Add(1)) - 1
+		if n < len(p.statuses) {
+			if p.retryAfter != "" {
+				w.Header().Set("Retry-After", p.retryAfter)
+			}
+			w.WriteHeader(p.statuses[n])
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *testProvider) newRequest(ctx context.Context) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodPost, p.URL, nil)
+}
+
+func testClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout:     time.Second,
+		MaxRetries:  3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+	}
+}
+
+func TestProviderClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int32
+		wantErr  error
+		status   int
+	}{
+		{"success", nil, 1, nil, 0},
+		{"rate limited", []int{429, 429}, 3, nil, 0},
+		{"server errors", []int{500, 502, 503}, 4, nil, 0},
+		{"retries exhausted", []int{500, 500, 500, 500}, 4, ErrProviderUnavailable, 500},
+		{"rate limited to the end", []int{429, 429, 429, 429}, 4, ErrProviderRateLimited, 429},
+		{"rejected", []int{400}, 1, nil, 400},
+		{"unauthorized", []int{401, 500}, 1, nil, 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, tt.statuses...)
+			client := newProviderClient("test", testClientOptions())
+
+			body, err := client.do(context.Background(), provider.newRequest)
+			if got := provider.requests.Load(); got != tt.attempts {
+				t.Errorf("provider got %d requests, want %d", got, tt.attempts)
+			}
+			if tt.status == 0 {
+				if err != nil || string(body) != "ok" {
+					t.Fatalf("do = %q, %v; want ok", body, err)
+				}
+				return
+			}
+
+			var providerErr *ProviderError
+			if !errors.As(err, &providerErr) {
+				t.Fatalf("do error = %v, want a ProviderError", err)
+			}
+			if providerErr.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", providerErr.StatusCode, tt.status)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("do error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && isTemporary(err) {
+				t.Errorf("do error = %v, want a permanent error", err)
+			}
+		})
+	}
+}
+
+func TestProviderClientRetryAfter(t *testing.T) {
+	provider := newTestProvider(t, 429)
+	provider.retryAfter = "1"
+	client := newProviderClient("test", testClientOptions())
+
+	start := time.Now()
+	if _, err := client.do(context.Background(), provider.newRequest); err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the second of Retry-After", elapsed)
+	}
+	if got := provider.requests.Load(); got != 2 {
+		t.Errorf("provider got %d requests, want 2", got)
+	}
+}
+
+func TestProviderClientRetryAfterPastDeadline(t *testing.T) {
+	provider := newTestProvider(t, 429)
+	provider.retryAfter = "30"
+	client := newProviderClient("test", testClientOptions())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.do(ctx, provider.newRequest)
+
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || !errors.Is(err, ErrProviderRateLimited) {
+		t.Fatalf("do error = %v, want rate limited", err)
+	}
+	if providerErr.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %s, want 30s", providerErr.RetryAfter)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("gave up after %s, want right away", elapsed)
+	}
+	if got := provider.requests.Load(); got != 1 {
+		t.Errorf("provider got %d requests, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestProviderClientCircuitBreaker(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	newRequest := func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	}
+
+	opts := testClientOptions()
+	opts.MaxRetries = 0
+	opts.BreakerThreshold = 2
+	opts.BreakerCooldown = 50 * time.Millisecond
+	client := newProviderClient("test", opts)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.do(ctx, newRequest); !errors.Is(err, ErrProviderUnavailable) {
+			t.Fatalf("request %d: error = %v, want unavailable", i+1, err)
+		}
+	}
+
+	// The circuit is open: requests fail without reaching the provider
+	_, err := client.do(ctx, newRequest)
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || !errors.Is(err, ErrProviderUnavailable) || providerErr.StatusCode != 0 {
+		t.Fatalf("open circuit: error = %v, want unavailable without a response", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("provider got %d requests, want 2", got)
+	}
+
+	// A failed probe after the cooldown opens the circuit again
+	time.Sleep(opts.BreakerCooldown)
+	if _, err := client.do(ctx, newRequest); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("failed probe: error = %v, want unavailable", err)
+	}
+	if _, err := client.do(ctx, newRequest); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("reopened circuit: error = %v, want unavailable", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("provider got %d requests, want 3", got)
+	}
+
+	// A successful probe closes it
+	failing.Store(false)
+	time.Sleep(opts.BreakerCooldown)
+	for i := 0; i < 3; i++ {
+		if _, err := client.do(ctx, newRequest); err != nil {
+			t.Fatalf("closed circuit, request %d: %v", i+1, err)
+		}
+	}
+	if got := requests.Load(); got != 6 {
+		t.Errorf("provider got %d requests, want 6", got)
+	}
+}
+
+func TestProviderClientBreakerIgnoresRejections(t *testing.T) {
+	provider := newTestProvider(t, 429, 429, 400, 400)
+	opts := testClientOptions()
+	opts.MaxRetries = 0
+	opts.BreakerThreshold = 1
+	opts.BreakerCooldown = time.Hour
+	client := newProviderClient("test", opts)
+
+	for i := 0; i < 4; i++ {
+		if _, err := client.do(context.Background(), provider.newRequest); err == nil {
+			t.Fatalf("request %d succeeded, want it rejected", i+1)
+		}
+	}
+	if _, err := client.do(context.Background(), provider.newRequest); err != nil {
+		t.Fatalf("do after rejections: %v, want the circuit still closed", err)
+	}
+}
+
+func TestProviderClientRateLimit(t *testing.T) {
+	provider := newTestProvider(t)
+	opts := testClientOptions()
+	opts.RateLimit = 1
+	opts.Burst = 2
+	client := newProviderClient("test", opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if _, err := client.do(ctx, provider.newRequest); err != nil {
+			t.Fatalf("request %d within burst: %v", i+1, err)
+		}
+	}
+
+	// The next token is a second away, past the deadline
+	_, err := client.do(ctx, provider.newRequest)
+	if !errors.Is(err, ErrProviderRateLimited) {
+		t.Fatalf("request past burst: error = %v, want rate limited", err)
+	}
+	if got := provider.requests.Load(); got != 2 {
+		t.Errorf("provider got %d requests, want 2", got)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	bucket := newTokenBucket(2, 3)
+
+	for i := 0; i < 3; i++ {
+		if delay, ok := bucket.reserve(now, time.Time{}); !ok || delay != 0 {
+			t.Fatalf("reserve %d = %s, %v; want a token right away", i+1, delay, ok)
+		}
+	}
+
+	// Waiting would pass the deadline, so no token is taken
+	if _, ok := bucket.reserve(now, now.Add(100*time.Millisecond)); ok {
+		t.Fatal("reserve past deadline succeeded")
+	}
+	if delay, ok := bucket.reserve(now, time.Time{}); !ok || delay != 500*time.Millisecond {
+		t.Fatalf("reserve empty bucket = %s, %v; want 500ms", delay, ok)
+	}
+	if delay, ok := bucket.reserve(now, time.Time{}); !ok || delay != time.Second {
+		t.Fatalf("reserve after reservation = %s, %v; want 1s", delay, ok)
+	}
+
+	// Refills at the rate, up to the burst
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if delay, ok := bucket.reserve(later, time.Time{}); !ok || delay != 0 {
+			t.Fatalf("reserve %d after refill = %s, %v; want a token right away", i+1, delay, ok)
+		}
+	}
+	if delay, _ := bucket.reserve(later, time.Time{}); delay == 0 {
+		t.Error("bucket refilled past its burst")
+	}
+}
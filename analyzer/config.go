@@ -0,0 +1,43 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tasklineby/certify-backend/config"
+)
+
+// NewDocumentAnalyzer returns the analyzer of the configured provider, wrapped in a first pass
+// analyzer when local comparison is to be tried first
+func NewDocumentAnalyzer(cfg config.AnalyzerConfig, gemini config.GeminiConfig) (DocumentAnalyzer, error) {
+	opts := DefaultClientOptions()
+	opts.Timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	opts.MaxRetries = max(cfg.MaxRetries, 0)
+	opts.RateLimit = max(float64(cfg.RateLimit), 0) / 60
+	opts.Burst = cfg.RateLimitBurst
+	opts.BreakerThreshold = max(cfg.BreakerThreshold, 0)
+	opts.BreakerCooldown = time.Duration(cfg.BreakerCooldownSeconds) * time.Second
+
+	var provider DocumentAnalyzer
+	switch cfg.Provider {
+	case "gemini":
+		if gemini.APIKey == "" {
+			return nil, fmt.Errorf("GEMINI_API_KEY is required for the gemini analyzer")
+		}
+		provider = NewGeminiAnalyzer(gemini.BaseURL, gemini.APIKey, gemini.Model, opts)
+	case "openai":
+		if cfg.OpenAIModel == "" {
+			return nil, fmt.Errorf("OPENAI_MODEL is required for the openai analyzer")
+		}
+		provider = NewOpenAIAnalyzer(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OpenAIModel, opts)
+	case "local":
+		return NewLocalAnalyzer(), nil
+	default:
+		return nil, fmt.Errorf("unknown analyzer provider %q", cfg.Provider)
+	}
+
+	if cfg.LocalFirst {
+		return NewFirstPassAnalyzer(provider), nil
+	}
+	return provider, nil
+}
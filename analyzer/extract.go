@@ -0,0 +1,190 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+const (
+	// MaxDocumentFields bounds the fields recorded for a document
+	MaxDocumentFields = 50
+	// DocumentFieldDateLayout is the format of date field values
+	DocumentFieldDateLayout = "2006-01-02"
+
+	maxFieldNameLength = 100
+)
+
+// extractionPrompt is the prompt sent to LLM providers to propose the details of an uploaded document
+const extractionPrompt = `You are a document analyst. Extract the key details of the attached document.
+
+Return this JSON structure:
+{
+  "type": "short lowercase document type, e.g. agreement, diploma, certificate, invoice",
+  "name": "title of the document",
+  "summary": "1-2 sentences describing the document",
+  "expiration_date": "YYYY-MM-DD when the document expires or stops being valid, empty if it does not say",
+  "fields": [
+    {"name": "snake_case name, e.g. employer, employee, contract_number, issue_date", "kind": "text|date|party|identifier|amount", "value": "value as written in the document, dates as YYYY-MM-DD"}
+  ]
+}
+
+IMPORTANT:
+- Include every party (people and organisations, named by their role) and every key identifier (document, registration or account numbers)
+- Copy values exactly as they appear in the document
+- Never invent values the document does not contain`
+
+var documentFieldKinds = []string{
+	string(entity.DocumentFieldText),
+	string(entity.DocumentFieldDate),
+	string(entity.DocumentFieldParty),
+	string(entity.DocumentFieldIdentifier),
+	string(entity.DocumentFieldAmount),
+}
+
+// extractionResponse represents the structured response requested by extractionPrompt
+type extractionResponse struct {
+	Type           string `json:"type"`
+	Name           string `json:"name"`
+	Summary        string `json:"summary"`
+	ExpirationDate string `json:"expiration_date"`
+	Fields         []struct {
+		Name  string `json:"name"`
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	} `json:"fields"`
+}
+
+// requestExtraction asks the model through send until an answer passes validation. Unlike comparisons,
+// invalid extractions are not repaired: they are only proposals and can be requested again.
+func requestExtraction(ctx context.Context, provider string, send func(ctx context.Context, maxTokens int) (modelAnswer, error)) (*entity.DocumentExtraction, error) {
+	var lastErr error
+	var usage entity.TokenUsage
+	maxTokens := comparisonMaxTokens
+	for attempt := 1; attempt <= maxResponseAttempts; attempt++ {
+		answer, err := send(ctx, maxTokens)
+		if err != nil {
+			return nil, withUsage(err, provider, usage)
+		}
+		usage.Add(answer.Usage)
+
+		extraction, err := parseExtraction(answer)
+		if err == nil {
+			extraction.Provider = provider
+			extraction.Usage = &usage
+			slog.Info("Document extraction completed", "provider", provider, "type", extraction.Type, "fields_count", len(extraction.Fields))
+			return extraction, nil
+		}
+		lastErr = err
+		slog.Warn("invalid extraction response", "provider", provider, "attempt", attempt, "truncated", answer.Truncated, "err", err)
+		if answer.Truncated {
+			maxTokens *= 2
+		}
+	}
+	slog.Error("no valid extraction response", "provider", provider, "err", lastErr, "total_tokens", usage.TotalTokens)
+	return nil, withUsage(fmt.Errorf("%w: %s: %v", ErrInvalidResponse, provider, lastErr), provider, usage)
+}
+
+// parseExtraction strictly converts the answer of a model to an extraction
+func parseExtraction(answer modelAnswer) (*entity.DocumentExtraction, error) {
+	if answer.Truncated {
+		return nil, fmt.Errorf("response was truncated")
+	}
+
+	var resp extractionResponse
+	if err := json.Unmarshal([]byte(normalizeResponse(answer.Text)), &resp); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	extraction := &entity.DocumentExtraction{
+		Type:    strings.ToLower(strings.TrimSpace(resp.Type)),
+		Name:    strings.TrimSpace(resp.Name),
+		Summary: strings.TrimSpace(resp.Summary),
+	}
+	if resp.ExpirationDate != "" {
+		date, err := time.Parse(DocumentFieldDateLayout, resp.ExpirationDate)
+		if err != nil {
+			return nil, fmt.Errorf("expiration_date %q is not formatted YYYY-MM-DD", resp.ExpirationDate)
+		}
+		extraction.ExpirationDate = &date
+	}
+
+	fields := make([]entity.DocumentFieldValue, 0, len(resp.Fields))
+	for _, f := range resp.Fields[:min(len(resp.Fields), MaxDocumentFields)] {
+		fields = append(fields, entity.DocumentFieldValue{Name: f.Name, Kind: entity.DocumentFieldKind(f.Kind), Value: f.Value})
+	}
+	fields, err := NormalizeDocumentFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	extraction.Fields = fields
+	return extraction, nil
+}
+
+// NormalizeDocumentFields validates fields, converts their names to snake_case and drops repeated
+// names, keeping the first
+func NormalizeDocumentFields(fields []entity.DocumentFieldValue) ([]entity.DocumentFieldValue, error) {
+	result := make([]entity.DocumentFieldValue, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for i, f := range fields {
+		f.Name = NormalizeFieldName(f.Name)
+		f.Value = strings.TrimSpace(f.Value)
+		if f.Name == "" {
+			return nil, fmt.Errorf("fields[%d]: name is empty", i)
+		}
+		if f.Value == "" {
+			return nil, fmt.Errorf("fields[%d]: value of %s is empty", i, f.Name)
+		}
+		if !slices.Contains(documentFieldKinds, string(f.Kind)) {
+			return nil, fmt.Errorf("fields[%d]: kind %q is not one of %v", i, f.Kind, documentFieldKinds)
+		}
+		if f.Kind == entity.DocumentFieldDate {
+			if _, err := time.Parse(DocumentFieldDateLayout, f.Value); err != nil {
+				return nil, fmt.Errorf("fields[%d]: date %q of %s is not formatted YYYY-MM-DD", i, f.Value, f.Name)
+			}
+		}
+		if seen[f.Name] {
+			continue
+		}
+		seen[f.Name] = true
+		result = append(result, f)
+	}
+	if len(result) > MaxDocumentFields {
+		return nil, fmt.Errorf("at most %d fields are allowed", MaxDocumentFields)
+	}
+	return result, nil
+}
+
+// NormalizeFieldName converts a field name to lowercase snake_case, e.g. "Contract No." to "contract_no"
+func NormalizeFieldName(name string) string {
+	var sb strings.Builder
+	pendingSeparator := false
+	for _, r := range strings.ToLower(name) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingSeparator = sb.Len() > 0
+			continue
+		}
+		if pendingSeparator {
+			sb.WriteByte('_')
+			pendingSeparator = false
+		}
+		sb.WriteRune(r)
+	}
+	result := sb.String()
+	if len(result) > maxFieldNameLength {
+		cut := maxFieldNameLength
+		for cut > 0 && !utf8.RuneStart(result[cut]) {
+			cut--
+		}
+		result = strings.TrimRight(result[:cut], "_")
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// firstPassAnalyzer compares PDFs with the local analyzer first and calls the AI provider only when
+// the deterministic result is not conclusive, e.g. for photos or documents with differences
+type firstPassAnalyzer struct {
+	local localAnalyzer
+	next  DocumentAnalyzer
+}
+
+// NewFirstPassAnalyzer runs the local analyzer before next. Identical documents and unreadable
+// provided files are decided locally; otherwise the differences found locally are added to the
+// result of next, which cannot be authentic when they are critical.
+func NewFirstPassAnalyzer(next DocumentAnalyzer) DocumentAnalyzer {
+	return &firstPassAnalyzer{next: next}
+}
+
+func (a *firstPassAnalyzer) Name() string {
+	return a.local.Name() + "+" + a.next.Name()
+}
+
+func (a *firstPassAnalyzer) Compare(ctx context.Context, prompt Prompt, original File, provided []File) (*entity.DocumentAnalysisResult, error) {
+	comparable := original.MimeType == "application/pdf" && len(provided) == 1 && provided[0].MimeType == "application/pdf"
+	if !comparable {
+		return a.next.Compare(ctx, prompt, original, provided)
+	}
+
+	local, conclusive := a.local.compare(original, provided)
+	if conclusive {
+		local.Provider = a.local.Name()
+		return local, nil
+	}
+
+	result, err := a.next.Compare(ctx, prompt, original, provided)
+	if err != nil {
+		return nil, err
+	}
+	result.Provider = a.Name()
+	if len(local.Differences) == 0 {
+		return result, nil
+	}
+
+	result.Differences = append(result.Differences, local.Differences...)
+	result.Findings = append(result.Findings, entity.AnalysisFinding{
+		Category:    "text",
+		Description: fmt.Sprintf("Deterministic comparison scored %.0f%% with %d differences", local.Score*100, len(local.Differences)),
+		Severity:    "info",
+	})
+	if result.IsAuthentic && !local.IsAuthentic && hasCritical(local.Differences) {
+		result.IsAuthentic = false
+		result.Score = min(result.Score, local.Score)
+		result.Summary = local.Summary
+		result.Findings = append(result.Findings, entity.AnalysisFinding{
+			Category:    "tampering",
+			Description: "Deterministic comparison found critical differences, the document is not considered authentic",
+			Severity:    "critical",
+		})
+	}
+	return result, nil
+}
+
+// Extract is left to next: models read documents far better than the local text heuristics
+func (a *firstPassAnalyzer) Extract(ctx context.Context, file File) (*entity.DocumentExtraction, error) {
+	return a.next.Extract(ctx, file)
+}
+
+func hasCritical(differences []entity.DocumentDifference) bool {
+	for _, d := range differences {
+		if d.Severity == "critical" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// geminiAnalyzer handles communication with Gemini API for document analysis
+type geminiAnalyzer struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *providerClient
+}
+
+// geminiRequest represents the request structure for Gemini API
+type geminiRequest struct {
+	Contents         []geminiContent        `json:"contents"`
+	GenerationConfig geminiGenerationConfig `json:"generationConfig"`
+}
+
+// geminiContent represents content in Gemini request
+type geminiContent struct {
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiPart represents a part of content (text or inline data)
+type geminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *geminiInlineData `json:"inlineData,omitempty"`
+}
+
+// geminiInlineData represents inline binary data (images/PDFs)
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64 encoded
+}
+
+// geminiGenerationConfig represents generation configuration
+type geminiGenerationConfig struct {
+	Temperature      float64       `json:"temperature"`
+	MaxOutputTokens  int           `json:"maxOutputTokens"`
+	ResponseMimeType string        `json:"responseMimeType"`
+	ResponseSchema   *geminiSchema `json:"responseSchema,omitempty"`
+}
+
+// geminiSchema is the subset of the OpenAPI schema Gemini accepts for structured output
+type geminiSchema struct {
+	Type             string                   `json:"type"`
+	Properties       map[string]*geminiSchema `json:"properties,omitempty"`
+	PropertyOrdering []string                 `json:"propertyOrdering,omitempty"`
+	Required         []string                 `json:"required,omitempty"`
+	Items            *geminiSchema            `json:"items,omitempty"`
+	Enum             []string                 `json:"enum,omitempty"`
+	Minimum          *float64                 `json:"minimum,omitempty"`
+	Maximum          *float64                 `json:"maximum,omitempty"`
+}
+
+// comparisonSchema constrains Gemini's answer to the structure parseComparison expects
+var comparisonSchema = &geminiSchema{
+	Type: "OBJECT",
+	Properties: map[string]*geminiSchema{
+		"score":        {Type: "NUMBER", Minimum: ptr(0.0), Maximum: ptr(1.0)},
+		"is_authentic": {Type: "BOOLEAN"},
+		"confidence":   {Type: "STRING", Enum: comparisonConfidences},
+		"differences": {
+			Type: "ARRAY",
+			Items: &geminiSchema{
+				Type: "OBJECT",
+				Properties: map[string]*geminiSchema{
+					"location":       {Type: "STRING"},
+					"field":          {Type: "STRING"},
+					"original_value": {Type: "STRING"},
+					"provided_value": {Type: "STRING"},
+					"severity":       {Type: "STRING", Enum: comparisonDifferenceSeverities},
+					"description":    {Type: "STRING"},
+				},
+				PropertyOrdering: []string{"location", "field", "original_value", "provided_value", "severity", "description"},
+				Required:         []string{"location", "severity", "description"},
+			},
+		},
+		"findings": {
+			Type: "ARRAY",
+			Items: &geminiSchema{
+				Type: "OBJECT",
+				Properties: map[string]*geminiSchema{
+					"category":    {Type: "STRING", Enum: comparisonFindingCategories},
+					"description": {Type: "STRING"},
+					"severity":    {Type: "STRING", Enum: comparisonFindingSeverities},
+				},
+				PropertyOrdering: []string{"category", "description", "severity"},
+				Required:         []string{"category", "description", "severity"},
+			},
+		},
+		"summary": {Type: "STRING"},
+	},
+	PropertyOrdering: []string{"score", "is_authentic", "confidence", "differences", "findings", "summary"},
+	Required:         []string{"score", "is_authentic", "confidence", "differences", "findings", "summary"},
+}
+
+// extractionSchema constrains Gemini's answer to the structure parseExtraction expects
+var extractionSchema = &geminiSchema{
+	Type: "OBJECT",
+	Properties: map[string]*geminiSchema{
+		"type":            {Type: "STRING"},
+		"name":            {Type: "STRING"},
+		"summary":         {Type: "STRING"},
+		"expiration_date": {Type: "STRING"},
+		"fields": {
+			Type: "ARRAY",
+			Items: &geminiSchema{
+				Type: "OBJECT",
+				Properties: map[string]*geminiSchema{
+					"name":  {Type: "STRING"},
+					"kind":  {Type: "STRING", Enum: documentFieldKinds},
+					"value": {Type: "STRING"},
+				},
+				PropertyOrdering: []string{"name", "kind", "value"},
+				Required:         []string{"name", "kind", "value"},
+			},
+		},
+	},
+	PropertyOrdering: []string{"type", "name", "summary", "expiration_date", "fields"},
+	Required:         []string{"type", "name", "summary", "expiration_date", "fields"},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// geminiAPIResponse represents the response from Gemini API
+type geminiAPIResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int `json:"thoughtsTokenCount"` // billed as output by thinking models
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error,omitempty"`
+}
+
+// NewGeminiAnalyzer creates a document analyzer backed by the Gemini API.
+// baseURL includes the API version, e.g. https://generativelanguage.googleapis.com/v1beta.
+func NewGeminiAnalyzer(baseURL, apiKey, model string, opts ClientOptions) DocumentAnalyzer {
+	a := &geminiAnalyzer{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+	a.client = newProviderClient(a.Name(), opts)
+	return a
+}
+
+func (a *geminiAnalyzer) Name() string {
+	return "gemini:" + a.model
+}
+
+// Compare sends the prompt followed by the original and the provided files as inline data
+func (a *geminiAnalyzer) Compare(ctx context.Context, prompt Prompt, original File, provided []File) (*entity.DocumentAnalysisResult, error) {
+	parts := []geminiPart{{Text: promptText(prompt)}}
+	for _, file := range append([]File{original}, provided...) {
+		parts = append(parts, geminiPart{
+			InlineData: &geminiInlineData{
+				MimeType: file.MimeType,
+				Data:     base64.StdEncoding.EncodeToString(file.Data),
+			},
+		})
+	}
+
+	return requestComparison(ctx, a.Name(), prompt.Version, func(ctx context.Context, maxTokens int) (modelAnswer, error) {
+		return a.sendRequest(ctx, parts, comparisonSchema, maxTokens)
+	})
+}
+
+// Extract sends the extraction prompt followed by the document as inline data
+func (a *geminiAnalyzer) Extract(ctx context.Context, file File) (*entity.DocumentExtraction, error) {
+	parts := []geminiPart{
+		{Text: extractionPrompt},
+		{InlineData: &geminiInlineData{MimeType: file.MimeType, Data: base64.StdEncoding.EncodeToString(file.Data)}},
+	}
+	return requestExtraction(ctx, a.Name(), func(ctx context.Context, maxTokens int) (modelAnswer, error) {
+		return a.sendRequest(ctx, parts, extractionSchema, maxTokens)
+	})
+}
+
+// sendRequest sends the request to Gemini API and returns the text of the first candidate
+func (a *geminiAnalyzer) sendRequest(ctx context.Context, parts []geminiPart, schema *geminiSchema, maxTokens int) (modelAnswer, error) {
+	reqBody := geminiRequest{
+		Contents: []geminiContent{
+			{Parts: parts},
+		},
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:      0.1,
+			MaxOutputTokens:  maxTokens,
+			ResponseMimeType: "application/json",
+			ResponseSchema:   schema,
+		},
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return modelAnswer{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := a.baseURL + "/models/" + a.model + ":generateContent"
+	body, err := a.client.do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		// Sent as a header rather than a query parameter so the key stays out of URLs in error messages
+		req.Header.Set("x-goog-api-key", a.apiKey)
+		return req, nil
+	})
+	if err != nil {
+		return modelAnswer{}, err
+	}
+
+	var apiResp geminiAPIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return modelAnswer{}, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	if apiResp.Error != nil {
+		return modelAnswer{}, fmt.Errorf("Gemini API error: %s", apiResp.Error.Message)
+	}
+
+	if len(apiResp.Candidates) == 0 || len(apiResp.Candidates[0].Content.Parts) == 0 {
+		return modelAnswer{}, fmt.Errorf("empty response from Gemini API")
+	}
+
+	candidate := apiResp.Candidates[0]
+	var sb strings.Builder
+	for _, part := range candidate.Content.Parts {
+		sb.WriteString(part.Text)
+	}
+	usage := apiResp.UsageMetadata
+	return modelAnswer{
+		Text:      sb.String(),
+		Truncated: candidate.FinishReason == "MAX_TOKENS",
+		Usage:     requestUsage(usage.PromptTokenCount, usage.CandidatesTokenCount+usage.ThoughtsTokenCount, usage.TotalTokenCount),
+	}, nil
+}
@@ -0,0 +1,455 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/pdf"
+)
+
+// Weights of the checks in the local score, summing to 1
+const (
+	localTextWeight     = 0.7
+	localLayoutWeight   = 0.1
+	localFontWeight     = 0.1
+	localMetadataWeight = 0.1
+)
+
+const (
+	// maxLocalDifferences bounds the differences reported for heavily modified documents
+	maxLocalDifferences = 100
+	// maxDifferenceValue bounds the original and provided values of a text difference
+	maxDifferenceValue = 500
+)
+
+// localMetadataKeys are the document information entries compared by the local analyzer
+var localMetadataKeys = []string{"Title", "Author", "Subject", "Creator", "Producer", "CreationDate"}
+
+// localAnalyzer compares PDFs deterministically without a model. Pages that render the same, judged
+// by their fingerprints, match outright; other pages are compared line by line on their extracted
+// text, and pages with the same text that render differently are reported as visual differences.
+// Page count and sizes, fonts and document metadata are compared too.
+// It cannot judge photos or pages without a text layer, such as scans.
+type localAnalyzer struct{}
+
+// NewLocalAnalyzer creates the deterministic, offline document analyzer
+func NewLocalAnalyzer() DocumentAnalyzer {
+	return localAnalyzer{}
+}
+
+func (localAnalyzer) Name() string {
+	return "local"
+}
+
+func (a localAnalyzer) Compare(ctx context.Context, prompt Prompt, original File, provided []File) (*entity.DocumentAnalysisResult, error) {
+	result, _ := a.compare(original, provided)
+	return result, nil
+}
+
+func (a localAnalyzer) Extract(ctx context.Context, file File) (*entity.DocumentExtraction, error) {
+	extraction := extractLocal(file)
+	extraction.Provider = a.Name()
+	return extraction, nil
+}
+
+// compare also reports whether the result is conclusive without a model: identical files, provided
+// files that are not readable PDFs, and PDFs whose pages have the same text and render the same
+func (localAnalyzer) compare(original File, provided []File) (*entity.DocumentAnalysisResult, bool) {
+	if len(provided) == 1 && sha256.Sum256(original.Data) == sha256.Sum256(provided[0].Data) {
+		return &entity.DocumentAnalysisResult{
+			Score:       1,
+			IsAuthentic: true,
+			Confidence:  "high",
+			Findings: []entity.AnalysisFinding{
+				{Category: "tampering", Description: "Provided file is byte-for-byte identical to the original", Severity: "info"},
+			},
+			Summary: "Provided file is identical to the original document.",
+		}, true
+	}
+
+	if original.MimeType != "application/pdf" || len(provided) != 1 || provided[0].MimeType != "application/pdf" {
+		return &entity.DocumentAnalysisResult{
+			Score:       0,
+			IsAuthentic: false,
+			Confidence:  "low",
+			Findings: []entity.AnalysisFinding{
+				{Category: "visual", Description: "The local analyzer compares PDF files only; photos and scans need an AI provider", Severity: "warning"},
+			},
+			Summary: "Automatic comparison is not available for photos. Review the document manually.",
+		}, false
+	}
+
+	return comparePDFs(original.Data, provided[0].Data)
+}
+
+// pdfContent is the comparable content of a PDF
+type pdfContent struct {
+	doc          *pdf.Document
+	pages        []pdf.Page
+	text         [][]string // lines per page
+	fingerprints []string   // per page, empty when the page content cannot be read
+	fonts        map[string]pdf.Font
+}
+
+func readPDF(data []byte) (*pdfContent, error) {
+	doc, err := pdf.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.IsEncrypted() {
+		return nil, pdf.ErrEncrypted
+	}
+	pages, err := doc.Pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w: no pages", pdf.ErrMalformed)
+	}
+
+	content := &pdfContent{
+		doc:          doc,
+		pages:        pages,
+		text:         make([][]string, len(pages)),
+		fingerprints: make([]string, len(pages)),
+		fonts:        map[string]pdf.Font{},
+	}
+	for i, page := range pages {
+		content.text[i], _ = doc.PageText(page)
+		content.fingerprints[i], _ = doc.PageFingerprint(page)
+		for _, font := range doc.PageFonts(page) {
+			content.fonts[font.Name] = font
+		}
+	}
+	return content, nil
+}
+
+// pdfComparison accumulates the differences found between two PDFs
+type pdfComparison struct {
+	differences []entity.DocumentDifference
+	omitted     int
+	critical    bool
+}
+
+func (c *pdfComparison) add(diff entity.DocumentDifference) {
+	if diff.Severity == "critical" {
+		c.critical = true
+	}
+	if len(c.differences) >= maxLocalDifferences {
+		c.omitted++
+		return
+	}
+	c.differences = append(c.differences, diff)
+}
+
+// comparePDFs scores the similarity of two PDFs and reports whether the result is conclusive
+func comparePDFs(original, provided []byte) (*entity.DocumentAnalysisResult, bool) {
+	orig, err := readPDF(original)
+	if err != nil {
+		return &entity.DocumentAnalysisResult{
+			Confidence: "low",
+			Findings: []entity.AnalysisFinding{
+				{Category: "visual", Description: "Original document could not be read: " + err.Error(), Severity: "warning"},
+			},
+			Summary: "Original document could not be analyzed. Review the document manually.",
+		}, false
+	}
+	prov, err := readPDF(provided)
+	if err != nil {
+		return &entity.DocumentAnalysisResult{
+			Confidence: "high",
+			Findings: []entity.AnalysisFinding{
+				{Category: "tampering", Description: "Provided file is not a readable PDF: " + err.Error(), Severity: "critical"},
+			},
+			Summary: "Provided file is not a readable PDF and cannot match the original document.",
+		}, true
+	}
+
+	c := &pdfComparison{}
+	if len(orig.pages) != len(prov.pages) {
+		c.add(entity.DocumentDifference{
+			Location:      "Document",
+			OriginalValue: fmt.Sprintf("%d pages", len(orig.pages)),
+			ProvidedValue: fmt.Sprintf("%d pages", len(prov.pages)),
+			Severity:      "critical",
+			Description:   "Page count differs",
+		})
+	}
+
+	pageCount := max(len(orig.pages), len(prov.pages))
+	var textScore float64
+	sameSize, samePages, sameText, textless, changedLines := 0, 0, 0, 0, 0
+	// Pages with the same text that render differently, and pages whose rendering could not be compared
+	redrawn, unrendered := 0, 0
+	for i := range pageCount {
+		location := fmt.Sprintf("Page %d", i+1)
+		if i >= len(prov.pages) {
+			c.add(entity.DocumentDifference{Location: location, OriginalValue: firstLine(orig.text[i]), Severity: "critical", Description: "Page is missing from the provided file"})
+			continue
+		}
+		if i >= len(orig.pages) {
+			c.add(entity.DocumentDifference{Location: location, ProvidedValue: firstLine(prov.text[i]), Severity: "critical", Description: "Page is not in the original document"})
+			continue
+		}
+
+		if o, p := orig.pages[i].MediaBox, prov.pages[i].MediaBox; o == p {
+			sameSize++
+		} else {
+			c.add(entity.DocumentDifference{
+				Location:      location,
+				OriginalValue: pageSize(o),
+				ProvidedValue: pageSize(p),
+				Severity:      "moderate",
+				Description:   "Page size differs",
+			})
+		}
+
+		if orig.fingerprints[i] != "" && orig.fingerprints[i] == prov.fingerprints[i] {
+			samePages++
+			sameText++
+			textScore++
+			continue
+		}
+
+		origText, provText := orig.text[i], prov.text[i]
+		if len(origText) == 0 && len(provText) == 0 {
+			textless++
+			c.add(entity.DocumentDifference{
+				Location:    location,
+				Severity:    "moderate",
+				Description: "Page content differs and the page has no text to compare",
+			})
+			continue
+		}
+
+		common, hunks := diffLines(origText, provText)
+		textScore += 2 * float64(common) / float64(len(origText)+len(provText))
+		if len(hunks) == 0 {
+			sameText++
+			if orig.fingerprints[i] == "" || prov.fingerprints[i] == "" {
+				unrendered++
+				c.add(entity.DocumentDifference{
+					Location:    location,
+					Severity:    "moderate",
+					Description: "Page has the same text, but how it renders could not be compared",
+				})
+			} else {
+				// The same text rendered differently, e.g. with a swapped photo, signature or stamp
+				redrawn++
+				c.add(entity.DocumentDifference{
+					Location:    location,
+					Severity:    "moderate",
+					Description: "Page renders differently although its text is the same",
+				})
+			}
+		}
+		for _, h := range hunks {
+			changedLines += max(len(h.removed), len(h.added))
+			severity, description := classifyHunk(h)
+			lineLocation := fmt.Sprintf("%s, line %d", location, h.origLine+1)
+			if len(h.removed) == 0 {
+				lineLocation = fmt.Sprintf("%s, line %d of the provided file", location, h.provLine+1)
+			}
+			c.add(entity.DocumentDifference{
+				Location:      lineLocation,
+				OriginalValue: truncateValue(strings.Join(h.removed, "\n")),
+				ProvidedValue: truncateValue(strings.Join(h.added, "\n")),
+				Severity:      severity,
+				Description:   description,
+			})
+		}
+	}
+
+	fontScore := compareFonts(c, orig.fonts, prov.fonts)
+	metadataScore := compareMetadata(c, orig.doc.Info(), prov.doc.Info())
+
+	score := localTextWeight*textScore/float64(pageCount) +
+		localLayoutWeight*float64(sameSize)/float64(pageCount) +
+		localFontWeight*fontScore +
+		localMetadataWeight*metadataScore
+	score = math.Round(score*100) / 100
+
+	result := &entity.DocumentAnalysisResult{
+		Score:       score,
+		IsAuthentic: score >= 0.95 && !c.critical && redrawn == 0 && unrendered == 0,
+		Confidence:  "high",
+		Differences: c.differences,
+		Findings: []entity.AnalysisFinding{
+			{Category: "layout", Description: fmt.Sprintf("%d of %d pages render identically", samePages, pageCount), Severity: "info"},
+			{Category: "text", Description: fmt.Sprintf("%d of %d pages have identical text", sameText, pageCount), Severity: "info"},
+		},
+	}
+	if changedLines > 0 {
+		result.Findings = append(result.Findings, entity.AnalysisFinding{
+			Category:    "text",
+			Description: fmt.Sprintf("Lines of text that differ from the original: %d", changedLines),
+			Severity:    findingSeverity(c.critical),
+		})
+	}
+	if textless > 0 {
+		// Scanned pages need a visual comparison
+		result.Confidence = "low"
+		result.Findings = append(result.Findings, entity.AnalysisFinding{
+			Category:    "visual",
+			Description: fmt.Sprintf("%d differing pages have no text layer and could not be compared", textless),
+			Severity:    "warning",
+		})
+	}
+	if redrawn > 0 {
+		// Images, signatures and stamps need a visual comparison
+		result.Confidence = "low"
+		result.Findings = append(result.Findings, entity.AnalysisFinding{
+			Category:    "visual",
+			Description: fmt.Sprintf("%d pages with the same text render differently; images, signatures or stamps may have been changed", redrawn),
+			Severity:    "warning",
+		})
+	}
+	if unrendered > 0 {
+		// The text matched, but nothing shows that the rest of the page did
+		result.Confidence = "low"
+		result.Findings = append(result.Findings, entity.AnalysisFinding{
+			Category:    "visual",
+			Description: fmt.Sprintf("%d pages with the same text could not be checked for changed images, signatures or stamps", unrendered),
+			Severity:    "warning",
+		})
+	}
+	if c.omitted > 0 {
+		result.Findings = append(result.Findings, entity.AnalysisFinding{
+			Category:    "text",
+			Description: fmt.Sprintf("%d further differences were not listed", c.omitted),
+			Severity:    "warning",
+		})
+	}
+	result.Summary = fmt.Sprintf("Deterministic comparison scored %.0f%% with %d differences. %s",
+		score*100, len(c.differences)+c.omitted, localRecommendation(result))
+
+	conclusive := len(orig.pages) == len(prov.pages) && sameText == pageCount && redrawn == 0 && unrendered == 0
+	return result, conclusive
+}
+
+// compareFonts reports fonts added, removed or no longer embedded and returns the share of fonts
+// used by both files
+func compareFonts(c *pdfComparison, orig, prov map[string]pdf.Font) float64 {
+	names := map[string]bool{}
+	for name := range orig {
+		names[name] = true
+	}
+	for name := range prov {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	shared := 0
+	for _, name := range sorted {
+		o, inOrig := orig[name]
+		p, inProv := prov[name]
+		location := "Font " + name
+		switch {
+		case !inProv:
+			c.add(entity.DocumentDifference{Location: location, OriginalValue: name, Severity: "minor", Description: "Font of the original is not used"})
+		case !inOrig:
+			// Text edited in another program is typically set in a font the original does not use
+			c.add(entity.DocumentDifference{Location: location, ProvidedValue: name, Severity: "moderate", Description: "Font is not used in the original"})
+		default:
+			shared++
+			if o.Embedded != p.Embedded {
+				c.add(entity.DocumentDifference{
+					Location:      location,
+					OriginalValue: embeddedLabel(o.Embedded),
+					ProvidedValue: embeddedLabel(p.Embedded),
+					Severity:      "minor",
+					Description:   "Font embedding differs",
+				})
+			}
+		}
+	}
+	if len(sorted) == 0 {
+		return 1
+	}
+	return float64(shared) / float64(len(sorted))
+}
+
+// compareMetadata reports differing document information entries and returns the share of equal ones
+func compareMetadata(c *pdfComparison, orig, prov map[string]string) float64 {
+	compared, same := 0, 0
+	for _, key := range localMetadataKeys {
+		ov, oOK := orig[key]
+		pv, pOK := prov[key]
+		if !oOK && !pOK {
+			continue
+		}
+		compared++
+		if ov == pv {
+			same++
+			continue
+		}
+		c.add(entity.DocumentDifference{
+			Location:      "Document information: " + key,
+			OriginalValue: ov,
+			ProvidedValue: pv,
+			Severity:      "minor",
+			Description:   "Metadata differs",
+		})
+	}
+	if compared == 0 {
+		return 1
+	}
+	return float64(same) / float64(compared)
+}
+
+func embeddedLabel(embedded bool) string {
+	if embedded {
+		return "embedded"
+	}
+	return "not embedded"
+}
+
+func findingSeverity(critical bool) string {
+	if critical {
+		return "critical"
+	}
+	return "warning"
+}
+
+func firstLine(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return truncateValue(lines[0])
+}
+
+func truncateValue(s string) string {
+	if len(s) <= maxDifferenceValue {
+		return s
+	}
+	cut := maxDifferenceValue
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+func pageSize(box pdf.Rectangle) string {
+	return fmt.Sprintf("%.0fx%.0f pt", box.Width(), box.Height())
+}
+
+func localRecommendation(result *entity.DocumentAnalysisResult) string {
+	switch {
+	case result.IsAuthentic:
+		return "The provided file matches the original."
+	case result.Score >= 0.7:
+		return "Review the listed differences before accepting the document."
+	default:
+		return "The provided file does not match the original; treat it as suspicious."
+	}
+}
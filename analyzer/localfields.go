@@ -0,0 +1,163 @@
+package analyzer
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+const (
+	// maxLocalSummary bounds the summary proposed from the opening lines of a document
+	maxLocalSummary = 300
+	// maxLocalNameLength bounds the name proposed from the first line of a document
+	maxLocalNameLength = 200
+)
+
+// localDocumentTypes maps words found in a document to the type proposed for it, most specific first
+var localDocumentTypes = []struct{ word, docType string }{
+	{"diploma", "diploma"},
+	{"certificate", "certificate"},
+	{"invoice", "invoice"},
+	{"licence", "license"},
+	{"license", "license"},
+	{"agreement", "agreement"},
+	{"contract", "contract"},
+}
+
+// Dates are recognised as 2024-12-31, 31.12.2024, 31/12/2024, December 31, 2024 and 31 December 2024
+var (
+	localDatePattern = regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2}|\d{1,2}[./]\d{1,2}[./]\d{4}|[A-Z][a-z]+ \d{1,2}, \d{4}|\d{1,2} [A-Z][a-z]+ \d{4})\b`)
+	localDateLayouts = []string{"2006-01-02", "2.1.2006", "2/1/2006", "January 2, 2006", "2 January 2006"}
+
+	// "Contract No. EA-42", "Registration number: 12345", "Invoice # 2024/17"
+	localIdentifierPattern = regexp.MustCompile(`(?i)\b([a-z]+)\s+(?:no\b\.?|number\b|#)\s*[:#]?\s*([a-z0-9][a-z0-9./-]*\d[a-z0-9./-]*)`)
+	// "between Acme Corp and Jane Smith"
+	localPartiesPattern = regexp.MustCompile(`(?i)\bbetween\s+(.{2,80}?)\s+and\s+(.{2,80}?)(?:[,.;(]|$)`)
+)
+
+// localExpirationKeywords mark a line that states when a document expires
+var localExpirationKeywords = []string{"expir", "valid until", "valid through", "valid till", "valid to"}
+
+// localIssueKeywords mark a line that states when a document was issued
+var localIssueKeywords = []string{"issued", "date of issue", "dated"}
+
+// extractLocal proposes the details of a PDF from its text layer with simple heuristics. Photos, scans
+// and PDFs without text yield an empty proposal.
+func extractLocal(file File) *entity.DocumentExtraction {
+	extraction := &entity.DocumentExtraction{Fields: []entity.DocumentFieldValue{}}
+	if file.MimeType != "application/pdf" {
+		return extraction
+	}
+	content, err := readPDF(file.Data)
+	if err != nil {
+		return extraction
+	}
+
+	var lines []string
+	for _, page := range content.text {
+		for _, line := range page {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return extraction
+	}
+
+	extraction.Name = truncateText(lines[0], maxLocalNameLength)
+	extraction.Type = localDocumentType(lines)
+	extraction.Summary = localSummary(lines[1:])
+
+	fields := []entity.DocumentFieldValue{}
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		if date, ok := findLocalDate(line); ok {
+			switch {
+			case extraction.ExpirationDate == nil && containsAny(lower, localExpirationKeywords):
+				extraction.ExpirationDate = &date
+			case containsAny(lower, localIssueKeywords):
+				fields = append(fields, entity.DocumentFieldValue{Name: "issue_date", Kind: entity.DocumentFieldDate, Value: date.Format(DocumentFieldDateLayout)})
+			}
+		}
+		for _, m := range localIdentifierPattern.FindAllStringSubmatch(line, -1) {
+			fields = append(fields, entity.DocumentFieldValue{Name: m[1] + " number", Kind: entity.DocumentFieldIdentifier, Value: strings.TrimRight(m[2], "./-")})
+		}
+		if m := localPartiesPattern.FindStringSubmatch(line); m != nil {
+			fields = append(fields,
+				entity.DocumentFieldValue{Name: "first_party", Kind: entity.DocumentFieldParty, Value: strings.TrimSpace(m[1])},
+				entity.DocumentFieldValue{Name: "second_party", Kind: entity.DocumentFieldParty, Value: strings.TrimSpace(m[2])})
+		}
+	}
+	if normalized, err := NormalizeDocumentFields(fields[:min(len(fields), MaxDocumentFields)]); err == nil {
+		extraction.Fields = normalized
+	}
+	return extraction
+}
+
+func localDocumentType(lines []string) string {
+	// The title names the type more reliably than the body
+	for _, text := range []string{lines[0], strings.Join(lines, " ")} {
+		text = strings.ToLower(text)
+		for _, t := range localDocumentTypes {
+			if strings.Contains(text, t.word) {
+				return t.docType
+			}
+		}
+	}
+	return ""
+}
+
+// localSummary joins the opening lines after the title
+func localSummary(lines []string) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(line)
+		if sb.Len() >= maxLocalSummary {
+			break
+		}
+	}
+	return truncateText(sb.String(), maxLocalSummary)
+}
+
+func findLocalDate(line string) (time.Time, bool) {
+	for _, match := range localDatePattern.FindAllString(line, -1) {
+		for _, layout := range localDateLayouts {
+			if date, err := time.Parse(layout, match); err == nil {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func containsAny(s string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(s, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateText shortens s to at most limit bytes, cutting at a word boundary where possible
+func truncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	s = s[:cut]
+	if i := strings.LastIndexByte(s, ' '); i > limit/2 {
+		s = s[:i]
+	}
+	return s + "…"
+}
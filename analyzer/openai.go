@@ -0,0 +1,182 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// openAIAnalyzer talks to any server implementing the OpenAI chat completions API,
+// e.g. OpenAI itself, vLLM, Ollama or LocalAI
+type openAIAnalyzer struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *providerClient
+}
+
+// openAIRequest represents a chat completions request
+type openAIRequest struct {
+	Model          string               `json:"model"`
+	Messages       []openAIMessage      `json:"messages"`
+	Temperature    float64              `json:"temperature"`
+	MaxTokens      int                  `json:"max_tokens"`
+	ResponseFormat openAIResponseFormat `json:"response_format"`
+}
+
+type openAIMessage struct {
+	Role    string              `json:"role"`
+	Content []openAIContentPart `json:"content"`
+}
+
+// openAIContentPart is a text, image or file part of a message
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+	File     *openAIFile     `json:"file,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"` // data URL
+}
+
+type openAIFile struct {
+	FileName string `json:"filename"`
+	FileData string `json:"file_data"` // data URL
+}
+
+type openAIResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// openAIResponse represents a chat completions response
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error,omitempty"`
+}
+
+// NewOpenAIAnalyzer creates a document analyzer for an OpenAI-compatible server.
+// baseURL includes the API version, e.g. https://api.openai.com/v1. apiKey may be empty for self-hosted servers.
+func NewOpenAIAnalyzer(baseURL, apiKey, model string, opts ClientOptions) DocumentAnalyzer {
+	a := &openAIAnalyzer{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+	a.client = newProviderClient(a.Name(), opts)
+	return a
+}
+
+func (a *openAIAnalyzer) Name() string {
+	return "openai:" + a.model
+}
+
+// Compare sends the prompt followed by the original and the provided files in one user message.
+// Images are sent as image_url parts and PDFs as file parts, both as data URLs.
+func (a *openAIAnalyzer) Compare(ctx context.Context, prompt Prompt, original File, provided []File) (*entity.DocumentAnalysisResult, error) {
+	content := []openAIContentPart{{Type: "text", Text: promptText(prompt)}}
+	for i, file := range append([]File{original}, provided...) {
+		name := "original.pdf"
+		if i > 0 {
+			name = fmt.Sprintf("provided-%d.pdf", i)
+		}
+		content = append(content, openAIFilePart(file, name))
+	}
+
+	// JSON mode rather than a JSON schema since not every OpenAI-compatible server supports schemas;
+	// the answer is validated either way
+	return requestComparison(ctx, a.Name(), prompt.Version, func(ctx context.Context, maxTokens int) (modelAnswer, error) {
+		return a.sendRequest(ctx, openAIRequest{
+			Model:          a.model,
+			Messages:       []openAIMessage{{Role: "user", Content: content}},
+			Temperature:    0.1,
+			MaxTokens:      maxTokens,
+			ResponseFormat: openAIResponseFormat{Type: "json_object"},
+		})
+	})
+}
+
+// Extract sends the extraction prompt followed by the document in one user message
+func (a *openAIAnalyzer) Extract(ctx context.Context, file File) (*entity.DocumentExtraction, error) {
+	content := []openAIContentPart{{Type: "text", Text: extractionPrompt}, openAIFilePart(file, "document.pdf")}
+	return requestExtraction(ctx, a.Name(), func(ctx context.Context, maxTokens int) (modelAnswer, error) {
+		return a.sendRequest(ctx, openAIRequest{
+			Model:          a.model,
+			Messages:       []openAIMessage{{Role: "user", Content: content}},
+			Temperature:    0.1,
+			MaxTokens:      maxTokens,
+			ResponseFormat: openAIResponseFormat{Type: "json_object"},
+		})
+	})
+}
+
+// openAIFilePart sends images as image_url parts and other files as file parts, both as data URLs
+func openAIFilePart(file File, name string) openAIContentPart {
+	dataURL := "data:" + file.MimeType + ";base64," + base64.StdEncoding.EncodeToString(file.Data)
+	if strings.HasPrefix(file.MimeType, "image/") {
+		return openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: dataURL}}
+	}
+	return openAIContentPart{Type: "file", File: &openAIFile{FileName: name, FileData: dataURL}}
+}
+
+// sendRequest sends a chat completions request and returns the content of the first choice
+func (a *openAIAnalyzer) sendRequest(ctx context.Context, reqBody openAIRequest) (modelAnswer, error) {
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return modelAnswer{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	body, err := a.client.do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/chat/completions", bytes.NewReader(jsonBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if a.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+a.apiKey)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return modelAnswer{}, err
+	}
+
+	var apiResp openAIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return modelAnswer{}, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	if apiResp.Error != nil {
+		return modelAnswer{}, fmt.Errorf("OpenAI-compatible API error: %s", apiResp.Error.Message)
+	}
+
+	if len(apiResp.Choices) == 0 || apiResp.Choices[0].Message.Content == "" {
+		return modelAnswer{}, fmt.Errorf("empty response from OpenAI-compatible API")
+	}
+	choice := apiResp.Choices[0]
+	return modelAnswer{
+		Text:      choice.Message.Content,
+		Truncated: choice.FinishReason == "length",
+		Usage:     requestUsage(apiResp.Usage.PromptTokens, apiResp.Usage.CompletionTokens, apiResp.Usage.TotalTokens),
+	}, nil
+}
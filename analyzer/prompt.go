@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// DefaultPromptVersion identifies DefaultPromptTemplate in stored analyses.
+// Bump it whenever the default template or comparisonResponseFormat changes.
+const DefaultPromptVersion = "builtin-3"
+
+// DefaultPromptTemplate holds the comparison instructions used when no template is configured for a
+// company or document type. Templates are Go text templates rendered with PromptVars.
+const DefaultPromptTemplate = `You are a document verification expert. Compare the ORIGINAL document (first) with the PROVIDED document (second).
+{{- if .DocumentType}}
+
+The document is of type "{{.DocumentType}}"{{if .DocumentName}} and named "{{.DocumentName}}"{{end}}.
+{{- end}}
+{{- if .DocumentSummary}}
+Issuer's description: {{.DocumentSummary}}
+{{- end}}
+
+ALWAYS provide a detailed response. Analyze:
+- Text differences (names, dates, numbers, addresses)
+- Visual differences (logos, signatures, stamps, layout)
+- Signs of tampering or forgery
+{{- if .ExpectedFields}}
+- These fields in particular: {{join .ExpectedFields ", "}}
+{{- end}}
+{{- if .Fields}}
+
+Fields recorded for the original document, check each of them:
+{{- range .Fields}}
+- {{.Name}} ({{.Kind}}): {{.Value}}
+{{- end}}
+{{- end}}`
+
+// comparisonResponseFormat is appended to every rendered prompt so templates cannot change the
+// structure parseComparison expects
+const comparisonResponseFormat = `Return this JSON structure:
+{
+  "score": 0.0-1.0,
+  "is_authentic": true/false,
+  "confidence": "low|medium|high",
+  "differences": [
+    {"location": "specific area", "field": "name of the recorded field the difference concerns, empty if none", "original_value": "what original shows", "provided_value": "what provided shows", "severity": "minor|moderate|critical", "description": "explain the difference"}
+  ],
+  "findings": [
+    {"category": "text|layout|visual|tampering", "description": "what you found", "severity": "info|warning|critical"}
+  ],
+  "summary": "2-3 sentences explaining overall comparison result and recommendation"
+}
+
+IMPORTANT:
+- Score 0.95-1.0 = identical, 0.85-0.94 = minor diffs, 0.70-0.84 = moderate, <0.70 = major issues
+- ALWAYS include at least 1 finding explaining your analysis
+- ALWAYS write a summary even if documents match
+- If documents differ, list specific differences with exact values`
+
+// Prompt is the rendered comparison instructions sent to LLM providers
+type Prompt struct {
+	Text    string
+	Version string // recorded with the result so the analysis can be reproduced
+}
+
+// DefaultPrompt renders DefaultPromptTemplate
+func DefaultPrompt(vars PromptVars) Prompt {
+	text, err := RenderPrompt(DefaultPromptTemplate, vars)
+	if err != nil {
+		// The default template is known to render
+		panic(err)
+	}
+	return Prompt{Text: text, Version: DefaultPromptVersion}
+}
+
+// PromptVars are the variables available to prompt templates
+type PromptVars struct {
+	DocumentType    string
+	DocumentName    string
+	DocumentSummary string
+	ExpectedFields  []string                    // fields the template asks to check, configured with the template
+	Fields          []entity.DocumentFieldValue // fields recorded for the document
+}
+
+var promptFuncs = template.FuncMap{"join": strings.Join}
+
+// ParsePromptTemplate checks that body is a valid template that renders with every variable set
+func ParsePromptTemplate(body string) error {
+	_, err := RenderPrompt(body, PromptVars{
+		DocumentType:    "agreement",
+		DocumentName:    "Employment Agreement",
+		DocumentSummary: "Standard employment agreement",
+		ExpectedFields:  []string{"employee name"},
+		Fields:          []entity.DocumentFieldValue{{Name: "employee", Kind: entity.DocumentFieldParty, Value: "Jane Smith"}},
+	})
+	return err
+}
+
+// RenderPrompt renders a prompt template. Unknown variables are an error.
+func RenderPrompt(body string, vars PromptVars) (string, error) {
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// promptText returns the instructions of prompt followed by the response format
+func promptText(prompt Prompt) string {
+	return prompt.Text + "\n\n" + comparisonResponseFormat
+}
@@ -0,0 +1,425 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// ErrInvalidResponse is returned when every answer of a model failed validation and none could be repaired
+var ErrInvalidResponse = errors.New("invalid analyzer response")
+
+// UsageError is returned when a comparison or extraction failed after the provider had already
+// billed tokens for it, so that they can be accounted all the same
+type UsageError struct {
+	Err      error
+	Provider string
+	Usage    entity.TokenUsage
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// withUsage adds the usage of the requests sent before err to it, unless there were none
+func withUsage(err error, provider string, usage entity.TokenUsage) error {
+	if usage.Requests == 0 {
+		return err
+	}
+	return &UsageError{Err: err, Provider: provider, Usage: usage}
+}
+
+const (
+	// maxResponseAttempts bounds the requests sent for one comparison when answers fail validation
+	maxResponseAttempts = 2
+	// comparisonMaxTokens is the output limit of the first request, doubled after a truncated answer
+	comparisonMaxTokens = 2048
+)
+
+// Values allowed in a comparison response, also used for the response schema sent to providers
+var (
+	comparisonConfidences          = []string{"low", "medium", "high"}
+	comparisonDifferenceSeverities = []string{"minor", "moderate", "critical"}
+	comparisonFindingCategories    = []string{"text", "layout", "visual", "tampering"}
+	comparisonFindingSeverities    = []string{"info", "warning", "critical"}
+)
+
+// comparisonResponse represents the structured response requested by comparisonResponseFormat.
+// Score and IsAuthentic are pointers to tell missing values from zero values.
+type comparisonResponse struct {
+	Score       *float64 `json:"score"`
+	IsAuthentic *bool    `json:"is_authentic"`
+	Confidence  string   `json:"confidence"`
+	Differences []struct {
+		Location      string `json:"location"`
+		Field         string `json:"field"`
+		OriginalValue string `json:"original_value"`
+		ProvidedValue string `json:"provided_value"`
+		Severity      string `json:"severity"`
+		Description   string `json:"description"`
+	} `json:"differences"`
+	Findings []struct {
+		Category    string `json:"category"`
+		Description string `json:"description"`
+		Severity    string `json:"severity"`
+	} `json:"findings"`
+	Summary string `json:"summary"`
+}
+
+// modelAnswer is the text a model answered with
+type modelAnswer struct {
+	Text      string
+	Truncated bool              // the model stopped at the output token limit
+	Usage     entity.TokenUsage // tokens the provider billed for the request
+}
+
+// requestUsage returns the usage of one request. Servers that only count input and output tokens
+// report no total.
+func requestUsage(input, output, total int) entity.TokenUsage {
+	if total == 0 {
+		total = input + output
+	}
+	return entity.TokenUsage{Requests: 1, InputTokens: input, OutputTokens: output, TotalTokens: total}
+}
+
+// requestComparison asks the model through send until an answer passes validation, re-requesting
+// invalid answers with a larger output limit if they were truncated. When no answer passed, the last
+// one that could be repaired is returned: it is flagged as repaired and never authentic. The usage of
+// the result counts the tokens of every request; when none succeeds, a UsageError carries them.
+func requestComparison(ctx context.Context, provider, promptVersion string, send func(ctx context.Context, maxTokens int) (modelAnswer, error)) (*entity.DocumentAnalysisResult, error) {
+	var repaired *entity.DocumentAnalysisResult
+	var lastErr error
+	var usage entity.TokenUsage
+	maxTokens := comparisonMaxTokens
+	for attempt := 1; attempt <= maxResponseAttempts; attempt++ {
+		answer, err := send(ctx, maxTokens)
+		if err != nil {
+			return nil, withUsage(err, provider, usage)
+		}
+		usage.Add(answer.Usage)
+
+		result, err := parseComparison(answer)
+		if err == nil {
+			result.PromptVersion = promptVersion
+			result.Usage = &usage
+			logComparison(provider, result)
+			return result, nil
+		}
+		lastErr = err
+		slog.Warn("invalid comparison response", "provider", provider, "attempt", attempt, "truncated", answer.Truncated, "err", err)
+
+		if r := repairComparison(answer.Text); r != nil {
+			repaired = r
+		}
+		if answer.Truncated {
+			maxTokens *= 2
+		}
+	}
+
+	if repaired == nil {
+		slog.Error("no valid comparison response", "provider", provider, "err", lastErr, "total_tokens", usage.TotalTokens)
+		return nil, withUsage(fmt.Errorf("%w: %s: %v", ErrInvalidResponse, provider, lastErr), provider, usage)
+	}
+	slog.Warn("using repaired comparison response", "provider", provider, "err", lastErr)
+	repaired.PromptVersion = promptVersion
+	repaired.Usage = &usage
+	logComparison(provider, repaired)
+	return repaired, nil
+}
+
+// parseComparison strictly converts the answer of a model to an analysis result
+func parseComparison(answer modelAnswer) (*entity.DocumentAnalysisResult, error) {
+	if answer.Truncated {
+		return nil, fmt.Errorf("response was truncated")
+	}
+
+	var resp comparisonResponse
+	if err := json.Unmarshal([]byte(normalizeResponse(answer.Text)), &resp); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if resp.IsAuthentic == nil {
+		return nil, fmt.Errorf("is_authentic is missing")
+	}
+	if !slices.Contains(comparisonConfidences, resp.Confidence) {
+		return nil, fmt.Errorf("confidence %q is not one of %v", resp.Confidence, comparisonConfidences)
+	}
+	if strings.TrimSpace(resp.Summary) == "" {
+		return nil, fmt.Errorf("summary is missing")
+	}
+	if err := validateComparison(resp); err != nil {
+		return nil, err
+	}
+	return comparisonResult(resp), nil
+}
+
+// repairComparison closes the open structures of a truncated answer. The result needs at least a valid
+// score; it is never authentic since the verdict may have been cut off or contradicted by the lost part.
+func repairComparison(text string) *entity.DocumentAnalysisResult {
+	var resp comparisonResponse
+	if err := json.Unmarshal([]byte(repairTruncatedJSON(normalizeResponse(text))), &resp); err != nil {
+		return nil
+	}
+	if err := validateComparison(resp); err != nil {
+		return nil
+	}
+
+	result := comparisonResult(resp)
+	result.Repaired = true
+	result.IsAuthentic = false
+	result.Confidence = "low"
+	if result.Summary == "" {
+		result.Summary = "The analysis was incomplete. Review the document manually."
+	}
+	result.Findings = append(result.Findings, entity.AnalysisFinding{
+		Category:    "tampering",
+		Description: "The analyzer response was incomplete and had to be repaired, so it cannot confirm the document is authentic",
+		Severity:    "warning",
+	})
+	return result
+}
+
+// validateComparison checks the score range and the values of the differences and findings
+func validateComparison(resp comparisonResponse) error {
+	if resp.Score == nil {
+		return fmt.Errorf("score is missing")
+	}
+	if *resp.Score < 0 || *resp.Score > 1 {
+		return fmt.Errorf("score %v is not between 0 and 1", *resp.Score)
+	}
+	for i, d := range resp.Differences {
+		if !slices.Contains(comparisonDifferenceSeverities, d.Severity) {
+			return fmt.Errorf("differences[%d]: severity %q is not one of %v", i, d.Severity, comparisonDifferenceSeverities)
+		}
+	}
+	for i, f := range resp.Findings {
+		if !slices.Contains(comparisonFindingCategories, f.Category) {
+			return fmt.Errorf("findings[%d]: category %q is not one of %v", i, f.Category, comparisonFindingCategories)
+		}
+		if !slices.Contains(comparisonFindingSeverities, f.Severity) {
+			return fmt.Errorf("findings[%d]: severity %q is not one of %v", i, f.Severity, comparisonFindingSeverities)
+		}
+	}
+	return nil
+}
+
+// comparisonResult converts a validated response to entity format
+func comparisonResult(resp comparisonResponse) *entity.DocumentAnalysisResult {
+	var differences []entity.DocumentDifference
+	for _, d := range resp.Differences {
+		differences = append(differences, entity.DocumentDifference{
+			Location:      d.Location,
+			Field:         d.Field,
+			OriginalValue: d.OriginalValue,
+			ProvidedValue: d.ProvidedValue,
+			Severity:      d.Severity,
+			Description:   d.Description,
+		})
+	}
+
+	var findings []entity.AnalysisFinding
+	for _, f := range resp.Findings {
+		findings = append(findings, entity.AnalysisFinding{
+			Category:    f.Category,
+			Description: f.Description,
+			Severity:    f.Severity,
+		})
+	}
+
+	result := &entity.DocumentAnalysisResult{
+		Score:       *resp.Score,
+		Confidence:  resp.Confidence,
+		Differences: differences,
+		Findings:    findings,
+		Summary:     resp.Summary,
+	}
+	if resp.IsAuthentic != nil {
+		result.IsAuthentic = *resp.IsAuthentic
+	}
+	return result
+}
+
+func logComparison(provider string, result *entity.DocumentAnalysisResult) {
+	slog.Info("Document comparison completed",
+		"provider", provider,
+		"score", result.Score,
+		"is_authentic", result.IsAuthentic,
+		"confidence", result.Confidence,
+		"repaired", result.Repaired,
+		"differences_count", len(result.Differences),
+		"findings_count", len(result.Findings))
+}
+
+// normalizeResponse removes common formatting models may add (e.g., code fences)
+// and trims whitespace so the JSON decoder receives a clean payload.
+func normalizeResponse(resp string) string {
+	resp = strings.TrimSpace(resp)
+
+	// Handle code fences ```json ... ```
+	if strings.HasPrefix(resp, "```") {
+		// Remove first fence line
+		if idx := strings.Index(resp, "\n"); idx != -1 {
+			resp = resp[idx+1:]
+		}
+		resp = strings.TrimPrefix(resp, "json")
+		resp = strings.TrimPrefix(resp, "JSON")
+		resp = strings.TrimSpace(resp)
+		// Remove trailing fence if present
+		resp = strings.TrimSuffix(resp, "```")
+		resp = strings.TrimSpace(resp)
+	}
+
+	return resp
+}
+
+// repairTruncatedJSON attempts to fix truncated JSON by:
+// 1. Finding the last complete JSON element
+// 2. Closing any unclosed brackets/braces
+func repairTruncatedJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return "{}"
+	}
+
+	// Find position of last complete value (after a complete string, number, bool, null, }, or ]).
+	// Object keys are not values: an answer cut off after a key drops it.
+	runes := []rune(s)
+	inString := false
+	isKey := false
+	escaped := false
+	lastCompleteValue := 0
+	var containers []rune
+	var lastStructural rune
+
+	for i, r := range runes {
+		if escaped {
+			escaped = false
+			continue
+		}
+		if r == '\\' && inString {
+			escaped = true
+			continue
+		}
+		if r == '"' {
+			inString = !inString
+			if inString {
+				isKey = len(containers) > 0 && containers[len(containers)-1] == '{' &&
+					(lastStructural == '{' || lastStructural == ',')
+			} else if !isKey {
+				// Just completed a string value
+				lastCompleteValue = i + 1
+			}
+			continue
+		}
+		if !inString {
+			switch r {
+			case '{', '[':
+				// An empty structure can be closed right away
+				containers = append(containers, r)
+				lastCompleteValue = i + 1
+			case '}', ']':
+				if len(containers) > 0 {
+					containers = containers[:len(containers)-1]
+				}
+				lastCompleteValue = i + 1
+			case ',':
+				// Comma after a value means previous value was complete
+				lastCompleteValue = i
+			}
+			if strings.ContainsRune("{}[],:", r) {
+				lastStructural = r
+			}
+		}
+	}
+
+	// If we're inside a string or have trailing incomplete content, truncate
+	if inString || lastCompleteValue < len(runes) {
+		// Check what's after lastCompleteValue
+		trailing := strings.TrimSpace(string(runes[lastCompleteValue:]))
+
+		// If trailing is just structural chars that need values, truncate
+		if trailing != "" && !strings.HasPrefix(trailing, "}") && !strings.HasPrefix(trailing, "]") {
+			s = string(runes[:lastCompleteValue])
+		}
+	}
+
+	// Remove trailing incomplete elements: comma, colon, or incomplete key
+	s = strings.TrimSpace(s)
+	for {
+		trimmed := false
+		// Remove trailing comma
+		if strings.HasSuffix(s, ",") {
+			s = strings.TrimSuffix(s, ",")
+			s = strings.TrimSpace(s)
+			trimmed = true
+		}
+		// Remove trailing colon (incomplete key-value)
+		if strings.HasSuffix(s, ":") {
+			// Find and remove the key too
+			s = strings.TrimSuffix(s, ":")
+			s = strings.TrimSpace(s)
+			// Remove the key string
+			if strings.HasSuffix(s, "\"") {
+				idx := strings.LastIndex(s[:len(s)-1], "\"")
+				if idx >= 0 {
+					s = strings.TrimSpace(s[:idx])
+				}
+			}
+			// Remove comma before the removed key if present
+			s = strings.TrimSuffix(s, ",")
+			s = strings.TrimSpace(s)
+			trimmed = true
+		}
+		if !trimmed {
+			break
+		}
+	}
+
+	// Now close any unclosed brackets/braces
+	var stack []rune
+	inString = false
+	escaped = false
+
+	for _, r := range s {
+		if escaped {
+			escaped = false
+			continue
+		}
+		if r == '\\' && inString {
+			escaped = true
+			continue
+		}
+		if r == '"' {
+			inString = !inString
+			continue
+		}
+		if !inString {
+			switch r {
+			case '{':
+				stack = append(stack, '}')
+			case '[':
+				stack = append(stack, ']')
+			case '}', ']':
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			}
+		}
+	}
+
+	// Close unclosed structures in reverse order
+	for i := len(stack) - 1; i >= 0; i-- {
+		s += string(stack[i])
+	}
+
+	return s
+}
@@ -0,0 +1,190 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+const validComparison = `{
+	"score": 0.42,
+	"is_authentic": true,
+	"confidence": "high",
+	"differences": [{"location": "page 1", "field": "name", "original_value": "Anna", "provided_value": "Anya", "severity": "moderate", "description": "Name differs"}],
+	"findings": [{"category": "text", "description": "Name was changed", "severity": "warning"}],
+	"summary": "The name differs"
+}`
+
+// truncatedComparison is a valid answer cut off before the summary, after the verdict, differences and
+// findings were answered
+func truncatedComparison() string {
+	return validComparison[:strings.Index(validComparison, `"The name differs"`)]
+}
+
+func TestParseComparison(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  modelAnswer
+		wantErr string
+	}{
+		{"valid", modelAnswer{Text: validComparison}, ""},
+		{"code fence", modelAnswer{Text: "```json\n" + validComparison + "\n```"}, ""},
+		{"score of zero", modelAnswer{Text: strings.Replace(validComparison, "0.42", "0", 1)}, ""},
+		{"score of one", modelAnswer{Text: strings.Replace(validComparison, "0.42", "1", 1)}, ""},
+		{"score above one", modelAnswer{Text: strings.Replace(validComparison, "0.42", "1.5", 1)}, "score 1.5 is not between 0 and 1"},
+		{"negative score", modelAnswer{Text: strings.Replace(validComparison, "0.42", "-0.1", 1)}, "score -0.1 is not between 0 and 1"},
+		{"percentage score", modelAnswer{Text: strings.Replace(validComparison, "0.42", "42", 1)}, "score 42 is not between 0 and 1"},
+		{"missing score", modelAnswer{Text: strings.Replace(validComparison, `"score": 0.42,`, "", 1)}, "score is missing"},
+		{"missing verdict", modelAnswer{Text: strings.Replace(validComparison, `"is_authentic": true,`, "", 1)}, "is_authentic is missing"},
+		{"invalid confidence", modelAnswer{Text: strings.Replace(validComparison, `"high"`, `"certain"`, 1)}, `confidence "certain"`},
+		{"missing confidence", modelAnswer{Text: strings.Replace(validComparison, `"confidence": "high",`, "", 1)}, `confidence ""`},
+		{"invalid difference severity", modelAnswer{Text: strings.Replace(validComparison, `"moderate"`, `"major"`, 1)}, `differences[0]: severity "major"`},
+		{"invalid finding category", modelAnswer{Text: strings.Replace(validComparison, `"category": "text"`, `"category": "other"`, 1)}, `findings[0]: category "other"`},
+		{"invalid finding severity", modelAnswer{Text: strings.Replace(validComparison, `"severity": "warning"`, `"severity": "high"`, 1)}, `findings[0]: severity "high"`},
+		{"missing summary", modelAnswer{Text: strings.Replace(validComparison, `"The name differs"`, `"  "`, 1)}, "summary is missing"},
+		{"truncated json", modelAnswer{Text: validComparison[:len(validComparison)/2]}, "not valid JSON"},
+		{"truncated answer", modelAnswer{Text: validComparison, Truncated: true}, "truncated"},
+		{"not json", modelAnswer{Text: "The documents match."}, "not valid JSON"},
+		{"empty", modelAnswer{}, "not valid JSON"},
+		{"wrong type", modelAnswer{Text: strings.Replace(validComparison, "0.42", `"0.42"`, 1)}, "not valid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseComparison(tt.answer)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseComparison error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseComparison: %v", err)
+			}
+			if !result.IsAuthentic || result.Repaired || result.Confidence != "high" || len(result.Differences) != 1 || len(result.Findings) != 1 {
+				t.Errorf("parseComparison = %+v, want the answer converted", result)
+			}
+		})
+	}
+}
+
+func TestRepairComparison(t *testing.T) {
+	truncated := truncatedComparison()
+
+	result := repairComparison(truncated)
+	if result == nil {
+		t.Fatal("repairComparison could not repair a truncated answer")
+	}
+	if !result.Repaired || result.IsAuthentic || result.Confidence != "low" {
+		t.Errorf("repairComparison = repaired %v, authentic %v, confidence %q; want repaired, not authentic, low",
+			result.Repaired, result.IsAuthentic, result.Confidence)
+	}
+	if result.Score != 0.42 || len(result.Differences) != 1 || len(result.Findings) != 2 || result.Summary == "" {
+		t.Errorf("repairComparison = %+v, want the answered score, differences and findings and a summary", result)
+	}
+	if last := result.Findings[len(result.Findings)-1]; last.Category != "tampering" {
+		t.Errorf("last finding = %+v, want the repair reported", last)
+	}
+
+	for _, text := range []string{
+		`{"is_authentic": true, "confidence": "high"`,            // no score
+		strings.Replace(truncated, "0.42", "4.2", 1),             // invalid score
+		strings.Replace(truncated, `"moderate"`, `"unknown"`, 1), // invalid severity
+		"not json",
+	} {
+		if result := repairComparison(text); result != nil {
+			t.Errorf("repairComparison(%q) = %+v, want nil", text, result)
+		}
+	}
+}
+
+func TestRequestComparison(t *testing.T) {
+	truncated := truncatedComparison()
+	tests := []struct {
+		name          string
+		answers       []string
+		wantRepaired  bool
+		wantAuthentic bool
+		wantErr       bool
+	}{
+		{"valid", []string{validComparison}, false, true, false},
+		{"valid after truncated", []string{truncated, validComparison}, false, true, false},
+		{"repaired", []string{truncated, truncated}, true, false, false},
+		{"repaired after invalid", []string{truncated, "not json"}, true, false, false},
+		{"invalid", []string{"not json", "not json"}, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var maxTokens []int
+			send := func(ctx context.Context, limit int) (modelAnswer, error) {
+				maxTokens = append(maxTokens, limit)
+				text := tt.answers[len(maxTokens)-1]
+				usage := requestUsage(100, 50, 0)
+				return modelAnswer{Text: text, Truncated: text == truncated, Usage: usage}, nil
+			}
+
+			result, err := requestComparison(context.Background(), "test", "1", send)
+			if len(maxTokens) != len(tt.answers) {
+				t.Errorf("requests = %d, want %d", len(maxTokens), len(tt.answers))
+			}
+			if tt.wantErr {
+				var usageErr *UsageError
+				if !errors.Is(err, ErrInvalidResponse) || !errors.As(err, &usageErr) || usageErr.Usage.Requests != len(tt.answers) {
+					t.Fatalf("requestComparison error = %v, want %v with the usage of %d requests", err, ErrInvalidResponse, len(tt.answers))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("requestComparison: %v", err)
+			}
+			if result.Repaired != tt.wantRepaired || result.IsAuthentic != tt.wantAuthentic {
+				t.Errorf("requestComparison = repaired %v, authentic %v; want %v, %v", result.Repaired, result.IsAuthentic, tt.wantRepaired, tt.wantAuthentic)
+			}
+			want := entity.TokenUsage{Requests: len(tt.answers), InputTokens: 100 * len(tt.answers), OutputTokens: 50 * len(tt.answers), TotalTokens: 150 * len(tt.answers)}
+			if result.Usage == nil || *result.Usage != want || result.PromptVersion != "1" {
+				t.Errorf("requestComparison usage = %+v, prompt version %q; want %+v, 1", result.Usage, result.PromptVersion, want)
+			}
+			// A truncated answer is requested again with a larger output limit
+			if tt.answers[0] == truncated && maxTokens[1] != 2*comparisonMaxTokens {
+				t.Errorf("max tokens = %v, want the limit doubled after a truncated answer", maxTokens)
+			}
+		})
+	}
+}
+
+func TestRepairTruncatedJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"complete", `{"a": 1}`, `{"a": 1}`},
+		{"empty", "", "{}"},
+		{"open object", "{", "{}"},
+		{"in a key", `{"a": 1, "su`, `{"a": 1}`},
+		{"after a key", `{"a": 1, "summary"`, `{"a": 1}`},
+		{"after a colon", `{"a": 1, "summary": `, `{"a": 1}`},
+		{"first key", `{"summary": `, `{}`},
+		{"in a string value", `{"a": "b", "c": "unfini`, `{"a": "b"}`},
+		{"in a number", `{"a": "b", "c": 0.4`, `{"a": "b"}`},
+		{"in a literal", `{"a": "b", "c": tr`, `{"a": "b"}`},
+		{"after a comma", `{"a": "b",`, `{"a": "b"}`},
+		{"string in an array", `{"a": ["b", "c"`, `{"a": ["b", "c"]}`},
+		{"in a nested object", `{"a": [{"b": "c", "d"`, `{"a": [{"b": "c"}]}`},
+		{"escaped quote", `{"a": "say \"hi\"", "b`, `{"a": "say \"hi\""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := repairTruncatedJSON(tt.input)
+			if got != tt.want {
+				t.Errorf("repairTruncatedJSON(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("repairTruncatedJSON(%q) = %q, not valid JSON", tt.input, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxDiffCells bounds the LCS table of a page diff; larger pages are compared as one block
+const maxDiffCells = 4 << 20
+
+// lineHunk is a run of lines that differ between an original and a provided page
+type lineHunk struct {
+	origLine int // 0-based index of the first removed line, or of the line the text was added before
+	provLine int // 0-based index of the first added line
+	removed  []string
+	added    []string
+}
+
+// diffLines returns the number of lines both pages share in order and the hunks between them,
+// using a longest common subsequence of lines
+func diffLines(orig, prov []string) (int, []lineHunk) {
+	prefix := 0
+	for prefix < len(orig) && prefix < len(prov) && orig[prefix] == prov[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(orig)-prefix && suffix < len(prov)-prefix && orig[len(orig)-1-suffix] == prov[len(prov)-1-suffix] {
+		suffix++
+	}
+	a, b := orig[prefix:len(orig)-suffix], prov[prefix:len(prov)-suffix]
+	common := prefix + suffix
+	if len(a) == 0 && len(b) == 0 {
+		return common, nil
+	}
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		return common, []lineHunk{{origLine: prefix, provLine: prefix, removed: a, added: b}}
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	width := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	var hunks []lineHunk
+	var current *lineHunk
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			common++
+			current = nil
+			i++
+			j++
+			continue
+		}
+		if current == nil {
+			hunks = append(hunks, lineHunk{origLine: prefix + i, provLine: prefix + j})
+			current = &hunks[len(hunks)-1]
+		}
+		if j == len(b) || (i < len(a) && lcs[(i+1)*width+j] >= lcs[i*width+j+1]) {
+			current.removed = append(current.removed, a[i])
+			i++
+		} else {
+			current.added = append(current.added, b[j])
+			j++
+		}
+	}
+	return common, hunks
+}
+
+// classifyHunk rates a change: formatting only (case, spacing, punctuation) is minor, changed
+// numbers, e.g. dates, amounts or identifiers, are critical and other wording changes moderate
+func classifyHunk(h lineHunk) (severity, description string) {
+	removed, added := strings.Join(h.removed, " "), strings.Join(h.added, " ")
+	switch {
+	case len(h.added) == 0 && digitsOf(removed) != "":
+		return "critical", "Text with numbers removed"
+	case len(h.removed) == 0 && digitsOf(added) != "":
+		return "critical", "Text with numbers added"
+	case len(h.added) == 0:
+		return "moderate", "Text removed"
+	case len(h.removed) == 0:
+		return "moderate", "Text added"
+	case normalizeText(removed) == normalizeText(added):
+		return "minor", "Formatting, spacing or punctuation changed"
+	case digitsOf(removed) != digitsOf(added):
+		return "critical", "Numbers changed"
+	default:
+		return "moderate", "Wording changed"
+	}
+}
+
+// normalizeText keeps only lower-cased letters and digits
+func normalizeText(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return sb.String()
+}
+
+// digitsOf returns the digit sequences of s separated by spaces
+func digitsOf(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsDigit(r) }), " ")
+}
@@ -7,18 +7,24 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
+	"github.com/tasklineby/certify-backend/analyzer"
 	"github.com/tasklineby/certify-backend/config"
 	"github.com/tasklineby/certify-backend/db"
+	"github.com/tasklineby/certify-backend/imaging"
+	"github.com/tasklineby/certify-backend/repository/blob"
 	"github.com/tasklineby/certify-backend/repository/pg"
 	"github.com/tasklineby/certify-backend/repository/rdb"
+	"github.com/tasklineby/certify-backend/scanner"
 	"github.com/tasklineby/certify-backend/service"
 	"github.com/tasklineby/certify-backend/transport/rest/handlers"
+	"github.com/tasklineby/certify-backend/transport/rest/middleware"
 
 	_ "github.com/tasklineby/certify-backend/docs" // swagger docs
 )
@@ -70,31 +76,119 @@ func main() {
 		os.Exit(1)
 	}
 
+	blobStore, err := blob.NewBlobStore(cfg.Storage, blob.NamespaceDocuments)
+	if err != nil {
+		slog.Error("Failed to initialize blob store", "error", err)
+		os.Exit(1)
+	}
+
+	evidenceStore, err := blob.NewBlobStore(cfg.Storage, blob.NamespaceEvidence)
+	if err != nil {
+		slog.Error("Failed to initialize evidence store", "error", err)
+		os.Exit(1)
+	}
+
+	jobStore, err := blob.NewBlobStore(cfg.Storage, blob.NamespaceJobs)
+	if err != nil {
+		slog.Error("Failed to initialize job store", "error", err)
+		os.Exit(1)
+	}
+
+	fileScanner, err := scanner.NewFileScanner(cfg.Scanner)
+	if err != nil {
+		slog.Error("Failed to initialize file scanner", "error", err)
+		os.Exit(1)
+	}
+
+	documentAnalyzer, err := analyzer.NewDocumentAnalyzer(cfg.Analyzer, cfg.Gemini)
+	if err != nil {
+		slog.Error("Failed to initialize document analyzer", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Document analyzer initialized", "provider", documentAnalyzer.Name())
+
 	userRepo := pg.NewUserRepository(dbConn)
 	companyRepo := pg.NewCompanyRepository(dbConn)
 	documentRepo := pg.NewDocumentRepository(dbConn)
+	versionRepo := pg.NewDocumentVersionRepository(dbConn)
 	historyRepo := pg.NewHistoryRepository(dbConn)
+	analysisRepo := pg.NewAnalysisRepository(dbConn)
+	fieldRepo := pg.NewDocumentFieldRepository(dbConn)
+	evidenceRepo := pg.NewEvidenceRepository(dbConn)
+	jobRepo := pg.NewComparisonJobRepository(dbConn)
+	promptRepo := pg.NewPromptTemplateRepository(dbConn)
+	usageRepo := pg.NewUsageRepository(dbConn)
 	tokenRepo := rdb.NewTokenRepository(redisClient)
+	sessionRepo := rdb.NewSessionRepository(redisClient)
+	rateLimitRepo := rdb.NewRateLimitRepository(redisClient)
+	jobQueue := rdb.NewJobQueueRepository(redisClient, "comparison")
+	analysisCache := rdb.NewAnalysisCacheRepository(redisClient)
 
 	jwtService := service.NewJwtService(
 		cfg.Jwt.AccessTokenSecret,
 		cfg.Jwt.AccessTokenTTL*time.Minute,
 		cfg.Jwt.RefreshTokenTTL*time.Hour,
 	)
-	userService := service.NewUserService(userRepo, companyRepo)
-	authService := service.NewAuthService(userService, tokenRepo, jwtService)
-	documentService := service.NewDocumentService(documentRepo, historyRepo, cfg.Gemini.APIKey, cfg.Gemini.Model)
+	hashKeys, err := cfg.Hash.GetKeys()
+	if err != nil {
+		slog.Error("Invalid document hash keys", "error", err)
+		os.Exit(1)
+	}
+	hashSigner, err := service.NewDocumentHashSigner(hashKeys, cfg.Hash.ActiveKeyID, cfg.Hash.AllowLegacy)
+	if err != nil {
+		slog.Error("Failed to initialize document hash signer", "error", err)
+		os.Exit(1)
+	}
 
-	userHandler := handlers.NewUserHandler(userService, jwtService, tokenRepo)
-	authHandler := handlers.NewAuthHandler(authService)
+	sessionService := service.NewSessionService(sessionRepo, tokenRepo, jwtService, cfg.Jwt.AccessTokenTTL*time.Minute)
+	userService := service.NewUserService(userRepo, companyRepo, sessionService)
+	authService := service.NewAuthService(userService, sessionService, tokenRepo, jwtService)
+	uploadValidator := service.NewUploadValidator(cfg.Upload.GetMaxFileSize(), cfg.Upload.AllowImages)
+	photoPreprocessor := service.NewPhotoPreprocessor(imaging.Options{
+		MaxDimension:  cfg.Photo.MaxDimension,
+		MinBrightness: cfg.Photo.MinBrightness,
+		MinSharpness:  cfg.Photo.MinSharpness,
+	})
+	evidenceService := service.NewEvidenceService(evidenceRepo, companyRepo, evidenceStore, cfg.Evidence.GetRetention())
+	promptService := service.NewPromptService(promptRepo)
+	usageService := service.NewUsageService(usageRepo, companyRepo, cfg.Usage.MonthlyTokenQuota, service.UsagePrices{
+		InputPerMillion:  cfg.Usage.InputPricePerMillion,
+		OutputPerMillion: cfg.Usage.OutputPricePerMillion,
+	})
+	documentService := service.NewDocumentService(documentRepo, versionRepo, historyRepo, analysisRepo, fieldRepo, companyRepo, blobStore, uploadValidator, fileScanner, hashSigner, documentAnalyzer, promptService, evidenceService, usageService, analysisCache, cfg.Analyzer.GetCacheTTL(), cfg.Server.PublicVerifyURL)
+	jobService := service.NewComparisonJobService(jobRepo, jobQueue, jobStore, documentService, photoPreprocessor, usageService, cfg.Jobs.Workers, cfg.Jobs.MaxAttempts, cfg.Jobs.GetTimeout(), cfg.Jobs.WebhookSecret)
+
+	userHandler := handlers.NewUserHandler(userService)
+	authHandler := handlers.NewAuthHandler(authService, sessionService)
 	documentHandler := handlers.NewDocumentHandler(documentService)
+	jobHandler := handlers.NewJobHandler(jobService)
+	evidenceHandler := handlers.NewEvidenceHandler(evidenceService)
+	promptHandler := handlers.NewPromptHandler(promptService)
+	usageHandler := handlers.NewUsageHandler(usageService)
+
+	publicVerifyLimiter := middleware.RateLimitMiddleware(rateLimitRepo, "public_verify", cfg.Server.PublicVerifyRateLimit, time.Minute)
+
+	// Leave room for the other form fields next to the file
+	uploadSizeLimit := middleware.BodySizeLimitMiddleware(cfg.Upload.GetMaxFileSize() + 1<<20)
 
-	router := handlers.InitRoutes(userHandler, authHandler, documentHandler, authService)
+	router := handlers.InitRoutes(userHandler, authHandler, documentHandler, jobHandler, evidenceHandler, promptHandler, usageHandler, authService, publicVerifyLimiter, uploadSizeLimit)
 	server := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
 		Handler: router,
 	}
 
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		jobService.Run(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		evidenceService.Run(workersCtx, cfg.Evidence.GetPurgeInterval())
+	}()
+
 	errCh := make(chan error, 1)
 	go func() {
 		slog.Info("Server start up", "port", cfg.Server.Port)
@@ -102,10 +196,10 @@ func main() {
 			errCh <- fmt.Errorf("server err: %w", err)
 		}
 	}()
-	shutdownApp(server, dbConn, redisClient, errCh)
+	shutdownApp(server, stopWorkers, &workers, dbConn, redisClient, errCh)
 }
 
-func shutdownApp(server *http.Server, db *sqlx.DB, redisClient *redis.Client, serverErrCh <-chan error) {
+func shutdownApp(server *http.Server, stopWorkers context.CancelFunc, workers *sync.WaitGroup, db *sqlx.DB, redisClient *redis.Client, serverErrCh <-chan error) {
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
@@ -126,6 +220,20 @@ func shutdownApp(server *http.Server, db *sqlx.DB, redisClient *redis.Client, se
 		}
 	}
 
+	// Jobs in progress need Postgres and Redis to record their results
+	slog.Info("Stopping background workers...")
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		slog.Error("Background workers did not stop in time")
+	}
+
 	if db != nil {
 		slog.Info("Disconnecting from Postgres...")
 		err := db.Close()
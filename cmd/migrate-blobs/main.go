@@ -0,0 +1,92 @@
+// Command migrate-blobs moves document files stored in Postgres BYTEA columns to the configured blob store.
+// It is safe to interrupt and run again: rows are switched to their blob key one at a time.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tasklineby/certify-backend/config"
+	"github.com/tasklineby/certify-backend/db"
+	"github.com/tasklineby/certify-backend/repository/blob"
+	"github.com/tasklineby/certify-backend/repository/pg"
+)
+
+func main() {
+	configPath := flag.String("config", "/app/.env", "path to the configuration file")
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("Error loading config", "error", err)
+		os.Exit(1)
+	}
+
+	dbConn, err := db.InitPostgresDB(cfg.Database.GetDSN())
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer dbConn.Close()
+
+	blobStore, err := blob.NewBlobStore(cfg.Storage, blob.NamespaceDocuments)
+	if err != nil {
+		slog.Error("Failed to initialize blob store", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	repo := pg.NewFileMigrationRepository(dbConn)
+	for _, table := range []pg.FileTable{pg.FileTableDocuments, pg.FileTableDocumentVersions} {
+		moved, err := migrateTable(ctx, repo, blobStore, table)
+		if err != nil {
+			slog.Error("Blob migration failed", "table", table, "moved", moved, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Blob migration finished", "table", table, "moved", moved, "backend", cfg.Storage.Backend)
+	}
+}
+
+func migrateTable(ctx context.Context, repo pg.FileMigrationRepository, blobStore blob.BlobStore, table pg.FileTable) (int, error) {
+	ids, err := repo.GetLegacyFileIDs(ctx, table)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return moved, err
+		}
+
+		data, err := repo.GetLegacyFileData(ctx, table, id)
+		if err != nil {
+			return moved, fmt.Errorf("reading %s %d: %w", table, id, err)
+		}
+
+		info, err := blobStore.Put(ctx, bytes.NewReader(data))
+		if err != nil {
+			return moved, fmt.Errorf("storing %s %d: %w", table, id, err)
+		}
+		if info.Size != int64(len(data)) {
+			return moved, fmt.Errorf("storing %s %d: stored %d of %d bytes", table, id, info.Size, len(data))
+		}
+
+		if err := repo.SetFileKey(ctx, table, id, info.Key); err != nil {
+			return moved, fmt.Errorf("updating %s %d: %w", table, id, err)
+		}
+		moved++
+		slog.Info("Moved file to blob store", "table", table, "id", id, "key", info.Key, "size", info.Size)
+	}
+	return moved, nil
+}
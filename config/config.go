@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -13,16 +14,96 @@ type Config struct {
 	Redis    RedisConfig
 	Jwt      JwtConfig
 	Gemini   GeminiConfig
+	Analyzer AnalyzerConfig
+	Hash     DocumentHashConfig
+	Storage  StorageConfig
+	Upload   UploadConfig
+	Photo    PhotoConfig
+	Scanner  ScannerConfig
+	Jobs     JobsConfig
+	Evidence EvidenceConfig
+	Usage    UsageConfig
 }
 
 type GeminiConfig struct {
-	APIKey string `mapstructure:"GEMINI_API_KEY"`
-	Model  string `mapstructure:"GEMINI_MODEL"`
+	APIKey  string `mapstructure:"GEMINI_API_KEY"`
+	Model   string `mapstructure:"GEMINI_MODEL"`
+	BaseURL string `mapstructure:"GEMINI_BASE_URL"`
+}
+
+type AnalyzerConfig struct {
+	Provider      string `mapstructure:"ANALYZER_PROVIDER"` // gemini, openai or local
+	OpenAIBaseURL string `mapstructure:"OPENAI_BASE_URL"`   // any OpenAI-compatible server, e.g. http://vllm:8000/v1
+	OpenAIAPIKey  string `mapstructure:"OPENAI_API_KEY"`
+	OpenAIModel   string `mapstructure:"OPENAI_MODEL"`
+	LocalFirst    bool   `mapstructure:"ANALYZER_LOCAL_FIRST"` // compare PDFs locally before calling the AI provider
+
+	TimeoutSeconds         int `mapstructure:"ANALYZER_TIMEOUT_SECONDS"` // per request to the AI provider
+	MaxRetries             int `mapstructure:"ANALYZER_MAX_RETRIES"`     // for 429, 5xx and network errors, -1 disables retries
+	RateLimit              int `mapstructure:"ANALYZER_RATE_LIMIT"`      // requests per minute, -1 disables the limit
+	RateLimitBurst         int `mapstructure:"ANALYZER_RATE_LIMIT_BURST"`
+	BreakerThreshold       int `mapstructure:"ANALYZER_BREAKER_THRESHOLD"` // consecutive failures that stop requests, -1 disables the breaker
+	BreakerCooldownSeconds int `mapstructure:"ANALYZER_BREAKER_COOLDOWN_SECONDS"`
+	CacheTTLMinutes        int `mapstructure:"ANALYZER_CACHE_TTL_MINUTES"` // how long comparison results are reused, -1 disables the cache
+}
+
+type DocumentHashConfig struct {
+	SigningKeys string `mapstructure:"DOCUMENT_HASH_KEYS"` // comma separated "key_id:secret" pairs
+	ActiveKeyID string `mapstructure:"DOCUMENT_HASH_ACTIVE_KEY_ID"`
+	AllowLegacy bool   `mapstructure:"DOCUMENT_HASH_ALLOW_LEGACY"`
+}
+
+type StorageConfig struct {
+	Backend     string `mapstructure:"STORAGE_BACKEND"`    // local or s3
+	LocalPath   string `mapstructure:"STORAGE_LOCAL_PATH"` // root directory of the local backend
+	S3Endpoint  string `mapstructure:"S3_ENDPOINT"`
+	S3Region    string `mapstructure:"S3_REGION"`
+	S3Bucket    string `mapstructure:"S3_BUCKET"`
+	S3AccessKey string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey string `mapstructure:"S3_SECRET_KEY"`
+	S3PathStyle bool   `mapstructure:"S3_PATH_STYLE"` // required by MinIO and most S3-compatible servers
+}
+
+type UploadConfig struct {
+	MaxFileSizeMB int  `mapstructure:"UPLOAD_MAX_FILE_SIZE_MB"`
+	AllowImages   bool `mapstructure:"UPLOAD_ALLOW_IMAGES"` // accept PNG and JPEG scans besides PDF
+}
+
+type PhotoConfig struct {
+	MaxDimension  int     `mapstructure:"PHOTO_MAX_DIMENSION"`  // longest side in pixels photos are scaled down to before comparison
+	MinBrightness float64 `mapstructure:"PHOTO_MIN_BRIGHTNESS"` // mean luminance 0-255, darker photos are rejected, -1 disables the check
+	MinSharpness  float64 `mapstructure:"PHOTO_MIN_SHARPNESS"`  // variance of the Laplacian, blurrier photos are rejected, -1 disables the check
+}
+
+type ScannerConfig struct {
+	Backend             string `mapstructure:"SCANNER_BACKEND"` // none or clamav
+	ClamdAddress        string `mapstructure:"CLAMD_ADDRESS"`   // tcp://host:port or unix:///path/to/clamd.sock
+	ClamdTimeoutSeconds int    `mapstructure:"CLAMD_TIMEOUT_SECONDS"`
+}
+
+type JobsConfig struct {
+	Workers        int    `mapstructure:"JOBS_WORKERS"`
+	MaxAttempts    int    `mapstructure:"JOBS_MAX_ATTEMPTS"`
+	TimeoutSeconds int    `mapstructure:"JOBS_TIMEOUT_SECONDS"` // per attempt
+	WebhookSecret  string `mapstructure:"JOBS_WEBHOOK_SECRET"`  // signs webhook payloads with HMAC-SHA256
+}
+
+type EvidenceConfig struct {
+	RetentionDays        int `mapstructure:"EVIDENCE_RETENTION_DAYS"`
+	PurgeIntervalMinutes int `mapstructure:"EVIDENCE_PURGE_INTERVAL_MINUTES"`
+}
+
+type UsageConfig struct {
+	MonthlyTokenQuota     int64   `mapstructure:"USAGE_MONTHLY_TOKEN_QUOTA"`      // AI tokens per company and month unless set for the company, 0 for no limit
+	InputPricePerMillion  float64 `mapstructure:"USAGE_INPUT_PRICE_PER_MILLION"`  // provider price of a million input tokens, used to record costs
+	OutputPricePerMillion float64 `mapstructure:"USAGE_OUTPUT_PRICE_PER_MILLION"` // provider price of a million output tokens
 }
 
 type ServerConfig struct {
-	Host string `mapstructure:"SERVER_HOST"`
-	Port int    `mapstructure:"SERVER_PORT"`
+	Host                  string `mapstructure:"SERVER_HOST"`
+	Port                  int    `mapstructure:"SERVER_PORT"`
+	PublicVerifyRateLimit int    `mapstructure:"PUBLIC_VERIFY_RATE_LIMIT"` // requests per minute per IP
+	PublicVerifyURL       string `mapstructure:"PUBLIC_VERIFY_URL"`        // encoded into QR codes, e.g. https://certify.example/verify
 }
 
 type DatabaseConfig struct {
@@ -57,8 +138,10 @@ func LoadConfig(path string) (*Config, error) {
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Host: viper.GetString("SERVER_HOST"),
-			Port: viper.GetInt("SERVER_PORT"),
+			Host:                  viper.GetString("SERVER_HOST"),
+			Port:                  viper.GetInt("SERVER_PORT"),
+			PublicVerifyRateLimit: viper.GetInt("PUBLIC_VERIFY_RATE_LIMIT"),
+			PublicVerifyURL:       viper.GetString("PUBLIC_VERIFY_URL"),
 		},
 		Database: DatabaseConfig{
 			PostgresHost:     viper.GetString("POSTGRES_HOST"),
@@ -79,8 +162,68 @@ func LoadConfig(path string) (*Config, error) {
 			RefreshTokenTTL:   viper.GetDuration("REFRESH_TOKEN_TTL_HOURS"),
 		},
 		Gemini: GeminiConfig{
-			APIKey: viper.GetString("GEMINI_API_KEY"),
-			Model:  viper.GetString("GEMINI_MODEL"),
+			APIKey:  viper.GetString("GEMINI_API_KEY"),
+			Model:   viper.GetString("GEMINI_MODEL"),
+			BaseURL: viper.GetString("GEMINI_BASE_URL"),
+		},
+		Analyzer: AnalyzerConfig{
+			Provider:      viper.GetString("ANALYZER_PROVIDER"),
+			OpenAIBaseURL: viper.GetString("OPENAI_BASE_URL"),
+			OpenAIAPIKey:  viper.GetString("OPENAI_API_KEY"),
+			OpenAIModel:   viper.GetString("OPENAI_MODEL"),
+			LocalFirst:    viper.GetBool("ANALYZER_LOCAL_FIRST"),
+
+			TimeoutSeconds:         viper.GetInt("ANALYZER_TIMEOUT_SECONDS"),
+			MaxRetries:             viper.GetInt("ANALYZER_MAX_RETRIES"),
+			RateLimit:              viper.GetInt("ANALYZER_RATE_LIMIT"),
+			RateLimitBurst:         viper.GetInt("ANALYZER_RATE_LIMIT_BURST"),
+			BreakerThreshold:       viper.GetInt("ANALYZER_BREAKER_THRESHOLD"),
+			BreakerCooldownSeconds: viper.GetInt("ANALYZER_BREAKER_COOLDOWN_SECONDS"),
+			CacheTTLMinutes:        viper.GetInt("ANALYZER_CACHE_TTL_MINUTES"),
+		},
+		Hash: DocumentHashConfig{
+			SigningKeys: viper.GetString("DOCUMENT_HASH_KEYS"),
+			ActiveKeyID: viper.GetString("DOCUMENT_HASH_ACTIVE_KEY_ID"),
+			AllowLegacy: viper.GetBool("DOCUMENT_HASH_ALLOW_LEGACY"),
+		},
+		Storage: StorageConfig{
+			Backend:     viper.GetString("STORAGE_BACKEND"),
+			LocalPath:   viper.GetString("STORAGE_LOCAL_PATH"),
+			S3Endpoint:  viper.GetString("S3_ENDPOINT"),
+			S3Region:    viper.GetString("S3_REGION"),
+			S3Bucket:    viper.GetString("S3_BUCKET"),
+			S3AccessKey: viper.GetString("S3_ACCESS_KEY"),
+			S3SecretKey: viper.GetString("S3_SECRET_KEY"),
+			S3PathStyle: viper.GetBool("S3_PATH_STYLE"),
+		},
+		Upload: UploadConfig{
+			MaxFileSizeMB: viper.GetInt("UPLOAD_MAX_FILE_SIZE_MB"),
+			AllowImages:   viper.GetBool("UPLOAD_ALLOW_IMAGES"),
+		},
+		Photo: PhotoConfig{
+			MaxDimension:  viper.GetInt("PHOTO_MAX_DIMENSION"),
+			MinBrightness: viper.GetFloat64("PHOTO_MIN_BRIGHTNESS"),
+			MinSharpness:  viper.GetFloat64("PHOTO_MIN_SHARPNESS"),
+		},
+		Scanner: ScannerConfig{
+			Backend:             viper.GetString("SCANNER_BACKEND"),
+			ClamdAddress:        viper.GetString("CLAMD_ADDRESS"),
+			ClamdTimeoutSeconds: viper.GetInt("CLAMD_TIMEOUT_SECONDS"),
+		},
+		Jobs: JobsConfig{
+			Workers:        viper.GetInt("JOBS_WORKERS"),
+			MaxAttempts:    viper.GetInt("JOBS_MAX_ATTEMPTS"),
+			TimeoutSeconds: viper.GetInt("JOBS_TIMEOUT_SECONDS"),
+			WebhookSecret:  viper.GetString("JOBS_WEBHOOK_SECRET"),
+		},
+		Evidence: EvidenceConfig{
+			RetentionDays:        viper.GetInt("EVIDENCE_RETENTION_DAYS"),
+			PurgeIntervalMinutes: viper.GetInt("EVIDENCE_PURGE_INTERVAL_MINUTES"),
+		},
+		Usage: UsageConfig{
+			MonthlyTokenQuota:     viper.GetInt64("USAGE_MONTHLY_TOKEN_QUOTA"),
+			InputPricePerMillion:  viper.GetFloat64("USAGE_INPUT_PRICE_PER_MILLION"),
+			OutputPricePerMillion: viper.GetFloat64("USAGE_OUTPUT_PRICE_PER_MILLION"),
 		},
 	}
 
@@ -89,6 +232,97 @@ func LoadConfig(path string) (*Config, error) {
 		cfg.Gemini.Model = "gemini-1.5-flash"
 	}
 
+	// Keep using Gemini when only its API key is configured
+	if cfg.Analyzer.Provider == "" {
+		cfg.Analyzer.Provider = "local"
+		if cfg.Gemini.APIKey != "" {
+			cfg.Analyzer.Provider = "gemini"
+		}
+	}
+	if cfg.Analyzer.OpenAIBaseURL == "" {
+		cfg.Analyzer.OpenAIBaseURL = "https://api.openai.com/v1"
+	}
+	if cfg.Gemini.BaseURL == "" {
+		cfg.Gemini.BaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+	if cfg.Analyzer.TimeoutSeconds <= 0 {
+		cfg.Analyzer.TimeoutSeconds = 60
+	}
+	if cfg.Analyzer.MaxRetries == 0 {
+		cfg.Analyzer.MaxRetries = 3
+	}
+	if cfg.Analyzer.RateLimit == 0 {
+		cfg.Analyzer.RateLimit = 60
+	}
+	if cfg.Analyzer.RateLimitBurst <= 0 {
+		cfg.Analyzer.RateLimitBurst = 5
+	}
+	if cfg.Analyzer.BreakerThreshold == 0 {
+		cfg.Analyzer.BreakerThreshold = 5
+	}
+	if cfg.Analyzer.BreakerCooldownSeconds <= 0 {
+		cfg.Analyzer.BreakerCooldownSeconds = 30
+	}
+	if cfg.Analyzer.CacheTTLMinutes == 0 {
+		cfg.Analyzer.CacheTTLMinutes = 24 * 60
+	}
+
+	if cfg.Storage.Backend == "" {
+		cfg.Storage.Backend = "local"
+	}
+	if cfg.Storage.LocalPath == "" {
+		cfg.Storage.LocalPath = "data/blobs"
+	}
+
+	if cfg.Upload.MaxFileSizeMB <= 0 {
+		cfg.Upload.MaxFileSizeMB = 20
+	}
+
+	if cfg.Photo.MaxDimension <= 0 {
+		cfg.Photo.MaxDimension = 2048
+	}
+	if cfg.Photo.MinBrightness == 0 {
+		cfg.Photo.MinBrightness = 40
+	}
+	if cfg.Photo.MinSharpness == 0 {
+		cfg.Photo.MinSharpness = 25
+	}
+
+	if cfg.Scanner.Backend == "" {
+		cfg.Scanner.Backend = "none"
+	}
+	if cfg.Scanner.ClamdTimeoutSeconds <= 0 {
+		cfg.Scanner.ClamdTimeoutSeconds = 30
+	}
+
+	if cfg.Jobs.Workers <= 0 {
+		cfg.Jobs.Workers = 4
+	}
+	if cfg.Jobs.MaxAttempts <= 0 {
+		cfg.Jobs.MaxAttempts = 3
+	}
+	if cfg.Jobs.TimeoutSeconds <= 0 {
+		cfg.Jobs.TimeoutSeconds = 120
+	}
+
+	if cfg.Evidence.RetentionDays <= 0 {
+		cfg.Evidence.RetentionDays = 90
+	}
+	if cfg.Evidence.PurgeIntervalMinutes <= 0 {
+		cfg.Evidence.PurgeIntervalMinutes = 60
+	}
+
+	if cfg.Server.PublicVerifyRateLimit <= 0 {
+		cfg.Server.PublicVerifyRateLimit = 30
+	}
+
+	// Sign with the first configured key if no active key is specified
+	if cfg.Hash.ActiveKeyID == "" {
+		if kid, _, found := strings.Cut(strings.Split(cfg.Hash.SigningKeys, ",")[0], ":"); found {
+			cfg.Hash.ActiveKeyID = strings.TrimSpace(kid)
+		}
+	}
+
 	return cfg, nil
 }
 
@@ -98,6 +332,54 @@ func (c *DatabaseConfig) GetDSN() string {
 	return result
 }
 
+// GetTimeout returns the time limit of one job attempt
+func (c *JobsConfig) GetTimeout() time.Duration {
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// GetCacheTTL returns how long comparison results are cached, 0 when the cache is disabled
+func (c *AnalyzerConfig) GetCacheTTL() time.Duration {
+	if c.CacheTTLMinutes < 0 {
+		return 0
+	}
+	return time.Duration(c.CacheTTLMinutes) * time.Minute
+}
+
+// GetRetention returns how long submitted evidence is kept
+func (c *EvidenceConfig) GetRetention() time.Duration {
+	return time.Duration(c.RetentionDays) * 24 * time.Hour
+}
+
+// GetPurgeInterval returns how often expired evidence is purged
+func (c *EvidenceConfig) GetPurgeInterval() time.Duration {
+	return time.Duration(c.PurgeIntervalMinutes) * time.Minute
+}
+
+// GetMaxFileSize returns the upload size limit in bytes
+func (c *UploadConfig) GetMaxFileSize() int64 {
+	return int64(c.MaxFileSizeMB) << 20
+}
+
 func (c *RedisConfig) GetAddr() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
+
+// GetKeys parses the configured signing keys into a key ID to secret map
+func (c *DocumentHashConfig) GetKeys() (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, pair := range strings.Split(c.SigningKeys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kid, secret, found := strings.Cut(pair, ":")
+		if !found || kid == "" || secret == "" {
+			return nil, fmt.Errorf("invalid DOCUMENT_HASH_KEYS entry, expected key_id:secret")
+		}
+		if _, exists := keys[kid]; exists {
+			return nil, fmt.Errorf("duplicate document hash key id %q", kid)
+		}
+		keys[kid] = []byte(secret)
+	}
+	return keys, nil
+}
@@ -26,7 +26,7 @@ const docTemplate = `{
     "paths": {
         "/auth/login": {
             "post": {
-                "description": "Authenticate user with email and password, returns access and refresh tokens",
+                "description": "Authenticate user with email and password, returns access and refresh tokens of a new session. An optional device name identifies the session.",
                 "consumes": [
                     "application/json"
                 ],
@@ -77,7 +77,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Invalidate access and refresh tokens",
+                "description": "Invalidate access and refresh tokens and end the session",
                 "consumes": [
                     "application/json"
                 ],
@@ -126,7 +126,7 @@ const docTemplate = `{
         },
         "/auth/refresh": {
             "post": {
-                "description": "Exchange refresh token for new access and refresh token pair",
+                "description": "Exchange refresh token for new access and refresh token pair of the same session. Each refresh token can be used once: an expired or unknown token fails with TOKEN_EXPIRED, while reusing a token that was already exchanged ends the whole session and fails with TOKEN_REUSED.",
                 "consumes": [
                     "application/json"
                 ],
@@ -162,7 +162,7 @@ const docTemplate = `{
                         }
                     },
                     "401": {
-                        "description": "Invalid or expired refresh token",
+                        "description": "Expired (TOKEN_EXPIRED) or reused (TOKEN_REUSED) refresh token",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -222,6 +222,118 @@ const docTemplate = `{
                 }
             }
         },
+        "/auth/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the active sessions of the authenticated user, most recently used first. The session of the request is marked current.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "List sessions",
+                "responses": {
+                    "200": {
+                        "description": "Active sessions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.SessionInfo"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "End all sessions of the authenticated user, including the current one, and revoke their access tokens",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Log out everywhere",
+                "responses": {
+                    "200": {
+                        "description": "Sessions ended",
+                        "schema": {
+                            "$ref": "#/definitions/entity.SessionRevocationResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/sessions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "End a session of the authenticated user and revoke its access tokens, e.g. of a lost device",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Revoke session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Session ended",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Session not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
         "/documents": {
             "get": {
                 "security": [
@@ -267,7 +379,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Create a new document for the authenticated user's company with PDF file attachment",
+                "description": "Create a new document for the authenticated user's company with PDF file attachment. The file type is detected from its content; encrypted or malformed PDFs and files over the size limit are rejected, and PNG or JPEG scans are accepted if enabled. Uploading a file that another document of the company already has is rejected. Details left out are extracted from the file together with its fields (parties, dates, identifiers); reviewed fields, e.g. from /documents/extract, are stored instead of extracted ones.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -281,31 +393,45 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Document type",
+                        "description": "Document type, extracted if empty",
                         "name": "type",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "Document name",
+                        "description": "Document name, extracted if empty",
                         "name": "name",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "Document summary",
+                        "description": "Document summary, extracted if empty",
                         "name": "summary",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "Expiration date (RFC3339 format)",
+                        "description": "Expiration date (RFC3339 format), extracted if empty",
                         "name": "expiration_date",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Allow unauthenticated third parties to verify the document",
+                        "name": "public_verification",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reviewed fields as a JSON array of {name, kind, value}",
+                        "name": "fields",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Extract and store fields even if every detail is given",
+                        "name": "extract_fields",
+                        "in": "formData"
                     },
                     {
                         "type": "file",
@@ -323,7 +449,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "Invalid request, rejected file or details that could not be extracted",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -334,11 +460,41 @@ const docTemplate = `{
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
+                    "402": {
+                        "description": "Monthly AI usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Company already has a document with the same file",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "422": {
+                        "description": "File rejected by the malware scanner",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Extraction is rate limited",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
+                    },
+                    "503": {
+                        "description": "Extraction is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
                     }
                 }
             }
@@ -350,7 +506,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Compare a document with an uploaded PDF file. Sends both documents to analysis server.",
+                "description": "Verify a document and queue its comparison with an uploaded PDF file. The file is scanned for malware before it is stored. Poll the returned job at /jobs/{id} or pass a webhook_url to be notified when it finished. Comparing the same file with the same document version again returns the cached result, flagged in analysis.cache.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -375,13 +531,25 @@ const docTemplate = `{
                         "name": "file",
                         "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Public http or https URL notified with the finished job",
+                        "name": "webhook_url",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Comparison result",
+                    "202": {
+                        "description": "Comparison job queued",
                         "schema": {
-                            "$ref": "#/definitions/entity.CompareDocumentResponse"
+                            "$ref": "#/definitions/entity.ComparisonJobResponse"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the job"
+                            }
                         }
                     },
                     "400": {
@@ -396,8 +564,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
-                    "404": {
-                        "description": "Document not found",
+                    "402": {
+                        "description": "Monthly AI usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "422": {
+                        "description": "File rejected by the malware scanner",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -418,7 +592,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Compare a document with uploaded photos. Sends original document and photos to analysis server.",
+                "description": "Verify a document and queue its comparison with uploaded photos. The photos are scanned for malware before they are stored. Poll the returned job at /jobs/{id} or pass a webhook_url to be notified when it finished. Photos (JPEG, PNG, GIF or WEBP) are turned upright according to their EXIF orientation, scaled down and stripped of their metadata, including GPS coordinates, before they are stored or sent for analysis. HEIC photos and photos that are too dark or blurry are rejected with a message asking to retake them.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -439,21 +613,106 @@ const docTemplate = `{
                     },
                     {
                         "type": "file",
-                        "description": "Photos of the document (multiple files allowed)",
+                        "description": "Photos of the document (up to 20 files, together no larger than the upload size limit)",
                         "name": "photos",
                         "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page of the document each photo shows, in the order of the photos, e.g. 1,2,2,3. Compares the document page by page and reports missing and extra pages.",
+                        "name": "pages",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Public http or https URL notified with the finished job",
+                        "name": "webhook_url",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Comparison job queued",
+                        "schema": {
+                            "$ref": "#/definitions/entity.ComparisonJobResponse"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request, too many or too large photos or a photo that has to be retaken",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "402": {
+                        "description": "Monthly AI usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "422": {
+                        "description": "File rejected by the malware scanner",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/extract": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Propose the type, name, summary, expiration date and fields (parties, dates, identifiers) of a file for review before creating the document. The file is validated and scanned like an uploaded document but not stored.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Extract document details",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Comparison result",
+                        "description": "Proposed details",
                         "schema": {
-                            "$ref": "#/definitions/entity.CompareDocumentResponse"
+                            "$ref": "#/definitions/entity.DocumentExtraction"
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "Invalid request or rejected file",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -464,8 +723,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
-                    "404": {
-                        "description": "Document not found",
+                    "402": {
+                        "description": "Monthly AI usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "422": {
+                        "description": "File rejected by the malware scanner",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Extraction is rate limited",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -475,6 +746,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
+                    },
+                    "503": {
+                        "description": "Extraction is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
                     }
                 }
             }
@@ -486,7 +763,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Verify a document using its hash from query parameter and get full details with expiration status. Only employees from the same company can verify. Each verification is recorded in history.",
+                "description": "Verify a document using its signed hash from query parameter and get full details with expiration status. The hash signature is validated before any lookup. Only employees from the same company can verify. Each verification is recorded in history.",
                 "produces": [
                     "application/json"
                 ],
@@ -497,7 +774,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Document hash",
+                        "description": "Signed document hash",
                         "name": "hash",
                         "in": "query",
                         "required": true
@@ -587,23 +864,21 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/documents/{id}/file": {
-            "get": {
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Download the PDF file attached to a document. Only employees from the same company can access.",
+                "description": "Delete a document. Only admins can delete documents from the same company. Verification history and document events are kept, and verification reports the document as not found.",
                 "produces": [
-                    "application/pdf"
+                    "application/json"
                 ],
                 "tags": [
                     "documents"
                 ],
-                "summary": "Download document file",
+                "summary": "Delete a document",
                 "parameters": [
                     {
                         "type": "integer",
@@ -615,9 +890,12 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "PDF file",
+                        "description": "Document deleted",
                         "schema": {
-                            "type": "file"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "400": {
@@ -627,13 +905,13 @@ const docTemplate = `{
                         }
                     },
                     "401": {
-                        "description": "Unauthorized",
+                        "description": "Unauthorized - only admins can delete documents",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
                     "404": {
-                        "description": "Document or file not found",
+                        "description": "Document not found",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -645,31 +923,53 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/history": {
-            "get": {
+            },
+            "patch": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get the authenticated user's document verification history",
+                "description": "Correct the type, name, summary or expiration date of an active document. Only employees from the same company can update. Changes are recorded in the document events.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "documents"
                 ],
-                "summary": "Get verification history",
-                "responses": {
-                    "200": {
-                        "description": "Verification history",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/entity.VerificationHistory"
-                            }
+                "summary": "Update document details",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Document update data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.UpdateDocumentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated document",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Document"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or document is revoked or superseded",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
                         }
                     },
                     "401": {
@@ -678,6 +978,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -687,86 +993,57 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/company": {
-            "get": {
+        "/documents/{id}/analysis-cache": {
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get all users from the authenticated user's company",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Delete the cached comparison results of a document, so that the next comparison of the same evidence asks the analyzer again. Results are cached per document version, evidence, prompt and model. Only admins can invalidate the cache of documents from the same company.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "documents"
+                ],
+                "summary": "Invalidate cached comparisons",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get users by company",
                 "responses": {
                     "200": {
-                        "description": "List of users",
+                        "description": "Number of cached results deleted",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/entity.User"
-                            }
+                            "$ref": "#/definitions/entity.AnalysisCacheInvalidationResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Invalid document ID",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
-                    "404": {
-                        "description": "Company not found",
+                    "401": {
+                        "description": "Unauthorized - only admins can invalidate the cache",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
-                    }
-                }
-            },
-            "post": {
-                "description": "Create a new company and register its admin user. Returns access and refresh tokens for the admin.",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "user"
-                ],
-                "summary": "Create company with admin",
-                "parameters": [
-                    {
-                        "description": "Company and admin data",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/entity.CreateCompanyRequest"
-                        }
-                    }
-                ],
-                "responses": {
-                    "201": {
-                        "description": "Company created, admin registered",
-                        "schema": {
-                            "$ref": "#/definitions/entity.TokenPair"
-                        }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "404": {
+                        "description": "Document not found",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
-                    "409": {
-                        "description": "Email already exists",
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -774,29 +1051,59 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/me": {
+        "/documents/{id}/certified-copy": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get the authenticated user's profile information",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Download the document PDF with its verification QR code stamped onto the first or last page. Only employees from the same company can access.",
                 "produces": [
-                    "application/json"
+                    "application/pdf"
                 ],
                 "tags": [
-                    "user"
+                    "documents"
+                ],
+                "summary": "Download certified copy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page to stamp: first or last (default last)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "QR code size in points, 48-288 (default 96)",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Error correction level: L, M, Q or H (default M)",
+                        "name": "ec",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get current user",
                 "responses": {
                     "200": {
-                        "description": "User profile",
+                        "description": "Certified PDF copy",
                         "schema": {
-                            "$ref": "#/definitions/entity.User"
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or unsupported PDF",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
                         }
                     },
                     "401": {
@@ -806,53 +1113,56 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/documents/{id}/events": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Update the authenticated user's profile information",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get the change history of a document (updates, revocation, supersession), newest first. Only employees from the same company can access.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "documents"
                 ],
-                "summary": "Update current user",
+                "summary": "Get document events",
                 "parameters": [
                     {
-                        "description": "User update data",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/entity.UpdateUserRequest"
-                        }
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "User updated successfully",
+                        "description": "Document events",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.DocumentEvent"
                             }
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "Invalid document ID",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -864,13 +1174,13 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Document not found",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
-                    "409": {
-                        "description": "Email already exists",
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -878,28 +1188,25 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/{id}": {
+        "/documents/{id}/fields": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get user profile information by user ID",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get the fields recorded for a document (parties, dates, identifiers). Comparisons check them and reference them in differences. Only employees from the same company can access.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "documents"
                 ],
-                "summary": "Get user by ID",
+                "summary": "Get document fields",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Document ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -907,13 +1214,16 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "User profile",
+                        "description": "Document fields",
                         "schema": {
-                            "$ref": "#/definitions/entity.User"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.DocumentField"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid user ID",
+                        "description": "Invalid document ID",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -925,7 +1235,13 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
@@ -938,7 +1254,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Update user profile information. Only admins can update other users from the same company.",
+                "description": "Replace the fields of an active document, e.g. to correct extracted values. The change is recorded in the document events. Only employees from the same company can update.",
                 "consumes": [
                     "application/json"
                 ],
@@ -946,92 +1262,269 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "documents"
                 ],
-                "summary": "Update user by ID",
+                "summary": "Update document fields",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Document ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "User update data",
+                        "description": "Document fields",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entity.UpdateUserRequest"
+                            "$ref": "#/definitions/entity.UpdateDocumentFieldsRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "User updated successfully",
+                        "description": "Updated fields",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.DocumentField"
                             }
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "Invalid request or document is revoked or superseded",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - only admins can update other users",
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Document not found",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
-                    "409": {
-                        "description": "Email already exists",
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/documents/{id}/file": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Delete a user. Only admins can delete users from the same company.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Download the PDF file attached to a document. Supports Range requests and conditional requests with the ETag (If-None-Match, If-Range). Only employees from the same company can access.",
                 "produces": [
-                    "application/json"
+                    "application/pdf"
                 ],
                 "tags": [
-                    "user"
+                    "documents"
                 ],
-                "summary": "Delete user by ID",
+                "summary": "Download document file",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Document ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "User deleted successfully",
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "PDF file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested byte range of the PDF file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Cached copy is current"
+                    },
+                    "400": {
+                        "description": "Invalid document ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document or file not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "416": {
+                        "description": "Requested range not satisfiable"
+                    },
+                    "422": {
+                        "description": "Document is quarantined",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Upload a new PDF file for an active document. The file goes through the same validation as on creation. The document moves to a new version and the previous file is kept in its version history; QR codes issued for earlier versions report the new version on verification. Only employees from the same company can replace.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Replace document file",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "PDF file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated document",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Document"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request, rejected file or document is revoked or superseded",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Company already has a document with the same file",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "422": {
+                        "description": "File rejected by the malware scanner",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/public-verification": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Allow or forbid unauthenticated third parties to verify a document. Only employees from the same company can change it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Toggle public verification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Public verification setting",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.UpdatePublicVerificationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Setting updated",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1040,49 +1533,2633 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid user ID",
+                        "description": "Invalid request",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - only admins can delete users",
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/errs.Error"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "entity.AdminUser": {
-            "description": "Admin user registration data",
-            "type": "object",
-            "required": [
-                "email",
-                "first_name",
-                "last_name",
-                "password"
-            ],
-            "properties": {
+        },
+        "/documents/{id}/qr": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Render the document verification QR code (public verification URL or hash) as PNG or SVG. Only employees from the same company can access.",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Get document QR code",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Image format: png or svg (default png)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image size in pixels, 64-2048 (default 256)",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Error correction level: L, M, Q or H (default M)",
+                        "name": "ec",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "QR code image",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/rescan": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Scan the stored document file for malware again, e.g. after the scanner signatures were updated. A flagged active document is quarantined: its file is no longer served and verification reports red. A quarantined document whose file is now clean is released. Only admins can rescan documents from the same company.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Rescan document file",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Scan verdict and resulting document state",
+                        "schema": {
+                            "$ref": "#/definitions/entity.DocumentScanResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid document ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can rescan documents",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error or scanner unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/revoke": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Withdraw an active document. Verification of a revoked document returns red status with the revocation reason. Only employees from the same company can revoke.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Revoke a document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Revocation reason",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.RevokeDocumentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Document revoked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or document is already revoked or superseded",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/supersede": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mark an active document as replaced by another active document of the same company. Verification of a superseded document returns red status pointing at the successor. Only employees from the same company can supersede.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Supersede a document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Replacement document",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.SupersedeDocumentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Document superseded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or document or successor is not active",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document or successor not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/versions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all versions of a document, newest first. Each change to the document details or file creates a new version. Only employees from the same company can access.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Get document versions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Document versions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.DocumentVersion"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid document ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/versions/{version}/file": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Download the PDF file of a specific document version. Supports Range requests and conditional requests with the ETag. Only employees from the same company can access.",
+                "produces": [
+                    "application/pdf"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Download document version file",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document version",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "PDF file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid document ID or version",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document or version not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "422": {
+                        "description": "Document is quarantined",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/evidence/{id}/file": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Download a retained photo or PDF. Supports Range requests and conditional requests with the ETag. Only admins of the company that submitted the file can download it.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Download evidence file",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Evidence ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retained file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested byte range of the file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Cached copy is current"
+                    },
+                    "400": {
+                        "description": "Invalid evidence ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can access evidence",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Evidence not found or already purged",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/history": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the authenticated user's document verification history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Get verification history",
+                "responses": {
+                    "200": {
+                        "description": "Verification history",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.VerificationHistory"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/history/{id}/analysis": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the comparison analysis stored for a verification history entry, including the provider, model and prompt version that produced it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Get analysis of a verification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "History entry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stored analysis",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Analysis"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid history ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "No analysis stored for the entry",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/history/{id}/evidence": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the photos or PDF retained for a verification history entry. The list is empty when the company did not retain evidence at the time or the retention period ended. Only admins can access evidence.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "List evidence of a verification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "History entry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retained files",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.Evidence"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid history ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can access evidence",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the status of a comparison job and, once it succeeded, the comparison result. Failed jobs carry the error of their last attempt.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get comparison job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Comparison job",
+                        "schema": {
+                            "$ref": "#/definitions/entity.ComparisonJobResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/prompts": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every version of the company's comparison prompt templates and of the global templates",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "List prompt templates",
+                "responses": {
+                    "200": {
+                        "description": "Prompt templates",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.PromptTemplate"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a new version of the company's comparison prompt for a document type, or for all types when document_type is empty. Comparisons use the latest version of the most specific template: the company's template for the document type, the company's default, the global template for the type, the global default and finally the built-in prompt. Only admins can change prompts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "Add prompt template version",
+                "parameters": [
+                    {
+                        "description": "Prompt template",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.CreatePromptTemplateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created template version",
+                        "schema": {
+                            "$ref": "#/definitions/entity.PromptTemplate"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or template",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can change prompt templates",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Another version was added at the same time",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/prompts/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a version of a comparison prompt template, e.g. the one referenced by a stored analysis",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "prompts"
+                ],
+                "summary": "Get prompt template",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Prompt template ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Prompt template",
+                        "schema": {
+                            "$ref": "#/definitions/entity.PromptTemplate"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid prompt template ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Prompt template not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/verify": {
+            "get": {
+                "description": "Verify a document without authentication. Returns a redacted view (no file, no summary) if the issuing company allowed public verification for the document. Each verification is recorded anonymously in history. Requests are rate-limited per IP.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "public"
+                ],
+                "summary": "Publicly verify a document by hash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Signed document hash",
+                        "name": "hash",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Redacted document verification result",
+                        "schema": {
+                            "$ref": "#/definitions/entity.PublicDocumentView"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or hash",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found or not publicly verifiable",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/usage": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the tokens AI providers billed for the company's comparisons and extractions, aggregated by day or month in UTC, with their cost and the company's monthly token quota. Comparisons served from the cache use no tokens. Without a range, the current month is reported by day and the last twelve months by month. Only admins can view usage.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "usage"
+                ],
+                "summary": "Get AI usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Aggregation period: day (default) or month",
+                        "name": "period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "First day of the report (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day of the report (YYYY-MM-DD), inclusive",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only count usage of this user",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only count comparisons of this document",
+                        "name": "document_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Usage report",
+                        "schema": {
+                            "$ref": "#/definitions/entity.UsageReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid period, date or range",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can view usage",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/company": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all users from the authenticated user's company",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Get users by company",
+                "responses": {
+                    "200": {
+                        "description": "List of users",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.User"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Company not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new company and register its admin user. Returns access and refresh tokens for the admin.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Create company with admin",
+                "parameters": [
+                    {
+                        "description": "Company and admin data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.CreateCompanyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Company created, admin registered",
+                        "schema": {
+                            "$ref": "#/definitions/entity.TokenPair"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Email already exists",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/company/evidence-retention": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Opt the company in or out of keeping the photos and PDFs submitted for comparison. Retained files are linked to the verification history entry and purged automatically when the configured retention period ends. Opting out keeps files retained so far until they expire. Only admins can change the setting.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Set evidence retention",
+                "parameters": [
+                    {
+                        "description": "Evidence retention setting",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.UpdateEvidenceRetentionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Evidence retention updated",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can change evidence retention",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the authenticated user's profile information",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Get current user",
+                "responses": {
+                    "200": {
+                        "description": "User profile",
+                        "schema": {
+                            "$ref": "#/definitions/entity.User"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the authenticated user's profile information. Changing the password requires the current password and ends all sessions.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Update current user",
+                "parameters": [
+                    {
+                        "description": "User update data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.UpdateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User updated successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Email already exists",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get user profile information by user ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Get user by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User profile",
+                        "schema": {
+                            "$ref": "#/definitions/entity.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update user profile information. Only admins can update other users from the same company and change roles. Changing the role or password ends all sessions of the user.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Update user by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User update data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.UpdateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User updated successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can update other users",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Email already exists",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a user and end all of their sessions. Only admins can delete users from the same company.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Delete user by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User deleted successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can delete users",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/sessions": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "End all sessions of a user and revoke their access tokens, e.g. when the user's credentials were compromised. Only admins can end the sessions of users from the same company.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Log user out everywhere",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Sessions ended",
+                        "schema": {
+                            "$ref": "#/definitions/entity.SessionRevocationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - only admins can end sessions of users",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/errs.Error"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "entity.AdminUser": {
+            "description": "Admin user registration data",
+            "type": "object",
+            "required": [
+                "email",
+                "first_name",
+                "last_name",
+                "password"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "admin@example.com"
+                },
+                "first_name": {
+                    "type": "string",
+                    "example": "John"
+                },
+                "last_name": {
+                    "type": "string",
+                    "example": "Doe"
+                },
+                "password": {
+                    "type": "string",
+                    "minLength": 8,
+                    "example": "password123"
+                }
+            }
+        },
+        "entity.Analysis": {
+            "description": "Analysis result kept for a comparison, with the provider, model and prompt version that produced it",
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "confidence": {
+                    "type": "string",
+                    "example": "high"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:00Z"
+                },
+                "differences": {
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                },
+                "document_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "findings": {
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                },
+                "history_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "is_authentic": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "model": {
+                    "type": "string",
+                    "example": "gemini-1.5-flash"
+                },
+                "pages": {
+                    "description": "Per-page results, empty unless photos were compared page by page",
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                },
+                "prompt": {
+                    "description": "Rendered instructions sent to the provider",
+                    "type": "string",
+                    "example": "You are a document verification expert..."
+                },
+                "prompt_template_id": {
+                    "description": "Template the prompt was rendered from, unset for the built-in prompt",
+                    "type": "integer",
+                    "example": 1
+                },
+                "prompt_version": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "gemini"
+                },
+                "repaired": {
+                    "description": "The provider's answer failed validation and was repaired",
+                    "type": "boolean",
+                    "example": false
+                },
+                "score": {
+                    "type": "number",
+                    "example": 0.95
+                },
+                "summary": {
+                    "type": "string",
+                    "example": "Documents match with 95% confidence."
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "entity.AnalysisCacheInfo": {
+            "description": "Comparison cache metadata of an analysis result",
+            "type": "object",
+            "properties": {
+                "cached_at": {
+                    "description": "When the result was computed",
+                    "type": "string",
+                    "example": "2024-06-01T12:00:00Z"
+                },
+                "expires_at": {
+                    "description": "When the cached result is discarded",
+                    "type": "string",
+                    "example": "2024-06-02T12:00:00Z"
+                },
+                "hit": {
+                    "description": "The same evidence was compared before and the stored result was returned",
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "entity.AnalysisCacheInvalidationResponse": {
+            "description": "Number of cached comparison results removed for a document",
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "document_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "entity.AnalysisFinding": {
+            "description": "General observation or finding from the analysis",
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "text"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Minor text differences detected in footer"
+                },
+                "severity": {
+                    "type": "string",
+                    "example": "warning"
+                }
+            }
+        },
+        "entity.CompareDocumentResponse": {
+            "description": "Response containing document verification status, details and analysis result",
+            "type": "object",
+            "properties": {
+                "analysis": {
+                    "$ref": "#/definitions/entity.DocumentAnalysisResult"
+                },
+                "document": {
+                    "$ref": "#/definitions/entity.Document"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Document is valid"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.DocumentStatus"
+                        }
+                    ],
+                    "example": "green"
+                }
+            }
+        },
+        "entity.ComparisonJobKind": {
+            "type": "string",
+            "enum": [
+                "photos",
+                "pdf"
+            ],
+            "x-enum-varnames": [
+                "ComparisonJobPhotos",
+                "ComparisonJobPDF"
+            ]
+        },
+        "entity.ComparisonJobResponse": {
+            "description": "Asynchronous comparison job, polled until its status is succeeded or failed",
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:00Z"
+                },
+                "error": {
+                    "type": "string",
+                    "example": ""
+                },
+                "finished_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:09Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "3f2a9c1e5b7d4e8f9a0b1c2d3e4f5a6b"
+                },
+                "kind": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.ComparisonJobKind"
+                        }
+                    ],
+                    "example": "pdf"
+                },
+                "result": {
+                    "$ref": "#/definitions/entity.CompareDocumentResponse"
+                },
+                "started_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:01Z"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.ComparisonJobStatus"
+                        }
+                    ],
+                    "example": "succeeded"
+                }
+            }
+        },
+        "entity.ComparisonJobStatus": {
+            "type": "string",
+            "enum": [
+                "queued",
+                "running",
+                "succeeded",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "ComparisonJobQueued",
+                "ComparisonJobRunning",
+                "ComparisonJobSucceeded",
+                "ComparisonJobFailed"
+            ]
+        },
+        "entity.CreateCompanyRequest": {
+            "description": "Request to create a company and register its admin",
+            "type": "object",
+            "required": [
+                "admin",
+                "company_name"
+            ],
+            "properties": {
+                "admin": {
+                    "$ref": "#/definitions/entity.AdminUser"
+                },
+                "company_name": {
+                    "type": "string",
+                    "example": "Acme Corp"
+                }
+            }
+        },
+        "entity.CreateDocumentResponse": {
+            "description": "Response containing the document hash for later retrieval",
+            "type": "object",
+            "properties": {
+                "hash": {
+                    "type": "string",
+                    "example": "v1.k1.eyJpZCI6MSwiY29tcGFueV9pZCI6MSwidHlwZSI6ImFncmVlbWVudCIsIm5hbWUiOiJFbXBsb3ltZW50IEFncmVlbWVudCJ9.3q2-7wAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+                }
+            }
+        },
+        "entity.CreatePromptTemplateRequest": {
+            "description": "Request to add a new version of the company's prompt template for a document type. The body is a Go text template with the variables .DocumentType, .DocumentName, .DocumentSummary, .ExpectedFields and .Fields (the name, kind and value of each field recorded for the document) and the function join. The required JSON response format is appended automatically.",
+            "type": "object",
+            "required": [
+                "body"
+            ],
+            "properties": {
+                "body": {
+                    "type": "string",
+                    "maxLength": 20000,
+                    "example": "Compare the ORIGINAL {{.DocumentType}} with the PROVIDED copy. Check the seal and the signatures."
+                },
+                "document_type": {
+                    "description": "Empty for all document types",
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "diploma"
+                },
+                "expected_fields": {
+                    "type": "array",
+                    "maxItems": 50,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "graduate name",
+                        "degree",
+                        "graduation date"
+                    ]
+                }
+            }
+        },
+        "entity.Document": {
+            "description": "Document entity with type, name, summary and expiration date",
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "content_hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "content_type": {
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "expiration_date": {
+                    "type": "string",
+                    "example": "2025-12-31T00:00:00Z"
+                },
+                "file_name": {
+                    "type": "string",
+                    "example": "contract.pdf"
+                },
+                "file_size": {
+                    "type": "integer",
+                    "example": 102400
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Employment Agreement"
+                },
+                "public_verification": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "revocation_reason": {
+                    "type": "string",
+                    "example": "Issued with wrong employee name"
+                },
+                "revoked_at": {
+                    "type": "string",
+                    "example": "2024-06-01T00:00:00Z"
+                },
+                "scan_count": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "state": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.DocumentState"
+                        }
+                    ],
+                    "example": "active"
+                },
+                "summary": {
+                    "type": "string",
+                    "example": "Standard employment agreement for full-time employees"
+                },
+                "superseded_by": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "type": {
+                    "type": "string",
+                    "example": "agreement"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "entity.DocumentAnalysisResult": {
+            "description": "Analysis result comparing uploaded document/photos with original",
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "description": "Set when comparison caching is enabled",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.AnalysisCacheInfo"
+                        }
+                    ]
+                },
+                "confidence": {
+                    "type": "string",
+                    "example": "high"
+                },
+                "differences": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.DocumentDifference"
+                    }
+                },
+                "findings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.AnalysisFinding"
+                    }
+                },
+                "id": {
+                    "description": "Stored analysis, set once the result was persisted",
+                    "type": "integer",
+                    "example": 1
+                },
+                "is_authentic": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "pages": {
+                    "description": "Per-page results when photos were compared page by page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.PageAnalysis"
+                    }
+                },
+                "prompt_template_id": {
+                    "description": "Template the prompt was rendered from, unset for the built-in prompt",
+                    "type": "integer",
+                    "example": 1
+                },
+                "prompt_version": {
+                    "description": "Version of the prompt sent to an LLM provider",
+                    "type": "string",
+                    "example": "1"
+                },
+                "provider": {
+                    "description": "Analyzer that produced the result",
+                    "type": "string",
+                    "example": "gemini:gemini-1.5-flash"
+                },
+                "repaired": {
+                    "description": "The provider's answer failed validation and was repaired; such results are never authentic",
+                    "type": "boolean",
+                    "example": false
+                },
+                "score": {
+                    "type": "number",
+                    "example": 0.95
+                },
+                "summary": {
+                    "type": "string",
+                    "example": "Documents match with 95% confidence. Minor formatting differences detected."
+                },
+                "usage": {
+                    "description": "Tokens used by an AI provider, unset when decided locally or cached",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.TokenUsage"
+                        }
+                    ]
+                }
+            }
+        },
+        "entity.DocumentDifference": {
+            "description": "Specific difference detected between original and provided document",
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Name field has a typo - missing letter i"
+                },
+                "field": {
+                    "description": "Recorded document field the difference concerns",
+                    "type": "string",
+                    "example": "employee"
+                },
+                "location": {
+                    "type": "string",
+                    "example": "Header section"
+                },
+                "original_value": {
+                    "type": "string",
+                    "example": "John Smith"
+                },
+                "page": {
+                    "description": "Page of the document, set when photos were compared page by page",
+                    "type": "integer",
+                    "example": 1
+                },
+                "provided_value": {
+                    "type": "string",
+                    "example": "John Smth"
+                },
+                "severity": {
+                    "type": "string",
+                    "example": "moderate"
+                }
+            }
+        },
+        "entity.DocumentEvent": {
+            "description": "Audit record of a document change",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.DocumentEventAction"
+                        }
+                    ],
+                    "example": "revoked"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:00Z"
+                },
+                "details": {
+                    "type": "object"
+                },
+                "document_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "entity.DocumentEventAction": {
+            "type": "string",
+            "enum": [
+                "updated",
+                "file_replaced",
+                "public_verification",
+                "revoked",
+                "superseded",
+                "deleted",
+                "quarantined",
+                "released",
+                "fields_updated"
+            ],
+            "x-enum-varnames": [
+                "DocumentEventUpdated",
+                "DocumentEventFileReplaced",
+                "DocumentEventPublicVerification",
+                "DocumentEventRevoked",
+                "DocumentEventSuperseded",
+                "DocumentEventDeleted",
+                "DocumentEventQuarantined",
+                "DocumentEventReleased",
+                "DocumentEventFieldsUpdated"
+            ]
+        },
+        "entity.DocumentExtraction": {
+            "description": "Details and fields extracted from a file for the issuer to review before creating the document",
+            "type": "object",
+            "properties": {
+                "expiration_date": {
+                    "type": "string",
+                    "example": "2025-12-31T00:00:00Z"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.DocumentFieldValue"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Employment Agreement"
+                },
+                "provider": {
+                    "description": "Analyzer that extracted the details",
+                    "type": "string",
+                    "example": "gemini:gemini-1.5-flash"
+                },
+                "summary": {
+                    "type": "string",
+                    "example": "Employment agreement between Acme Corp and Jane Smith"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "agreement"
+                },
+                "usage": {
+                    "description": "Tokens used by an AI provider, unset when decided locally",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.TokenUsage"
+                        }
+                    ]
+                }
+            }
+        },
+        "entity.DocumentField": {
+            "description": "Named value recorded for a document. Comparisons check the fields and reference them in differences.",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:00Z"
+                },
+                "document_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "kind": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.DocumentFieldKind"
+                        }
+                    ],
+                    "example": "identifier"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "contract_number"
+                },
+                "source": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.DocumentFieldSource"
+                        }
+                    ],
+                    "example": "extracted"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "EA-2024-0042"
+                }
+            }
+        },
+        "entity.DocumentFieldKind": {
+            "type": "string",
+            "enum": [
+                "text",
+                "date",
+                "party",
+                "identifier",
+                "amount"
+            ],
+            "x-enum-comments": {
+                "DocumentFieldDate": "YYYY-MM-DD"
+            },
+            "x-enum-descriptions": [
+                "",
+                "YYYY-MM-DD",
+                "",
+                "",
+                ""
+            ],
+            "x-enum-varnames": [
+                "DocumentFieldText",
+                "DocumentFieldDate",
+                "DocumentFieldParty",
+                "DocumentFieldIdentifier",
+                "DocumentFieldAmount"
+            ]
+        },
+        "entity.DocumentFieldSource": {
+            "type": "string",
+            "enum": [
+                "extracted",
+                "manual"
+            ],
+            "x-enum-varnames": [
+                "DocumentFieldExtracted",
+                "DocumentFieldManual"
+            ]
+        },
+        "entity.DocumentFieldValue": {
+            "description": "Named value of a document. Names are lowercase snake_case, dates are formatted YYYY-MM-DD.",
+            "type": "object",
+            "required": [
+                "kind",
+                "name",
+                "value"
+            ],
+            "properties": {
+                "kind": {
+                    "enum": [
+                        "text",
+                        "date",
+                        "party",
+                        "identifier",
+                        "amount"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.DocumentFieldKind"
+                        }
+                    ],
+                    "example": "identifier"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "contract_number"
+                },
+                "value": {
+                    "type": "string",
+                    "maxLength": 1000,
+                    "example": "EA-2024-0042"
+                }
+            }
+        },
+        "entity.DocumentScanResponse": {
+            "description": "Malware scan verdict for the stored document file and the resulting document state",
+            "type": "object",
+            "properties": {
+                "clean": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "document_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "signature": {
+                    "type": "string",
+                    "example": "Eicar-Test-Signature"
+                },
+                "state": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.DocumentState"
+                        }
+                    ],
+                    "example": "quarantined"
+                }
+            }
+        },
+        "entity.DocumentState": {
+            "type": "string",
+            "enum": [
+                "active",
+                "revoked",
+                "superseded",
+                "quarantined"
+            ],
+            "x-enum-comments": {
+                "DocumentStateActive": "Document is in force",
+                "DocumentStateQuarantined": "Document file was flagged by the malware scanner",
+                "DocumentStateRevoked": "Document was withdrawn by the issuer",
+                "DocumentStateSuperseded": "Document was replaced by another document"
+            },
+            "x-enum-descriptions": [
+                "Document is in force",
+                "Document was withdrawn by the issuer",
+                "Document was replaced by another document",
+                "Document file was flagged by the malware scanner"
+            ],
+            "x-enum-varnames": [
+                "DocumentStateActive",
+                "DocumentStateRevoked",
+                "DocumentStateSuperseded",
+                "DocumentStateQuarantined"
+            ]
+        },
+        "entity.DocumentStatus": {
+            "type": "string",
+            "enum": [
+                "green",
+                "yellow",
+                "red"
+            ],
+            "x-enum-comments": {
+                "DocumentStatusGreen": "Document is valid",
+                "DocumentStatusRed": "Document is expired or not found",
+                "DocumentStatusYellow": "Document will expire soon (within 30 days)"
+            },
+            "x-enum-descriptions": [
+                "Document is valid",
+                "Document will expire soon (within 30 days)",
+                "Document is expired or not found"
+            ],
+            "x-enum-varnames": [
+                "DocumentStatusGreen",
+                "DocumentStatusYellow",
+                "DocumentStatusRed"
+            ]
+        },
+        "entity.DocumentVersion": {
+            "description": "Snapshot of document details and file at a specific version",
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:00Z"
+                },
+                "created_by": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "document_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "expiration_date": {
+                    "type": "string",
+                    "example": "2025-12-31T00:00:00Z"
+                },
+                "file_name": {
+                    "type": "string",
+                    "example": "contract.pdf"
+                },
+                "file_size": {
+                    "type": "integer",
+                    "example": 102400
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Employment Agreement"
+                },
+                "summary": {
+                    "type": "string",
+                    "example": "Standard employment agreement for full-time employees"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "agreement"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "entity.Evidence": {
+            "description": "Photo or PDF submitted for comparison, kept until its retention period ends",
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "content_type": {
+                    "type": "string",
+                    "example": "image/jpeg"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:00Z"
+                },
+                "document_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "expires_at": {
+                    "type": "string",
+                    "example": "2024-04-01T12:00:00Z"
+                },
+                "file_size": {
+                    "type": "integer",
+                    "example": 482133
+                },
+                "history_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "kind": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.EvidenceKind"
+                        }
+                    ],
+                    "example": "photo"
+                },
+                "position": {
+                    "description": "Order of the file in the submission, starting at 1",
+                    "type": "integer",
+                    "example": 1
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "entity.EvidenceKind": {
+            "type": "string",
+            "enum": [
+                "photo",
+                "pdf"
+            ],
+            "x-enum-varnames": [
+                "EvidenceKindPhoto",
+                "EvidenceKindPDF"
+            ]
+        },
+        "entity.LoginRequest": {
+            "description": "Login credentials",
+            "type": "object",
+            "required": [
+                "email",
+                "password"
+            ],
+            "properties": {
+                "device": {
+                    "description": "Optional name of the client, shown with its session",
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Warehouse scanner 3"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "password": {
+                    "type": "string",
+                    "example": "password123"
+                }
+            }
+        },
+        "entity.PageAnalysis": {
+            "description": "Result of comparing one page of the original document",
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "type": "string",
+                    "example": "high"
+                },
+                "is_authentic": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "page": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "photos": {
+                    "description": "Numbers of the photos showing the page, in submission order",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1
+                    ]
+                },
+                "score": {
+                    "type": "number",
+                    "example": 0.95
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.PageAnalysisStatus"
+                        }
+                    ],
+                    "example": "compared"
+                },
+                "summary": {
+                    "type": "string",
+                    "example": "Page matches the original."
+                }
+            }
+        },
+        "entity.PageAnalysisStatus": {
+            "type": "string",
+            "enum": [
+                "compared",
+                "missing",
+                "extra"
+            ],
+            "x-enum-comments": {
+                "PageAnalysisExtra": "Photos were provided for a page the document does not have",
+                "PageAnalysisMissing": "No photo of the page was provided"
+            },
+            "x-enum-descriptions": [
+                "",
+                "No photo of the page was provided",
+                "Photos were provided for a page the document does not have"
+            ],
+            "x-enum-varnames": [
+                "PageAnalysisCompared",
+                "PageAnalysisMissing",
+                "PageAnalysisExtra"
+            ]
+        },
+        "entity.PromptTemplate": {
+            "description": "Comparison prompt template. Templates without a company apply to every company and templates without a document type to every type. The latest version of the most specific template is used.",
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "string",
+                    "example": "Compare the ORIGINAL {{.DocumentType}} with the PROVIDED copy. Check the seal and the signatures."
+                },
+                "company_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-01T12:00:00Z"
+                },
+                "created_by": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "document_type": {
+                    "type": "string",
+                    "example": "diploma"
+                },
+                "expected_fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "entity.PublicDocumentView": {
+            "description": "Redacted document details returned by public verification",
+            "type": "object",
+            "properties": {
+                "expiration_date": {
+                    "type": "string",
+                    "example": "2025-12-31T00:00:00Z"
+                },
+                "issuer_name": {
+                    "type": "string",
+                    "example": "Acme Corp"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Document is valid"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Employment Agreement"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.DocumentStatus"
+                        }
+                    ],
+                    "example": "green"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "agreement"
+                }
+            }
+        },
+        "entity.RefreshRequest": {
+            "description": "Refresh token request",
+            "type": "object",
+            "required": [
+                "refresh_token"
+            ],
+            "properties": {
+                "refresh_token": {
+                    "type": "string",
+                    "example": "abc123def456..."
+                }
+            }
+        },
+        "entity.RegisterEmployeeRequest": {
+            "description": "Request to register a new employee user",
+            "type": "object",
+            "required": [
+                "company_id",
+                "email",
+                "first_name",
+                "last_name",
+                "password"
+            ],
+            "properties": {
+                "company_id": {
+                    "type": "integer",
+                    "example": 1
+                },
                 "email": {
                     "type": "string",
-                    "example": "admin@example.com"
+                    "example": "employee@example.com"
                 },
                 "first_name": {
                     "type": "string",
-                    "example": "John"
+                    "example": "Jane"
                 },
                 "last_name": {
                     "type": "string",
-                    "example": "Doe"
+                    "example": "Smith"
                 },
                 "password": {
                     "type": "string",
@@ -1091,85 +4168,144 @@ const docTemplate = `{
                 }
             }
         },
-        "entity.CompareDocumentResponse": {
-            "description": "Response containing document verification status, details and analysis result",
+        "entity.RevokeDocumentRequest": {
+            "description": "Request to revoke a document with a reason shown on verification",
             "type": "object",
+            "required": [
+                "reason"
+            ],
             "properties": {
-                "analysis": {
-                    "$ref": "#/definitions/entity.DocumentAnalysisResult"
+                "reason": {
+                    "type": "string",
+                    "example": "Issued with wrong employee name"
+                }
+            }
+        },
+        "entity.SessionInfo": {
+            "description": "Active login session of the user",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-01T00:00:00Z"
                 },
-                "document": {
-                    "$ref": "#/definitions/entity.Document"
+                "current": {
+                    "description": "The session of the access token of the request",
+                    "type": "boolean",
+                    "example": true
                 },
-                "message": {
+                "device": {
                     "type": "string",
-                    "example": "Document is valid"
+                    "example": "Warehouse scanner 3"
                 },
-                "status": {
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/entity.DocumentStatus"
-                        }
-                    ],
-                    "example": "green"
+                "expires_at": {
+                    "type": "string",
+                    "example": "2024-01-09T00:00:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "4ee24864f98bb5437e5189f606fc16c2"
+                },
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "last_used_at": {
+                    "type": "string",
+                    "example": "2024-01-02T00:00:00Z"
+                },
+                "user_agent": {
+                    "type": "string",
+                    "example": "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"
                 }
             }
         },
-        "entity.CreateCompanyRequest": {
-            "description": "Request to create a company and register its admin",
+        "entity.SessionRevocationResponse": {
+            "description": "Number of sessions ended",
+            "type": "object",
+            "properties": {
+                "revoked": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "entity.SupersedeDocumentRequest": {
+            "description": "Request to mark a document as superseded by a replacement document",
             "type": "object",
             "required": [
-                "admin",
-                "company_name"
+                "successor_id"
             ],
             "properties": {
-                "admin": {
-                    "$ref": "#/definitions/entity.AdminUser"
-                },
-                "company_name": {
-                    "type": "string",
-                    "example": "Acme Corp"
+                "successor_id": {
+                    "type": "integer",
+                    "example": 2
                 }
             }
         },
-        "entity.CreateDocumentResponse": {
-            "description": "Response containing the document hash for later retrieval",
+        "entity.TokenPair": {
+            "description": "Token pair response for authentication",
             "type": "object",
             "properties": {
-                "hash": {
+                "access_token": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                },
+                "refresh_token": {
                     "type": "string",
-                    "example": "eyJpZCI6MSwidHlwZSI6ImFncmVlbWVudCIsIm5hbWUiOiJFbXBsb3ltZW50IEFncmVlbWVudCJ9"
+                    "example": "abc123def456..."
                 }
             }
         },
-        "entity.Document": {
-            "description": "Document entity with type, name, summary and expiration date",
+        "entity.TokenUsage": {
+            "description": "Tokens billed by the AI provider",
             "type": "object",
             "properties": {
-                "company_id": {
+                "input_tokens": {
                     "type": "integer",
-                    "example": 1
-                },
-                "expiration_date": {
-                    "type": "string",
-                    "example": "2025-12-31T00:00:00Z"
+                    "example": 2580
                 },
-                "file_name": {
-                    "type": "string",
-                    "example": "contract.pdf"
+                "output_tokens": {
+                    "description": "Includes the tokens models spent thinking",
+                    "type": "integer",
+                    "example": 412
                 },
-                "id": {
+                "requests": {
+                    "description": "Requests sent, more than one when answers were requested again",
                     "type": "integer",
                     "example": 1
                 },
+                "total_tokens": {
+                    "type": "integer",
+                    "example": 2992
+                }
+            }
+        },
+        "entity.UpdateDocumentFieldsRequest": {
+            "description": "Request to replace every recorded field of a document",
+            "type": "object",
+            "properties": {
+                "fields": {
+                    "type": "array",
+                    "maxItems": 50,
+                    "items": {
+                        "$ref": "#/definitions/entity.DocumentFieldValue"
+                    }
+                }
+            }
+        },
+        "entity.UpdateDocumentRequest": {
+            "description": "Request to update document details (all fields optional)",
+            "type": "object",
+            "properties": {
+                "expiration_date": {
+                    "type": "string",
+                    "example": "2026-12-31T00:00:00Z"
+                },
                 "name": {
                     "type": "string",
                     "example": "Employment Agreement"
                 },
-                "scan_count": {
-                    "type": "integer",
-                    "example": 42
-                },
                 "summary": {
                     "type": "string",
                     "example": "Standard employment agreement for full-time employees"
@@ -1180,137 +4316,176 @@ const docTemplate = `{
                 }
             }
         },
-        "entity.DocumentAnalysisResult": {
-            "description": "Analysis result comparing uploaded document/photos with original",
-            "type": "object",
-            "properties": {
-                "message": {
-                    "type": "string",
-                    "example": "Documents match with 95% confidence"
-                },
-                "score": {
-                    "type": "number",
-                    "example": 0.95
-                }
-            }
-        },
-        "entity.DocumentStatus": {
-            "type": "string",
-            "enum": [
-                "green",
-                "yellow",
-                "red"
-            ],
-            "x-enum-comments": {
-                "DocumentStatusGreen": "Document is valid",
-                "DocumentStatusRed": "Document is expired or not found",
-                "DocumentStatusYellow": "Document will expire soon (within 30 days)"
-            },
-            "x-enum-descriptions": [
-                "Document is valid",
-                "Document will expire soon (within 30 days)",
-                "Document is expired or not found"
-            ],
-            "x-enum-varnames": [
-                "DocumentStatusGreen",
-                "DocumentStatusYellow",
-                "DocumentStatusRed"
-            ]
-        },
-        "entity.LoginRequest": {
-            "description": "Login credentials",
+        "entity.UpdateEvidenceRetentionRequest": {
+            "description": "Request to keep or stop keeping the files the company submits for comparison",
             "type": "object",
             "required": [
-                "email",
-                "password"
+                "enabled"
             ],
             "properties": {
-                "email": {
-                    "type": "string",
-                    "example": "user@example.com"
-                },
-                "password": {
-                    "type": "string",
-                    "example": "password123"
+                "enabled": {
+                    "type": "boolean",
+                    "example": true
                 }
             }
         },
-        "entity.RefreshRequest": {
-            "description": "Refresh token request",
+        "entity.UpdatePublicVerificationRequest": {
+            "description": "Request to allow or forbid unauthenticated verification of a document",
             "type": "object",
             "required": [
-                "refresh_token"
+                "enabled"
             ],
             "properties": {
-                "refresh_token": {
-                    "type": "string",
-                    "example": "abc123def456..."
+                "enabled": {
+                    "type": "boolean",
+                    "example": true
                 }
             }
         },
-        "entity.RegisterEmployeeRequest": {
-            "description": "Request to register a new employee user",
+        "entity.UpdateUserRequest": {
+            "description": "Request to update user profile (all fields optional). Changing the role or password ends all sessions of the user.",
             "type": "object",
-            "required": [
-                "company_id",
-                "email",
-                "first_name",
-                "last_name",
-                "password"
-            ],
             "properties": {
-                "company_id": {
-                    "type": "integer",
-                    "example": 1
+                "current_password": {
+                    "description": "Required to change one's own password",
+                    "type": "string",
+                    "example": "password123"
                 },
                 "email": {
                     "type": "string",
-                    "example": "employee@example.com"
+                    "example": "newemail@example.com"
                 },
                 "first_name": {
                     "type": "string",
-                    "example": "Jane"
+                    "example": "John"
                 },
                 "last_name": {
                     "type": "string",
-                    "example": "Smith"
+                    "example": "Doe"
                 },
                 "password": {
+                    "description": "Ends all sessions of the user",
                     "type": "string",
                     "minLength": 8,
-                    "example": "password123"
+                    "example": "newpassword123"
+                },
+                "role": {
+                    "description": "Admins only, not for themselves",
+                    "type": "string",
+                    "enum": [
+                        "admin",
+                        "employee"
+                    ],
+                    "example": "employee"
                 }
             }
         },
-        "entity.TokenPair": {
-            "description": "Token pair response for authentication",
+        "entity.UsagePeriod": {
+            "type": "string",
+            "enum": [
+                "day",
+                "month"
+            ],
+            "x-enum-varnames": [
+                "UsagePeriodDay",
+                "UsagePeriodMonth"
+            ]
+        },
+        "entity.UsageQuota": {
+            "description": "Monthly AI token quota of the company and how much of it is used",
             "type": "object",
             "properties": {
-                "access_token": {
-                    "type": "string",
-                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                "monthly_tokens": {
+                    "description": "0 if usage is not limited",
+                    "type": "integer",
+                    "example": 1000000
                 },
-                "refresh_token": {
+                "remaining_tokens": {
+                    "description": "Unset if usage is not limited",
+                    "type": "integer",
+                    "example": 874336
+                },
+                "resets_at": {
                     "type": "string",
-                    "example": "abc123def456..."
+                    "example": "2024-07-01T00:00:00Z"
+                },
+                "used_tokens": {
+                    "type": "integer",
+                    "example": 125664
                 }
             }
         },
-        "entity.UpdateUserRequest": {
-            "description": "Request to update user profile (all fields optional)",
+        "entity.UsageReport": {
+            "description": "AI usage of the company aggregated by day or month, with its monthly quota",
             "type": "object",
             "properties": {
-                "email": {
+                "from": {
                     "type": "string",
-                    "example": "newemail@example.com"
+                    "example": "2024-06-01T00:00:00Z"
                 },
-                "first_name": {
+                "period": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.UsagePeriod"
+                        }
+                    ],
+                    "example": "day"
+                },
+                "periods": {
+                    "description": "Periods without usage are left out",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.UsageSummary"
+                    }
+                },
+                "quota": {
+                    "$ref": "#/definitions/entity.UsageQuota"
+                },
+                "to": {
+                    "description": "Exclusive",
                     "type": "string",
-                    "example": "John"
+                    "example": "2024-07-01T00:00:00Z"
                 },
-                "last_name": {
+                "total": {
+                    "$ref": "#/definitions/entity.UsageSummary"
+                }
+            }
+        },
+        "entity.UsageSummary": {
+            "description": "AI usage aggregated over a period",
+            "type": "object",
+            "properties": {
+                "comparisons": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "cost": {
+                    "type": "number",
+                    "example": 0.0133
+                },
+                "extractions": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "input_tokens": {
+                    "type": "integer",
+                    "example": 108360
+                },
+                "output_tokens": {
+                    "type": "integer",
+                    "example": 17304
+                },
+                "period_start": {
                     "type": "string",
-                    "example": "Doe"
+                    "example": "2024-06-01T00:00:00Z"
+                },
+                "requests": {
+                    "type": "integer",
+                    "example": 49
+                },
+                "total_tokens": {
+                    "type": "integer",
+                    "example": 125664
                 }
             }
         },
@@ -1356,6 +4531,11 @@ const docTemplate = `{
             "description": "Record of a document verification attempt",
             "type": "object",
             "properties": {
+                "analysis_id": {
+                    "description": "Set when the verification was part of a comparison",
+                    "type": "integer",
+                    "example": 1
+                },
                 "document_id": {
                     "type": "integer",
                     "example": 1
@@ -1372,6 +4552,14 @@ const docTemplate = `{
                     "type": "string",
                     "example": "2024-01-01T12:00:00Z"
                 },
+                "source": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entity.VerificationSource"
+                        }
+                    ],
+                    "example": "internal"
+                },
                 "status": {
                     "allOf": [
                         {
@@ -1386,6 +4574,25 @@ const docTemplate = `{
                 }
             }
         },
+        "entity.VerificationSource": {
+            "type": "string",
+            "enum": [
+                "internal",
+                "public"
+            ],
+            "x-enum-comments": {
+                "VerificationSourceInternal": "Authenticated company employee",
+                "VerificationSourcePublic": "Anonymous third party"
+            },
+            "x-enum-descriptions": [
+                "Authenticated company employee",
+                "Anonymous third party"
+            ],
+            "x-enum-varnames": [
+                "VerificationSourceInternal",
+                "VerificationSourcePublic"
+            ]
+        },
         "entity.VerifyDocumentResponse": {
             "description": "Response containing full document details and verification status",
             "type": "object",
@@ -1433,7 +4640,13 @@ const docTemplate = `{
                 "BAD_REQUEST",
                 "NOT_FOUND",
                 "UNAUTHORIZED",
-                "ALREADY_EXISTS"
+                "ALREADY_EXISTS",
+                "RATE_LIMITED",
+                "FILE_REJECTED",
+                "UNAVAILABLE",
+                "QUOTA_EXCEEDED",
+                "TOKEN_EXPIRED",
+                "TOKEN_REUSED"
             ],
             "x-enum-varnames": [
                 "ErrorTypeValidation",
@@ -1441,7 +4654,13 @@ const docTemplate = `{
                 "ErrorTypeBadRequest",
                 "ErrorTypeNotFound",
                 "ErrorTypeUnauthorized",
-                "ErrorTypeAlreadyExists"
+                "ErrorTypeAlreadyExists",
+                "ErrorTypeRateLimited",
+                "ErrorTypeFileRejected",
+                "ErrorTypeUnavailable",
+                "ErrorTypeQuotaExceeded",
+                "ErrorTypeTokenExpired",
+                "ErrorTypeTokenReused"
             ]
         }
     },
@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -21,8 +22,10 @@ type User struct {
 // Company represents a company entity
 // @Description Company entity
 type Company struct {
-	ID   int    `db:"id" json:"id" example:"1"`
-	Name string `db:"name" json:"name" example:"Acme Corp"`
+	ID                int    `db:"id" json:"id" example:"1"`
+	Name              string `db:"name" json:"name" example:"Acme Corp"`
+	RetainEvidence    bool   `db:"retain_evidence" json:"retain_evidence" example:"false"`                     // Keep files submitted for comparison
+	MonthlyTokenQuota *int64 `db:"monthly_token_quota" json:"monthly_token_quota,omitempty" example:"1000000"` // AI tokens the company may use per month, the configured default if unset, 0 for no limit
 }
 
 // TokenPayload represents the payload in JWT tokens
@@ -30,15 +33,59 @@ type TokenPayload struct {
 	UserID    string `json:"user_id"`
 	Role      string `json:"role"`
 	CompanyID string `json:"company_id"`
+	SessionID string `json:"session_id,omitempty"`
 }
 
-// RefreshToken represents a refresh token stored in Redis
+// RefreshToken represents a newly generated refresh token. The token is given to the client, only
+// its hash is stored.
 type RefreshToken struct {
-	UserID    string        `json:"user_id"`
-	Token     string        `json:"token"`
+	Token     string        `json:"token"` // Family ID and secret
+	Hash      string        `json:"hash"`
+	FamilyID  string        `json:"family_id"` // ID of the session the token belongs to
 	ExpiresIn time.Duration `json:"expires_in"`
 }
 
+// Session represents a login of a user on a client, stored in Redis. It is kept alive by
+// refreshing, each refresh replacing its refresh token. The tokens of a session form a family
+// named by the session ID.
+type Session struct {
+	ID         string       `json:"id"`
+	TokenHash  string       `json:"token_hash"` // Hash of the current refresh token
+	Payload    TokenPayload `json:"payload"`
+	Device     string       `json:"device,omitempty"` // Name the client gave itself at login
+	IP         string       `json:"ip"`
+	UserAgent  string       `json:"user_agent"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastUsedAt time.Time    `json:"last_used_at"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+}
+
+// SessionInfo represents a session as shown to its user
+// @Description Active login session of the user
+type SessionInfo struct {
+	ID         string    `json:"id" example:"4ee24864f98bb5437e5189f606fc16c2"`
+	Device     string    `json:"device,omitempty" example:"Warehouse scanner 3"`
+	IP         string    `json:"ip" example:"203.0.113.7"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0 (Windows NT 10.0; Win64; x64)"`
+	CreatedAt  time.Time `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	LastUsedAt time.Time `json:"last_used_at" example:"2024-01-02T00:00:00Z"`
+	ExpiresAt  time.Time `json:"expires_at" example:"2024-01-09T00:00:00Z"`
+	Current    bool      `json:"current" example:"true"` // The session of the access token of the request
+}
+
+// SessionRevocationResponse reports how many sessions were ended
+// @Description Number of sessions ended
+type SessionRevocationResponse struct {
+	Revoked int `json:"revoked" example:"3"`
+}
+
+// SessionClient describes the client a session is created for
+type SessionClient struct {
+	Device    string
+	IP        string
+	UserAgent string
+}
+
 // TokenPair represents access and refresh token pair
 // @Description Token pair response for authentication
 type TokenPair struct {
@@ -77,6 +124,7 @@ type RegisterEmployeeRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email" example:"user@example.com"`
 	Password string `json:"password" binding:"required" example:"password123"`
+	Device   string `json:"device" binding:"max=100" example:"Warehouse scanner 3"` // Optional name of the client, shown with its session
 }
 
 // RefreshRequest represents refresh token request
@@ -86,38 +134,138 @@ type RefreshRequest struct {
 }
 
 // UpdateUserRequest represents request to update user profile
-// @Description Request to update user profile (all fields optional)
+// @Description Request to update user profile (all fields optional). Changing the role or password ends all sessions of the user.
 type UpdateUserRequest struct {
-	FirstName *string `json:"first_name" example:"John"`
-	LastName  *string `json:"last_name" example:"Doe"`
-	Email     *string `json:"email" binding:"omitempty,email" example:"newemail@example.com"`
+	FirstName       *string `json:"first_name" example:"John"`
+	LastName        *string `json:"last_name" example:"Doe"`
+	Email           *string `json:"email" binding:"omitempty,email" example:"newemail@example.com"`
+	Role            *string `json:"role" binding:"omitempty,oneof=admin employee" example:"employee"` // Admins only, not for themselves
+	Password        *string `json:"password" binding:"omitempty,min=8" example:"newpassword123"`      // Ends all sessions of the user
+	CurrentPassword *string `json:"current_password" example:"password123"`                           // Required to change one's own password
 }
 
 // Document represents a document entity
 // @Description Document entity with type, name, summary and expiration date
 type Document struct {
+	ID                 int           `db:"id" json:"id" example:"1"`
+	CompanyID          int           `db:"company_id" json:"company_id" example:"1"`
+	Type               string        `db:"type" json:"type" example:"agreement"`
+	Name               string        `db:"name" json:"name" example:"Employment Agreement"`
+	Summary            string        `db:"summary" json:"summary" example:"Standard employment agreement for full-time employees"`
+	ExpirationDate     time.Time     `db:"expiration_date" json:"expiration_date" example:"2025-12-31T00:00:00Z"`
+	ScanCount          int           `db:"scan_count" json:"scan_count" example:"42"`
+	Version            int           `db:"version" json:"version" example:"1"`
+	PublicVerification bool          `db:"public_verification" json:"public_verification" example:"false"`
+	State              DocumentState `db:"state" json:"state" example:"active"`
+	RevocationReason   *string       `db:"revocation_reason" json:"revocation_reason,omitempty" example:"Issued with wrong employee name"`
+	RevokedAt          *time.Time    `db:"revoked_at" json:"revoked_at,omitempty" example:"2024-06-01T00:00:00Z"`
+	SupersededBy       *int          `db:"superseded_by" json:"superseded_by,omitempty" example:"2"`
+	FileName           string        `db:"file_name" json:"file_name" example:"contract.pdf"`
+	FileSize           int64         `db:"file_size" json:"file_size" example:"102400"`
+	ContentType        string        `db:"content_type" json:"content_type" example:"application/pdf"`
+	ContentHash        string        `db:"content_hash" json:"content_hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	FileKey            string        `db:"file_key" json:"-"` // Blob store key, empty while the file is still stored in Postgres
+}
+
+// DocumentVersion represents an immutable snapshot of a document revision
+// @Description Snapshot of document details and file at a specific version
+type DocumentVersion struct {
 	ID             int       `db:"id" json:"id" example:"1"`
-	CompanyID      int       `db:"company_id" json:"company_id" example:"1"`
+	DocumentID     int       `db:"document_id" json:"document_id" example:"1"`
+	Version        int       `db:"version" json:"version" example:"1"`
 	Type           string    `db:"type" json:"type" example:"agreement"`
 	Name           string    `db:"name" json:"name" example:"Employment Agreement"`
 	Summary        string    `db:"summary" json:"summary" example:"Standard employment agreement for full-time employees"`
 	ExpirationDate time.Time `db:"expiration_date" json:"expiration_date" example:"2025-12-31T00:00:00Z"`
-	ScanCount      int       `db:"scan_count" json:"scan_count" example:"42"`
 	FileName       string    `db:"file_name" json:"file_name" example:"contract.pdf"`
-	FileData       []byte    `db:"file_data" json:"-"`
+	FileSize       int64     `db:"file_size" json:"file_size" example:"102400"`
+	ContentType    string    `db:"content_type" json:"content_type" example:"application/pdf"`
+	FileKey        string    `db:"file_key" json:"-"` // Blob store key, empty while the file is still stored in Postgres
+	CreatedBy      *int      `db:"created_by" json:"created_by,omitempty" example:"1"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at" example:"2024-01-01T12:00:00Z"`
+}
+
+// DocumentState represents the lifecycle state of an issued document
+type DocumentState string
+
+const (
+	DocumentStateActive      DocumentState = "active"      // Document is in force
+	DocumentStateRevoked     DocumentState = "revoked"     // Document was withdrawn by the issuer
+	DocumentStateSuperseded  DocumentState = "superseded"  // Document was replaced by another document
+	DocumentStateQuarantined DocumentState = "quarantined" // Document file was flagged by the malware scanner
+)
+
+// DocumentEvent represents a change made to a document after it was issued
+// @Description Audit record of a document change
+type DocumentEvent struct {
+	ID         int                 `db:"id" json:"id" example:"1"`
+	DocumentID int                 `db:"document_id" json:"document_id" example:"1"`
+	UserID     *int                `db:"user_id" json:"user_id,omitempty" example:"1"`
+	Action     DocumentEventAction `db:"action" json:"action" example:"revoked"`
+	Details    json.RawMessage     `db:"details" json:"details" swaggertype:"object"`
+	CreatedAt  time.Time           `db:"created_at" json:"created_at" example:"2024-01-01T12:00:00Z"`
 }
 
+// DocumentEventAction represents the kind of change recorded in a document event
+type DocumentEventAction string
+
+const (
+	DocumentEventUpdated            DocumentEventAction = "updated"
+	DocumentEventFileReplaced       DocumentEventAction = "file_replaced"
+	DocumentEventPublicVerification DocumentEventAction = "public_verification"
+	DocumentEventRevoked            DocumentEventAction = "revoked"
+	DocumentEventSuperseded         DocumentEventAction = "superseded"
+	DocumentEventDeleted            DocumentEventAction = "deleted"
+	DocumentEventQuarantined        DocumentEventAction = "quarantined"
+	DocumentEventReleased           DocumentEventAction = "released"
+	DocumentEventFieldsUpdated      DocumentEventAction = "fields_updated"
+)
+
 // VerificationHistory represents a document verification history entry
 // @Description Record of a document verification attempt
 type VerificationHistory struct {
-	ID         int            `db:"id" json:"id" example:"1"`
-	UserID     int            `db:"user_id" json:"user_id" example:"1"`
-	DocumentID int            `db:"document_id" json:"document_id" example:"1"`
-	Status     DocumentStatus `db:"status" json:"status" example:"green"`
-	Message    string         `db:"message" json:"message" example:"Document is valid"`
-	ScannedAt  time.Time      `db:"scanned_at" json:"scanned_at" example:"2024-01-01T12:00:00Z"`
+	ID         int                `db:"id" json:"id" example:"1"`
+	UserID     *int               `db:"user_id" json:"user_id,omitempty" example:"1"`
+	DocumentID int                `db:"document_id" json:"document_id" example:"1"`
+	Status     DocumentStatus     `db:"status" json:"status" example:"green"`
+	Message    string             `db:"message" json:"message" example:"Document is valid"`
+	Source     VerificationSource `db:"source" json:"source" example:"internal"`
+	AnalysisID *int               `db:"analysis_id" json:"analysis_id,omitempty" example:"1"` // Set when the verification was part of a comparison
+	ScannedAt  time.Time          `db:"scanned_at" json:"scanned_at" example:"2024-01-01T12:00:00Z"`
+}
+
+// Analysis represents a stored comparison analysis
+// @Description Analysis result kept for a comparison, with the provider, model and prompt version that produced it
+type Analysis struct {
+	ID               int             `db:"id" json:"id" example:"1"`
+	HistoryID        *int            `db:"history_id" json:"history_id,omitempty" example:"1"`
+	CompanyID        int             `db:"company_id" json:"company_id" example:"1"`
+	DocumentID       int             `db:"document_id" json:"document_id" example:"1"`
+	UserID           *int            `db:"user_id" json:"user_id,omitempty" example:"1"`
+	Score            float64         `db:"score" json:"score" example:"0.95"`
+	IsAuthentic      bool            `db:"is_authentic" json:"is_authentic" example:"true"`
+	Confidence       string          `db:"confidence" json:"confidence" example:"high"`
+	Summary          string          `db:"summary" json:"summary" example:"Documents match with 95% confidence."`
+	Differences      json.RawMessage `db:"differences" json:"differences" swaggertype:"array,object"`
+	Findings         json.RawMessage `db:"findings" json:"findings" swaggertype:"array,object"`
+	Provider         string          `db:"provider" json:"provider" example:"gemini"`
+	Model            string          `db:"model" json:"model" example:"gemini-1.5-flash"`
+	PromptVersion    string          `db:"prompt_version" json:"prompt_version" example:"1"`
+	PromptTemplateID *int            `db:"prompt_template_id" json:"prompt_template_id,omitempty" example:"1"`       // Template the prompt was rendered from, unset for the built-in prompt
+	Prompt           string          `db:"prompt" json:"prompt" example:"You are a document verification expert..."` // Rendered instructions sent to the provider
+	Repaired         bool            `db:"repaired" json:"repaired" example:"false"`                                 // The provider's answer failed validation and was repaired
+	Pages            json.RawMessage `db:"pages" json:"pages" swaggertype:"array,object"`                            // Per-page results, empty unless photos were compared page by page
+	CreatedAt        time.Time       `db:"created_at" json:"created_at" example:"2024-01-01T12:00:00Z"`
 }
 
+// VerificationSource represents where a verification request came from
+type VerificationSource string
+
+const (
+	VerificationSourceInternal VerificationSource = "internal" // Authenticated company employee
+	VerificationSourcePublic   VerificationSource = "public"   // Anonymous third party
+)
+
 // DocumentStatus represents the status of a document based on expiration
 type DocumentStatus string
 
@@ -127,19 +275,192 @@ const (
 	DocumentStatusRed    DocumentStatus = "red"    // Document is expired or not found
 )
 
+// Evidence represents a file submitted for comparison and retained for investigations
+// @Description Photo or PDF submitted for comparison, kept until its retention period ends
+type Evidence struct {
+	ID          int          `db:"id" json:"id" example:"1"`
+	CompanyID   int          `db:"company_id" json:"company_id" example:"1"`
+	DocumentID  int          `db:"document_id" json:"document_id" example:"1"`
+	HistoryID   *int         `db:"history_id" json:"history_id,omitempty" example:"1"`
+	UserID      *int         `db:"user_id" json:"user_id,omitempty" example:"1"`
+	Kind        EvidenceKind `db:"kind" json:"kind" example:"photo"`
+	Position    int          `db:"position" json:"position" example:"1"` // Order of the file in the submission, starting at 1
+	FileKey     string       `db:"file_key" json:"-"`
+	FileSize    int64        `db:"file_size" json:"file_size" example:"482133"`
+	ContentType string       `db:"content_type" json:"content_type" example:"image/jpeg"`
+	CreatedAt   time.Time    `db:"created_at" json:"created_at" example:"2024-01-01T12:00:00Z"`
+	ExpiresAt   time.Time    `db:"expires_at" json:"expires_at" example:"2024-04-01T12:00:00Z"`
+}
+
+// EvidenceKind represents how a retained file was submitted
+type EvidenceKind string
+
+const (
+	EvidenceKindPhoto EvidenceKind = "photo"
+	EvidenceKindPDF   EvidenceKind = "pdf"
+)
+
+// UpdateEvidenceRetentionRequest represents request to opt in or out of evidence retention
+// @Description Request to keep or stop keeping the files the company submits for comparison
+type UpdateEvidenceRetentionRequest struct {
+	Enabled *bool `json:"enabled" binding:"required" example:"true"`
+}
+
+// PromptTemplate represents a version of the comparison instructions sent to LLM providers
+// @Description Comparison prompt template. Templates without a company apply to every company and templates without a document type to every type. The latest version of the most specific template is used.
+type PromptTemplate struct {
+	ID             int             `db:"id" json:"id" example:"1"`
+	CompanyID      *int            `db:"company_id" json:"company_id,omitempty" example:"1"`
+	DocumentType   string          `db:"document_type" json:"document_type" example:"diploma"`
+	Version        int             `db:"version" json:"version" example:"2"`
+	Body           string          `db:"body" json:"body" example:"Compare the ORIGINAL {{.DocumentType}} with the PROVIDED copy. Check the seal and the signatures."`
+	ExpectedFields json.RawMessage `db:"expected_fields" json:"expected_fields" swaggertype:"array,string"`
+	CreatedBy      *int            `db:"created_by" json:"created_by,omitempty" example:"1"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at" example:"2024-01-01T12:00:00Z"`
+}
+
+// CreatePromptTemplateRequest represents request to add a prompt template version
+// @Description Request to add a new version of the company's prompt template for a document type. The body is a Go text template with the variables .DocumentType, .DocumentName, .DocumentSummary, .ExpectedFields and .Fields (the name, kind and value of each field recorded for the document) and the function join. The required JSON response format is appended automatically.
+type CreatePromptTemplateRequest struct {
+	DocumentType   string   `json:"document_type" binding:"max=100" example:"diploma"` // Empty for all document types
+	Body           string   `json:"body" binding:"required,max=20000" example:"Compare the ORIGINAL {{.DocumentType}} with the PROVIDED copy. Check the seal and the signatures."`
+	ExpectedFields []string `json:"expected_fields" binding:"max=50,dive,max=200" example:"graduate name,degree,graduation date"`
+}
+
 // CreateDocumentRequest represents request to create a document
-// @Description Request to create a new document
+// @Description Request to create a new document. Missing details are extracted from the file.
 type CreateDocumentRequest struct {
-	Type           string    `json:"type" binding:"required" example:"agreement"`
-	Name           string    `json:"name" binding:"required" example:"Employment Agreement"`
-	Summary        string    `json:"summary" binding:"required" example:"Standard employment agreement for full-time employees"`
-	ExpirationDate time.Time `json:"expiration_date" binding:"required" example:"2025-12-31T00:00:00Z"`
+	Type               string               `json:"type" example:"agreement"`
+	Name               string               `json:"name" example:"Employment Agreement"`
+	Summary            string               `json:"summary" example:"Standard employment agreement for full-time employees"`
+	ExpirationDate     time.Time            `json:"expiration_date" example:"2025-12-31T00:00:00Z"` // Zero when not given
+	PublicVerification bool                 `json:"public_verification" example:"false"`
+	Fields             []DocumentFieldValue `json:"fields"`         // Reviewed fields, stored instead of extracted ones
+	ExtractFields      bool                 `json:"extract_fields"` // Extract and store fields even if every detail is given
+}
+
+// DocumentFieldKind represents the type of value a document field holds
+type DocumentFieldKind string
+
+const (
+	DocumentFieldText       DocumentFieldKind = "text"
+	DocumentFieldDate       DocumentFieldKind = "date" // YYYY-MM-DD
+	DocumentFieldParty      DocumentFieldKind = "party"
+	DocumentFieldIdentifier DocumentFieldKind = "identifier"
+	DocumentFieldAmount     DocumentFieldKind = "amount"
+)
+
+// DocumentFieldSource represents how a document field was recorded
+type DocumentFieldSource string
+
+const (
+	DocumentFieldExtracted DocumentFieldSource = "extracted"
+	DocumentFieldManual    DocumentFieldSource = "manual"
+)
+
+// DocumentField represents a named value recorded for a document, e.g. a party or an identifier
+// @Description Named value recorded for a document. Comparisons check the fields and reference them in differences.
+type DocumentField struct {
+	ID         int                 `db:"id" json:"id" example:"1"`
+	DocumentID int                 `db:"document_id" json:"document_id" example:"1"`
+	Name       string              `db:"name" json:"name" example:"contract_number"`
+	Kind       DocumentFieldKind   `db:"kind" json:"kind" example:"identifier"`
+	Value      string              `db:"value" json:"value" example:"EA-2024-0042"`
+	Source     DocumentFieldSource `db:"source" json:"source" example:"extracted"`
+	CreatedAt  time.Time           `db:"created_at" json:"created_at" example:"2024-01-01T12:00:00Z"`
+}
+
+// DocumentFieldValue represents a document field to record
+// @Description Named value of a document. Names are lowercase snake_case, dates are formatted YYYY-MM-DD.
+type DocumentFieldValue struct {
+	Name  string            `json:"name" binding:"required,max=100" example:"contract_number"`
+	Kind  DocumentFieldKind `json:"kind" binding:"required,oneof=text date party identifier amount" example:"identifier"`
+	Value string            `json:"value" binding:"required,max=1000" example:"EA-2024-0042"`
+}
+
+// UpdateDocumentFieldsRequest represents request to replace the fields of a document
+// @Description Request to replace every recorded field of a document
+type UpdateDocumentFieldsRequest struct {
+	Fields []DocumentFieldValue `json:"fields" binding:"max=50,dive"`
+}
+
+// DocumentExtraction represents the details and fields proposed for an uploaded file
+// @Description Details and fields extracted from a file for the issuer to review before creating the document
+type DocumentExtraction struct {
+	Type           string               `json:"type" example:"agreement"`
+	Name           string               `json:"name" example:"Employment Agreement"`
+	Summary        string               `json:"summary" example:"Employment agreement between Acme Corp and Jane Smith"`
+	ExpirationDate *time.Time           `json:"expiration_date,omitempty" example:"2025-12-31T00:00:00Z"`
+	Fields         []DocumentFieldValue `json:"fields"`
+	Provider       string               `json:"provider" example:"gemini:gemini-1.5-flash"` // Analyzer that extracted the details
+	Usage          *TokenUsage          `json:"usage,omitempty"`                            // Tokens used by an AI provider, unset when decided locally
+}
+
+// UpdateDocumentRequest represents request to correct document details
+// @Description Request to update document details (all fields optional)
+type UpdateDocumentRequest struct {
+	Type           *string    `json:"type" example:"agreement"`
+	Name           *string    `json:"name" example:"Employment Agreement"`
+	Summary        *string    `json:"summary" example:"Standard employment agreement for full-time employees"`
+	ExpirationDate *time.Time `json:"expiration_date" example:"2026-12-31T00:00:00Z"`
+}
+
+// RevokeDocumentRequest represents request to revoke a document
+// @Description Request to revoke a document with a reason shown on verification
+type RevokeDocumentRequest struct {
+	Reason string `json:"reason" binding:"required" example:"Issued with wrong employee name"`
+}
+
+// SupersedeDocumentRequest represents request to replace a document with another one
+// @Description Request to mark a document as superseded by a replacement document
+type SupersedeDocumentRequest struct {
+	SuccessorID int `json:"successor_id" binding:"required" example:"2"`
+}
+
+// DocumentScanResponse represents the outcome of rescanning a document file
+// @Description Malware scan verdict for the stored document file and the resulting document state
+type DocumentScanResponse struct {
+	DocumentID int           `json:"document_id" example:"1"`
+	Clean      bool          `json:"clean" example:"false"`
+	Signature  string        `json:"signature,omitempty" example:"Eicar-Test-Signature"`
+	State      DocumentState `json:"state" example:"quarantined"`
+}
+
+// UpdatePublicVerificationRequest represents request to toggle public verification of a document
+// @Description Request to allow or forbid unauthenticated verification of a document
+type UpdatePublicVerificationRequest struct {
+	Enabled *bool `json:"enabled" binding:"required" example:"true"`
+}
+
+// QRCodeOptions represents rendering options for a document QR code
+type QRCodeOptions struct {
+	Format          string // png or svg
+	Size            int    // image width and height in pixels
+	ErrorCorrection string // L, M, Q or H
+}
+
+// CertifiedCopyOptions represents options for stamping a QR code onto the document PDF
+type CertifiedCopyOptions struct {
+	Page            string // first or last
+	Size            int    // QR code width and height in points
+	ErrorCorrection string // L, M, Q or H
+}
+
+// PublicDocumentView represents the redacted document details shown to third parties
+// @Description Redacted document details returned by public verification
+type PublicDocumentView struct {
+	Type           string         `json:"type" example:"agreement"`
+	Name           string         `json:"name" example:"Employment Agreement"`
+	ExpirationDate time.Time      `json:"expiration_date" example:"2025-12-31T00:00:00Z"`
+	IssuerName     string         `json:"issuer_name" example:"Acme Corp"`
+	Status         DocumentStatus `json:"status" example:"green"`
+	Message        string         `json:"message" example:"Document is valid"`
 }
 
 // CreateDocumentResponse represents response after creating a document
 // @Description Response containing the document hash for later retrieval
 type CreateDocumentResponse struct {
-	Hash string `json:"hash" example:"eyJpZCI6MSwidHlwZSI6ImFncmVlbWVudCIsIm5hbWUiOiJFbXBsb3ltZW50IEFncmVlbWVudCJ9"`
+	Hash string `json:"hash" example:"v1.k1.eyJpZCI6MSwiY29tcGFueV9pZCI6MSwidHlwZSI6ImFncmVlbWVudCIsIm5hbWUiOiJFbXBsb3ltZW50IEFncmVlbWVudCJ9.3q2-7wAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"`
 }
 
 // VerifyDocumentResponse represents response with document details and status
@@ -156,12 +477,17 @@ type DocumentHashPayload struct {
 	CompanyID int    `json:"company_id"`
 	Type      string `json:"type"`
 	Name      string `json:"name"`
+	Version   int    `json:"ver,omitempty"` // Absent in hashes issued before versioning, which refer to version 1
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Legacy    bool   `json:"-"` // Set when decoded from an unsigned pre-v1 hash
 }
 
 // DocumentDifference represents a specific difference found between documents
 // @Description Specific difference detected between original and provided document
 type DocumentDifference struct {
 	Location      string `json:"location" example:"Header section"`
+	Field         string `json:"field,omitempty" example:"employee"` // Recorded document field the difference concerns
+	Page          int    `json:"page,omitempty" example:"1"`         // Page of the document, set when photos were compared page by page
 	OriginalValue string `json:"original_value" example:"John Smith"`
 	ProvidedValue string `json:"provided_value" example:"John Smth"`
 	Severity      string `json:"severity" example:"moderate"`
@@ -179,12 +505,64 @@ type AnalysisFinding struct {
 // DocumentAnalysisResult represents the result of document comparison analysis
 // @Description Analysis result comparing uploaded document/photos with original
 type DocumentAnalysisResult struct {
-	Score       float64              `json:"score" example:"0.95"`
-	IsAuthentic bool                 `json:"is_authentic" example:"true"`
-	Confidence  string               `json:"confidence" example:"high"`
-	Differences []DocumentDifference `json:"differences,omitempty"`
-	Findings    []AnalysisFinding    `json:"findings,omitempty"`
-	Summary     string               `json:"summary" example:"Documents match with 95% confidence. Minor formatting differences detected."`
+	ID               int                  `json:"id,omitempty" example:"1"` // Stored analysis, set once the result was persisted
+	Score            float64              `json:"score" example:"0.95"`
+	IsAuthentic      bool                 `json:"is_authentic" example:"true"`
+	Confidence       string               `json:"confidence" example:"high"`
+	Differences      []DocumentDifference `json:"differences,omitempty"`
+	Findings         []AnalysisFinding    `json:"findings,omitempty"`
+	Summary          string               `json:"summary" example:"Documents match with 95% confidence. Minor formatting differences detected."`
+	Provider         string               `json:"provider" example:"gemini:gemini-1.5-flash"` // Analyzer that produced the result
+	PromptVersion    string               `json:"prompt_version,omitempty" example:"1"`       // Version of the prompt sent to an LLM provider
+	PromptTemplateID *int                 `json:"prompt_template_id,omitempty" example:"1"`   // Template the prompt was rendered from, unset for the built-in prompt
+	Prompt           string               `json:"-"`                                          // Rendered prompt, kept with the stored analysis
+	Repaired         bool                 `json:"repaired,omitempty" example:"false"`         // The provider's answer failed validation and was repaired; such results are never authentic
+	Pages            []PageAnalysis       `json:"pages,omitempty"`                            // Per-page results when photos were compared page by page
+	Cache            *AnalysisCacheInfo   `json:"cache,omitempty"`                            // Set when comparison caching is enabled
+	Usage            *TokenUsage          `json:"usage,omitempty"`                            // Tokens used by an AI provider, unset when decided locally or cached
+}
+
+// AnalysisCacheInfo tells whether an analysis result was served from the comparison cache
+// @Description Comparison cache metadata of an analysis result
+type AnalysisCacheInfo struct {
+	Hit       bool      `json:"hit" example:"true"`                        // The same evidence was compared before and the stored result was returned
+	CachedAt  time.Time `json:"cached_at" example:"2024-06-01T12:00:00Z"`  // When the result was computed
+	ExpiresAt time.Time `json:"expires_at" example:"2024-06-02T12:00:00Z"` // When the cached result is discarded
+}
+
+// CachedAnalysis is an analysis result kept in the comparison cache
+type CachedAnalysis struct {
+	Result   DocumentAnalysisResult `json:"result"`
+	Prompt   string                 `json:"prompt,omitempty"` // Rendered prompt, not part of the serialized result
+	CachedAt time.Time              `json:"cached_at"`
+}
+
+// AnalysisCacheInvalidationResponse represents the outcome of clearing the comparison cache of a document
+// @Description Number of cached comparison results removed for a document
+type AnalysisCacheInvalidationResponse struct {
+	DocumentID int `json:"document_id" example:"1"`
+	Deleted    int `json:"deleted" example:"3"`
+}
+
+// PageAnalysisStatus tells whether a page of a document was compared
+type PageAnalysisStatus string
+
+const (
+	PageAnalysisCompared PageAnalysisStatus = "compared"
+	PageAnalysisMissing  PageAnalysisStatus = "missing" // No photo of the page was provided
+	PageAnalysisExtra    PageAnalysisStatus = "extra"   // Photos were provided for a page the document does not have
+)
+
+// PageAnalysis represents the comparison of one page of a document with the photos showing it
+// @Description Result of comparing one page of the original document
+type PageAnalysis struct {
+	Page        int                `json:"page" example:"1"`
+	Status      PageAnalysisStatus `json:"status" example:"compared"`
+	Photos      []int              `json:"photos,omitempty" example:"1"` // Numbers of the photos showing the page, in submission order
+	Score       float64            `json:"score" example:"0.95"`
+	IsAuthentic bool               `json:"is_authentic" example:"true"`
+	Confidence  string             `json:"confidence,omitempty" example:"high"`
+	Summary     string             `json:"summary,omitempty" example:"Page matches the original."`
 }
 
 // CompareDocumentResponse represents the response for document comparison
@@ -195,3 +573,156 @@ type CompareDocumentResponse struct {
 	Document *Document               `json:"document"`
 	Analysis *DocumentAnalysisResult `json:"analysis,omitempty"`
 }
+
+// ComparisonJobKind is the kind of files submitted for an asynchronous comparison
+type ComparisonJobKind string
+
+const (
+	ComparisonJobPhotos ComparisonJobKind = "photos"
+	ComparisonJobPDF    ComparisonJobKind = "pdf"
+)
+
+// ComparisonJobStatus represents the progress of a comparison job
+type ComparisonJobStatus string
+
+const (
+	ComparisonJobQueued    ComparisonJobStatus = "queued"
+	ComparisonJobRunning   ComparisonJobStatus = "running"
+	ComparisonJobSucceeded ComparisonJobStatus = "succeeded"
+	ComparisonJobFailed    ComparisonJobStatus = "failed"
+)
+
+// ComparisonJobInput is a submitted file kept in the job store until the job finished
+type ComparisonJobInput struct {
+	FileKey     string `json:"file_key"`
+	ContentType string `json:"content_type"`
+	Page        int    `json:"page,omitempty"` // Page of the document a photo shows, 0 if not given
+}
+
+// ComparisonJob is an asynchronous comparison of a document with submitted photos or a PDF
+type ComparisonJob struct {
+	ID                 string              `db:"id"`
+	CompanyID          int                 `db:"company_id"`
+	UserID             int                 `db:"user_id"`
+	Kind               ComparisonJobKind   `db:"kind"`
+	Status             ComparisonJobStatus `db:"status"`
+	DocumentHash       string              `db:"document_hash"`
+	Inputs             string              `db:"inputs"` // JSON encoded []ComparisonJobInput
+	DocumentID         *int                `db:"document_id"`
+	HistoryID          *int                `db:"history_id"`
+	Verification       *string             `db:"verification"` // JSON encoded CompareDocumentResponse without analysis
+	Attempts           int                 `db:"attempts"`
+	Result             *string             `db:"result"` // JSON encoded CompareDocumentResponse, set when succeeded
+	Error              *string             `db:"error"`
+	WebhookURL         *string             `db:"webhook_url"`
+	WebhookDeliveredAt *time.Time          `db:"webhook_delivered_at"`
+	CreatedAt          time.Time           `db:"created_at"`
+	StartedAt          *time.Time          `db:"started_at"`
+	FinishedAt         *time.Time          `db:"finished_at"`
+}
+
+// ComparisonJobResponse represents a comparison job and, once it succeeded, its result
+// @Description Asynchronous comparison job, polled until its status is succeeded or failed
+type ComparisonJobResponse struct {
+	ID         string                   `json:"id" example:"3f2a9c1e5b7d4e8f9a0b1c2d3e4f5a6b"`
+	Kind       ComparisonJobKind        `json:"kind" example:"pdf"`
+	Status     ComparisonJobStatus      `json:"status" example:"succeeded"`
+	Attempts   int                      `json:"attempts" example:"1"`
+	Result     *CompareDocumentResponse `json:"result,omitempty"`
+	Error      string                   `json:"error,omitempty" example:""`
+	CreatedAt  time.Time                `json:"created_at" example:"2024-01-01T12:00:00Z"`
+	StartedAt  *time.Time               `json:"started_at,omitempty" example:"2024-01-01T12:00:01Z"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty" example:"2024-01-01T12:00:09Z"`
+}
+
+// TokenUsage counts the tokens an AI provider billed for one comparison or extraction
+// @Description Tokens billed by the AI provider
+type TokenUsage struct {
+	Requests     int `json:"requests" example:"1"` // Requests sent, more than one when answers were requested again
+	InputTokens  int `json:"input_tokens" example:"2580"`
+	OutputTokens int `json:"output_tokens" example:"412"` // Includes the tokens models spent thinking
+	TotalTokens  int `json:"total_tokens" example:"2992"`
+}
+
+// Add counts the tokens of u into t
+func (t *TokenUsage) Add(u TokenUsage) {
+	t.Requests += u.Requests
+	t.InputTokens += u.InputTokens
+	t.OutputTokens += u.OutputTokens
+	t.TotalTokens += u.TotalTokens
+}
+
+// UsageOperation names what an AI provider was asked for
+type UsageOperation string
+
+const (
+	UsageOperationComparison UsageOperation = "comparison"
+	UsageOperationExtraction UsageOperation = "extraction"
+)
+
+// UsageRecord is the token usage of one comparison or extraction, kept for billing
+type UsageRecord struct {
+	ID           int            `db:"id"`
+	CompanyID    int            `db:"company_id"`
+	UserID       *int           `db:"user_id"`
+	DocumentID   *int           `db:"document_id"` // Unset for extractions of documents not created yet
+	Operation    UsageOperation `db:"operation"`
+	Provider     string         `db:"provider"`
+	Model        string         `db:"model"`
+	Requests     int            `db:"requests"`
+	InputTokens  int            `db:"input_tokens"`
+	OutputTokens int            `db:"output_tokens"`
+	TotalTokens  int            `db:"total_tokens"`
+	Cost         float64        `db:"cost"` // In the currency of the configured prices
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+// UsagePeriod is the length of the periods usage is aggregated by
+type UsagePeriod string
+
+const (
+	UsagePeriodDay   UsagePeriod = "day"
+	UsagePeriodMonth UsagePeriod = "month"
+)
+
+// UsageFilter selects the usage records to aggregate
+type UsageFilter struct {
+	Period     UsagePeriod
+	From       time.Time // Inclusive
+	To         time.Time // Exclusive
+	UserID     int       // 0 for all users
+	DocumentID int       // 0 for all documents
+}
+
+// UsageSummary represents the usage aggregated over a period
+// @Description AI usage aggregated over a period
+type UsageSummary struct {
+	PeriodStart  time.Time `db:"period_start" json:"period_start" example:"2024-06-01T00:00:00Z"`
+	Comparisons  int64     `db:"comparisons" json:"comparisons" example:"42"`
+	Extractions  int64     `db:"extractions" json:"extractions" example:"5"`
+	Requests     int64     `db:"requests" json:"requests" example:"49"`
+	InputTokens  int64     `db:"input_tokens" json:"input_tokens" example:"108360"`
+	OutputTokens int64     `db:"output_tokens" json:"output_tokens" example:"17304"`
+	TotalTokens  int64     `db:"total_tokens" json:"total_tokens" example:"125664"`
+	Cost         float64   `db:"cost" json:"cost" example:"0.0133"`
+}
+
+// UsageQuota represents the monthly token quota of a company
+// @Description Monthly AI token quota of the company and how much of it is used
+type UsageQuota struct {
+	MonthlyTokens   int64     `json:"monthly_tokens" example:"1000000"` // 0 if usage is not limited
+	UsedTokens      int64     `json:"used_tokens" example:"125664"`
+	RemainingTokens *int64    `json:"remaining_tokens,omitempty" example:"874336"` // Unset if usage is not limited
+	ResetsAt        time.Time `json:"resets_at" example:"2024-07-01T00:00:00Z"`
+}
+
+// UsageReport represents the AI usage of a company
+// @Description AI usage of the company aggregated by day or month, with its monthly quota
+type UsageReport struct {
+	Period  UsagePeriod    `json:"period" example:"day"`
+	From    time.Time      `json:"from" example:"2024-06-01T00:00:00Z"`
+	To      time.Time      `json:"to" example:"2024-07-01T00:00:00Z"` // Exclusive
+	Total   UsageSummary   `json:"total"`
+	Periods []UsageSummary `json:"periods"` // Periods without usage are left out
+	Quota   UsageQuota     `json:"quota"`
+}
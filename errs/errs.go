@@ -17,6 +17,12 @@ const (
 	ErrorTypeNotFound      ErrorType = "NOT_FOUND"
 	ErrorTypeUnauthorized  ErrorType = "UNAUTHORIZED"
 	ErrorTypeAlreadyExists ErrorType = "ALREADY_EXISTS"
+	ErrorTypeRateLimited   ErrorType = "RATE_LIMITED"
+	ErrorTypeFileRejected  ErrorType = "FILE_REJECTED"
+	ErrorTypeUnavailable   ErrorType = "UNAVAILABLE"
+	ErrorTypeQuotaExceeded ErrorType = "QUOTA_EXCEEDED"
+	ErrorTypeTokenExpired  ErrorType = "TOKEN_EXPIRED"
+	ErrorTypeTokenReused   ErrorType = "TOKEN_REUSED"
 )
 
 // Error represents an API error response
@@ -42,10 +48,18 @@ func (e Error) StatusCode() int {
 		return http.StatusBadRequest
 	case ErrorTypeNotFound:
 		return http.StatusNotFound
-	case ErrorTypeUnauthorized:
+	case ErrorTypeUnauthorized, ErrorTypeTokenExpired, ErrorTypeTokenReused:
 		return http.StatusUnauthorized
 	case ErrorTypeAlreadyExists:
 		return http.StatusConflict
+	case ErrorTypeRateLimited:
+		return http.StatusTooManyRequests
+	case ErrorTypeFileRejected:
+		return http.StatusUnprocessableEntity
+	case ErrorTypeUnavailable:
+		return http.StatusServiceUnavailable
+	case ErrorTypeQuotaExceeded:
+		return http.StatusPaymentRequired
 	default:
 		return http.StatusInternalServerError
 	}
@@ -59,10 +73,18 @@ func (e Error) GrpcStatusCode() codes.Code {
 		return codes.InvalidArgument
 	case ErrorTypeNotFound:
 		return codes.NotFound
-	case ErrorTypeUnauthorized:
+	case ErrorTypeUnauthorized, ErrorTypeTokenExpired, ErrorTypeTokenReused:
 		return codes.Unauthenticated
 	case ErrorTypeAlreadyExists:
 		return codes.AlreadyExists
+	case ErrorTypeRateLimited:
+		return codes.ResourceExhausted
+	case ErrorTypeFileRejected:
+		return codes.FailedPrecondition
+	case ErrorTypeUnavailable:
+		return codes.Unavailable
+	case ErrorTypeQuotaExceeded:
+		return codes.ResourceExhausted
 	default:
 		return codes.Internal
 	}
@@ -100,6 +122,30 @@ func AlreadyExistsError(item string, err error) Error {
 	return New(ErrorTypeAlreadyExists, fmt.Sprintf("%s already exists", item), err)
 }
 
+func RateLimitedError(message string, err error) Error {
+	return New(ErrorTypeRateLimited, message, err)
+}
+
+func FileRejectedError(message string, err error) Error {
+	return New(ErrorTypeFileRejected, message, err)
+}
+
+func UnavailableError(message string, err error) Error {
+	return New(ErrorTypeUnavailable, message, err)
+}
+
+func QuotaExceededError(message string, err error) Error {
+	return New(ErrorTypeQuotaExceeded, message, err)
+}
+
+func TokenExpiredError(message string, err error) Error {
+	return New(ErrorTypeTokenExpired, message, err)
+}
+
+func TokenReusedError(message string, err error) Error {
+	return New(ErrorTypeTokenReused, message, err)
+}
+
 func IsErrorType(err error, errType ErrorType) (bool, Error) {
 	var e Error
 	if errors.As(err, &e) && e.Type == errType {
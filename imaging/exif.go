@@ -0,0 +1,92 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	exifTagOrientation = 0x0112
+	exifTagGPSInfo     = 0x8825
+)
+
+// exifInfo holds the EXIF tags of a JPEG file that matter for processing
+type exifInfo struct {
+	Orientation int  // 1 to 8, 1 if absent
+	HasGPS      bool // the file records where the photo was taken
+}
+
+// readJPEGExif reads the first IFD of the EXIF segment of a JPEG file. Missing or malformed EXIF
+// data yields the defaults.
+func readJPEGExif(data []byte) exifInfo {
+	info := exifInfo{Orientation: 1}
+	tiff := jpegExifSegment(data)
+	if len(tiff) < 8 {
+		return info
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return info
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return info
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := range count {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		switch order.Uint16(tiff[entry:]) {
+		case exifTagOrientation:
+			// SHORT value stored inline
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				info.Orientation = o
+			}
+		case exifTagGPSInfo:
+			info.HasGPS = true
+		}
+	}
+	return info
+}
+
+// jpegExifSegment returns the TIFF data of the APP1 "Exif" segment, or nil
+func jpegExifSegment(data []byte) []byte {
+	if !bytes.HasPrefix(data, []byte("\xff\xd8")) {
+		return nil
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xff {
+			return nil
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			// fill byte
+			pos++
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			// start of scan or end of image, metadata segments come before
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		pos = end
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// exifEntry is an IFD entry with a value stored inline
+type exifEntry struct {
+	tag   uint16
+	value uint16
+}
+
+// tiffData returns TIFF data with the entries in its first IFD
+func tiffData(order binary.AppendByteOrder, entries ...exifEntry) []byte {
+	tiff := []byte("II*\x00")
+	if order == binary.BigEndian {
+		tiff = []byte("MM\x00*")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, uint16(len(entries)))
+	for _, e := range entries {
+		tiff = order.AppendUint16(tiff, e.tag)
+		tiff = order.AppendUint16(tiff, 3) // SHORT
+		tiff = order.AppendUint32(tiff, 1)
+		tiff = order.AppendUint16(tiff, e.value)
+		tiff = order.AppendUint16(tiff, 0)
+	}
+	return order.AppendUint32(tiff, 0)
+}
+
+// segment returns a JPEG marker segment holding payload
+func segment(marker byte, payload []byte) []byte {
+	seg := []byte{0xff, marker}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// exifSegment returns an APP1 segment holding the TIFF data
+func exifSegment(tiff []byte) []byte {
+	return segment(0xe1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+// jpegHead returns the start of a JPEG file made of the segments, up to its start of scan
+func jpegHead(segments ...[]byte) []byte {
+	data := []byte{0xff, 0xd8}
+	for _, seg := range segments {
+		data = append(data, seg...)
+	}
+	return append(data, 0xff, 0xda, 0x00, 0x02)
+}
+
+func TestReadJPEGExif(t *testing.T) {
+	jfif := segment(0xe0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	rotated := tiffData(binary.LittleEndian, exifEntry{exifTagOrientation, 6})
+	located := tiffData(binary.BigEndian, exifEntry{0x010f, 0}, exifEntry{exifTagOrientation, 8}, exifEntry{exifTagGPSInfo, 0})
+
+	// An IFD whose entry count runs past the data, holding a valid first entry
+	overcounted := tiffData(binary.LittleEndian, exifEntry{exifTagOrientation, 3})
+	binary.LittleEndian.PutUint16(overcounted[8:], 1000)
+
+	// An IFD offset beyond the data
+	badOffset := tiffData(binary.LittleEndian, exifEntry{exifTagOrientation, 6})
+	binary.LittleEndian.PutUint32(badOffset[4:], 4000)
+
+	// An IFD offset inside the header
+	headerOffset := tiffData(binary.BigEndian, exifEntry{exifTagOrientation, 6})
+	binary.BigEndian.PutUint32(headerOffset[4:], 2)
+
+	// A segment length beyond the data
+	truncatedSegment := exifSegment(rotated)
+	binary.BigEndian.PutUint16(truncatedSegment[2:], 0xfff0)
+
+	tests := []struct {
+		name string
+		data []byte
+		want exifInfo
+	}{
+		{"little endian", jpegHead(jfif, exifSegment(rotated)), exifInfo{Orientation: 6}},
+		{"big endian with GPS", jpegHead(exifSegment(located)), exifInfo{Orientation: 8, HasGPS: true}},
+		{"fill bytes", jpegHead([]byte{0xff, 0xff}, exifSegment(rotated)), exifInfo{Orientation: 6}},
+		{"no exif", jpegHead(jfif), exifInfo{Orientation: 1}},
+		{"other APP1 segment", jpegHead(segment(0xe1, []byte("http://ns.adobe.com/xap/1.0/\x00"))), exifInfo{Orientation: 1}},
+		{"orientation out of range", jpegHead(exifSegment(tiffData(binary.LittleEndian, exifEntry{exifTagOrientation, 9}))), exifInfo{Orientation: 1}},
+		{"orientation zero", jpegHead(exifSegment(tiffData(binary.LittleEndian, exifEntry{exifTagOrientation, 0}))), exifInfo{Orientation: 1}},
+		{"entry count beyond the data", jpegHead(exifSegment(overcounted)), exifInfo{Orientation: 3}},
+		{"IFD offset beyond the data", jpegHead(exifSegment(badOffset)), exifInfo{Orientation: 1}},
+		{"IFD offset in the header", jpegHead(exifSegment(headerOffset)), exifInfo{Orientation: 1}},
+		{"invalid byte order", jpegHead(exifSegment(append([]byte("XX*\x00"), rotated[4:]...))), exifInfo{Orientation: 1}},
+		{"truncated TIFF header", jpegHead(exifSegment(rotated[:6])), exifInfo{Orientation: 1}},
+		{"truncated segment", jpegHead(truncatedSegment), exifInfo{Orientation: 1}},
+		{"truncated file", jpegHead(exifSegment(rotated))[:20], exifInfo{Orientation: 1}},
+		{"segment length too short", jpegHead([]byte{0xff, 0xe1, 0x00, 0x01}, exifSegment(rotated)), exifInfo{Orientation: 1}},
+		{"garbage between segments", jpegHead(jfif, []byte{0x00}, exifSegment(rotated)), exifInfo{Orientation: 1}},
+		{"exif after start of scan", append(jpegHead(jfif), exifSegment(rotated)...), exifInfo{Orientation: 1}},
+		{"not a jpeg", exifSegment(rotated), exifInfo{Orientation: 1}},
+		{"empty", nil, exifInfo{Orientation: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readJPEGExif(tt.data); got != tt.want {
+				t.Errorf("readJPEGExif = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadJPEGExifTruncations(t *testing.T) {
+	// No prefix of a file may be read out of bounds
+	data := jpegHead(exifSegment(tiffData(binary.BigEndian, exifEntry{exifTagOrientation, 6}, exifEntry{exifTagGPSInfo, 0})))
+	for n := range len(data) {
+		readJPEGExif(data[:n])
+	}
+}
@@ -0,0 +1,68 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// MIME types of the image formats recognised by DetectFormat
+const (
+	FormatJPEG = "image/jpeg"
+	FormatPNG  = "image/png"
+	FormatGIF  = "image/gif"
+	FormatWEBP = "image/webp"
+	FormatHEIC = "image/heic"
+	FormatAVIF = "image/avif"
+)
+
+// heifBrands are the ISO base media file brands of HEIF images, as written by phone cameras
+var heifBrands = map[string]string{
+	"heic": FormatHEIC,
+	"heix": FormatHEIC,
+	"heim": FormatHEIC,
+	"heis": FormatHEIC,
+	"hevc": FormatHEIC,
+	"hevx": FormatHEIC,
+	"mif1": FormatHEIC,
+	"msf1": FormatHEIC,
+	"avif": FormatAVIF,
+	"avis": FormatAVIF,
+}
+
+// DetectFormat returns the MIME type of an image from its signature, or "" if data is not an image
+// in a recognised format
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWEBP
+	}
+	return heifFormat(data)
+}
+
+// heifFormat reads the major and compatible brands of the "ftyp" box that starts HEIF files
+func heifFormat(data []byte) string {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return ""
+	}
+	size := int(binary.BigEndian.Uint32(data[:4]))
+	if size < 16 || size > len(data) {
+		size = min(len(data), 64)
+	}
+	// Major brand at 8, minor version at 12, compatible brands from 16
+	brands := [][]byte{data[8:12]}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, data[i:i+4])
+	}
+	for _, brand := range brands {
+		if format, ok := heifBrands[string(brand)]; ok {
+			return format
+		}
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// ftyp returns the "ftyp" box starting an ISO base media file with the brands
+func ftyp(major string, compatible ...string) []byte {
+	box := binary.BigEndian.AppendUint32(nil, uint32(16+4*len(compatible)))
+	box = append(box, "ftyp"+major+"\x00\x00\x00\x00"...)
+	for _, brand := range compatible {
+		box = append(box, brand...)
+	}
+	return box
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), FormatJPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), FormatPNG},
+		{"gif87a", []byte("GIF87a\x01\x00"), FormatGIF},
+		{"gif89a", []byte("GIF89a\x01\x00"), FormatGIF},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), FormatWEBP},
+		{"heic", ftyp("heic", "mif1", "heic"), FormatHEIC},
+		{"heix", ftyp("heix", "mif1", "heix"), FormatHEIC},
+		{"heif image sequence", ftyp("hevc", "msf1", "hevc"), FormatHEIC},
+		{"heif", ftyp("mif1", "mif1", "miaf"), FormatHEIC},
+		{"heic compatible brand", ftyp("miaf", "MiHE", "heic"), FormatHEIC},
+		{"avif", ftyp("avif", "avif", "mif1", "miaf"), FormatAVIF},
+		{"avif sequence", ftyp("avis", "avis", "msf1"), FormatAVIF},
+		{"avif compatible brand", ftyp("MA1B", "avif"), FormatAVIF},
+		{"heic with trailing boxes", append(ftyp("heic", "mif1"), "\x00\x00\x00\x08meta"...), FormatHEIC},
+		{"box size beyond the data", append([]byte("\x00\x00\x10\x00ftypMA1B\x00\x00\x00\x00"), "avif"...), FormatAVIF},
+		{"brand after the box", append(ftyp("MA1B"), "avif"...), ""},
+		{"mp4 video", ftyp("isom", "isom", "iso2", "mp41"), ""},
+		{"quicktime video", ftyp("qt  ", "qt  "), ""},
+		{"truncated ftyp", ftyp("heic")[:12], ""},
+		{"wave audio", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ""},
+		{"truncated jpeg signature", []byte("\xff\xd8"), ""},
+		{"pdf", []byte("%PDF-1.7"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.data); got != tt.want {
+				t.Errorf("DetectFormat(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// grayImage returns an image with the gray levels of rows
+func grayImage(rows [][]uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, v := range row {
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+// grayLevels returns the gray levels of img by row
+func grayLevels(img image.Image) [][]uint8 {
+	b := img.Bounds()
+	rows := make([][]uint8, b.Dy())
+	for y := range rows {
+		rows[y] = make([]uint8, b.Dx())
+		for x := range rows[y] {
+			rows[y][x] = color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return rows
+}
+
+func TestOrient(t *testing.T) {
+	upright := [][]uint8{
+		{1, 2, 3},
+		{4, 5, 6},
+	}
+	// How a camera stores the upright image with each orientation
+	tests := []struct {
+		orientation int
+		stored      [][]uint8
+	}{
+		{1, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{2, [][]uint8{{3, 2, 1}, {6, 5, 4}}},
+		{3, [][]uint8{{6, 5, 4}, {3, 2, 1}}},
+		{4, [][]uint8{{4, 5, 6}, {1, 2, 3}}},
+		{5, [][]uint8{{1, 4}, {2, 5}, {3, 6}}},
+		{6, [][]uint8{{3, 6}, {2, 5}, {1, 4}}},
+		{7, [][]uint8{{6, 3}, {5, 2}, {4, 1}}},
+		{8, [][]uint8{{4, 1}, {5, 2}, {6, 3}}},
+		// Invalid orientations are ignored
+		{0, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{9, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+	}
+	for _, tt := range tests {
+		got := grayLevels(orient(grayImage(tt.stored), tt.orientation))
+		if !reflect.DeepEqual(got, upright) {
+			t.Errorf("orient(%v, %d) = %v, want %v", tt.stored, tt.orientation, got, upright)
+		}
+	}
+}
+
+func TestOrientOffsetBounds(t *testing.T) {
+	// Images need not start at the origin
+	img := grayImage([][]uint8{{0, 0, 0}, {0, 3, 6}, {0, 2, 5}, {0, 1, 4}}).SubImage(image.Rect(1, 1, 3, 4))
+	got := orient(img, 6)
+	if b := got.Bounds(); b != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("orient bounds = %v, want %v", b, image.Rect(0, 0, 3, 2))
+	}
+	if want := [][]uint8{{1, 2, 3}, {4, 5, 6}}; !reflect.DeepEqual(grayLevels(got), want) {
+		t.Errorf("orient = %v, want %v", grayLevels(got), want)
+	}
+}
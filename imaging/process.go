@@ -0,0 +1,163 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	// MaxPixels bounds the decoded size of a photo to guard against decompression bombs
+	MaxPixels = 50_000_000
+	// jpegQuality keeps printed text sharp while shrinking camera photos
+	jpegQuality = 90
+)
+
+var (
+	// ErrUnsupportedFormat is returned for data that is not a JPEG, PNG, GIF or WEBP image
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrHEIF is returned for HEIC and AVIF photos, which cannot be decoded
+	ErrHEIF = errors.New("HEIC and AVIF images are not supported")
+	// ErrInvalidImage is returned when the image cannot be decoded
+	ErrInvalidImage = errors.New("invalid image")
+	// ErrTooLarge is returned for images over MaxPixels
+	ErrTooLarge = errors.New("image resolution is too large")
+	// ErrTooDark is returned for photos darker than Options.MinBrightness
+	ErrTooDark = errors.New("photo is too dark")
+	// ErrTooBlurry is returned for photos less sharp than Options.MinSharpness
+	ErrTooBlurry = errors.New("photo is too blurry")
+)
+
+// Options configure Process
+type Options struct {
+	MaxDimension  int     // longest side of processed photos in pixels, 0 keeps the resolution
+	MinBrightness float64 // photos with a lower mean luminance are rejected, 0 disables the check
+	MinSharpness  float64 // photos with a lower Laplacian variance are rejected, 0 disables the check
+}
+
+// Photo is a photo prepared to be sent for comparison
+type Photo struct {
+	Data        []byte
+	MimeType    string
+	Width       int
+	Height      int
+	Quality     Quality
+	Orientation int  // EXIF orientation that was corrected, 1 if the photo was upright
+	Downscaled  bool // the photo was larger than Options.MaxDimension
+	GPSStripped bool // the original recorded where it was taken
+}
+
+// Process decodes a photo, turns it upright according to its EXIF orientation, scales it down to
+// opts.MaxDimension and checks that it is bright and sharp enough to be compared. The photo is
+// re-encoded, which drops all of its metadata, including GPS coordinates. PNG photos stay PNG,
+// other formats are encoded as JPEG.
+func Process(data []byte, opts Options) (*Photo, error) {
+	format := DetectFormat(data)
+	switch format {
+	case FormatHEIC, FormatAVIF:
+		return nil, ErrHEIF
+	case "":
+		return nil, ErrUnsupportedFormat
+	}
+
+	config, err := decodeConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, err := decode(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	photo := &Photo{Orientation: 1}
+	if format == FormatJPEG {
+		exif := readJPEGExif(data)
+		photo.Orientation = exif.Orientation
+		photo.GPSStripped = exif.HasGPS
+		img = orient(img, exif.Orientation)
+	}
+
+	photo.Quality = measureQuality(img)
+	if opts.MinBrightness > 0 && photo.Quality.Brightness < opts.MinBrightness {
+		return nil, ErrTooDark
+	}
+	if opts.MinSharpness > 0 && photo.Quality.Sharpness < opts.MinSharpness {
+		return nil, ErrTooBlurry
+	}
+
+	if opts.MaxDimension > 0 {
+		img, photo.Downscaled = downscale(img, opts.MaxDimension)
+	}
+	photo.Width, photo.Height = img.Bounds().Dx(), img.Bounds().Dy()
+
+	var buf bytes.Buffer
+	if format == FormatPNG {
+		photo.MimeType = FormatPNG
+		err = png.Encode(&buf, img)
+	} else {
+		photo.MimeType = FormatJPEG
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encoding photo: %w", err)
+	}
+	photo.Data = buf.Bytes()
+	return photo, nil
+}
+
+func decodeConfig(data []byte, format string) (image.Config, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case FormatJPEG:
+		return jpeg.DecodeConfig(r)
+	case FormatPNG:
+		return png.DecodeConfig(r)
+	case FormatGIF:
+		return gif.DecodeConfig(r)
+	default:
+		return webp.DecodeConfig(r)
+	}
+}
+
+func decode(data []byte, format string) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case FormatJPEG:
+		return jpeg.Decode(r)
+	case FormatPNG:
+		return png.Decode(r)
+	case FormatGIF:
+		return gif.Decode(r)
+	default:
+		return webp.Decode(r)
+	}
+}
+
+// downscale shrinks img so that its longest side is at most maxDimension
+func downscale(img image.Image, maxDimension int) (image.Image, bool) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	longest := max(w, h)
+	if longest <= maxDimension {
+		return img, false
+	}
+	w = max(1, w*maxDimension/longest)
+	h = max(1, h*maxDimension/longest)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst, true
+}
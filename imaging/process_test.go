@@ -0,0 +1,174 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// checkerboard returns a sharp image of black and white squares
+func checkerboard(w, h, square int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			if (x/square+y/square)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+// uniform returns an image of a single gray level
+func uniform(w, h int, level uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = level
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encoding JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an EXIF segment holding the TIFF data after the start of a JPEG file
+func withExif(data, tiff []byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	out = append(out, exifSegment(tiff)...)
+	return append(out, data[2:]...)
+}
+
+// hugePNG returns a small PNG file whose header claims a resolution of width by height
+func hugePNG(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	data := encodePNG(t, uniform(1, 1, 128))
+	// The IHDR chunk follows the signature: length, type, width, height, ..., CRC of type and data
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestProcess(t *testing.T) {
+	sharp := checkerboard(400, 200, 8)
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, sharp, nil); err != nil {
+		t.Fatalf("encoding GIF: %v", err)
+	}
+	located := tiffData(binary.LittleEndian, exifEntry{exifTagOrientation, 6}, exifEntry{exifTagGPSInfo, 0})
+
+	tests := []struct {
+		name            string
+		data            []byte
+		opts            Options
+		wantMimeType    string
+		wantWidth       int
+		wantHeight      int
+		wantOrientation int
+		wantDownscaled  bool
+		wantGPSStripped bool
+	}{
+		{"png", encodePNG(t, sharp), Options{}, FormatPNG, 400, 200, 1, false, false},
+		{"jpeg", encodeJPEG(t, sharp), Options{}, FormatJPEG, 400, 200, 1, false, false},
+		{"gif", gifData.Bytes(), Options{}, FormatJPEG, 400, 200, 1, false, false},
+		{"rotated jpeg with GPS", withExif(encodeJPEG(t, sharp), located), Options{}, FormatJPEG, 200, 400, 6, false, true},
+		{"downscaled", encodePNG(t, sharp), Options{MaxDimension: 100}, FormatPNG, 100, 50, 1, true, false},
+		{"downscaled after rotation", withExif(encodeJPEG(t, sharp), located), Options{MaxDimension: 100}, FormatJPEG, 50, 100, 6, true, true},
+		{"within the limits", encodePNG(t, sharp), Options{MaxDimension: 400, MinBrightness: 50, MinSharpness: 100}, FormatPNG, 400, 200, 1, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			photo, err := Process(tt.data, tt.opts)
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if photo.MimeType != tt.wantMimeType || photo.Width != tt.wantWidth || photo.Height != tt.wantHeight {
+				t.Errorf("Process = %s %dx%d, want %s %dx%d", photo.MimeType, photo.Width, photo.Height, tt.wantMimeType, tt.wantWidth, tt.wantHeight)
+			}
+			if photo.Orientation != tt.wantOrientation || photo.Downscaled != tt.wantDownscaled || photo.GPSStripped != tt.wantGPSStripped {
+				t.Errorf("Process = orientation %d, downscaled %v, GPS stripped %v; want %d, %v, %v",
+					photo.Orientation, photo.Downscaled, photo.GPSStripped, tt.wantOrientation, tt.wantDownscaled, tt.wantGPSStripped)
+			}
+
+			if got := DetectFormat(photo.Data); got != tt.wantMimeType {
+				t.Fatalf("processed photo format = %q, want %q", got, tt.wantMimeType)
+			}
+			config, _, err := image.DecodeConfig(bytes.NewReader(photo.Data))
+			if err != nil || config.Width != tt.wantWidth || config.Height != tt.wantHeight {
+				t.Errorf("processed photo = %dx%d, %v; want %dx%d", config.Width, config.Height, err, tt.wantWidth, tt.wantHeight)
+			}
+			// Re-encoding drops the metadata
+			if exif := readJPEGExif(photo.Data); exif.Orientation != 1 || exif.HasGPS {
+				t.Errorf("processed photo EXIF = %+v, want none", exif)
+			}
+		})
+	}
+}
+
+func TestProcessRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    Options
+		wantErr error
+	}{
+		{"too dark", encodePNG(t, uniform(200, 200, 10)), Options{MinBrightness: 40}, ErrTooDark},
+		{"dark jpeg", encodeJPEG(t, uniform(200, 200, 10)), Options{MinBrightness: 40, MinSharpness: 100}, ErrTooDark},
+		{"too blurry", encodePNG(t, uniform(200, 200, 128)), Options{MinBrightness: 40, MinSharpness: 100}, ErrTooBlurry},
+		{"blurry jpeg", encodeJPEG(t, uniform(200, 200, 128)), Options{MinSharpness: 100}, ErrTooBlurry},
+		{"too large", hugePNG(t, 10_000, 10_000), Options{}, ErrTooLarge},
+		{"too large for its width", hugePNG(t, 1<<30, 1), Options{}, ErrTooLarge},
+		{"heic", append(ftyp("heic", "mif1", "heic"), make([]byte, 64)...), Options{}, ErrHEIF},
+		{"avif", append(ftyp("avif", "avif", "mif1"), make([]byte, 64)...), Options{}, ErrHEIF},
+		{"not an image", []byte("%PDF-1.7"), Options{}, ErrUnsupportedFormat},
+		{"empty", nil, Options{}, ErrUnsupportedFormat},
+		{"truncated png", encodePNG(t, checkerboard(64, 64, 8))[:60], Options{}, ErrInvalidImage},
+		{"truncated jpeg header", []byte("\xff\xd8\xff\xe0\x00"), Options{}, ErrInvalidImage},
+		{"zero width", hugePNG(t, 0, 10), Options{}, ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			photo, err := Process(tt.data, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Process = %+v, %v; want %v", photo, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMeasureQuality(t *testing.T) {
+	dark, bright := measureQuality(uniform(100, 100, 20)), measureQuality(uniform(100, 100, 230))
+	if dark.Brightness != 20 || bright.Brightness != 230 {
+		t.Errorf("brightness = %v and %v, want 20 and 230", dark.Brightness, bright.Brightness)
+	}
+	if dark.Sharpness != 0 {
+		t.Errorf("sharpness of a uniform image = %v, want 0", dark.Sharpness)
+	}
+	// Sharpness does not depend on the resolution of a photo
+	small, large := measureQuality(checkerboard(256, 256, 16)), measureQuality(checkerboard(2048, 2048, 128))
+	if small.Sharpness <= 1000 || large.Sharpness <= 1000 {
+		t.Errorf("sharpness of checkerboards = %v and %v, want both sharp", small.Sharpness, large.Sharpness)
+	}
+}
@@ -0,0 +1,54 @@
+package imaging
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// qualitySampleSize is the longest side photos are scaled to before measuring their quality, so that
+// scores do not depend on the camera resolution
+const qualitySampleSize = 512
+
+// Quality scores how usable a photo is for comparison
+type Quality struct {
+	Brightness float64 `json:"brightness"` // mean luminance, 0 (black) to 255 (white)
+	Sharpness  float64 `json:"sharpness"`  // variance of the Laplacian of the luminance, low for blurry photos
+}
+
+// measureQuality scores the brightness and sharpness of img
+func measureQuality(img image.Image) Quality {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if longest := max(w, h); longest > qualitySampleSize {
+		w = max(1, w*qualitySampleSize/longest)
+		h = max(1, h*qualitySampleSize/longest)
+	}
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(gray, gray.Bounds(), img, b, draw.Src, nil)
+
+	var sum float64
+	for _, v := range gray.Pix {
+		sum += float64(v)
+	}
+	quality := Quality{Brightness: sum / float64(len(gray.Pix))}
+
+	if w < 3 || h < 3 {
+		return quality
+	}
+	var lapSum, lapSquares float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*gray.Stride + x
+			lap := 4*float64(gray.Pix[i]) -
+				float64(gray.Pix[i-1]) - float64(gray.Pix[i+1]) -
+				float64(gray.Pix[i-gray.Stride]) - float64(gray.Pix[i+gray.Stride])
+			lapSum += lap
+			lapSquares += lap * lap
+		}
+	}
+	n := float64((w - 2) * (h - 2))
+	mean := lapSum / n
+	quality.Sharpness = lapSquares/n - mean*mean
+	return quality
+}
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE documents
+    ADD COLUMN public_verification BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Public verifications are anonymous, so user_id becomes optional
+ALTER TABLE verification_history
+    ALTER COLUMN user_id DROP NOT NULL,
+    ADD COLUMN source VARCHAR(20) NOT NULL DEFAULT 'internal';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM verification_history WHERE user_id IS NULL;
+
+ALTER TABLE verification_history
+    DROP COLUMN IF EXISTS source,
+    ALTER COLUMN user_id SET NOT NULL;
+
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS public_verification;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE documents
+    ADD COLUMN state VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN revocation_reason TEXT,
+    ADD COLUMN revoked_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN superseded_by INTEGER,
+    ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT fk_document_superseded_by FOREIGN KEY (superseded_by) REFERENCES documents(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_documents_state ON documents(state);
+
+-- Audit trail of every change made to a document after it was issued
+CREATE TABLE document_events (
+    id SERIAL PRIMARY KEY,
+    document_id INTEGER NOT NULL,
+    user_id INTEGER,
+    action VARCHAR(30) NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT fk_event_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+    CONSTRAINT fk_event_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_document_events_document_id ON document_events(document_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_document_events_document_id;
+DROP TABLE IF EXISTS document_events;
+
+-- Soft-deleted documents would otherwise reappear
+DELETE FROM documents WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_documents_state;
+ALTER TABLE documents
+    DROP CONSTRAINT IF EXISTS fk_document_superseded_by,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS superseded_by,
+    DROP COLUMN IF EXISTS revoked_at,
+    DROP COLUMN IF EXISTS revocation_reason,
+    DROP COLUMN IF EXISTS state;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE documents
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Immutable snapshot of every revision of a document, including the current one
+CREATE TABLE document_versions (
+    id SERIAL PRIMARY KEY,
+    document_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    type VARCHAR(100) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    summary TEXT NOT NULL,
+    expiration_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    file_data BYTEA NOT NULL,
+    created_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT fk_version_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+    CONSTRAINT fk_version_user FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT uq_document_version UNIQUE (document_id, version)
+);
+
+INSERT INTO document_versions (document_id, version, type, name, summary, expiration_date, file_name, file_data, created_at)
+SELECT id, version, type, name, summary, expiration_date, file_name, file_data, created_at FROM documents;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS document_versions;
+
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
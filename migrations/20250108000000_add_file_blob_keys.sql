@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Files are stored in the blob store under their SHA-256 key. file_data is kept for rows
+-- that have not been moved yet by the migrate-blobs command.
+ALTER TABLE documents
+    ADD COLUMN file_key VARCHAR(64),
+    ADD COLUMN file_size BIGINT,
+    ALTER COLUMN file_data DROP NOT NULL;
+
+ALTER TABLE document_versions
+    ADD COLUMN file_key VARCHAR(64),
+    ADD COLUMN file_size BIGINT,
+    ALTER COLUMN file_data DROP NOT NULL;
+
+UPDATE documents SET file_size = octet_length(file_data);
+UPDATE document_versions SET file_size = octet_length(file_data);
+
+ALTER TABLE documents
+    ALTER COLUMN file_size SET NOT NULL,
+    ADD CONSTRAINT chk_document_file CHECK (file_key IS NOT NULL OR file_data IS NOT NULL);
+
+ALTER TABLE document_versions
+    ALTER COLUMN file_size SET NOT NULL,
+    ADD CONSTRAINT chk_document_version_file CHECK (file_key IS NOT NULL OR file_data IS NOT NULL);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Fails if files were moved to the blob store; they must be copied back into file_data first
+ALTER TABLE document_versions
+    DROP CONSTRAINT IF EXISTS chk_document_version_file,
+    ALTER COLUMN file_data SET NOT NULL,
+    DROP COLUMN IF EXISTS file_size,
+    DROP COLUMN IF EXISTS file_key;
+
+ALTER TABLE documents
+    DROP CONSTRAINT IF EXISTS chk_document_file,
+    ALTER COLUMN file_data SET NOT NULL,
+    DROP COLUMN IF EXISTS file_size,
+    DROP COLUMN IF EXISTS file_key;
+-- +goose StatementEnd
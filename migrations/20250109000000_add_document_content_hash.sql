@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- content_hash is the SHA-256 of the file, used to detect duplicate uploads within a company.
+-- It equals file_key for files in the blob store and is computed from file_data for the rest.
+ALTER TABLE documents
+    ADD COLUMN content_hash VARCHAR(64),
+    ADD COLUMN content_type VARCHAR(100) NOT NULL DEFAULT 'application/pdf';
+
+ALTER TABLE document_versions
+    ADD COLUMN content_type VARCHAR(100) NOT NULL DEFAULT 'application/pdf';
+
+UPDATE documents SET content_hash = COALESCE(file_key, encode(sha256(file_data), 'hex'));
+
+ALTER TABLE documents ALTER COLUMN content_hash SET NOT NULL;
+
+-- Not unique: documents uploaded before validation may already share a file
+CREATE INDEX idx_documents_company_content_hash ON documents(company_id, content_hash) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_documents_company_content_hash;
+
+ALTER TABLE document_versions DROP COLUMN IF EXISTS content_type;
+
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS content_type,
+    DROP COLUMN IF EXISTS content_hash;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Asynchronous document comparisons. The queue itself lives in Redis; this table is the
+-- durable record of each job, its inputs in the blob store and its result.
+CREATE TABLE comparison_jobs (
+    id VARCHAR(32) PRIMARY KEY,
+    company_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    document_hash TEXT NOT NULL,
+    inputs JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    result JSONB,
+    error TEXT,
+    webhook_url TEXT,
+    webhook_delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT fk_comparison_jobs_company FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    CONSTRAINT fk_comparison_jobs_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_comparison_jobs_company_id ON comparison_jobs(company_id);
+CREATE INDEX idx_comparison_jobs_created_at ON comparison_jobs(created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_comparison_jobs_created_at;
+DROP INDEX IF EXISTS idx_comparison_jobs_company_id;
+DROP TABLE IF EXISTS comparison_jobs;
+-- +goose StatementEnd
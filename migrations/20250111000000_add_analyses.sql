@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Comparison analyses are kept for disputes, linked to the verification they were made for
+CREATE TABLE analyses (
+    id SERIAL PRIMARY KEY,
+    history_id INTEGER,
+    company_id INTEGER NOT NULL,
+    document_id INTEGER NOT NULL,
+    user_id INTEGER,
+    score DOUBLE PRECISION NOT NULL,
+    is_authentic BOOLEAN NOT NULL,
+    confidence VARCHAR(20) NOT NULL,
+    summary TEXT NOT NULL,
+    differences JSONB NOT NULL DEFAULT '[]',
+    findings JSONB NOT NULL DEFAULT '[]',
+    provider VARCHAR(100) NOT NULL,
+    model VARCHAR(255) NOT NULL DEFAULT '',
+    prompt_version VARCHAR(50) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT fk_analysis_history FOREIGN KEY (history_id) REFERENCES verification_history(id) ON DELETE SET NULL,
+    CONSTRAINT fk_analysis_company FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    CONSTRAINT fk_analysis_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+    CONSTRAINT fk_analysis_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_analyses_history_id ON analyses(history_id);
+CREATE INDEX idx_analyses_document_id ON analyses(document_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_analyses_document_id;
+DROP INDEX IF EXISTS idx_analyses_history_id;
+DROP TABLE IF EXISTS analyses;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE companies
+    ADD COLUMN retain_evidence BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Photos and PDFs submitted for comparison, kept until expires_at for companies that opted in
+CREATE TABLE evidence (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER NOT NULL,
+    document_id INTEGER NOT NULL,
+    history_id INTEGER,
+    user_id INTEGER,
+    kind VARCHAR(20) NOT NULL,
+    position INTEGER NOT NULL,
+    file_key VARCHAR(64) NOT NULL,
+    file_size BIGINT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT fk_evidence_company FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    CONSTRAINT fk_evidence_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+    CONSTRAINT fk_evidence_history FOREIGN KEY (history_id) REFERENCES verification_history(id) ON DELETE SET NULL,
+    CONSTRAINT fk_evidence_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_evidence_history_id ON evidence(history_id);
+CREATE INDEX idx_evidence_file_key ON evidence(file_key);
+CREATE INDEX idx_evidence_expires_at ON evidence(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_evidence_expires_at;
+DROP INDEX IF EXISTS idx_evidence_file_key;
+DROP INDEX IF EXISTS idx_evidence_history_id;
+DROP TABLE IF EXISTS evidence;
+ALTER TABLE companies DROP COLUMN IF EXISTS retain_evidence;
+-- +goose StatementEnd
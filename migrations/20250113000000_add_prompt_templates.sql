@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Comparison prompt templates. Templates without a company apply to every company, templates with an
+-- empty document type to every type of document. Rows are never updated: a new version is added instead.
+CREATE TABLE prompt_templates (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER,
+    document_type VARCHAR(100) NOT NULL DEFAULT '',
+    version INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    expected_fields JSONB NOT NULL DEFAULT '[]',
+    created_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT fk_prompt_template_company FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    CONSTRAINT fk_prompt_template_user FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX idx_prompt_templates_version ON prompt_templates(COALESCE(company_id, 0), document_type, version);
+
+-- The rendered prompt is kept with the analysis so the result can be reproduced
+ALTER TABLE analyses ADD COLUMN prompt_template_id INTEGER;
+ALTER TABLE analyses ADD COLUMN prompt TEXT NOT NULL DEFAULT '';
+ALTER TABLE analyses ADD CONSTRAINT fk_analysis_prompt_template FOREIGN KEY (prompt_template_id) REFERENCES prompt_templates(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analyses DROP CONSTRAINT IF EXISTS fk_analysis_prompt_template;
+ALTER TABLE analyses DROP COLUMN IF EXISTS prompt;
+ALTER TABLE analyses DROP COLUMN IF EXISTS prompt_template_id;
+DROP INDEX IF EXISTS idx_prompt_templates_version;
+DROP TABLE IF EXISTS prompt_templates;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Set when the provider's answer failed validation and the stored result was repaired from it
+ALTER TABLE analyses ADD COLUMN repaired BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analyses DROP COLUMN IF EXISTS repaired;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Named values of a document, extracted from its file or entered by the issuer, that comparisons check
+CREATE TABLE document_fields (
+    id SERIAL PRIMARY KEY,
+    document_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    value TEXT NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT fk_document_field_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+    CONSTRAINT uq_document_field_name UNIQUE (document_id, name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS document_fields;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Per-page results of comparisons with photos of multi-page documents
+ALTER TABLE analyses ADD COLUMN pages JSONB NOT NULL DEFAULT '[]';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE analyses DROP COLUMN IF EXISTS pages;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Monthly AI token quota of a company, the configured default applies when unset and 0 means no limit
+ALTER TABLE companies
+    ADD COLUMN monthly_token_quota BIGINT;
+
+-- Tokens billed by AI providers for each comparison and extraction, kept to bill companies by usage
+CREATE TABLE usage_records (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER NOT NULL,
+    user_id INTEGER,
+    document_id INTEGER,
+    operation VARCHAR(20) NOT NULL,
+    provider VARCHAR(100) NOT NULL,
+    model VARCHAR(255) NOT NULL DEFAULT '',
+    requests INTEGER NOT NULL,
+    input_tokens INTEGER NOT NULL,
+    output_tokens INTEGER NOT NULL,
+    total_tokens INTEGER NOT NULL,
+    cost NUMERIC(14, 6) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT fk_usage_record_company FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    CONSTRAINT fk_usage_record_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT fk_usage_record_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_usage_records_company_created_at ON usage_records(company_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_usage_records_company_created_at;
+DROP TABLE IF EXISTS usage_records;
+ALTER TABLE companies DROP COLUMN IF EXISTS monthly_token_quota;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Comparison jobs are verified and their files scanned when they are submitted; only the analysis
+-- runs in the background. The verification result and its history entry are kept with the job.
+ALTER TABLE comparison_jobs
+    ADD COLUMN document_id INTEGER,
+    ADD COLUMN history_id INTEGER,
+    ADD COLUMN verification JSONB,
+    ADD CONSTRAINT fk_comparison_jobs_document FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_comparison_jobs_history FOREIGN KEY (history_id) REFERENCES verification_history(id) ON DELETE SET NULL;
+
+-- Finds the jobs still using a stored input before it is deleted
+CREATE INDEX idx_comparison_jobs_inputs ON comparison_jobs USING GIN (inputs jsonb_path_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_comparison_jobs_inputs;
+ALTER TABLE comparison_jobs
+    DROP CONSTRAINT IF EXISTS fk_comparison_jobs_history,
+    DROP CONSTRAINT IF EXISTS fk_comparison_jobs_document,
+    DROP COLUMN IF EXISTS verification,
+    DROP COLUMN IF EXISTS history_id,
+    DROP COLUMN IF EXISTS document_id;
+-- +goose StatementEnd
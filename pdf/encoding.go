@@ -0,0 +1,63 @@
+package pdf
+
+// Base encodings of simple fonts, indexed by character code. Codes without a character map to "".
+var (
+	winAnsiEncoding  [256]string
+	macRomanEncoding [256]string
+	standardEncoding [256]string
+)
+
+// winAnsiHigh holds codes 0x80-0x9F of WinAnsiEncoding, where it differs from Latin-1
+const winAnsiHigh = "€\x00‚ƒ„…†‡ˆ‰Š‹Œ\x00Ž\x00\x00‘’“”•–—˜™š›œ\x00žŸ"
+
+// macRomanHigh holds codes 0x80-0xFF of MacRomanEncoding
+const macRomanHigh = "ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ"
+
+func init() {
+	for c := 0x20; c < 0x7F; c++ {
+		winAnsiEncoding[c] = string(rune(c))
+		macRomanEncoding[c] = string(rune(c))
+		standardEncoding[c] = string(rune(c))
+	}
+	standardEncoding['\''] = "’"
+	standardEncoding['`'] = "‘"
+
+	code := 0x80
+	for _, r := range winAnsiHigh {
+		if r != 0 {
+			winAnsiEncoding[code] = string(r)
+		}
+		code++
+	}
+	for c := 0xA0; c <= 0xFF; c++ {
+		winAnsiEncoding[c] = string(rune(c))
+	}
+
+	code = 0x80
+	for _, r := range macRomanHigh {
+		macRomanEncoding[code] = string(r)
+		code++
+	}
+}
+
+// glyphNames maps the glyph names of the standard Latin character set to text
+var glyphNames = map[string]string{
+	"space": " ", "nbspace": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#",
+	"dollar": "$", "percent": "%", "ampersand": "&", "quotesingle": "'", "quoteright": "’",
+	"quoteleft": "‘", "parenleft": "(", "parenright": ")", "asterisk": "*", "plus": "+",
+	"comma": ",", "hyphen": "-", "minus": "−", "period": ".", "slash": "/",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4",
+	"five": "5", "six": "6", "seven": "7", "eight": "8", "nine": "9",
+	"colon": ":", "semicolon": ";", "less": "<", "equal": "=", "greater": ">",
+	"question": "?", "at": "@", "bracketleft": "[", "backslash": "\\", "bracketright": "]",
+	"asciicircum": "^", "underscore": "_", "grave": "`", "braceleft": "{", "bar": "|",
+	"braceright": "}", "asciitilde": "~", "bullet": "•", "endash": "–", "emdash": "—",
+	"quotedblleft": "“", "quotedblright": "”", "quotesinglbase": "‚", "quotedblbase": "„",
+	"guillemotleft": "«", "guillemotright": "»", "guilsinglleft": "‹", "guilsinglright": "›",
+	"ellipsis": "…", "dagger": "†", "daggerdbl": "‡", "degree": "°", "copyright": "©",
+	"registered": "®", "trademark": "™", "section": "§", "paragraph": "¶",
+	"periodcentered": "·", "Euro": "€", "sterling": "£", "yen": "¥", "cent": "¢",
+	"multiply": "×", "divide": "÷", "plusminus": "±", "numero": "№", "perthousand": "‰",
+	"fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl",
+	"germandbls": "ß", "dotlessi": "ı", "exclamdown": "¡", "questiondown": "¿",
+}
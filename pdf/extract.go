@@ -0,0 +1,162 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ExtractPage returns a new PDF file holding only the page at index (0-based) and the objects it
+// uses, e.g. to compare documents page by page. References to other pages, such as link targets,
+// become null.
+func (d *Document) ExtractPage(index int) ([]byte, error) {
+	if d.IsEncrypted() {
+		return nil, ErrEncrypted
+	}
+	pages, err := d.Pages()
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(pages) {
+		return nil, fmt.Errorf("page %d out of range", index+1)
+	}
+	page := pages[index]
+
+	c := &pageCopier{doc: d, nums: make(map[int]int), objects: []Object{nil}}
+	catalogNum, pagesNum, pageNum := c.reserve(), c.reserve(), c.reserve()
+	c.nums[page.Ref.Num] = pageNum
+
+	// Inherited attributes are set on the page, its page tree is not copied
+	pageDict := make(Dict, len(page.Dict)+4)
+	for k, v := range page.Dict {
+		if k != "Parent" {
+			pageDict[k] = v
+		}
+	}
+	pageDict["MediaBox"] = rectangleArray(page.MediaBox)
+	pageDict["CropBox"] = rectangleArray(page.CropBox)
+	pageDict["Resources"] = page.Resources
+	if page.Resources == nil {
+		pageDict["Resources"] = Dict{}
+	}
+	if page.Rotate != 0 {
+		pageDict["Rotate"] = page.Rotate
+	}
+
+	copied, err := c.copy(pageDict, 0)
+	if err != nil {
+		return nil, err
+	}
+	copied.(Dict)["Parent"] = Ref{Num: pagesNum}
+	c.objects[pageNum] = copied
+	c.objects[pagesNum] = Dict{"Type": Name("Pages"), "Kids": Array{Ref{Num: pageNum}}, "Count": 1}
+	c.objects[catalogNum] = Dict{"Type": Name("Catalog"), "Pages": Ref{Num: pagesNum}}
+
+	// Objects referenced from copied objects are copied in turn
+	for len(c.queue) > 0 {
+		old := c.queue[0]
+		c.queue = c.queue[1:]
+		obj, err := d.Resolve(Ref{Num: old})
+		if err != nil {
+			return nil, err
+		}
+		if c.objects[c.nums[old]], err = c.copy(obj, 0); err != nil {
+			return nil, err
+		}
+	}
+	return c.bytes(catalogNum), nil
+}
+
+// pageCopier renumbers the objects used by a page into a new file
+type pageCopier struct {
+	doc     *Document
+	nums    map[int]int // object number in the document to number in the new file
+	objects []Object    // objects of the new file by number, 0 is unused
+	queue   []int       // document objects referenced but not copied yet
+}
+
+func (c *pageCopier) reserve() int {
+	c.objects = append(c.objects, nil)
+	return len(c.objects) - 1
+}
+
+// copy returns obj with its references renumbered. Referenced pages and page tree nodes other than
+// the extracted page are replaced by null.
+func (c *pageCopier) copy(obj Object, depth int) (Object, error) {
+	if depth > maxObjectDepth {
+		return nil, fmt.Errorf("%w: objects nested too deep", ErrMalformed)
+	}
+	switch v := obj.(type) {
+	case Ref:
+		if num, ok := c.nums[v.Num]; ok {
+			return Ref{Num: num}, nil
+		}
+		target, err := c.doc.Resolve(v)
+		if err != nil {
+			return nil, err
+		}
+		if dict, ok := target.(Dict); ok {
+			if t, _ := dict.Name("Type"); t == "Page" || t == "Pages" {
+				return nil, nil
+			}
+		}
+		num := c.reserve()
+		c.nums[v.Num] = num
+		c.queue = append(c.queue, v.Num)
+		return Ref{Num: num}, nil
+	case Array:
+		arr := make(Array, len(v))
+		for i, item := range v {
+			copied, err := c.copy(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = copied
+		}
+		return arr, nil
+	case Dict:
+		dict := make(Dict, len(v))
+		for k, item := range v {
+			copied, err := c.copy(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			dict[k] = copied
+		}
+		return dict, nil
+	case *Stream:
+		dict, err := c.copy(v.Dict, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return &Stream{Dict: dict.(Dict), Data: v.Data}, nil
+	}
+	return obj, nil
+}
+
+// bytes writes the new file with a classic cross-reference table
+func (c *pageCopier) bytes(catalogNum int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(c.objects))
+	for num := 1; num < len(c.objects); num++ {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", num)
+		buf.Write(Serialize(c.objects[num]))
+		buf.WriteString("\nendobj\n")
+	}
+
+	startxref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(c.objects))
+	for num := 1; num < len(c.objects); num++ {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", offsets[num])
+	}
+	buf.WriteString("trailer\n")
+	buf.Write(Serialize(Dict{"Size": len(c.objects), "Root": Ref{Num: catalogNum}}))
+	fmt.Fprintf(&buf, "\nstartxref\n%d\n%%%%EOF\n", startxref)
+	return buf.Bytes()
+}
+
+func rectangleArray(r Rectangle) Array {
+	return Array{r.LLX, r.LLY, r.URX, r.URY}
+}
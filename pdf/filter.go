@@ -0,0 +1,239 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// errDecodeLimit is returned when a stream decodes to more data than a document may
+var errDecodeLimit = fmt.Errorf("%w: stream data exceeds the decoded size limit", ErrMalformed)
+
+// Decode returns the decoded stream data, applying all filters in order. The data decoded by every
+// filter counts towards the limits of a stream and of the document; exceeding one fails with
+// ErrMalformed, and so does every later call.
+func (d *Document) Decode(s *Stream) ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	filters, err := d.streamFilters(s)
+	if err != nil {
+		return nil, err
+	}
+
+	data := s.Data
+	for _, f := range filters {
+		limit := min(maxStreamSize, maxDecodedSize-d.decoded)
+		switch f.name {
+		case "FlateDecode", "Fl":
+			data, err = flateDecode(data, limit)
+			if err != nil {
+				break
+			}
+			data, err = applyPredictor(data, f.params)
+		case "ASCIIHexDecode", "AHx":
+			data, err = asciiHexDecode(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %s", f.name)
+		}
+		if err == nil && len(data) > limit {
+			err = errDecodeLimit
+		}
+		if err == errDecodeLimit {
+			d.err = err
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.decoded += len(data)
+	}
+	return data, nil
+}
+
+type streamFilter struct {
+	name   Name
+	params Dict
+}
+
+func (d *Document) streamFilters(s *Stream) ([]streamFilter, error) {
+	filterObj, _ := d.Resolve(s.Dict["Filter"])
+	paramsObj, _ := d.Resolve(s.Dict["DecodeParms"])
+
+	var names []Name
+	switch v := filterObj.(type) {
+	case nil:
+		return nil, nil
+	case Name:
+		names = []Name{v}
+	case Array:
+		for _, item := range v {
+			item, _ = d.Resolve(item)
+			name, ok := item.(Name)
+			if !ok {
+				return nil, fmt.Errorf("invalid stream filter")
+			}
+			names = append(names, name)
+		}
+	default:
+		return nil, fmt.Errorf("invalid stream filter")
+	}
+
+	filters := make([]streamFilter, len(names))
+	for i, name := range names {
+		filters[i].name = name
+		var params Object
+		switch v := paramsObj.(type) {
+		case Dict:
+			if i == 0 {
+				params = v
+			}
+		case Array:
+			if i < len(v) {
+				params, _ = d.Resolve(v[i])
+			}
+		}
+		if dict, ok := params.(Dict); ok {
+			filters[i].params = dict
+		}
+	}
+	return filters, nil
+}
+
+// flateDecode inflates zlib data, tolerating truncated streams as long as some data was recovered.
+// Data inflating to more than limit bytes fails with errDecodeLimit without being inflated further.
+func flateDecode(data []byte, limit int) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("flate decode: %w", err)
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(io.LimitReader(zr, int64(limit)+1))
+	if len(out) > limit {
+		return nil, errDecodeLimit
+	}
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("flate decode: %w", err)
+	}
+	return out, nil
+}
+
+// applyPredictor reverses PNG predictors used by xref and object streams
+func applyPredictor(data []byte, params Dict) ([]byte, error) {
+	if params == nil {
+		return data, nil
+	}
+	predictor, _ := params.Int("Predictor")
+	if predictor < 10 {
+		if predictor == 2 {
+			return nil, fmt.Errorf("unsupported TIFF predictor")
+		}
+		return data, nil
+	}
+
+	colors, ok := params.Int("Colors")
+	if !ok || colors < 1 {
+		colors = 1
+	}
+	bpc, ok := params.Int("BitsPerComponent")
+	if !ok || bpc < 1 {
+		bpc = 8
+	}
+	columns, ok := params.Int("Columns")
+	if !ok || columns < 1 {
+		columns = 1
+	}
+	if colors > 32 || bpc > 16 || columns > len(data) {
+		return nil, fmt.Errorf("invalid predictor parameters")
+	}
+
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (colors*bpc*columns + 7) / 8
+	if len(data)%(rowLen+1) != 0 {
+		return nil, fmt.Errorf("invalid predictor data length")
+	}
+
+	out := make([]byte, 0, len(data)/(rowLen+1)*rowLen)
+	prev := make([]byte, rowLen)
+	for off := 0; off < len(data); off += rowLen + 1 {
+		filter := data[off]
+		row := append([]byte(nil), data[off+1:off+1+rowLen]...)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch filter {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("invalid PNG predictor %d", filter)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func asciiHexDecode(data []byte) ([]byte, error) {
+	var digits []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if !isWhitespace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	return hex.DecodeString(string(digits))
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	// Every group decodes to four bytes, even the final partial one and the single z
+	out := make([]byte, 4*len(data)+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	if err != nil {
+		return nil, fmt.Errorf("ascii85 decode: %w", err)
+	}
+	return out[:n], nil
+}
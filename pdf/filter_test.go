@@ -0,0 +1,85 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	text := []byte("BT (Hello World) Tj ET")
+	// Two rows of three bytes with the PNG Up predictor: the second row stores the difference
+	predicted := []byte{2, 1, 2, 3, 2, 1, 1, 1}
+
+	tests := []struct {
+		name    string
+		stream  *Stream
+		want    []byte
+		wantErr bool
+	}{
+		{"no filter", &Stream{Dict: Dict{}, Data: text}, text, false},
+		{"flate", &Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Data: deflate(text)}, text, false},
+		{"flate abbreviated", &Stream{Dict: Dict{"Filter": Name("Fl")}, Data: deflate(text)}, text, false},
+		{"truncated flate", &Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Data: deflate(bytes.Repeat(text, 100))[:40]}, nil, false},
+		{"not flate", &Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Data: text}, nil, true},
+		{"ascii hex", &Stream{Dict: Dict{"Filter": Name("ASCIIHexDecode")}, Data: []byte("48 65 6c 6C 6f>")}, []byte("Hello"), false},
+		{"ascii85", &Stream{Dict: Dict{"Filter": Name("ASCII85Decode")}, Data: []byte("87cURDZ~>")}, []byte("Hello"), false},
+		{
+			"filter chain",
+			&Stream{Dict: Dict{"Filter": Array{Name("ASCIIHexDecode"), Name("FlateDecode")}}, Data: []byte(hexString(deflate(text)))},
+			text, false,
+		},
+		{
+			"png predictor",
+			&Stream{Dict: Dict{"Filter": Name("FlateDecode"), "DecodeParms": Dict{"Predictor": 12, "Columns": 3}}, Data: deflate([]byte{2, 1, 2, 3, 2, 1, 1, 1})},
+			[]byte{1, 2, 3, 2, 3, 4}, false,
+		},
+		{
+			"predictor columns beyond the data",
+			&Stream{Dict: Dict{"Filter": Name("FlateDecode"), "DecodeParms": Dict{"Predictor": 12, "Columns": 1 << 62}}, Data: deflate(predicted)},
+			nil, true,
+		},
+		{"unsupported filter", &Stream{Dict: Dict{"Filter": Name("DCTDecode")}, Data: text}, nil, true},
+		{"invalid filter", &Stream{Dict: Dict{"Filter": 3}, Data: text}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&Document{}).Decode(tt.stream)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !bytes.Equal(got, tt.want) {
+				t.Errorf("Decode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeLimit(t *testing.T) {
+	d := &Document{}
+	bomb := &Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Data: deflate(make([]byte, maxStreamSize+1))}
+	if _, err := d.Decode(bomb); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("Decode error = %v, want %v", err, ErrMalformed)
+	}
+	// Nothing else is decoded once a stream exceeded the limit
+	small := &Stream{Dict: Dict{}, Data: []byte("BT ET")}
+	if _, err := d.Decode(small); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Decode after the limit error = %v, want %v", err, ErrMalformed)
+	}
+
+	// Streams within the limit are decoded whole
+	d = &Document{}
+	data := make([]byte, 1<<20)
+	if got, err := d.Decode(&Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Data: deflate(data)}); err != nil || len(got) != len(data) {
+		t.Errorf("Decode = %d bytes, %v; want %d bytes", len(got), err, len(data))
+	}
+}
+
+func hexString(data []byte) string {
+	const digits = "0123456789ABCDEF"
+	out := make([]byte, 0, 2*len(data)+1)
+	for _, b := range data {
+		out = append(out, digits[b>>4], digits[b&15])
+	}
+	return string(append(out, '>'))
+}
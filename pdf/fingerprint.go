@@ -0,0 +1,166 @@
+package pdf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+)
+
+// PageFingerprint returns a SHA-256 digest of everything that determines how a page renders:
+// its boxes and rotation, the tokens of its content stream and the resources they draw with.
+// Streams are hashed after decoding and references are followed, so a page keeps its fingerprint
+// when the file is re-saved, recompressed or its objects are renumbered.
+func (d *Document) PageFingerprint(page Page) (string, error) {
+	content, err := d.PageContent(page)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "box %v %v rotate %d\n", page.MediaBox, page.CropBox, page.Rotate)
+	hashContent(h, content)
+	h.Write([]byte("\nresources "))
+	d.hashObject(h, page.Resources, map[Ref]bool{}, 0)
+	if d.err != nil {
+		return "", d.err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashContent writes the content stream tokens separated by single spaces, ignoring formatting
+func hashContent(h hash.Hash, content []byte) {
+	p := &parser{data: content}
+	for {
+		start := p.pos
+		obj, err := p.parseObject()
+		if err != nil {
+			p.skipSpace()
+			if !p.eof() {
+				// keep malformed content as is
+				h.Write(content[start:])
+			}
+			return
+		}
+		writeCanonical(h, obj)
+		h.Write([]byte{' '})
+		if kw, ok := obj.(keyword); ok && kw == "ID" {
+			start = p.pos
+			p.pos++
+			skipToInlineImageEnd(p)
+			h.Write(content[start:p.pos])
+		}
+	}
+}
+
+// hashObject writes a canonical form of obj: references resolved, dictionary keys sorted and
+// streams replaced by the digest of their decoded data. It stops once the document exceeded a
+// limit, which the caller must check.
+func (d *Document) hashObject(h hash.Hash, obj Object, path map[Ref]bool, depth int) {
+	if depth > maxObjectDepth || d.spend(objectCost) != nil {
+		return
+	}
+	if ref, ok := obj.(Ref); ok {
+		if path[ref] {
+			h.Write([]byte("cycle"))
+			return
+		}
+		path[ref] = true
+		defer delete(path, ref)
+		resolved, err := d.Resolve(ref)
+		if err != nil {
+			h.Write([]byte("null"))
+			return
+		}
+		obj = resolved
+	}
+
+	switch v := obj.(type) {
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		h.Write([]byte("<<"))
+		for _, k := range keys {
+			fmt.Fprintf(h, "/%s ", k)
+			d.hashObject(h, v[Name(k)], path, depth+1)
+			h.Write([]byte{' '})
+		}
+		h.Write([]byte(">>"))
+	case Array:
+		h.Write([]byte{'['})
+		for _, item := range v {
+			d.hashObject(h, item, path, depth+1)
+			h.Write([]byte{' '})
+		}
+		h.Write([]byte{']'})
+	case *Stream:
+		dict := make(Dict, len(v.Dict))
+		for k, val := range v.Dict {
+			switch k {
+			case "Length", "Filter", "DecodeParms":
+			default:
+				dict[k] = val
+			}
+		}
+		d.hashObject(h, dict, path, depth+1)
+		fmt.Fprintf(h, "stream %x", d.streamDigest(v))
+	default:
+		writeCanonical(h, obj)
+	}
+}
+
+// streamDigest returns the SHA-256 of the decoded stream data. Digests are kept, so that resources
+// shared by many pages are decoded once.
+func (d *Document) streamDigest(s *Stream) [sha256.Size]byte {
+	if sum, ok := d.digests[s]; ok {
+		return sum
+	}
+	data, err := d.Decode(s)
+	if err != nil {
+		// e.g. DCT encoded images, which are compared as stored
+		data = s.Data
+	}
+	sum := sha256.Sum256(data)
+	if d.digests == nil {
+		d.digests = make(map[*Stream][sha256.Size]byte)
+	}
+	d.digests[s] = sum
+	return sum
+}
+
+// writeCanonical writes a direct object without references or streams
+func writeCanonical(h hash.Hash, obj Object) {
+	switch v := obj.(type) {
+	case keyword:
+		h.Write([]byte(v))
+	case float64:
+		h.Write([]byte(strconv.FormatFloat(v, 'f', -1, 64)))
+	case Array:
+		h.Write([]byte{'['})
+		for _, item := range v {
+			writeCanonical(h, item)
+			h.Write([]byte{' '})
+		}
+		h.Write([]byte{']'})
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		h.Write([]byte("<<"))
+		for _, k := range keys {
+			fmt.Fprintf(h, "/%s ", k)
+			writeCanonical(h, v[Name(k)])
+			h.Write([]byte{' '})
+		}
+		h.Write([]byte(">>"))
+	default:
+		h.Write(Serialize(obj))
+	}
+}
@@ -0,0 +1,428 @@
+package pdf
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Font describes a font resource used by a page
+type Font struct {
+	Name     string // BaseFont without the subset prefix, e.g. Helvetica
+	Subtype  string // Type1, TrueType, Type0, Type3, ...
+	Embedded bool   // the font program is stored in the file
+}
+
+// PageFonts returns the fonts referenced by the page resources, including those of form XObjects,
+// sorted by name
+func (d *Document) PageFonts(page Page) []Font {
+	fonts := map[Font]bool{}
+	d.collectFonts(page.Resources, fonts, map[*Stream]bool{}, 0)
+
+	out := make([]Font, 0, len(fonts))
+	for f := range fonts {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Subtype < out[j].Subtype
+	})
+	return out
+}
+
+func (d *Document) collectFonts(resources Dict, fonts map[Font]bool, visited map[*Stream]bool, depth int) {
+	if resources == nil || depth > maxObjectDepth {
+		return
+	}
+	if fontDict, ok := d.ResolveDict(resources["Font"]); ok {
+		for _, obj := range fontDict {
+			if font, ok := d.ResolveDict(obj); ok {
+				fonts[d.describeFont(font)] = true
+			}
+		}
+	}
+	xobjects, _ := d.ResolveDict(resources["XObject"])
+	for _, obj := range xobjects {
+		form, ok := d.formXObject(obj)
+		if !ok || visited[form] {
+			continue
+		}
+		visited[form] = true
+		res, _ := d.ResolveDict(form.Dict["Resources"])
+		d.collectFonts(res, fonts, visited, depth+1)
+	}
+}
+
+func (d *Document) describeFont(font Dict) Font {
+	subtype, _ := font.Name("Subtype")
+	name, _ := font.Name("BaseFont")
+	f := Font{Name: stripSubsetPrefix(string(name)), Subtype: string(subtype)}
+
+	descriptorOwner := font
+	if subtype == "Type0" {
+		if descendants, ok := d.resolveArray(font["DescendantFonts"]); ok && len(descendants) > 0 {
+			if cid, ok := d.ResolveDict(descendants[0]); ok {
+				descriptorOwner = cid
+			}
+		}
+	}
+	if subtype == "Type3" {
+		// glyphs are content streams of the font dictionary itself
+		f.Embedded = true
+		return f
+	}
+	if descriptor, ok := d.ResolveDict(descriptorOwner["FontDescriptor"]); ok {
+		for _, key := range []Name{"FontFile", "FontFile2", "FontFile3"} {
+			if _, ok := descriptor[key]; ok {
+				f.Embedded = true
+			}
+		}
+	}
+	return f
+}
+
+// stripSubsetPrefix removes the six letter tag of subset fonts, e.g. ABCDEF+Helvetica
+func stripSubsetPrefix(name string) string {
+	if len(name) > 7 && name[6] == '+' && strings.ToUpper(name[:6]) == name[:6] {
+		return name[7:]
+	}
+	return name
+}
+
+// formXObject returns the stream of a form XObject
+func (d *Document) formXObject(obj Object) (*Stream, bool) {
+	obj, err := d.Resolve(obj)
+	if err != nil {
+		return nil, false
+	}
+	s, ok := obj.(*Stream)
+	if !ok {
+		return nil, false
+	}
+	if subtype, _ := s.Dict.Name("Subtype"); subtype != "Form" {
+		return nil, false
+	}
+	return s, true
+}
+
+func (d *Document) resolveArray(obj Object) (Array, bool) {
+	obj, err := d.Resolve(obj)
+	if err != nil {
+		return nil, false
+	}
+	arr, ok := obj.(Array)
+	return arr, ok
+}
+
+// fontDecoder maps the character codes shown with a font to text and glyph widths
+type fontDecoder struct {
+	codeLen      int               // bytes per character code: 1 for simple fonts, usually 2 for Type0
+	toUnicode    map[uint32]string // from the ToUnicode CMap
+	encoding     *[256]string      // simple fonts without a ToUnicode entry for the code
+	firstChar    int
+	widths       []float64          // simple font widths starting at firstChar, in 1/1000 text space units
+	cidWidths    map[uint32]float64 // Type0 widths
+	defaultWidth float64
+}
+
+// newFontDecoder builds the decoder of a font dictionary. A nil font yields a WinAnsiEncoding decoder.
+func (d *Document) newFontDecoder(font Dict) *fontDecoder {
+	dec := &fontDecoder{codeLen: 1, encoding: &winAnsiEncoding, defaultWidth: 500}
+	if font == nil {
+		return dec
+	}
+
+	subtype, _ := font.Name("Subtype")
+	if subtype == "Type0" {
+		dec.codeLen = 2
+		dec.encoding = nil
+		dec.defaultWidth = 1000
+		if descendants, ok := d.resolveArray(font["DescendantFonts"]); ok && len(descendants) > 0 {
+			if cid, ok := d.ResolveDict(descendants[0]); ok {
+				if dw, err := d.Resolve(cid["DW"]); err == nil {
+					if n, ok := Number(dw); ok {
+						dec.defaultWidth = n
+					}
+				}
+				dec.cidWidths = d.cidWidths(cid["W"])
+			}
+		}
+	} else {
+		dec.encoding = d.simpleEncoding(font["Encoding"])
+		if first, err := d.Resolve(font["FirstChar"]); err == nil {
+			if n, ok := first.(int); ok {
+				dec.firstChar = n
+			}
+		}
+		if widths, ok := d.resolveArray(font["Widths"]); ok {
+			for _, w := range widths {
+				w, _ = d.Resolve(w)
+				n, _ := Number(w)
+				dec.widths = append(dec.widths, n)
+			}
+		}
+	}
+
+	if obj, err := d.Resolve(font["ToUnicode"]); err == nil {
+		if s, ok := obj.(*Stream); ok {
+			if data, err := d.Decode(s); err == nil {
+				var codeLen int
+				dec.toUnicode, codeLen = parseToUnicode(data)
+				if codeLen > 0 && subtype == "Type0" {
+					dec.codeLen = codeLen
+				}
+			}
+		}
+	}
+	return dec
+}
+
+// cidWidths parses the W array of a CIDFont: "c [w1 w2 ...]" and "cfirst clast w" entries
+func (d *Document) cidWidths(obj Object) map[uint32]float64 {
+	arr, ok := d.resolveArray(obj)
+	if !ok {
+		return nil
+	}
+	widths := map[uint32]float64{}
+	codes := 0 // codes given a width by ranges so far, see maxCodes
+	for i := 0; i < len(arr); {
+		first, ok := arr[i].(int)
+		if !ok || i+1 >= len(arr) {
+			break
+		}
+		next, _ := d.Resolve(arr[i+1])
+		if list, ok := next.(Array); ok {
+			for j, w := range list {
+				w, _ = d.Resolve(w)
+				if n, ok := Number(w); ok {
+					widths[uint32(first+j)] = n
+				}
+			}
+			i += 2
+			continue
+		}
+		last, ok := next.(int)
+		if !ok || i+2 >= len(arr) || last-first > 0xFFFF || codes > maxCodes {
+			break
+		}
+		codes += max(last-first+1, 0)
+		w, _ := d.Resolve(arr[i+2])
+		n, _ := Number(w)
+		for c := first; c <= last; c++ {
+			widths[uint32(c)] = n
+		}
+		i += 3
+	}
+	return widths
+}
+
+// simpleEncoding resolves the Encoding entry of a simple font: a base encoding name or a
+// dictionary with a BaseEncoding and Differences
+func (d *Document) simpleEncoding(obj Object) *[256]string {
+	obj, _ = d.Resolve(obj)
+	switch v := obj.(type) {
+	case Name:
+		return baseEncoding(v)
+	case Dict:
+		base, _ := v.Name("BaseEncoding")
+		enc := *baseEncoding(base)
+		diffs, _ := d.resolveArray(v["Differences"])
+		code := 0
+		for _, item := range diffs {
+			switch item := item.(type) {
+			case int:
+				code = item
+			case Name:
+				if code >= 0 && code < 256 {
+					enc[code] = glyphText(string(item))
+				}
+				code++
+			}
+		}
+		return &enc
+	}
+	return &winAnsiEncoding
+}
+
+func baseEncoding(name Name) *[256]string {
+	switch name {
+	case "MacRomanEncoding":
+		return &macRomanEncoding
+	case "StandardEncoding":
+		return &standardEncoding
+	}
+	return &winAnsiEncoding
+}
+
+// decode splits a shown string into character codes and returns them with their text
+func (f *fontDecoder) decode(s String) []glyph {
+	glyphs := make([]glyph, 0, len(s)/f.codeLen+1)
+	for i := 0; i < len(s); i += f.codeLen {
+		var code uint32
+		for j := 0; j < f.codeLen && i+j < len(s); j++ {
+			code = code<<8 | uint32(s[i+j])
+		}
+		text, ok := f.toUnicode[code]
+		if !ok {
+			text = "�"
+			if f.encoding != nil && code < 256 {
+				text = f.encoding[code]
+			}
+		}
+		glyphs = append(glyphs, glyph{code: code, text: text, width: f.width(code), space: f.codeLen == 1 && code == ' '})
+	}
+	return glyphs
+}
+
+func (f *fontDecoder) width(code uint32) float64 {
+	if f.cidWidths != nil {
+		if w, ok := f.cidWidths[code]; ok {
+			return w
+		}
+		return f.defaultWidth
+	}
+	if i := int(code) - f.firstChar; i >= 0 && i < len(f.widths) && f.widths[i] > 0 {
+		return f.widths[i]
+	}
+	return f.defaultWidth
+}
+
+// glyph is a decoded character code
+type glyph struct {
+	code  uint32
+	text  string
+	width float64 // in 1/1000 text space units
+	space bool    // single byte code 32, subject to word spacing
+}
+
+// maxCodes bounds the character codes mapped by the ranges of a font, which a few bytes could
+// otherwise make millions of
+const maxCodes = 1 << 17
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap. It also returns the
+// code length of the first codespace range, or 0 when the CMap does not declare one.
+func parseToUnicode(data []byte) (map[uint32]string, int) {
+	mapping := map[uint32]string{}
+	codeLen := 0
+	codes := 0 // codes mapped by ranges so far, see maxCodes
+	p := &parser{data: data}
+	var operands []Object
+	for {
+		obj, err := p.parseObject()
+		if err != nil {
+			return mapping, codeLen
+		}
+		kw, ok := obj.(keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			if codeLen == 0 && len(operands) >= 2 {
+				if lo, ok := operands[0].(String); ok {
+					codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(String)
+				dst, ok2 := operands[i+1].(String)
+				if ok1 && ok2 {
+					mapping[codeValue(src)] = utf16BEText(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(String)
+				hi, ok2 := operands[i+1].(String)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start > 0xFFFF || codes > maxCodes {
+					continue
+				}
+				codes += int(end-start) + 1
+				switch dst := operands[i+2].(type) {
+				case String:
+					// consecutive codes map to consecutive values of the last UTF-16 unit. Counting
+					// the offset rather than the code keeps a range ending at 0xFFFFFFFF finite.
+					for k := uint32(0); k <= end-start; k++ {
+						next := append(String(nil), dst...)
+						if len(next) >= 2 {
+							last := uint32(next[len(next)-2])<<8 | uint32(next[len(next)-1])
+							last += k
+							next[len(next)-2], next[len(next)-1] = byte(last>>8), byte(last)
+						}
+						mapping[start+k] = utf16BEText(next)
+					}
+				case Array:
+					for j, item := range dst {
+						if s, ok := item.(String); ok && start+uint32(j) <= end {
+							mapping[start+uint32(j)] = utf16BEText(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func codeValue(s String) uint32 {
+	var v uint32
+	for _, b := range s {
+		v = v<<8 | uint32(b)
+	}
+	return v
+}
+
+func utf16BEText(s String) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// glyphText maps a glyph name to text following the Adobe Glyph List conventions for the
+// names used by common fonts: uniXXXX, uXXXX[XX], ligatures, suffixed variants and letters
+func glyphText(name string) string {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if text, ok := glyphNames[name]; ok {
+		return text
+	}
+	if strings.Contains(name, "_") {
+		var sb strings.Builder
+		for _, part := range strings.Split(name, "_") {
+			sb.WriteString(glyphText(part))
+		}
+		return sb.String()
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		var units []uint16
+		for i := 3; i < len(name); i += 4 {
+			v, err := strconv.ParseUint(name[i:i+4], 16, 16)
+			if err != nil {
+				return "�"
+			}
+			units = append(units, uint16(v))
+		}
+		return string(utf16.Decode(units))
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return string(rune(v))
+		}
+	}
+	if len(name) == 1 && (name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z') {
+		return name
+	}
+	return "�"
+}
@@ -0,0 +1,333 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errUnexpectedEOF = errors.New("unexpected end of data")
+
+// parser reads PDF objects from a byte slice
+type parser struct {
+	data  []byte
+	pos   int
+	depth int // arrays and dictionaries being parsed
+}
+
+func isWhitespace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+// skipSpace skips whitespace and comments
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isWhitespace(c) {
+			p.pos++
+			continue
+		}
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// readToken reads a regular token (number or keyword)
+func (p *parser) readToken() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isWhitespace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// parseObject parses the next object. Bare keywords are returned as keyword values.
+func (p *parser) parseObject() (Object, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, errUnexpectedEOF
+	}
+
+	c := p.data[p.pos]
+	switch {
+	case c == '/':
+		return p.parseName()
+	case c == '(':
+		return p.parseLiteralString()
+	case c == '<':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+			return p.parseDict()
+		}
+		return p.parseHexString()
+	case c == '[':
+		return p.parseArray()
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		p.pos++
+		return keyword(c), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumberOrRef()
+	}
+
+	tok := p.readToken()
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("unexpected character %q at offset %d", c, p.pos)
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return keyword(tok), nil
+}
+
+func (p *parser) parseName() (Object, error) {
+	p.pos++ // skip '/'
+	var name []byte
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isWhitespace(c) || isDelimiter(c) {
+			break
+		}
+		if c == '#' && p.pos+2 < len(p.data) {
+			if b, err := hex.DecodeString(string(p.data[p.pos+1 : p.pos+3])); err == nil {
+				name = append(name, b[0])
+				p.pos += 3
+				continue
+			}
+		}
+		name = append(name, c)
+		p.pos++
+	}
+	return Name(name), nil
+}
+
+func (p *parser) parseLiteralString() (Object, error) {
+	p.pos++ // skip '('
+	var out []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(out), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return nil, errUnexpectedEOF
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+			case '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return nil, errUnexpectedEOF
+}
+
+func (p *parser) parseHexString() (Object, error) {
+	p.pos++ // skip '<'
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end < 0 {
+		return nil, errUnexpectedEOF
+	}
+	var digits []byte
+	for _, c := range p.data[p.pos : p.pos+end] {
+		if !isWhitespace(c) {
+			digits = append(digits, c)
+		}
+	}
+	p.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex string: %w", err)
+	}
+	return String(out), nil
+}
+
+// nest enters an array or dictionary, failing when they are nested too deep
+func (p *parser) nest() error {
+	if p.depth >= maxNesting {
+		return fmt.Errorf("%w: objects nested too deep at offset %d", ErrMalformed, p.pos)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) parseArray() (Object, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	p.pos++ // skip '['
+	arr := Array{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, errUnexpectedEOF
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		obj, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, obj)
+	}
+}
+
+func (p *parser) parseDict() (Object, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	p.pos += 2 // skip '<<'
+	dict := Dict{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, errUnexpectedEOF
+		}
+		if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+			p.pos += 2
+			return dict, nil
+		}
+		key, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(Name)
+		if !ok {
+			return nil, fmt.Errorf("dictionary key is not a name at offset %d", p.pos)
+		}
+		value, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		if kw, ok := value.(keyword); ok {
+			return nil, fmt.Errorf("unexpected %q in dictionary at offset %d", string(kw), p.pos)
+		}
+		dict[name] = value
+	}
+}
+
+func (p *parser) parseNumber() (Object, error) {
+	if p.eof() {
+		return nil, errUnexpectedEOF
+	}
+	tok := p.readToken()
+	if tok == "" {
+		// a lone sign or dot followed by a delimiter
+		p.pos++
+		return 0, nil
+	}
+	if i, err := strconv.Atoi(tok); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", tok)
+	}
+	return f, nil
+}
+
+// parseNumberOrRef parses a number, looking ahead for the "num gen R" reference form
+func (p *parser) parseNumberOrRef() (Object, error) {
+	obj, err := p.parseNumber()
+	if err != nil {
+		return nil, err
+	}
+	num, ok := obj.(int)
+	if !ok || num < 0 {
+		return obj, nil
+	}
+
+	save := p.pos
+	p.skipSpace()
+	if p.eof() || p.data[p.pos] < '0' || p.data[p.pos] > '9' {
+		p.pos = save
+		return obj, nil
+	}
+	genObj, err := p.parseNumber()
+	gen, isInt := genObj.(int)
+	if err != nil || !isInt {
+		p.pos = save
+		return obj, nil
+	}
+	p.skipSpace()
+	if !p.eof() && p.data[p.pos] == 'R' && (p.pos+1 == len(p.data) || isWhitespace(p.data[p.pos+1]) || isDelimiter(p.data[p.pos+1])) {
+		p.pos++
+		return Ref{Num: num, Gen: gen}, nil
+	}
+	p.pos = save
+	return obj, nil
+}
+
+// expectKeyword consumes the given keyword or returns an error
+func (p *parser) expectKeyword(kw string) error {
+	p.skipSpace()
+	tok := p.readToken()
+	if tok != kw {
+		return fmt.Errorf("expected %q, found %q at offset %d", kw, tok, p.pos)
+	}
+	return nil
+}
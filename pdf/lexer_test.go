@@ -0,0 +1,72 @@
+package pdf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Object
+	}{
+		{"integer", "42", 42},
+		{"negative integer", "-17", -17},
+		{"real", "3.5", 3.5},
+		{"real without leading digit", "-.25", -.25},
+		{"true", "true", true},
+		{"false", "false", false},
+		{"null", "null", nil},
+		{"name", "/Type", Name("Type")},
+		{"name with escape", "/A#20B", Name("A B")},
+		{"literal string", "(Hello)", String("Hello")},
+		{"balanced parentheses", "(a (b) c)", String("a (b) c")},
+		{"escapes", `(\(\)\\\n\101)`, String("()\\\nA")},
+		{"line continuation", "(ab\\\ncd)", String("abcd")},
+		{"hex string", "<48656C6C6F>", String("Hello")},
+		{"hex string with odd digits", "<4 86>", String("H`")},
+		{"reference", "12 0 R", Ref{Num: 12}},
+		{"numbers, not a reference", "12 0", 12},
+		{"array", "[1 /Two (three) 4 0 R]", Array{1, Name("Two"), String("three"), Ref{Num: 4}}},
+		{"dictionary", "<< /Type /Page /Count 3 /Kids [] >>", Dict{"Type": Name("Page"), "Count": 3, "Kids": Array{}}},
+		{"keyword", "BT", keyword("BT")},
+		{"comment", "% comment\n7", 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &parser{data: []byte(tt.input)}
+			got, err := p.parseObject()
+			if err != nil {
+				t.Fatalf("parseObject(%q): %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseObject(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseObjectInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"unterminated string", "(abc"},
+		{"unterminated hex string", "<414"},
+		{"invalid hex string", "<4G>"},
+		{"unterminated array", "[1 2"},
+		{"unterminated dictionary", "<< /A 1"},
+		{"dictionary key not a name", "<< 1 2 >>"},
+		{"dictionary value a keyword", "<< /A BT >>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &parser{data: []byte(tt.input)}
+			if got, err := p.parseObject(); err == nil {
+				t.Errorf("parseObject(%q) = %#v, want an error", tt.input, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package pdf implements the subset of the PDF file format needed to inspect,
+// validate and amend uploaded documents: object parsing, cross-reference
+// resolution (tables, streams and object streams), page tree traversal and
+// incremental updates.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Object is any PDF object: nil (null), bool, int, float64, Name, String,
+// Array, Dict, Ref or *Stream.
+type Object interface{}
+
+// Name is a PDF name object such as /Type
+type Name string
+
+// String is a PDF string object (literal or hexadecimal)
+type String []byte
+
+// Array is a PDF array object
+type Array []Object
+
+// Dict is a PDF dictionary object
+type Dict map[Name]Object
+
+// Ref is an indirect reference to an object
+type Ref struct {
+	Num int
+	Gen int
+}
+
+// Stream is a PDF stream object. Data holds the raw, still encoded bytes.
+type Stream struct {
+	Dict Dict
+	Data []byte
+}
+
+// keyword is a bare token such as obj, stream or a content stream operator
+type keyword string
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%d %d R", r.Num, r.Gen)
+}
+
+// Int returns the integer value stored under key
+func (d Dict) Int(key Name) (int, bool) {
+	switch v := d[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// Name returns the name value stored under key
+func (d Dict) Name(key Name) (Name, bool) {
+	v, ok := d[key].(Name)
+	return v, ok
+}
+
+// Number converts an int or real object to float64
+func Number(obj Object) (float64, bool) {
+	switch v := obj.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Serialize encodes obj in PDF syntax
+func Serialize(obj Object) []byte {
+	var buf bytes.Buffer
+	writeObject(&buf, obj)
+	return buf.Bytes()
+}
+
+func writeObject(buf *bytes.Buffer, obj Object) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case Name:
+		writeName(buf, v)
+	case String:
+		writeString(buf, v)
+	case Array:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case Dict:
+		writeDict(buf, v)
+	case Ref:
+		buf.WriteString(v.String())
+	case *Stream:
+		dict := make(Dict, len(v.Dict)+1)
+		for k, val := range v.Dict {
+			dict[k] = val
+		}
+		dict["Length"] = len(v.Data)
+		writeDict(buf, dict)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.Data)
+		buf.WriteString("\nendstream")
+	case keyword:
+		buf.WriteString(string(v))
+	default:
+		buf.WriteString("null")
+	}
+}
+
+func writeDict(buf *bytes.Buffer, d Dict) {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+
+	buf.WriteString("<<")
+	for _, k := range keys {
+		writeName(buf, Name(k))
+		buf.WriteByte(' ')
+		writeObject(buf, d[Name(k)])
+	}
+	buf.WriteString(">>")
+}
+
+func writeName(buf *bytes.Buffer, n Name) {
+	buf.WriteByte('/')
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c < 0x21 || c > 0x7e || c == '#' || isDelimiter(c) {
+			fmt.Fprintf(buf, "#%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+}
+
+func writeString(buf *bytes.Buffer, s String) {
+	for _, c := range s {
+		if c < 0x20 || c > 0x7e {
+			fmt.Fprintf(buf, "<%X>", []byte(s))
+			return
+		}
+	}
+
+	buf.WriteByte('(')
+	for _, c := range s {
+		if c == '(' || c == ')' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	buf.WriteByte(')')
+}
@@ -0,0 +1,208 @@
+package pdf
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// Rectangle is a PDF rectangle in default user space units (points)
+type Rectangle struct {
+	LLX, LLY, URX, URY float64
+}
+
+// Width returns the rectangle width
+func (r Rectangle) Width() float64 {
+	return r.URX - r.LLX
+}
+
+// Height returns the rectangle height
+func (r Rectangle) Height() float64 {
+	return r.URY - r.LLY
+}
+
+// Page is a leaf of the page tree with its inheritable attributes resolved
+type Page struct {
+	Ref       Ref
+	Dict      Dict
+	MediaBox  Rectangle
+	CropBox   Rectangle
+	Resources Dict
+	Rotate    int
+}
+
+// defaultMediaBox is US Letter, used when a page declares no media box
+var defaultMediaBox = Rectangle{0, 0, 612, 792}
+
+// Pages returns all pages in document order
+func (d *Document) Pages() ([]Page, error) {
+	catalog, err := d.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	root, ok := catalog["Pages"].(Ref)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing page tree", ErrMalformed)
+	}
+
+	var pages []Page
+	visited := make(map[int]bool)
+	if err := d.walkPages(root, Dict{}, 0, visited, &pages); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// NumPages returns the number of pages in the document
+func (d *Document) NumPages() (int, error) {
+	pages, err := d.Pages()
+	if err != nil {
+		return 0, err
+	}
+	return len(pages), nil
+}
+
+func (d *Document) walkPages(ref Ref, inherited Dict, depth int, visited map[int]bool, pages *[]Page) error {
+	if depth > maxObjectDepth || visited[ref.Num] {
+		return fmt.Errorf("%w: page tree loop", ErrMalformed)
+	}
+	visited[ref.Num] = true
+
+	node, ok := d.ResolveDict(ref)
+	if !ok {
+		return fmt.Errorf("%w: invalid page tree node %d", ErrMalformed, ref.Num)
+	}
+
+	attrs := make(Dict, len(inherited))
+	for k, v := range inherited {
+		attrs[k] = v
+	}
+	for _, key := range []Name{"MediaBox", "CropBox", "Resources", "Rotate"} {
+		if v, ok := node[key]; ok {
+			attrs[key] = v
+		}
+	}
+
+	kids, hasKids := node["Kids"]
+	if t, _ := node.Name("Type"); t == "Pages" || (t == "" && hasKids) {
+		kidsObj, err := d.Resolve(kids)
+		if err != nil {
+			return err
+		}
+		arr, ok := kidsObj.(Array)
+		if !ok {
+			return fmt.Errorf("%w: invalid page tree kids", ErrMalformed)
+		}
+		for _, kid := range arr {
+			kidRef, ok := kid.(Ref)
+			if !ok {
+				return fmt.Errorf("%w: page tree kid is not a reference", ErrMalformed)
+			}
+			if err := d.walkPages(kidRef, attrs, depth+1, visited, pages); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	page := Page{Ref: ref, Dict: node, MediaBox: defaultMediaBox}
+	if box, ok := d.rectangle(attrs["MediaBox"]); ok {
+		page.MediaBox = box
+	}
+	page.CropBox = page.MediaBox
+	if box, ok := d.rectangle(attrs["CropBox"]); ok {
+		page.CropBox = box
+	}
+	if res, ok := d.ResolveDict(attrs["Resources"]); ok {
+		page.Resources = res
+	}
+	if rotate, err := d.Resolve(attrs["Rotate"]); err == nil {
+		if r, ok := rotate.(int); ok {
+			page.Rotate = ((r % 360) + 360) % 360
+		}
+	}
+	*pages = append(*pages, page)
+	return nil
+}
+
+func (d *Document) rectangle(obj Object) (Rectangle, bool) {
+	obj, err := d.Resolve(obj)
+	if err != nil {
+		return Rectangle{}, false
+	}
+	arr, ok := obj.(Array)
+	if !ok || len(arr) != 4 {
+		return Rectangle{}, false
+	}
+	var v [4]float64
+	for i, item := range arr {
+		item, _ = d.Resolve(item)
+		n, ok := Number(item)
+		if !ok {
+			return Rectangle{}, false
+		}
+		v[i] = n
+	}
+	return Rectangle{
+		LLX: min(v[0], v[2]), LLY: min(v[1], v[3]),
+		URX: max(v[0], v[2]), URY: max(v[1], v[3]),
+	}, true
+}
+
+// PageContent returns the decoded, concatenated content streams of a page
+func (d *Document) PageContent(page Page) ([]byte, error) {
+	contents, err := d.Resolve(page.Dict["Contents"])
+	if err != nil {
+		return nil, err
+	}
+
+	var streams []Object
+	switch v := contents.(type) {
+	case nil:
+		return nil, nil
+	case *Stream:
+		streams = []Object{v}
+	case Array:
+		streams = v
+	default:
+		return nil, fmt.Errorf("%w: invalid page contents", ErrMalformed)
+	}
+
+	var out []byte
+	for _, item := range streams {
+		obj, err := d.Resolve(item)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := obj.(*Stream)
+		if !ok {
+			continue
+		}
+		data, err := d.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data...)
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+// DecodeText decodes a PDF text string (UTF-16BE with byte order mark, UTF-8 with BOM, or PDFDocEncoding)
+func DecodeText(s String) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		units := make([]uint16, 0, (len(s)-2)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	if len(s) >= 3 && s[0] == 0xEF && s[1] == 0xBB && s[2] == 0xBF {
+		return string(s[3:])
+	}
+	// PDFDocEncoding matches Latin-1 for printable characters
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
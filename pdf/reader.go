@@ -0,0 +1,729 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// ErrNotPDF is returned when the data does not start with a PDF header
+	ErrNotPDF = errors.New("not a PDF file")
+	// ErrMalformed is returned when the document structure cannot be read
+	ErrMalformed = errors.New("malformed PDF file")
+)
+
+// maxObjectDepth bounds reference chains and page tree depth to guard against cycles
+const maxObjectDepth = 64
+
+// Files are untrusted, so reading a document is bounded: a small file must not make the reader
+// allocate or compute without limit. Documents exceeding a limit are malformed.
+const (
+	// maxNesting bounds arrays and dictionaries nested in each other
+	maxNesting = 256
+	// maxObjects bounds the objects a document may declare
+	maxObjects = 1 << 20
+	// maxStreamSize bounds the decoded data of one stream
+	maxStreamSize = 64 << 20
+	// maxDecodedSize bounds the decoded data of all streams of a document
+	maxDecodedSize = 256 << 20
+	// maxWork bounds the bytes parsed in total, including parts of the file read more than once
+	maxWork = 64 << 20
+	// objectCost is the work charged for every object loaded or visited besides its bytes, so
+	// that many tiny objects exhaust the budget too
+	objectCost = 64
+)
+
+type xrefEntry struct {
+	free   bool
+	offset int // byte offset for uncompressed objects
+	gen    int
+	stream int // object stream number for compressed objects
+	index  int // index inside the object stream
+}
+
+// Document is a parsed PDF file
+type Document struct {
+	data         []byte
+	xref         map[int]xrefEntry
+	trailer      Dict
+	startxref    int
+	xrefIsStream bool
+	repaired     bool
+	cache        map[int]Object
+	objStms      map[int]*objectStream
+	loading      map[int]bool // objects being loaded, to reject objects that need themselves
+	digests      map[*Stream][sha256.Size]byte
+	decoded      int   // bytes decoded from streams so far
+	work         int   // bytes parsed so far, see spend
+	err          error // set once the document exceeded a limit
+}
+
+type objectStream struct {
+	data    []byte
+	first   int
+	offsets []int
+}
+
+// Open parses the document structure. Objects are loaded lazily.
+func Open(data []byte) (*Document, error) {
+	header := bytes.Index(data[:min(len(data), 1024)], []byte("%PDF-"))
+	if header < 0 {
+		return nil, ErrNotPDF
+	}
+
+	d := &Document{
+		data:    data,
+		xref:    make(map[int]xrefEntry),
+		cache:   make(map[int]Object),
+		objStms: make(map[int]*objectStream),
+		loading: make(map[int]bool),
+	}
+
+	if err := d.loadXrefChain(); err != nil {
+		if d.err != nil {
+			return nil, d.err
+		}
+		// Fall back to scanning the file for objects, like most viewers do
+		if repairErr := d.reconstructXref(); repairErr != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		d.repaired = true
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if _, ok := d.trailer["Root"].(Ref); !ok {
+		return nil, fmt.Errorf("%w: missing document catalog", ErrMalformed)
+	}
+	return d, nil
+}
+
+// spend charges n bytes of parsing work and fails once the document used up its budget. The
+// failure sticks, so that callers ignoring an error still cannot make the document do more work.
+func (d *Document) spend(n int) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.work += n
+	if d.work > maxWork {
+		d.err = fmt.Errorf("%w: document takes too much work to read", ErrMalformed)
+	}
+	return d.err
+}
+
+// addEntry records a cross-reference entry unless a newer section already did
+func (d *Document) addEntry(num int, entry xrefEntry) error {
+	if _, known := d.xref[num]; known {
+		return nil
+	}
+	if len(d.xref) >= maxObjects {
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: more than %d objects", ErrMalformed, maxObjects)
+		}
+		return d.err
+	}
+	d.xref[num] = entry
+	return nil
+}
+
+// Trailer returns the most recent trailer dictionary
+func (d *Document) Trailer() Dict {
+	return d.trailer
+}
+
+// IsEncrypted reports whether the document uses the standard security handler or another encryption scheme
+func (d *Document) IsEncrypted() bool {
+	_, ok := d.trailer["Encrypt"]
+	return ok
+}
+
+// Repaired reports whether the cross-reference table was broken and had to be rebuilt
+func (d *Document) Repaired() bool {
+	return d.repaired
+}
+
+// Catalog returns the document catalog dictionary
+func (d *Document) Catalog() (Dict, error) {
+	root, err := d.Resolve(d.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := root.(Dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid document catalog", ErrMalformed)
+	}
+	return catalog, nil
+}
+
+// Info returns the document information dictionary entries as strings
+func (d *Document) Info() map[string]string {
+	info := make(map[string]string)
+	obj, err := d.Resolve(d.trailer["Info"])
+	if err != nil {
+		return info
+	}
+	dict, ok := obj.(Dict)
+	if !ok {
+		return info
+	}
+	for k, v := range dict {
+		v, _ = d.Resolve(v)
+		switch val := v.(type) {
+		case String:
+			info[string(k)] = DecodeText(val)
+		case Name:
+			info[string(k)] = string(val)
+		}
+	}
+	return info
+}
+
+// Resolve follows indirect references and returns the referenced object
+func (d *Document) Resolve(obj Object) (Object, error) {
+	for depth := 0; depth < maxObjectDepth; depth++ {
+		ref, ok := obj.(Ref)
+		if !ok {
+			return obj, nil
+		}
+		var err error
+		obj, err = d.loadObject(ref.Num)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: reference chain too deep", ErrMalformed)
+}
+
+// ResolveDict resolves obj and returns it as a dictionary (the dictionary of a stream is accepted)
+func (d *Document) ResolveDict(obj Object) (Dict, bool) {
+	obj, err := d.Resolve(obj)
+	if err != nil {
+		return nil, false
+	}
+	switch v := obj.(type) {
+	case Dict:
+		return v, true
+	case *Stream:
+		return v.Dict, true
+	}
+	return nil, false
+}
+
+// Size returns the number of object slots in use (the trailer /Size)
+func (d *Document) Size() int {
+	size, _ := d.trailer.Int("Size")
+	for num := range d.xref {
+		if num >= size {
+			size = num + 1
+		}
+	}
+	return size
+}
+
+func (d *Document) loadObject(num int) (Object, error) {
+	if obj, ok := d.cache[num]; ok {
+		return obj, nil
+	}
+
+	entry, ok := d.xref[num]
+	if !ok || entry.free {
+		return nil, nil // missing objects are treated as null
+	}
+	if d.loading[num] {
+		return nil, fmt.Errorf("%w: object %d refers to itself", ErrMalformed, num)
+	}
+	if err := d.spend(objectCost); err != nil {
+		return nil, err
+	}
+	d.loading[num] = true
+	defer delete(d.loading, num)
+
+	var obj Object
+	var err error
+	if entry.stream > 0 {
+		obj, err = d.loadCompressedObject(entry.stream, entry.index)
+	} else {
+		_, obj, err = d.parseIndirectObject(entry.offset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("object %d: %w", num, err)
+	}
+
+	d.cache[num] = obj
+	return obj, nil
+}
+
+// parseIndirectObject parses "num gen obj ... endobj" at offset
+func (d *Document) parseIndirectObject(offset int) (Ref, Object, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return Ref{}, nil, fmt.Errorf("offset %d out of range", offset)
+	}
+	p := &parser{data: d.data, pos: offset}
+	p.skipSpace()
+
+	numObj, err := p.parseNumber()
+	if err != nil {
+		return Ref{}, nil, err
+	}
+	p.skipSpace()
+	genObj, err := p.parseNumber()
+	if err != nil {
+		return Ref{}, nil, err
+	}
+	num, ok1 := numObj.(int)
+	gen, ok2 := genObj.(int)
+	if !ok1 || !ok2 {
+		return Ref{}, nil, fmt.Errorf("invalid object header at offset %d", offset)
+	}
+	if err := p.expectKeyword("obj"); err != nil {
+		return Ref{}, nil, err
+	}
+
+	obj, err := p.parseObject()
+	if spendErr := d.spend(p.pos - offset); spendErr != nil {
+		return Ref{}, nil, spendErr
+	}
+	if err != nil {
+		return Ref{}, nil, err
+	}
+
+	dict, isDict := obj.(Dict)
+	p.skipSpace()
+	if isDict && bytes.HasPrefix(d.data[p.pos:], []byte("stream")) {
+		stream, err := d.readStreamData(p, dict)
+		if err != nil {
+			return Ref{}, nil, err
+		}
+		obj = stream
+	}
+	return Ref{Num: num, Gen: gen}, obj, nil
+}
+
+func (d *Document) readStreamData(p *parser, dict Dict) (*Stream, error) {
+	p.pos += len("stream")
+	if p.pos < len(d.data) && d.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(d.data) && d.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	length := -1
+	switch v := dict["Length"].(type) {
+	case int:
+		length = v
+	case Ref:
+		if obj, err := d.Resolve(v); err == nil {
+			if n, ok := obj.(int); ok {
+				length = n
+			}
+		}
+	}
+
+	if length >= 0 && start+length <= len(d.data) {
+		rest := bytes.TrimLeft(d.data[start+length:min(len(d.data), start+length+32)], "\r\n \t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return &Stream{Dict: dict, Data: d.data[start : start+length]}, nil
+		}
+	}
+
+	// Length is missing or wrong, fall back to searching for endstream
+	end := bytes.Index(d.data[start:], []byte("endstream"))
+	searched := end
+	if end < 0 {
+		searched = len(d.data) - start
+	}
+	if err := d.spend(searched); err != nil {
+		return nil, err
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated stream at offset %d", start)
+	}
+	data := bytes.TrimRight(d.data[start:start+end], "\r\n")
+	return &Stream{Dict: dict, Data: data}, nil
+}
+
+func (d *Document) loadCompressedObject(streamNum, index int) (Object, error) {
+	stm, ok := d.objStms[streamNum]
+	if !ok {
+		obj, err := d.loadObject(streamNum)
+		if err != nil {
+			return nil, err
+		}
+		s, isStream := obj.(*Stream)
+		if !isStream {
+			return nil, fmt.Errorf("object stream %d is not a stream", streamNum)
+		}
+		data, err := d.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := s.Dict.Int("N")
+		first, _ := s.Dict.Int("First")
+		if n < 0 || n > maxObjects || first < 0 || first > len(data) {
+			return nil, fmt.Errorf("invalid object stream %d", streamNum)
+		}
+		if err := d.spend(first); err != nil {
+			return nil, err
+		}
+
+		hp := &parser{data: data[:first]}
+		offsets := make([]int, 0, n)
+		for i := 0; i < n; i++ {
+			hp.skipSpace()
+			if _, err := hp.parseNumber(); err != nil {
+				return nil, err
+			}
+			hp.skipSpace()
+			off, err := hp.parseNumber()
+			if err != nil {
+				return nil, err
+			}
+			offset, isInt := off.(int)
+			if !isInt {
+				return nil, fmt.Errorf("invalid object stream %d header", streamNum)
+			}
+			offsets = append(offsets, offset)
+		}
+		stm = &objectStream{data: data, first: first, offsets: offsets}
+		d.objStms[streamNum] = stm
+	}
+
+	if index < 0 || index >= len(stm.offsets) {
+		return nil, fmt.Errorf("object index %d out of range in object stream %d", index, streamNum)
+	}
+	start := stm.first + stm.offsets[index]
+	if start < 0 || start > len(stm.data) {
+		return nil, fmt.Errorf("object index %d out of range in object stream %d", index, streamNum)
+	}
+	p := &parser{data: stm.data, pos: start}
+	obj, err := p.parseObject()
+	if spendErr := d.spend(p.pos - start); spendErr != nil {
+		return nil, spendErr
+	}
+	return obj, err
+}
+
+// loadXrefChain reads the newest cross-reference section and all previous ones
+func (d *Document) loadXrefChain() error {
+	idx := bytes.LastIndex(d.data, []byte("startxref"))
+	if idx < 0 {
+		return fmt.Errorf("startxref not found")
+	}
+	p := &parser{data: d.data, pos: idx + len("startxref")}
+	p.skipSpace()
+	offObj, err := p.parseNumber()
+	if err != nil {
+		return err
+	}
+	offset, ok := offObj.(int)
+	if !ok {
+		return fmt.Errorf("invalid startxref")
+	}
+	d.startxref = offset
+
+	visited := make(map[int]bool)
+	for i := 0; ; i++ {
+		if visited[offset] || i >= maxObjectDepth {
+			return fmt.Errorf("cross-reference chain loop")
+		}
+		visited[offset] = true
+
+		trailer, isStream, err := d.loadXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			d.trailer = trailer
+			d.xrefIsStream = isStream
+		}
+
+		// Hybrid-reference files keep compressed entries in an additional stream
+		if stmOffset, ok := trailer.Int("XRefStm"); ok && !visited[stmOffset] {
+			visited[stmOffset] = true
+			if _, _, err := d.loadXrefSection(stmOffset); err != nil {
+				return err
+			}
+		}
+
+		prev, ok := trailer.Int("Prev")
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+	return nil
+}
+
+// loadXrefSection reads a cross-reference table or stream at offset.
+// Entries already known from newer sections take precedence.
+func (d *Document) loadXrefSection(offset int) (Dict, bool, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return nil, false, fmt.Errorf("cross-reference offset %d out of range", offset)
+	}
+	p := &parser{data: d.data, pos: offset}
+	p.skipSpace()
+	if bytes.HasPrefix(d.data[p.pos:], []byte("xref")) {
+		trailer, err := d.loadXrefTable(p)
+		return trailer, false, err
+	}
+
+	_, obj, err := d.parseIndirectObject(offset)
+	if err != nil {
+		return nil, false, err
+	}
+	stream, ok := obj.(*Stream)
+	if !ok {
+		return nil, false, fmt.Errorf("invalid cross-reference section at offset %d", offset)
+	}
+	if t, _ := stream.Dict.Name("Type"); t != "XRef" {
+		return nil, false, fmt.Errorf("invalid cross-reference stream at offset %d", offset)
+	}
+	if err := d.loadXrefStream(stream); err != nil {
+		return nil, false, err
+	}
+	return stream.Dict, true, nil
+}
+
+func (d *Document) loadXrefTable(p *parser) (Dict, error) {
+	p.pos += len("xref")
+	for {
+		p.skipSpace()
+		if bytes.HasPrefix(d.data[p.pos:], []byte("trailer")) {
+			p.pos += len("trailer")
+			obj, err := p.parseObject()
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := obj.(Dict)
+			if !ok {
+				return nil, fmt.Errorf("invalid trailer")
+			}
+			return trailer, nil
+		}
+
+		startObj, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		countObj, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		start, ok1 := startObj.(int)
+		count, ok2 := countObj.(int)
+		if !ok1 || !ok2 || start < 0 || count < 0 {
+			return nil, fmt.Errorf("invalid cross-reference subsection")
+		}
+
+		for i := 0; i < count; i++ {
+			p.skipSpace()
+			off := p.readToken()
+			p.skipSpace()
+			gen := p.readToken()
+			p.skipSpace()
+			kind := p.readToken()
+			offset, err1 := strconv.Atoi(off)
+			generation, err2 := strconv.Atoi(gen)
+			if err1 != nil || err2 != nil || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("invalid cross-reference entry")
+			}
+			if err := d.addEntry(start+i, xrefEntry{free: kind == "f", offset: offset, gen: generation}); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *Document) loadXrefStream(stream *Stream) error {
+	data, err := d.Decode(stream)
+	if err != nil {
+		return err
+	}
+
+	wArr, ok := stream.Dict["W"].(Array)
+	if !ok || len(wArr) != 3 {
+		return fmt.Errorf("invalid cross-reference stream widths")
+	}
+	var widths [3]int
+	rowLen := 0
+	for i, w := range wArr {
+		n, ok := w.(int)
+		if !ok || n < 0 || n > 8 {
+			return fmt.Errorf("invalid cross-reference stream widths")
+		}
+		widths[i] = n
+		rowLen += n
+	}
+	if rowLen == 0 {
+		return fmt.Errorf("invalid cross-reference stream widths")
+	}
+
+	size, _ := stream.Dict.Int("Size")
+	index := Array{0, size}
+	if arr, ok := stream.Dict["Index"].(Array); ok {
+		index = arr
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, ok1 := index[i].(int)
+		count, ok2 := index[i+1].(int)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("invalid cross-reference stream index")
+		}
+		for j := 0; j < count; j++ {
+			if pos+rowLen > len(data) {
+				return fmt.Errorf("truncated cross-reference stream")
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+
+			fields := [3]int{1, 0, 0} // type defaults to 1 when its width is 0
+			off := 0
+			for k := 0; k < 3; k++ {
+				if widths[k] == 0 {
+					continue
+				}
+				v := 0
+				for _, b := range row[off : off+widths[k]] {
+					v = v<<8 | int(b)
+				}
+				fields[k] = v
+				off += widths[k]
+			}
+
+			var entry xrefEntry
+			switch fields[0] {
+			case 0:
+				entry = xrefEntry{free: true}
+			case 1:
+				entry = xrefEntry{offset: fields[1], gen: fields[2]}
+			case 2:
+				entry = xrefEntry{stream: fields[1], index: fields[2]}
+			default:
+				continue
+			}
+			if err := d.addEntry(start+j, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var objectHeaderPattern = regexp.MustCompile(`(?m)(?:^|[\r\n\s])(\d{1,10})\s+(\d{1,5})\s+obj\b`)
+
+// reconstructXref rebuilds the cross-reference table by scanning for object headers
+func (d *Document) reconstructXref() error {
+	d.xref = make(map[int]xrefEntry)
+	d.cache = make(map[int]Object)
+	d.objStms = make(map[int]*objectStream)
+	d.trailer = nil
+
+	for _, m := range objectHeaderPattern.FindAllSubmatchIndex(d.data, maxObjects+1) {
+		if len(d.xref) >= maxObjects {
+			return fmt.Errorf("more than %d objects", maxObjects)
+		}
+		num, _ := strconv.Atoi(string(d.data[m[2]:m[3]]))
+		gen, _ := strconv.Atoi(string(d.data[m[4]:m[5]]))
+		d.xref[num] = xrefEntry{offset: m[2], gen: gen}
+	}
+	if len(d.xref) == 0 {
+		return fmt.Errorf("no objects found")
+	}
+
+	// Register objects stored inside object streams and look for the catalog
+	var root Ref
+	for num, entry := range d.xref {
+		if entry.stream > 0 {
+			continue
+		}
+		_, obj, err := d.parseIndirectObject(entry.offset)
+		if err != nil {
+			continue
+		}
+		switch v := obj.(type) {
+		case *Stream:
+			if t, _ := v.Dict.Name("Type"); t == "ObjStm" {
+				d.registerObjectStream(num, v)
+			}
+		case Dict:
+			if t, _ := v.Name("Type"); t == "Catalog" {
+				root = Ref{Num: num, Gen: entry.gen}
+			}
+		}
+	}
+
+	// Catalogs of PDF 1.5+ files usually live inside an object stream
+	if root.Num == 0 {
+		for num, entry := range d.xref {
+			if entry.stream == 0 {
+				continue
+			}
+			if dict, ok := d.ResolveDict(Ref{Num: num}); ok {
+				if t, _ := dict.Name("Type"); t == "Catalog" {
+					root = Ref{Num: num}
+					break
+				}
+			}
+		}
+	}
+
+	if idx := bytes.LastIndex(d.data, []byte("trailer")); idx >= 0 {
+		p := &parser{data: d.data, pos: idx + len("trailer")}
+		if obj, err := p.parseObject(); err == nil {
+			if trailer, ok := obj.(Dict); ok {
+				d.trailer = trailer
+			}
+		}
+	}
+	if d.trailer == nil {
+		d.trailer = Dict{}
+	}
+	if _, ok := d.trailer["Root"].(Ref); !ok {
+		if root.Num == 0 {
+			return fmt.Errorf("document catalog not found")
+		}
+		d.trailer["Root"] = root
+	}
+	delete(d.trailer, "Prev")
+	d.trailer["Size"] = d.Size()
+	return nil
+}
+
+func (d *Document) registerObjectStream(num int, s *Stream) {
+	data, err := d.Decode(s)
+	if err != nil {
+		return
+	}
+	n, _ := s.Dict.Int("N")
+	first, _ := s.Dict.Int("First")
+	if first < 0 || first > len(data) || d.spend(first) != nil {
+		return
+	}
+	p := &parser{data: data[:first]}
+	for i := 0; i < n; i++ {
+		p.skipSpace()
+		objNum, err := p.parseNumber()
+		if err != nil {
+			return
+		}
+		p.skipSpace()
+		if _, err := p.parseNumber(); err != nil {
+			return
+		}
+		if n, ok := objNum.(int); ok {
+			if d.addEntry(n, xrefEntry{stream: num, index: i}) != nil {
+				return
+			}
+		}
+	}
+}
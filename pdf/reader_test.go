@@ -0,0 +1,367 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildPDF returns a file holding the objects numbered from 1, a cross-reference table and a trailer
+// naming object 1 the catalog
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	startxref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, startxref)
+	return buf.Bytes()
+}
+
+// streamObject returns a stream object with the given dictionary entries
+func streamObject(entries string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", entries, len(data), data)
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// helloPDF is a one page document showing "Hello World" with a compressed content stream
+func helloPDF() []byte {
+	return buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 200 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		streamObject("/Filter /FlateDecode", deflate([]byte("BT /F1 12 Tf 10 50 Td (Hello World) Tj ET"))),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+}
+
+// objectStreamPDF is a document keeping its catalog and pages in an object stream, indexed by a
+// cross-reference stream
+func objectStreamPDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 300 400] >>",
+	}
+	var header, body strings.Builder
+	for i, obj := range objects {
+		fmt.Fprintf(&header, "%d %d ", i+1, body.Len())
+		body.WriteString(obj + "\n")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	objStm := buf.Len()
+	data := header.String() + body.String()
+	fmt.Fprintf(&buf, "4 0 obj\n%s\nendobj\n", streamObject(fmt.Sprintf("/Type /ObjStm /N 3 /First %d", header.Len()), []byte(data)))
+
+	xref := buf.Len()
+	rows := []byte{0, 0, 0, 0}
+	for i := range objects {
+		rows = append(rows, 2, 0, 4, byte(i))
+	}
+	rows = append(rows, 1, byte(objStm>>8), byte(objStm), 0, 1, byte(xref>>8), byte(xref), 0)
+	fmt.Fprintf(&buf, "5 0 obj\n%s\nendobj\n", streamObject("/Type /XRef /Size 6 /W [1 2 1] /Root 1 0 R /Filter /FlateDecode", deflate(rows)))
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+func TestOpen(t *testing.T) {
+	d, err := Open(helloPDF())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if d.Repaired() || d.IsEncrypted() {
+		t.Errorf("Repaired = %v, IsEncrypted = %v, want neither", d.Repaired(), d.IsEncrypted())
+	}
+
+	pages, err := d.Pages()
+	if err != nil {
+		t.Fatalf("Pages: %v", err)
+	}
+	if len(pages) != 1 {
+		t.Fatalf("Pages = %d pages, want 1", len(pages))
+	}
+	if want := (Rectangle{0, 0, 200, 100}); pages[0].MediaBox != want || pages[0].CropBox != want {
+		t.Errorf("inherited MediaBox = %v, CropBox = %v, want %v", pages[0].MediaBox, pages[0].CropBox, want)
+	}
+	content, err := d.PageContent(pages[0])
+	if err != nil {
+		t.Fatalf("PageContent: %v", err)
+	}
+	if !bytes.Contains(content, []byte("(Hello World) Tj")) {
+		t.Errorf("PageContent = %q", content)
+	}
+	text, err := d.PageText(pages[0])
+	if err != nil {
+		t.Fatalf("PageText: %v", err)
+	}
+	if len(text) != 1 || text[0] != "Hello World" {
+		t.Errorf("PageText = %q, want [Hello World]", text)
+	}
+	if fonts := d.PageFonts(pages[0]); len(fonts) != 1 || fonts[0].Name != "Helvetica" || fonts[0].Embedded {
+		t.Errorf("PageFonts = %+v", fonts)
+	}
+}
+
+func TestOpenObjectStream(t *testing.T) {
+	d, err := Open(objectStreamPDF())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if d.Repaired() {
+		t.Error("Repaired = true, want the cross-reference stream read")
+	}
+	pages, err := d.Pages()
+	if err != nil {
+		t.Fatalf("Pages: %v", err)
+	}
+	if len(pages) != 1 || pages[0].MediaBox != (Rectangle{0, 0, 300, 400}) {
+		t.Errorf("Pages = %+v", pages)
+	}
+}
+
+func TestOpenRepair(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"wrong startxref", bytes.Replace(helloPDF(), []byte("startxref\n"), []byte("startxref\n1"), 1)},
+		{"no cross-reference table", helloPDF()[:bytes.Index(helloPDF(), []byte("xref"))]},
+		{"shifted objects", bytes.Replace(helloPDF(), []byte("%PDF-1.7\n"), []byte("%PDF-1.7\n% a comment\n"), 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Open(tt.data)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			pages, err := d.Pages()
+			if err != nil || len(pages) != 1 {
+				t.Fatalf("Pages = %d pages, %v; want 1", len(pages), err)
+			}
+			if text, err := d.PageText(pages[0]); err != nil || len(text) != 1 || text[0] != "Hello World" {
+				t.Errorf("PageText = %q, %v", text, err)
+			}
+		})
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrNotPDF},
+		{"not a PDF", []byte("GIF89a"), ErrNotPDF},
+		{"header only", []byte("%PDF-1.7\n"), ErrMalformed},
+		{"no catalog", []byte("%PDF-1.7\n1 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n"), ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Open error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelfReference(t *testing.T) {
+	// The length of the content stream is the stream itself
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		"<< /Length 4 0 R >>\nstream\nBT (Looped) Tj ET\nendstream",
+	)
+	d, err := Open(data)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	pages, err := d.Pages()
+	if err != nil {
+		t.Fatalf("Pages: %v", err)
+	}
+	// The stream is read up to endstream instead
+	if text, err := d.PageText(pages[0]); err != nil || len(text) != 1 || text[0] != "Looped" {
+		t.Errorf("PageText = %q, %v", text, err)
+	}
+
+	// Page trees may not contain themselves
+	data = buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [2 0 R] /Count 1 >>",
+	)
+	if d, err = Open(data); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := d.Pages(); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Pages error = %v, want %v", err, ErrMalformed)
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	deep := strings.Repeat("[", maxNesting+1) + strings.Repeat("]", maxNesting+1)
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R /Deep 3 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		deep,
+	)
+	d, err := Open(data)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := d.Resolve(Ref{Num: 3}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Resolve error = %v, want %v", err, ErrMalformed)
+	}
+
+	shallow := strings.Repeat("[", maxNesting) + strings.Repeat("]", maxNesting)
+	p := &parser{data: []byte(shallow)}
+	if _, err := p.parseObject(); err != nil {
+		t.Errorf("parseObject at the nesting limit: %v", err)
+	}
+}
+
+// pagePDF returns a one page document with the given contents entry, followed by the objects from 4
+func pagePDF(contents string, objects ...string) []byte {
+	return buildPDF(append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /X 4 0 R >> >> /Contents " + contents + " >>",
+	}, objects...)...)
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		// One stream inflating to more than a stream may
+		{"stream", pagePDF("4 0 R", streamObject("/Filter /FlateDecode", deflate(make([]byte, maxStreamSize+1))))},
+		// A stream small enough on its own, drawn until the document decoded too much
+		{"document", pagePDF("["+strings.Repeat("4 0 R ", maxDecodedSize/(1<<20)+1)+"]", streamObject("/Filter /FlateDecode", deflate(make([]byte, 1<<20))))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.data) > 1<<20 {
+				t.Fatalf("bomb is %d bytes, want a small file", len(tt.data))
+			}
+			d, err := Open(tt.data)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			pages, err := d.Pages()
+			if err != nil {
+				t.Fatalf("Pages: %v", err)
+			}
+			if _, err := d.PageContent(pages[0]); !errors.Is(err, ErrMalformed) {
+				t.Errorf("PageContent error = %v, want %v", err, ErrMalformed)
+			}
+			// The document stays failed for every other way of decoding the page
+			if _, err := d.PageText(pages[0]); !errors.Is(err, ErrMalformed) {
+				t.Errorf("PageText error = %v, want %v", err, ErrMalformed)
+			}
+			if _, err := d.PageFingerprint(pages[0]); !errors.Is(err, ErrMalformed) {
+				t.Errorf("PageFingerprint error = %v, want %v", err, ErrMalformed)
+			}
+		})
+	}
+}
+
+func TestFormFanOut(t *testing.T) {
+	// A form drawing itself a thousand times would be drawn 1000^16 times up to the form depth limit
+	data := pagePDF("5 0 R",
+		streamObject("/Type /XObject /Subtype /Form /Resources << /XObject << /X 4 0 R >> >>", bytes.Repeat([]byte("/X Do "), 1000)),
+		streamObject("", []byte("/X Do")),
+	)
+	d, err := Open(data)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	pages, err := d.Pages()
+	if err != nil {
+		t.Fatalf("Pages: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := d.PageText(pages[0]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("PageText error = %v, want %v", err, ErrMalformed)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("PageText took %v", elapsed)
+	}
+}
+
+func TestToUnicodeRanges(t *testing.T) {
+	// A range ending at the largest code must not wrap around
+	mapping, _ := parseToUnicode([]byte("1 beginbfrange <FFFF0000> <FFFFFFFF> <0041> endbfrange"))
+	if len(mapping) != 0x10000 || mapping[0xFFFF0000] != "A" || mapping[0xFFFF0001] != "B" {
+		t.Errorf("parseToUnicode = %d codes, %q %q", len(mapping), mapping[0xFFFF0000], mapping[0xFFFF0001])
+	}
+
+	// Ranges stop being expanded once the font mapped enough codes
+	var cmap strings.Builder
+	cmap.WriteString("100 beginbfrange\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&cmap, "<%04X0000> <%04XFFFF> <0041>\n", i, i)
+	}
+	cmap.WriteString("endbfrange")
+	if mapping, _ = parseToUnicode([]byte(cmap.String())); len(mapping) > maxCodes+0x10000 {
+		t.Errorf("parseToUnicode = %d codes, want at most %d", len(mapping), maxCodes+0x10000)
+	}
+}
+
+func FuzzOpen(f *testing.F) {
+	f.Add(helloPDF())
+	f.Add(objectStreamPDF())
+	f.Add(pagePDF("5 0 R",
+		streamObject("/Type /XObject /Subtype /Form /Resources << /XObject << /X 4 0 R >> >>", []byte("/X Do /X Do")),
+		streamObject("", []byte("/X Do")),
+	))
+	f.Add(buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		streamObject("", []byte("BT /F1 12 Tf <0001> Tj ET")),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Font /DescendantFonts [<< /W [1 [500] 2 10 600] >>] /ToUnicode 6 0 R >>",
+		streamObject("", []byte("1 begincodespacerange <0000> <FFFF> endcodespacerange 1 beginbfrange <0000> <00FF> <0041> endbfrange")),
+	))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := Open(data)
+		if err != nil {
+			return
+		}
+		d.Info()
+		pages, err := d.Pages()
+		if err != nil {
+			return
+		}
+		for i, page := range pages[:min(len(pages), 4)] {
+			d.PageContent(page)
+			d.PageText(page)
+			d.PageFingerprint(page)
+			d.PageFonts(page)
+			d.ExtractPage(i)
+		}
+	})
+}
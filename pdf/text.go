@@ -0,0 +1,335 @@
+package pdf
+
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+const (
+	// lineTolerance is the baseline shift, relative to the font size, that starts a new line
+	lineTolerance = 0.5
+	// wordGap is the horizontal gap, relative to the font size, that separates two words
+	wordGap = 0.15
+	// maxFormDepth bounds nested form XObjects
+	maxFormDepth = 16
+)
+
+// matrix is a PDF transformation matrix [a b c d e f]
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// multiply returns m × n
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(tx, ty float64) matrix {
+	return matrix{1, 0, 0, 1, tx, ty}
+}
+
+// graphicsState is the part of the graphics state that affects text placement
+type graphicsState struct {
+	ctm         matrix
+	font        *fontDecoder
+	size        float64
+	charSpacing float64
+	wordSpacing float64
+	scale       float64 // horizontal scaling, 1 = 100%
+	leading     float64
+}
+
+// textExtractor interprets content streams and collects the shown text line by line
+type textExtractor struct {
+	doc   *Document
+	fonts map[Ref]*fontDecoder
+
+	lines   []string
+	line    strings.Builder
+	started bool
+	lineY   float64
+	lineEnd float64 // device x coordinate where the last shown string ended
+}
+
+// PageText extracts the text of a page as lines in content stream order. Strings shown on the same
+// baseline are joined into one line, with a space where they are visibly apart. Text is decoded with
+// the ToUnicode map of each font, falling back to its encoding; unmappable characters become U+FFFD.
+// Malformed content ends the extraction without an error, keeping the text read so far, unless the
+// document exceeded a limit.
+func (d *Document) PageText(page Page) ([]string, error) {
+	content, err := d.PageContent(page)
+	if err != nil {
+		return nil, err
+	}
+	e := &textExtractor{doc: d, fonts: map[Ref]*fontDecoder{}}
+	e.run(content, page.Resources, identity, 0)
+	if d.err != nil {
+		return nil, d.err
+	}
+	e.flushLine()
+	return e.lines, nil
+}
+
+func (e *textExtractor) run(content []byte, resources Dict, ctm matrix, depth int) {
+	// Forms drawn over and over are charged every time, however small they are
+	if e.doc.spend(len(content)+objectCost) != nil {
+		return
+	}
+	p := &parser{data: content}
+	gs := graphicsState{ctm: ctm, font: e.doc.newFontDecoder(nil), scale: 1}
+	var stack []graphicsState
+	var tm, tlm matrix
+	var operands []Object
+
+	nextLine := func() {
+		tlm = translate(0, -gs.leading).multiply(tlm)
+		tm = tlm
+	}
+
+	for {
+		obj, err := p.parseObject()
+		if err != nil {
+			return
+		}
+		op, ok := obj.(keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		nums := numbers(operands)
+		switch op {
+		case "q":
+			stack = append(stack, gs)
+		case "Q":
+			if len(stack) > 0 {
+				gs = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if len(nums) == 6 {
+				gs.ctm = matrix(nums).multiply(gs.ctm)
+			}
+		case "BT":
+			tm, tlm = identity, identity
+		case "Tf":
+			if len(operands) == 2 {
+				if name, ok := operands[0].(Name); ok {
+					gs.font = e.font(resources, name)
+				}
+				if size, ok := Number(operands[1]); ok {
+					gs.size = size
+				}
+			}
+		case "Tc":
+			if len(nums) == 1 {
+				gs.charSpacing = nums[0]
+			}
+		case "Tw":
+			if len(nums) == 1 {
+				gs.wordSpacing = nums[0]
+			}
+		case "Tz":
+			if len(nums) == 1 {
+				gs.scale = nums[0] / 100
+			}
+		case "TL":
+			if len(nums) == 1 {
+				gs.leading = nums[0]
+			}
+		case "Td", "TD":
+			if len(nums) == 2 {
+				if op == "TD" {
+					gs.leading = -nums[1]
+				}
+				tlm = translate(nums[0], nums[1]).multiply(tlm)
+				tm = tlm
+			}
+		case "Tm":
+			if len(nums) == 6 {
+				tlm = matrix(nums)
+				tm = tlm
+			}
+		case "T*":
+			nextLine()
+		case "Tj":
+			if len(operands) == 1 {
+				if s, ok := operands[0].(String); ok {
+					e.show(&gs, &tm, s)
+				}
+			}
+		case "'":
+			nextLine()
+			if len(operands) == 1 {
+				if s, ok := operands[0].(String); ok {
+					e.show(&gs, &tm, s)
+				}
+			}
+		case "\"":
+			if len(operands) == 3 {
+				gs.wordSpacing, _ = Number(operands[0])
+				gs.charSpacing, _ = Number(operands[1])
+				nextLine()
+				if s, ok := operands[2].(String); ok {
+					e.show(&gs, &tm, s)
+				}
+			}
+		case "TJ":
+			if len(operands) == 1 {
+				items, _ := operands[0].(Array)
+				for _, item := range items {
+					if s, ok := item.(String); ok {
+						e.show(&gs, &tm, s)
+					} else if n, ok := Number(item); ok {
+						tm = translate(-n/1000*gs.size*gs.scale, 0).multiply(tm)
+					}
+				}
+			}
+		case "Do":
+			if len(operands) == 1 && depth < maxFormDepth {
+				if name, ok := operands[0].(Name); ok {
+					e.form(resources, name, gs.ctm, depth)
+				}
+			}
+		case "BI":
+			skipInlineImage(p)
+		}
+		operands = operands[:0]
+	}
+}
+
+// show places a string at the current text position and advances the text matrix past it
+func (e *textExtractor) show(gs *graphicsState, tm *matrix, s String) {
+	trm := tm.multiply(gs.ctm)
+	size := math.Abs(gs.size) * math.Hypot(trm[2], trm[3])
+	if size == 0 {
+		size = 1
+	}
+	e.place(trm[4], trm[5], size)
+
+	for _, g := range gs.font.decode(s) {
+		for _, r := range g.text {
+			if r >= ' ' {
+				e.line.WriteRune(r)
+			}
+		}
+		advance := g.width/1000*gs.size + gs.charSpacing
+		if g.space {
+			advance += gs.wordSpacing
+		}
+		*tm = translate(advance*gs.scale, 0).multiply(*tm)
+	}
+	e.lineEnd = tm.multiply(gs.ctm)[4]
+}
+
+// place starts a new line when the baseline moved and separates words that are visibly apart
+func (e *textExtractor) place(x, y, size float64) {
+	if !e.started || math.Abs(y-e.lineY) > size*lineTolerance {
+		e.flushLine()
+		e.started = true
+		e.lineY = y
+		return
+	}
+	if x-e.lineEnd > size*wordGap || x < e.lineEnd-size {
+		e.line.WriteByte(' ')
+	}
+}
+
+func (e *textExtractor) flushLine() {
+	if line := strings.Join(strings.Fields(e.line.String()), " "); line != "" {
+		e.lines = append(e.lines, line)
+	}
+	e.line.Reset()
+}
+
+// font returns the decoder of a font resource, caching fonts stored as indirect objects
+func (e *textExtractor) font(resources Dict, name Name) *fontDecoder {
+	fonts, _ := e.doc.ResolveDict(resources["Font"])
+	obj := fonts[name]
+	ref, isRef := obj.(Ref)
+	if isRef {
+		if dec, ok := e.fonts[ref]; ok {
+			return dec
+		}
+	}
+	font, _ := e.doc.ResolveDict(obj)
+	dec := e.doc.newFontDecoder(font)
+	if isRef {
+		e.fonts[ref] = dec
+	}
+	return dec
+}
+
+// form extracts the text of a form XObject drawn with the Do operator
+func (e *textExtractor) form(resources Dict, name Name, ctm matrix, depth int) {
+	xobjects, _ := e.doc.ResolveDict(resources["XObject"])
+	form, ok := e.doc.formXObject(xobjects[name])
+	if !ok {
+		return
+	}
+	content, err := e.doc.Decode(form)
+	if err != nil {
+		return
+	}
+	if m, err := e.doc.Resolve(form.Dict["Matrix"]); err == nil {
+		if arr, ok := m.(Array); ok {
+			if nums := numbers(arr); len(nums) == 6 {
+				ctm = matrix(nums).multiply(ctm)
+			}
+		}
+	}
+	formResources := resources
+	if res, ok := e.doc.ResolveDict(form.Dict["Resources"]); ok {
+		formResources = res
+	}
+	e.run(content, formResources, ctm, depth+1)
+}
+
+// numbers returns the operands as numbers, or nil if any of them is not a number
+func numbers(operands []Object) []float64 {
+	nums := make([]float64, len(operands))
+	for i, obj := range operands {
+		n, ok := Number(obj)
+		if !ok {
+			return nil
+		}
+		nums[i] = n
+	}
+	return nums
+}
+
+// skipInlineImage moves the parser past the dictionary and data of an inline image following BI
+func skipInlineImage(p *parser) {
+	for {
+		obj, err := p.parseObject()
+		if err != nil {
+			return
+		}
+		if kw, ok := obj.(keyword); ok && kw == "ID" {
+			break
+		}
+	}
+	p.pos++ // single whitespace after ID
+	skipToInlineImageEnd(p)
+}
+
+// skipToInlineImageEnd moves the parser past the EI operator ending inline image data
+func skipToInlineImageEnd(p *parser) {
+	for p.pos < len(p.data) {
+		i := bytes.Index(p.data[p.pos:], []byte("EI"))
+		if i < 0 {
+			p.pos = len(p.data)
+			return
+		}
+		end := p.pos + i
+		p.pos = end + 2
+		if end > 0 && isWhitespace(p.data[end-1]) && (p.pos == len(p.data) || isWhitespace(p.data[p.pos])) {
+			return
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrEncrypted is returned when modifying an encrypted document is attempted
+var ErrEncrypted = errors.New("PDF file is encrypted")
+
+// Update collects objects to append to a document as an incremental update,
+// leaving the original bytes (and any signatures over them) untouched.
+type Update struct {
+	doc     *Document
+	objects map[int]Object
+	gens    map[int]int
+	next    int
+}
+
+// NewUpdate starts an incremental update of the document
+func (d *Document) NewUpdate() (*Update, error) {
+	if d.IsEncrypted() {
+		return nil, ErrEncrypted
+	}
+	return &Update{
+		doc:     d,
+		objects: make(map[int]Object),
+		gens:    make(map[int]int),
+		next:    d.Size(),
+	}, nil
+}
+
+// Add appends a new indirect object and returns its reference
+func (u *Update) Add(obj Object) Ref {
+	ref := Ref{Num: u.next}
+	u.next++
+	u.objects[ref.Num] = obj
+	return ref
+}
+
+// Replace stores a new version of an existing object
+func (u *Update) Replace(ref Ref, obj Object) {
+	u.objects[ref.Num] = obj
+	u.gens[ref.Num] = ref.Gen
+}
+
+// Bytes returns the original document followed by the incremental update
+func (u *Update) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(u.doc.data)
+	if !bytes.HasSuffix(u.doc.data, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(u.objects))
+	for num := range u.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	entries := make(map[int]xrefEntry, len(nums))
+	if u.doc.repaired {
+		// A repaired file has no usable previous section to chain to, so every object is listed again
+		for num, entry := range u.doc.xref {
+			if num > 0 && !entry.free {
+				entries[num] = entry
+			}
+		}
+	}
+	for _, num := range nums {
+		entries[num] = xrefEntry{offset: buf.Len(), gen: u.gens[num]}
+		fmt.Fprintf(&buf, "%d %d obj\n", num, u.gens[num])
+		buf.Write(Serialize(u.objects[num]))
+		buf.WriteString("\nendobj\n")
+	}
+
+	trailer := Dict{}
+	for _, key := range []Name{"Root", "Info", "ID"} {
+		if v, ok := u.doc.trailer[key]; ok {
+			trailer[key] = v
+		}
+	}
+	if !u.doc.repaired {
+		trailer["Prev"] = u.doc.startxref
+	}
+
+	if u.doc.xrefIsStream || hasCompressedEntries(entries) {
+		u.writeXrefStream(&buf, entries, trailer)
+	} else {
+		u.writeXrefTable(&buf, entries, trailer)
+	}
+	return buf.Bytes()
+}
+
+func hasCompressedEntries(entries map[int]xrefEntry) bool {
+	for _, entry := range entries {
+		if entry.stream > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNums(entries map[int]xrefEntry) []int {
+	nums := make([]int, 0, len(entries))
+	for num := range entries {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+func (u *Update) writeXrefTable(buf *bytes.Buffer, entries map[int]xrefEntry, trailer Dict) {
+	xrefOffset := buf.Len()
+	buf.WriteString("xref\n")
+	if u.doc.repaired {
+		buf.WriteString("0 1\n0000000000 65535 f\r\n")
+	}
+	for _, run := range runs(sortedNums(entries)) {
+		fmt.Fprintf(buf, "%d %d\n", run[0], len(run))
+		for _, num := range run {
+			fmt.Fprintf(buf, "%010d %05d n\r\n", entries[num].offset, entries[num].gen)
+		}
+	}
+
+	trailer["Size"] = u.next
+	buf.WriteString("trailer\n")
+	buf.Write(Serialize(trailer))
+	fmt.Fprintf(buf, "\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+}
+
+func (u *Update) writeXrefStream(buf *bytes.Buffer, entries map[int]xrefEntry, trailer Dict) {
+	xrefNum := u.next
+	u.next++
+	xrefOffset := buf.Len()
+	entries[xrefNum] = xrefEntry{offset: xrefOffset}
+
+	var data bytes.Buffer
+	index := Array{}
+	for _, run := range runs(sortedNums(entries)) {
+		index = append(index, run[0], len(run))
+		for _, num := range run {
+			entry := entries[num]
+			if entry.stream > 0 {
+				data.WriteByte(2)
+				binary.Write(&data, binary.BigEndian, uint32(entry.stream))
+				binary.Write(&data, binary.BigEndian, uint16(entry.index))
+				continue
+			}
+			data.WriteByte(1)
+			binary.Write(&data, binary.BigEndian, uint32(entry.offset))
+			binary.Write(&data, binary.BigEndian, uint16(entry.gen))
+		}
+	}
+
+	trailer["Type"] = Name("XRef")
+	trailer["Size"] = u.next
+	trailer["W"] = Array{1, 4, 2}
+	trailer["Index"] = index
+
+	fmt.Fprintf(buf, "%d 0 obj\n", xrefNum)
+	buf.Write(Serialize(&Stream{Dict: trailer, Data: data.Bytes()}))
+	fmt.Fprintf(buf, "\nendobj\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+}
+
+// runs splits sorted object numbers into consecutive subsections
+func runs(nums []int) [][]int {
+	var out [][]int
+	for _, num := range nums {
+		if n := len(out); n > 0 && out[n-1][len(out[n-1])-1] == num-1 {
+			out[n-1] = append(out[n-1], num)
+			continue
+		}
+		out = append(out, []int{num})
+	}
+	return out
+}
+
+// OverlayPage draws content on top of the page at index (0-based) and returns the updated file.
+// The existing page content is wrapped in a saved graphics state so the overlay
+// is drawn in default user space regardless of the page's own transformations.
+func (d *Document) OverlayPage(index int, content []byte) ([]byte, error) {
+	pages, err := d.Pages()
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(pages) {
+		return nil, fmt.Errorf("page %d out of range", index+1)
+	}
+	page := pages[index]
+
+	update, err := d.NewUpdate()
+	if err != nil {
+		return nil, err
+	}
+
+	var contents Array
+	switch v := page.Dict["Contents"].(type) {
+	case Ref:
+		contents = Array{v}
+	case Array:
+		contents = append(contents, v...)
+	}
+	if resolved, err := d.Resolve(page.Dict["Contents"]); err == nil {
+		if arr, ok := resolved.(Array); ok {
+			contents = append(Array{}, arr...)
+		}
+	}
+
+	overlay := append([]byte("\n"), content...)
+	if len(contents) > 0 {
+		prefix := update.Add(&Stream{Dict: Dict{}, Data: []byte("q\n")})
+		contents = append(Array{prefix}, contents...)
+		overlay = append([]byte("\nQ\n"), content...)
+	}
+	contents = append(contents, update.Add(&Stream{Dict: Dict{}, Data: overlay}))
+
+	pageDict := make(Dict, len(page.Dict))
+	for k, v := range page.Dict {
+		pageDict[k] = v
+	}
+	pageDict["Contents"] = contents
+	update.Replace(page.Ref, pageDict)
+
+	return update.Bytes(), nil
+}
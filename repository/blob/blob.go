@@ -0,0 +1,67 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotFound is returned when no blob is stored under the requested key
+var ErrNotFound = errors.New("blob not found")
+
+// Info describes a stored blob
+type Info struct {
+	Key  string // hex encoded SHA-256 of the content
+	Size int64
+}
+
+// BlobStore stores immutable content addressed by its SHA-256 digest.
+// Storing the same content twice returns the same key without writing it again.
+// Blobs are opened seekable so they can serve HTTP range requests.
+type BlobStore interface {
+	Put(ctx context.Context, r io.Reader) (Info, error)
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes a blob; deleting a missing blob is not an error. Since equal content shares
+	// a key, callers must make sure nothing else references the blob.
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey reports whether key is a lowercase hex SHA-256 digest, which also keeps keys safe to use in paths
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// spool copies r into a temporary file in dir while hashing it, so content can be
+// addressed by its digest before it is stored without holding it in memory.
+// The returned file is positioned at the start; the caller must close and remove it.
+func spool(dir string, r io.Reader) (*os.File, Info, error) {
+	f, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hasher), r)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, Info{}, err
+	}
+
+	return f, Info{Key: hex.EncodeToString(hasher.Sum(nil)), Size: size}, nil
+}
@@ -0,0 +1,355 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an S3 stand-in keeping the objects of one bucket in memory. It serves path-style
+// requests and checks that uploads carry their signed payload hash.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	puts    int
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	t.Helper()
+	s := &fakeS3{bucket: bucket, objects: make(map[string][]byte)}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-access/") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	name, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, exists := s.objects[name]
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+			return
+		}
+		s.objects[name] = body
+		s.puts++
+	case http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	case http.MethodGet:
+		if !exists {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			var start int
+			if _, err := fmt.Sscanf(rng, "bytes=%d-", &start); err != nil || start >= len(data) {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start:])
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *fakeS3) object(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[name]
+	return data, ok
+}
+
+func newTestS3Store(t *testing.T, prefix string) (BlobStore, *fakeS3) {
+	t.Helper()
+	fake, server := newFakeS3(t, "certify")
+	store, err := NewS3BlobStore(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "certify",
+		AccessKey: "test-access",
+		SecretKey: "test-secret",
+		PathStyle: true,
+		Prefix:    prefix,
+	})
+	if err != nil {
+		t.Fatalf("NewS3BlobStore: %v", err)
+	}
+	return store, fake
+}
+
+func TestBlobStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) BlobStore{
+		"local": func(t *testing.T) BlobStore {
+			store, err := NewLocalBlobStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewLocalBlobStore: %v", err)
+			}
+			return store
+		},
+		"s3": func(t *testing.T) BlobStore {
+			store, _ := newTestS3Store(t, "")
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Run("round trip", func(t *testing.T) { testRoundTrip(t, newStore(t)) })
+			t.Run("deduplication", func(t *testing.T) { testDeduplication(t, newStore(t)) })
+			t.Run("seek", func(t *testing.T) { testSeek(t, newStore(t)) })
+			t.Run("delete", func(t *testing.T) { testDelete(t, newStore(t)) })
+			t.Run("invalid keys", func(t *testing.T) { testInvalidKeys(t, newStore(t)) })
+		})
+	}
+}
+
+func testRoundTrip(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("%PDF-1.7 certified "), 1000)
+
+	info, err := store.Put(ctx, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if info.Key != hex.EncodeToString(sum[:]) || info.Size != int64(len(content)) {
+		t.Errorf("Put = %+v, want the SHA-256 and size of the content", info)
+	}
+
+	if exists, err := store.Exists(ctx, info.Key); err != nil || !exists {
+		t.Errorf("Exists = %v, %v; want true", exists, err)
+	}
+	got := readBlob(t, store, info.Key)
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get returned %d bytes, want the %d stored", len(got), len(content))
+	}
+
+	empty, err := store.Put(ctx, bytes.NewReader(nil))
+	if err != nil {
+		t.Fatalf("Put empty: %v", err)
+	}
+	if empty.Key != emptyPayloadHash || empty.Size != 0 {
+		t.Errorf("Put empty = %+v, want the SHA-256 of nothing", empty)
+	}
+	if got := readBlob(t, store, empty.Key); len(got) != 0 {
+		t.Errorf("Get empty returned %d bytes", len(got))
+	}
+}
+
+func testDeduplication(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	first, err := store.Put(ctx, strings.NewReader("same content"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	second, err := store.Put(ctx, strings.NewReader("same content"))
+	if err != nil {
+		t.Fatalf("Put again: %v", err)
+	}
+	if first != second {
+		t.Errorf("Put of equal content = %+v and %+v, want the same key", first, second)
+	}
+	other, err := store.Put(ctx, strings.NewReader("other content"))
+	if err != nil {
+		t.Fatalf("Put other: %v", err)
+	}
+	if other.Key == first.Key {
+		t.Error("different content got the same key")
+	}
+	if got := readBlob(t, store, first.Key); string(got) != "same content" {
+		t.Errorf("Get = %q, want the content", got)
+	}
+}
+
+func testSeek(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	info, err := store.Put(ctx, strings.NewReader("0123456789"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	r, err := store.Get(ctx, info.Key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "012" {
+		t.Fatalf("Read = %q, %v; want 012", buf, err)
+	}
+	if _, err := r.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if rest, err := io.ReadAll(r); err != nil || string(rest) != "6789" {
+		t.Fatalf("Read after seek = %q, %v; want 6789", rest, err)
+	}
+	if size, err := r.Seek(0, io.SeekEnd); err != nil || size != 10 {
+		t.Errorf("Seek to end = %d, %v; want 10", size, err)
+	}
+}
+
+func testDelete(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	info, err := store.Put(ctx, strings.NewReader("to delete"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Delete(ctx, info.Key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if exists, err := store.Exists(ctx, info.Key); err != nil || exists {
+		t.Errorf("Exists after delete = %v, %v; want false", exists, err)
+	}
+	if _, err := store.Get(ctx, info.Key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after delete: error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, info.Key); err != nil {
+		t.Errorf("Delete of a missing blob: %v", err)
+	}
+
+	// Deleted content can be stored again
+	if _, err := store.Put(ctx, strings.NewReader("to delete")); err != nil {
+		t.Fatalf("Put after delete: %v", err)
+	}
+	if got := readBlob(t, store, info.Key); string(got) != "to delete" {
+		t.Errorf("Get = %q after storing again", got)
+	}
+}
+
+func testInvalidKeys(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	for _, key := range []string{"", "../../etc/passwd", strings.Repeat("A", 64), strings.Repeat("0", 63)} {
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): error = %v, want ErrNotFound", key, err)
+		}
+		if exists, err := store.Exists(ctx, key); err != nil || exists {
+			t.Errorf("Exists(%q) = %v, %v; want false", key, exists, err)
+		}
+		if err := store.Delete(ctx, key); err != nil {
+			t.Errorf("Delete(%q): %v", key, err)
+		}
+	}
+}
+
+func TestS3BlobStoreUploadsOnce(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newTestS3Store(t, "")
+
+	for i := 0; i < 3; i++ {
+		if _, err := store.Put(ctx, strings.NewReader("same content")); err != nil {
+			t.Fatalf("Put %d: %v", i+1, err)
+		}
+	}
+	if fake.puts != 1 {
+		t.Errorf("S3 got %d uploads, want 1", fake.puts)
+	}
+}
+
+func TestS3BlobStorePrefix(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeS3(t, "certify")
+	newStore := func(prefix string) BlobStore {
+		store, err := NewS3BlobStore(S3Config{
+			Endpoint:  server.URL,
+			Bucket:    "certify",
+			AccessKey: "test-access",
+			SecretKey: "test-secret",
+			PathStyle: true,
+			Prefix:    prefix,
+		})
+		if err != nil {
+			t.Fatalf("NewS3BlobStore: %v", err)
+		}
+		return store
+	}
+	documents, evidence := newStore(""), newStore("evidence/")
+
+	info, err := evidence.Put(ctx, strings.NewReader("shared content"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok := fake.object("evidence/" + info.Key); !ok {
+		t.Errorf("object evidence/%s not stored", info.Key)
+	}
+
+	// Equal content in another store is a separate object
+	if exists, err := documents.Exists(ctx, info.Key); err != nil || exists {
+		t.Fatalf("Exists in other store = %v, %v; want false", exists, err)
+	}
+	if _, err := documents.Put(ctx, strings.NewReader("shared content")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := evidence.Delete(ctx, info.Key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := readBlob(t, documents, info.Key); string(got) != "shared content" {
+		t.Errorf("Get = %q after deleting the evidence copy", got)
+	}
+}
+
+func TestNewS3BlobStore(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  S3Config
+	}{
+		{"no endpoint", S3Config{Bucket: "certify"}},
+		{"endpoint without host", S3Config{Endpoint: "localhost", Bucket: "certify"}},
+		{"no bucket", S3Config{Endpoint: "http://localhost:9000"}},
+	}
+	for _, tt := range tests {
+		if _, err := NewS3BlobStore(tt.cfg); err == nil {
+			t.Errorf("%s: NewS3BlobStore succeeded", tt.name)
+		}
+	}
+}
+
+func readBlob(t *testing.T, store BlobStore, key string) []byte {
+	t.Helper()
+	r, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading blob: %v", err)
+	}
+	return data
+}
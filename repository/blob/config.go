@@ -0,0 +1,43 @@
+package blob
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/tasklineby/certify-backend/config"
+)
+
+// Namespaces keep blobs with different lifecycles apart, since equal content shares a key and
+// deleting a blob of one namespace must not remove the file of another
+const (
+	// NamespaceDocuments holds document files
+	NamespaceDocuments = ""
+	// NamespaceEvidence holds comparison evidence, which is purged after its retention period
+	NamespaceEvidence = "evidence"
+	// NamespaceJobs holds the files of comparison jobs, which are deleted once their job finished
+	NamespaceJobs = "jobs"
+)
+
+// NewBlobStore opens the configured storage backend for the blobs of a namespace
+func NewBlobStore(cfg config.StorageConfig, namespace string) (BlobStore, error) {
+	switch cfg.Backend {
+	case "local":
+		return NewLocalBlobStore(filepath.Join(cfg.LocalPath, namespace))
+	case "s3":
+		prefix := ""
+		if namespace != "" {
+			prefix = namespace + "/"
+		}
+		return NewS3BlobStore(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+			Prefix:    prefix,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
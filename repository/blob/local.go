@@ -0,0 +1,100 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+type localBlobStore struct {
+	root string
+}
+
+// NewLocalBlobStore stores blobs under root as <root>/<key[0:2]>/<key[2:4]>/<key>
+func NewLocalBlobStore(root string) (BlobStore, error) {
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0o750); err != nil {
+		return nil, fmt.Errorf("error creating blob store directory: %w", err)
+	}
+	return &localBlobStore{root: root}, nil
+}
+
+func (s *localBlobStore) path(key string) string {
+	return filepath.Join(s.root, key[0:2], key[2:4], key)
+}
+
+func (s *localBlobStore) Put(ctx context.Context, r io.Reader) (Info, error) {
+	// Spool inside the store so the final rename stays on one filesystem
+	f, info, err := spool(filepath.Join(s.root, "tmp"), r)
+	if err != nil {
+		slog.Error("error spooling blob", "err", err)
+		return Info{}, err
+	}
+	defer os.Remove(f.Name())
+
+	err = f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		slog.Error("error writing blob", "err", err)
+		return Info{}, err
+	}
+
+	path := s.path(info.Key)
+	if _, err := os.Stat(path); err == nil {
+		return info, nil // Already stored
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		slog.Error("error creating blob directory", "err", err, "key", info.Key)
+		return Info{}, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		slog.Error("error storing blob", "err", err, "key", info.Key)
+		return Info{}, err
+	}
+	return info, nil
+}
+
+func (s *localBlobStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if !validKey(key) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		slog.Error("error opening blob", "err", err, "key", key)
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *localBlobStore) Exists(ctx context.Context, key string) (bool, error) {
+	if !validKey(key) {
+		return false, nil
+	}
+	_, err := os.Stat(s.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		slog.Error("error checking blob", "err", err, "key", key)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *localBlobStore) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return nil
+	}
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		slog.Error("error deleting blob", "err", err, "key", key)
+		return err
+	}
+	return nil
+}
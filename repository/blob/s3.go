@@ -0,0 +1,362 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config configures an S3-compatible blob store
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool   // address the bucket in the path instead of the host name, as MinIO expects
+	Prefix    string // prepended to object names, e.g. "evidence/" to share a bucket between stores
+}
+
+type s3BlobStore struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3BlobStore stores blobs as objects named by their key in an S3-compatible bucket.
+// Requests are signed with AWS Signature Version 4.
+func NewS3BlobStore(cfg S3Config) (BlobStore, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &s3BlobStore{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Minute},
+	}, nil
+}
+
+func (s *s3BlobStore) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.cfg.PathStyle {
+		u.Path = base + "/" + s.cfg.Bucket + "/" + s.cfg.Prefix + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = base + "/" + s.cfg.Prefix + key
+	}
+	return &u
+}
+
+func (s *s3BlobStore) Put(ctx context.Context, r io.Reader) (Info, error) {
+	f, info, err := spool("", r)
+	if err != nil {
+		slog.Error("error spooling blob", "err", err)
+		return Info{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	exists, err := s.Exists(ctx, info.Key)
+	if err != nil {
+		return Info{}, err
+	}
+	if exists {
+		return info, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(info.Key).String(), f)
+	if err != nil {
+		return Info{}, err
+	}
+	req.ContentLength = info.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	// The content key is the SHA-256 of the body, exactly what the signature needs
+	s.sign(req, info.Key, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		slog.Error("error uploading blob", "err", err, "key", info.Key)
+		return Info{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := responseError(resp)
+		slog.Error("error uploading blob", "err", err, "key", info.Key)
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// Get checks that the object exists and returns a reader that downloads it lazily,
+// using ranged requests after a seek so nothing is transferred for conditional requests.
+func (s *s3BlobStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if !validKey(key) {
+		return nil, ErrNotFound
+	}
+	size, err := s.head(ctx, key)
+	if err != nil {
+		if err != ErrNotFound {
+			slog.Error("error opening blob", "err", err, "key", key)
+		}
+		return nil, err
+	}
+	return &s3Object{ctx: ctx, store: s, key: key, size: size}, nil
+}
+
+func (s *s3BlobStore) Exists(ctx context.Context, key string) (bool, error) {
+	if !validKey(key) {
+		return false, nil
+	}
+	_, err := s.head(ctx, key)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		slog.Error("error checking blob", "err", err, "key", key)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *s3BlobStore) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, emptyPayloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		slog.Error("error deleting blob", "err", err, "key", key)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		err := responseError(resp)
+		slog.Error("error deleting blob", "err", err, "key", key)
+		return err
+	}
+	return nil
+}
+
+// head returns the size of an object, or ErrNotFound
+func (s *s3BlobStore) head(ctx context.Context, key string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(key).String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	s.sign(req, emptyPayloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusNotFound:
+		return 0, ErrNotFound
+	default:
+		return 0, fmt.Errorf("S3 returned %s", resp.Status)
+	}
+}
+
+// s3Object reads an object from offset onwards, reopening the download when the offset moves
+type s3Object struct {
+	ctx     context.Context
+	store   *s3BlobStore
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+	bodyPos int64
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body != nil && o.bodyPos != o.offset {
+		o.body.Close()
+		o.body = nil
+	}
+	if o.body == nil {
+		if err := o.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	o.bodyPos = o.offset
+	if err == io.EOF && o.offset < o.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (o *s3Object) open() error {
+	req, err := http.NewRequestWithContext(o.ctx, http.MethodGet, o.store.objectURL(o.key).String(), nil)
+	if err != nil {
+		return err
+	}
+	if o.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+	}
+	o.store.sign(req, emptyPayloadHash, time.Now())
+
+	resp, err := o.store.client.Do(req)
+	if err != nil {
+		slog.Error("error downloading blob", "err", err, "key", o.key)
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		err := responseError(resp)
+		slog.Error("error downloading blob", "err", err, "key", o.key)
+		return err
+	}
+	if o.offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return fmt.Errorf("S3 ignored range request for blob %s", o.key)
+	}
+	o.body = resp.Body
+	o.bodyPos = o.offset
+	return nil
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+// sign adds AWS Signature Version 4 headers to req. The host, Content-Type, Range and all
+// x-amz-* headers are signed; payloadHash is the hex SHA-256 of the request body.
+func (s *s3BlobStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		vals := append([]string(nil), values[key]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsEscape(key)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything except unreserved characters, as SigV4 requires
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,67 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+type AnalysisRepository interface {
+	CreateAnalysis(ctx context.Context, analysis *entity.Analysis) error
+	GetAnalysisByHistoryID(ctx context.Context, historyID int) (entity.Analysis, error)
+}
+
+type analysisRepository struct {
+	db *sqlx.DB
+}
+
+func NewAnalysisRepository(db *sqlx.DB) AnalysisRepository {
+	return &analysisRepository{db: db}
+}
+
+func (r *analysisRepository) CreateAnalysis(ctx context.Context, analysis *entity.Analysis) error {
+	differences, findings, pages := "[]", "[]", "[]"
+	if len(analysis.Differences) > 0 {
+		differences = string(analysis.Differences)
+	}
+	if len(analysis.Findings) > 0 {
+		findings = string(analysis.Findings)
+	}
+	if len(analysis.Pages) > 0 {
+		pages = string(analysis.Pages)
+	}
+	query := `INSERT INTO analyses (history_id, company_id, document_id, user_id, score, is_authentic, confidence, summary,
+	              differences, findings, provider, model, prompt_version, prompt_template_id, prompt,
+	              repaired, pages)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		analysis.HistoryID, analysis.CompanyID, analysis.DocumentID, analysis.UserID, analysis.Score, analysis.IsAuthentic,
+		analysis.Confidence, analysis.Summary, differences, findings, analysis.Provider, analysis.Model, analysis.PromptVersion,
+		analysis.PromptTemplateID, analysis.Prompt, analysis.Repaired, pages).
+		Scan(&analysis.ID, &analysis.CreatedAt)
+	if err != nil {
+		slog.Error("error creating analysis", "err", err, "document_id", analysis.DocumentID)
+		return err
+	}
+	return nil
+}
+
+func (r *analysisRepository) GetAnalysisByHistoryID(ctx context.Context, historyID int) (entity.Analysis, error) {
+	query := `SELECT id, history_id, company_id, document_id, user_id, score, is_authentic, confidence, summary,
+	                 differences::text AS differences, findings::text AS findings, provider, model, prompt_version, prompt_template_id, prompt, repaired,
+	                 pages::text AS pages, created_at
+	          FROM analyses WHERE history_id = $1`
+	var analysis entity.Analysis
+	err := r.db.GetContext(ctx, &analysis, query, historyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.Analysis{}, err
+		}
+		slog.Error("error getting analysis by history id", "err", err, "history_id", historyID)
+		return entity.Analysis{}, err
+	}
+	return analysis, nil
+}
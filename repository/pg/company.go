@@ -14,6 +14,7 @@ type CompanyRepository interface {
 	GetCompanyByID(ctx context.Context, id int) (entity.Company, error)
 	UpdateCompany(ctx context.Context, id int, name string) error
 	DeleteCompany(ctx context.Context, id int) error
+	SetRetainEvidence(ctx context.Context, id int, enabled bool) error
 }
 
 type companyRepository struct {
@@ -35,9 +36,9 @@ func (r *companyRepository) CreateCompany(ctx context.Context, company *entity.C
 }
 
 func (r *companyRepository) GetCompanyByID(ctx context.Context, id int) (entity.Company, error) {
-	query := `SELECT id, name FROM companies WHERE id = $1`
+	query := `SELECT id, name, retain_evidence, monthly_token_quota FROM companies WHERE id = $1`
 	var company entity.Company
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&company.ID, &company.Name)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&company.ID, &company.Name, &company.RetainEvidence, &company.MonthlyTokenQuota)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return entity.Company{}, err
@@ -67,3 +68,13 @@ func (r *companyRepository) DeleteCompany(ctx context.Context, id int) error {
 	}
 	return nil
 }
+
+func (r *companyRepository) SetRetainEvidence(ctx context.Context, id int, enabled bool) error {
+	query := `UPDATE companies SET retain_evidence = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, enabled, id)
+	if err != nil {
+		slog.Error("error setting evidence retention", "err", err, "company_id", id)
+		return err
+	}
+	return nil
+}
@@ -3,17 +3,29 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/tasklineby/certify-backend/entity"
 )
 
 type DocumentRepository interface {
-	CreateDocument(ctx context.Context, doc *entity.Document) error
+	CreateDocument(ctx context.Context, doc *entity.Document, createdBy int) error
 	GetDocumentByID(ctx context.Context, id int) (entity.Document, error)
 	GetDocumentsByCompanyID(ctx context.Context, companyID int) ([]entity.Document, error)
 	IncrementScanCount(ctx context.Context, id int) error
+	SetPublicVerification(ctx context.Context, id int, enabled bool, event *entity.DocumentEvent) error
+	UpdateDocument(ctx context.Context, id int, req entity.UpdateDocumentRequest, event *entity.DocumentEvent) error
+	ReplaceFile(ctx context.Context, id int, fileName, contentType, fileKey string, fileSize int64, event *entity.DocumentEvent) error
+	GetDocumentByContentHash(ctx context.Context, companyID int, contentHash string) (entity.Document, error)
+	GetLegacyFileData(ctx context.Context, id int) ([]byte, error)
+	RevokeDocument(ctx context.Context, id int, reason string, event *entity.DocumentEvent) error
+	SupersedeDocument(ctx context.Context, id, successorID int, event *entity.DocumentEvent) error
+	SetQuarantine(ctx context.Context, id int, quarantined bool, event *entity.DocumentEvent) error
+	DeleteDocument(ctx context.Context, id int, event *entity.DocumentEvent) error
+	GetEventsByDocumentID(ctx context.Context, documentID int) ([]entity.DocumentEvent, error)
 }
 
 type documentRepository struct {
@@ -24,12 +36,9 @@ func NewDocumentRepository(db *sqlx.DB) DocumentRepository {
 	return &documentRepository{db: db}
 }
 
-func (r *documentRepository) CreateDocument(ctx context.Context, doc *entity.Document) error {
-	query := `INSERT INTO documents (company_id, type, name, summary, expiration_date, file_name, file_data) 
-	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
-	err := r.db.QueryRowContext(ctx, query,
-		doc.CompanyID, doc.Type, doc.Name, doc.Summary, doc.ExpirationDate, doc.FileName, doc.FileData).
-		Scan(&doc.ID)
+// CreateDocument inserts a document together with its first version snapshot
+func (r *documentRepository) CreateDocument(ctx context.Context, doc *entity.Document, createdBy int) error {
+	err := r.createDocument(ctx, doc, createdBy)
 	if err != nil {
 		slog.Error("error creating document", "err", err, "name", doc.Name)
 		return err
@@ -37,12 +46,38 @@ func (r *documentRepository) CreateDocument(ctx context.Context, doc *entity.Doc
 	return nil
 }
 
+func (r *documentRepository) createDocument(ctx context.Context, doc *entity.Document, createdBy int) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO documents (company_id, type, name, summary, expiration_date, public_verification, file_name, file_key, file_size, content_type, content_hash) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, state, version`
+	err = tx.QueryRowContext(ctx, query,
+		doc.CompanyID, doc.Type, doc.Name, doc.Summary, doc.ExpirationDate, doc.PublicVerification, doc.FileName, doc.FileKey, doc.FileSize,
+		doc.ContentType, doc.ContentHash).
+		Scan(&doc.ID, &doc.State, &doc.Version)
+	if err != nil {
+		return err
+	}
+
+	if err := insertDocumentVersion(ctx, tx, doc.ID, &createdBy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDocumentByID returns the metadata of a document that has not been deleted
 func (r *documentRepository) GetDocumentByID(ctx context.Context, id int) (entity.Document, error) {
-	query := `SELECT id, company_id, type, name, summary, expiration_date, scan_count, file_name, file_data 
-	          FROM documents WHERE id = $1`
+	query := `SELECT id, company_id, type, name, summary, expiration_date, scan_count, version, public_verification, 
+	                 state, revocation_reason, revoked_at, superseded_by, file_name, file_size, content_type, content_hash, COALESCE(file_key, '') 
+	          FROM documents WHERE id = $1 AND deleted_at IS NULL`
 	var doc entity.Document
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&doc.ID, &doc.CompanyID, &doc.Type, &doc.Name, &doc.Summary, &doc.ExpirationDate, &doc.ScanCount, &doc.FileName, &doc.FileData)
+		&doc.ID, &doc.CompanyID, &doc.Type, &doc.Name, &doc.Summary, &doc.ExpirationDate, &doc.ScanCount, &doc.Version, &doc.PublicVerification,
+		&doc.State, &doc.RevocationReason, &doc.RevokedAt, &doc.SupersededBy, &doc.FileName, &doc.FileSize, &doc.ContentType, &doc.ContentHash, &doc.FileKey)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return entity.Document{}, err
@@ -53,9 +88,44 @@ func (r *documentRepository) GetDocumentByID(ctx context.Context, id int) (entit
 	return doc, nil
 }
 
+// GetDocumentByContentHash returns the oldest document of a company with the given file, or sql.ErrNoRows
+func (r *documentRepository) GetDocumentByContentHash(ctx context.Context, companyID int, contentHash string) (entity.Document, error) {
+	query := `SELECT id, company_id, type, name, summary, expiration_date, scan_count, version, public_verification, 
+	                 state, revocation_reason, revoked_at, superseded_by, file_name, file_size, content_type, content_hash, 
+	                 COALESCE(file_key, '') AS file_key 
+	          FROM documents WHERE company_id = $1 AND content_hash = $2 AND deleted_at IS NULL ORDER BY id LIMIT 1`
+	var doc entity.Document
+	err := r.db.GetContext(ctx, &doc, query, companyID, contentHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.Document{}, err
+		}
+		slog.Error("error getting document by content hash", "err", err, "company_id", companyID)
+		return entity.Document{}, err
+	}
+	return doc, nil
+}
+
+// GetLegacyFileData returns the file of a document that has not been moved to the blob store yet
+func (r *documentRepository) GetLegacyFileData(ctx context.Context, id int) ([]byte, error) {
+	query := `SELECT file_data FROM documents WHERE id = $1 AND file_data IS NOT NULL`
+	var data []byte
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		slog.Error("error getting document file data", "err", err, "document_id", id)
+		return nil, err
+	}
+	return data, nil
+}
+
 func (r *documentRepository) GetDocumentsByCompanyID(ctx context.Context, companyID int) ([]entity.Document, error) {
-	query := `SELECT id, company_id, type, name, summary, expiration_date, scan_count, file_name 
-	          FROM documents WHERE company_id = $1 ORDER BY id DESC`
+	query := `SELECT id, company_id, type, name, summary, expiration_date, scan_count, version, public_verification, 
+	                 state, revocation_reason, revoked_at, superseded_by, file_name, file_size, content_type, content_hash, 
+	                 COALESCE(file_key, '') AS file_key 
+	          FROM documents WHERE company_id = $1 AND deleted_at IS NULL ORDER BY id DESC`
 	var docs []entity.Document
 	err := r.db.SelectContext(ctx, &docs, query, companyID)
 	if err != nil {
@@ -74,3 +144,179 @@ func (r *documentRepository) IncrementScanCount(ctx context.Context, id int) err
 	}
 	return nil
 }
+
+func (r *documentRepository) SetPublicVerification(ctx context.Context, id int, enabled bool, event *entity.DocumentEvent) error {
+	query := `UPDATE documents SET public_verification = $1, updated_at = NOW() WHERE id = $2 AND deleted_at IS NULL`
+	err := r.execWithEvent(ctx, event, false, query, enabled, id)
+	if err != nil {
+		slog.Error("error setting public verification", "err", err, "document_id", id)
+		return err
+	}
+	return nil
+}
+
+func (r *documentRepository) UpdateDocument(ctx context.Context, id int, req entity.UpdateDocumentRequest, event *entity.DocumentEvent) error {
+	// Build dynamic query based on provided fields
+	updates := []string{}
+	args := []interface{}{}
+	argPos := 1
+
+	if req.Type != nil {
+		updates = append(updates, fmt.Sprintf("type = $%d", argPos))
+		args = append(args, *req.Type)
+		argPos++
+	}
+	if req.Name != nil {
+		updates = append(updates, fmt.Sprintf("name = $%d", argPos))
+		args = append(args, *req.Name)
+		argPos++
+	}
+	if req.Summary != nil {
+		updates = append(updates, fmt.Sprintf("summary = $%d", argPos))
+		args = append(args, *req.Summary)
+		argPos++
+	}
+	if req.ExpirationDate != nil {
+		updates = append(updates, fmt.Sprintf("expiration_date = $%d", argPos))
+		args = append(args, *req.ExpirationDate)
+		argPos++
+	}
+
+	if len(updates) == 0 {
+		return nil // Nothing to update
+	}
+
+	updates = append(updates, "version = version + 1", "updated_at = NOW()")
+	args = append(args, id, entity.DocumentStateActive)
+	query := fmt.Sprintf("UPDATE documents SET %s WHERE id = $%d AND state = $%d AND deleted_at IS NULL",
+		strings.Join(updates, ", "), argPos, argPos+1)
+
+	err := r.execWithEvent(ctx, event, true, query, args...)
+	if err != nil {
+		slog.Error("error updating document", "err", err, "document_id", id)
+		return err
+	}
+	return nil
+}
+
+// ReplaceFile points an active document at a new blob store file as a new version
+func (r *documentRepository) ReplaceFile(ctx context.Context, id int, fileName, contentType, fileKey string, fileSize int64, event *entity.DocumentEvent) error {
+	query := `UPDATE documents SET file_name = $1, content_type = $2, file_key = $3, content_hash = $3, file_size = $4, file_data = NULL, 
+	                 version = version + 1, updated_at = NOW() 
+	          WHERE id = $5 AND state = $6 AND deleted_at IS NULL`
+	err := r.execWithEvent(ctx, event, true, query, fileName, contentType, fileKey, fileSize, id, entity.DocumentStateActive)
+	if err != nil {
+		slog.Error("error replacing document file", "err", err, "document_id", id)
+		return err
+	}
+	return nil
+}
+
+// RevokeDocument revokes an active document
+func (r *documentRepository) RevokeDocument(ctx context.Context, id int, reason string, event *entity.DocumentEvent) error {
+	query := `UPDATE documents SET state = $1, revocation_reason = $2, revoked_at = NOW(), updated_at = NOW() 
+	          WHERE id = $3 AND state = $4 AND deleted_at IS NULL`
+	err := r.execWithEvent(ctx, event, false, query, entity.DocumentStateRevoked, reason, id, entity.DocumentStateActive)
+	if err != nil {
+		slog.Error("error revoking document", "err", err, "document_id", id)
+		return err
+	}
+	return nil
+}
+
+// SupersedeDocument marks an active document as replaced by successorID
+func (r *documentRepository) SupersedeDocument(ctx context.Context, id, successorID int, event *entity.DocumentEvent) error {
+	query := `UPDATE documents SET state = $1, superseded_by = $2, updated_at = NOW() 
+	          WHERE id = $3 AND state = $4 AND deleted_at IS NULL`
+	err := r.execWithEvent(ctx, event, false, query, entity.DocumentStateSuperseded, successorID, id, entity.DocumentStateActive)
+	if err != nil {
+		slog.Error("error superseding document", "err", err, "document_id", id, "successor_id", successorID)
+		return err
+	}
+	return nil
+}
+
+// SetQuarantine moves an active document to quarantine, or a quarantined document back to active
+func (r *documentRepository) SetQuarantine(ctx context.Context, id int, quarantined bool, event *entity.DocumentEvent) error {
+	from, to := entity.DocumentStateActive, entity.DocumentStateQuarantined
+	if !quarantined {
+		from, to = to, from
+	}
+	query := `UPDATE documents SET state = $1, updated_at = NOW() WHERE id = $2 AND state = $3 AND deleted_at IS NULL`
+	err := r.execWithEvent(ctx, event, false, query, to, id, from)
+	if err != nil {
+		slog.Error("error setting document quarantine", "err", err, "document_id", id, "quarantined", quarantined)
+		return err
+	}
+	return nil
+}
+
+// DeleteDocument soft-deletes a document so its verification history and events are kept
+func (r *documentRepository) DeleteDocument(ctx context.Context, id int, event *entity.DocumentEvent) error {
+	query := `UPDATE documents SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	err := r.execWithEvent(ctx, event, false, query, id)
+	if err != nil {
+		slog.Error("error deleting document", "err", err, "document_id", id)
+		return err
+	}
+	return nil
+}
+
+func (r *documentRepository) GetEventsByDocumentID(ctx context.Context, documentID int) ([]entity.DocumentEvent, error) {
+	query := `SELECT id, document_id, user_id, action, details::text AS details, created_at 
+	          FROM document_events WHERE document_id = $1 ORDER BY created_at DESC, id DESC`
+	var events []entity.DocumentEvent
+	err := r.db.SelectContext(ctx, &events, query, documentID)
+	if err != nil {
+		slog.Error("error getting document events", "err", err, "document_id", documentID)
+		return nil, err
+	}
+	return events, nil
+}
+
+// execWithEvent runs a document change and records its event in the same transaction,
+// snapshotting the changed document as a new version if requested.
+// It returns sql.ErrNoRows if the change matched no document.
+func (r *documentRepository) execWithEvent(ctx context.Context, event *entity.DocumentEvent, snapshot bool, query string, args ...interface{}) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if snapshot {
+		if err := insertDocumentVersion(ctx, tx, event.DocumentID, event.UserID); err != nil {
+			return err
+		}
+	}
+
+	if err := insertDocumentEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertDocumentEvent records an event as part of the change made in tx
+func insertDocumentEvent(ctx context.Context, tx *sqlx.Tx, event *entity.DocumentEvent) error {
+	details := "{}"
+	if len(event.Details) > 0 {
+		details = string(event.Details)
+	}
+	eventQuery := `INSERT INTO document_events (document_id, user_id, action, details) 
+	               VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	return tx.QueryRowContext(ctx, eventQuery, event.DocumentID, event.UserID, event.Action, details).
+		Scan(&event.ID, &event.CreatedAt)
+}
@@ -0,0 +1,96 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+type EvidenceRepository interface {
+	CreateEvidence(ctx context.Context, evidence *entity.Evidence) error
+	GetEvidenceByID(ctx context.Context, id int) (entity.Evidence, error)
+	GetEvidenceByHistoryID(ctx context.Context, historyID int) ([]entity.Evidence, error)
+	DeleteExpiredEvidence(ctx context.Context, now time.Time, limit int) ([]string, error)
+	IsFileKeyReferenced(ctx context.Context, fileKey string) (bool, error)
+}
+
+type evidenceRepository struct {
+	db *sqlx.DB
+}
+
+func NewEvidenceRepository(db *sqlx.DB) EvidenceRepository {
+	return &evidenceRepository{db: db}
+}
+
+const evidenceColumns = `id, company_id, document_id, history_id, user_id, kind, position, file_key, file_size, content_type,
+	created_at, expires_at`
+
+func (r *evidenceRepository) CreateEvidence(ctx context.Context, evidence *entity.Evidence) error {
+	query := `INSERT INTO evidence (company_id, document_id, history_id, user_id, kind, position, file_key, file_size,
+	              content_type, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		evidence.CompanyID, evidence.DocumentID, evidence.HistoryID, evidence.UserID, evidence.Kind, evidence.Position,
+		evidence.FileKey, evidence.FileSize, evidence.ContentType, evidence.ExpiresAt).
+		Scan(&evidence.ID, &evidence.CreatedAt)
+	if err != nil {
+		slog.Error("error creating evidence", "err", err, "document_id", evidence.DocumentID)
+		return err
+	}
+	return nil
+}
+
+func (r *evidenceRepository) GetEvidenceByID(ctx context.Context, id int) (entity.Evidence, error) {
+	query := `SELECT ` + evidenceColumns + ` FROM evidence WHERE id = $1`
+	var evidence entity.Evidence
+	err := r.db.GetContext(ctx, &evidence, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.Evidence{}, err
+		}
+		slog.Error("error getting evidence by id", "err", err, "evidence_id", id)
+		return entity.Evidence{}, err
+	}
+	return evidence, nil
+}
+
+func (r *evidenceRepository) GetEvidenceByHistoryID(ctx context.Context, historyID int) ([]entity.Evidence, error) {
+	query := `SELECT ` + evidenceColumns + ` FROM evidence WHERE history_id = $1 ORDER BY position`
+	var evidence []entity.Evidence
+	err := r.db.SelectContext(ctx, &evidence, query, historyID)
+	if err != nil {
+		slog.Error("error getting evidence by history id", "err", err, "history_id", historyID)
+		return nil, err
+	}
+	return evidence, nil
+}
+
+// DeleteExpiredEvidence deletes up to limit entries that expired before now and returns their file keys
+func (r *evidenceRepository) DeleteExpiredEvidence(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	query := `DELETE FROM evidence WHERE id IN (
+	              SELECT id FROM evidence WHERE expires_at <= $1 ORDER BY expires_at LIMIT $2 FOR UPDATE SKIP LOCKED
+	          ) RETURNING file_key`
+	var keys []string
+	err := r.db.SelectContext(ctx, &keys, query, now, limit)
+	if err != nil {
+		slog.Error("error deleting expired evidence", "err", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+// IsFileKeyReferenced reports whether any evidence entry still uses the file
+func (r *evidenceRepository) IsFileKeyReferenced(ctx context.Context, fileKey string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM evidence WHERE file_key = $1)`
+	var referenced bool
+	err := r.db.GetContext(ctx, &referenced, query, fileKey)
+	if err != nil {
+		slog.Error("error checking evidence file references", "err", err, "file_key", fileKey)
+		return false, err
+	}
+	return referenced, nil
+}
@@ -0,0 +1,76 @@
+package pg
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+type DocumentFieldRepository interface {
+	GetDocumentFields(ctx context.Context, documentID int) ([]entity.DocumentField, error)
+	ReplaceDocumentFields(ctx context.Context, documentID int, fields []entity.DocumentField, event *entity.DocumentEvent) error
+}
+
+type documentFieldRepository struct {
+	db *sqlx.DB
+}
+
+func NewDocumentFieldRepository(db *sqlx.DB) DocumentFieldRepository {
+	return &documentFieldRepository{db: db}
+}
+
+func (r *documentFieldRepository) GetDocumentFields(ctx context.Context, documentID int) ([]entity.DocumentField, error) {
+	query := `SELECT id, document_id, name, kind, value, source, created_at
+	          FROM document_fields WHERE document_id = $1 ORDER BY position`
+	var fields []entity.DocumentField
+	err := r.db.SelectContext(ctx, &fields, query, documentID)
+	if err != nil {
+		slog.Error("error getting document fields", "err", err, "document_id", documentID)
+		return nil, err
+	}
+	return fields, nil
+}
+
+// ReplaceDocumentFields replaces every field of a document, keeping the given order, and records
+// event in the same transaction unless it is nil
+func (r *documentFieldRepository) ReplaceDocumentFields(ctx context.Context, documentID int, fields []entity.DocumentField, event *entity.DocumentEvent) error {
+	err := r.replaceDocumentFields(ctx, documentID, fields, event)
+	if err != nil {
+		slog.Error("error replacing document fields", "err", err, "document_id", documentID)
+		return err
+	}
+	return nil
+}
+
+func (r *documentFieldRepository) replaceDocumentFields(ctx context.Context, documentID int, fields []entity.DocumentField, event *entity.DocumentEvent) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM document_fields WHERE document_id = $1`, documentID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO document_fields (document_id, name, kind, value, source, position)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	for i := range fields {
+		field := &fields[i]
+		field.DocumentID = documentID
+		err := tx.QueryRowContext(ctx, query, documentID, field.Name, field.Kind, field.Value, field.Source, i+1).
+			Scan(&field.ID, &field.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if event != nil {
+		if err := insertDocumentEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,66 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// FileTable is a table holding document files that can be moved to the blob store
+type FileTable string
+
+const (
+	FileTableDocuments        FileTable = "documents"
+	FileTableDocumentVersions FileTable = "document_versions"
+)
+
+// FileMigrationRepository moves document files stored as BYTEA to blob store keys
+type FileMigrationRepository interface {
+	GetLegacyFileIDs(ctx context.Context, table FileTable) ([]int, error)
+	GetLegacyFileData(ctx context.Context, table FileTable, id int) ([]byte, error)
+	SetFileKey(ctx context.Context, table FileTable, id int, fileKey string) error
+}
+
+type fileMigrationRepository struct {
+	db *sqlx.DB
+}
+
+func NewFileMigrationRepository(db *sqlx.DB) FileMigrationRepository {
+	return &fileMigrationRepository{db: db}
+}
+
+// GetLegacyFileIDs returns the ids of rows whose file is still stored in Postgres
+func (r *fileMigrationRepository) GetLegacyFileIDs(ctx context.Context, table FileTable) ([]int, error) {
+	query := fmt.Sprintf(`SELECT id FROM %s WHERE file_key IS NULL ORDER BY id`, table)
+	var ids []int
+	err := r.db.SelectContext(ctx, &ids, query)
+	if err != nil {
+		slog.Error("error getting legacy file ids", "err", err, "table", table)
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *fileMigrationRepository) GetLegacyFileData(ctx context.Context, table FileTable, id int) ([]byte, error) {
+	query := fmt.Sprintf(`SELECT file_data FROM %s WHERE id = $1 AND file_key IS NULL`, table)
+	var data []byte
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&data)
+	if err != nil {
+		slog.Error("error getting legacy file data", "err", err, "table", table, "id", id)
+		return nil, err
+	}
+	return data, nil
+}
+
+// SetFileKey points a row at its blob store file and releases the BYTEA copy
+func (r *fileMigrationRepository) SetFileKey(ctx context.Context, table FileTable, id int, fileKey string) error {
+	query := fmt.Sprintf(`UPDATE %s SET file_key = $1, file_data = NULL WHERE id = $2 AND file_key IS NULL`, table)
+	_, err := r.db.ExecContext(ctx, query, fileKey, id)
+	if err != nil {
+		slog.Error("error setting file key", "err", err, "table", table, "id", id)
+		return err
+	}
+	return nil
+}
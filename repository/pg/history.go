@@ -22,21 +22,25 @@ func NewHistoryRepository(db *sqlx.DB) HistoryRepository {
 }
 
 func (r *historyRepository) CreateHistory(ctx context.Context, history *entity.VerificationHistory) error {
-	query := `INSERT INTO verification_history (user_id, document_id, status, message) 
-	          VALUES ($1, $2, $3, $4) RETURNING id, scanned_at`
+	if history.Source == "" {
+		history.Source = entity.VerificationSourceInternal
+	}
+	query := `INSERT INTO verification_history (user_id, document_id, status, message, source) 
+	          VALUES ($1, $2, $3, $4, $5) RETURNING id, scanned_at`
 	err := r.db.QueryRowContext(ctx, query,
-		history.UserID, history.DocumentID, history.Status, history.Message).
+		history.UserID, history.DocumentID, history.Status, history.Message, history.Source).
 		Scan(&history.ID, &history.ScannedAt)
 	if err != nil {
-		slog.Error("error creating verification history", "err", err, "user_id", history.UserID)
+		slog.Error("error creating verification history", "err", err, "document_id", history.DocumentID)
 		return err
 	}
 	return nil
 }
 
 func (r *historyRepository) GetHistoryByUserID(ctx context.Context, userID int) ([]entity.VerificationHistory, error) {
-	query := `SELECT id, user_id, document_id, status, message, scanned_at 
-	          FROM verification_history WHERE user_id = $1 ORDER BY scanned_at DESC`
+	query := `SELECT h.id, h.user_id, h.document_id, h.status, h.message, h.source, a.id AS analysis_id, h.scanned_at 
+	          FROM verification_history h LEFT JOIN analyses a ON a.history_id = h.id
+	          WHERE h.user_id = $1 ORDER BY h.scanned_at DESC`
 	var history []entity.VerificationHistory
 	err := r.db.SelectContext(ctx, &history, query, userID)
 	if err != nil {
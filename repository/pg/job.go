@@ -0,0 +1,131 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// ComparisonJobRepository stores asynchronous comparison jobs and their results
+type ComparisonJobRepository interface {
+	CreateJob(ctx context.Context, job *entity.ComparisonJob) error
+	GetJobByID(ctx context.Context, id string) (entity.ComparisonJob, error)
+	StartJob(ctx context.Context, id string) (entity.ComparisonJob, error)
+	RetryJob(ctx context.Context, id, errMessage string) error
+	FinishJob(ctx context.Context, id string, status entity.ComparisonJobStatus, result []byte, errMessage string) error
+	IsInputReferenced(ctx context.Context, fileKey string) (bool, error)
+	SetWebhookDelivered(ctx context.Context, id string) error
+}
+
+type comparisonJobRepository struct {
+	db *sqlx.DB
+}
+
+func NewComparisonJobRepository(db *sqlx.DB) ComparisonJobRepository {
+	return &comparisonJobRepository{db: db}
+}
+
+const comparisonJobColumns = `id, company_id, user_id, kind, status, document_hash, inputs::text AS inputs,
+	document_id, history_id, verification::text AS verification, attempts, result::text AS result, error, webhook_url, webhook_delivered_at, created_at, started_at, finished_at`
+
+func (r *comparisonJobRepository) CreateJob(ctx context.Context, job *entity.ComparisonJob) error {
+	query := `INSERT INTO comparison_jobs (id, company_id, user_id, kind, status, document_hash, inputs, document_id,
+	          history_id, verification, webhook_url)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		job.ID, job.CompanyID, job.UserID, job.Kind, job.Status, job.DocumentHash, job.Inputs, job.DocumentID,
+		job.HistoryID, job.Verification, job.WebhookURL).
+		Scan(&job.CreatedAt)
+	if err != nil {
+		slog.Error("error creating comparison job", "err", err, "company_id", job.CompanyID)
+		return err
+	}
+	return nil
+}
+
+func (r *comparisonJobRepository) GetJobByID(ctx context.Context, id string) (entity.ComparisonJob, error) {
+	query := `SELECT ` + comparisonJobColumns + ` FROM comparison_jobs WHERE id = $1`
+	var job entity.ComparisonJob
+	err := r.db.GetContext(ctx, &job, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.ComparisonJob{}, err
+		}
+		slog.Error("error getting comparison job", "err", err, "job_id", id)
+		return entity.ComparisonJob{}, err
+	}
+	return job, nil
+}
+
+// StartJob marks a queued job as running and counts the attempt. Running jobs can be started again
+// when the worker that ran them lost its lease. Finished jobs return sql.ErrNoRows.
+func (r *comparisonJobRepository) StartJob(ctx context.Context, id string) (entity.ComparisonJob, error) {
+	query := `UPDATE comparison_jobs SET status = $2, attempts = attempts + 1, started_at = NOW()
+	          WHERE id = $1 AND status IN ($3, $2) RETURNING ` + comparisonJobColumns
+	var job entity.ComparisonJob
+	err := r.db.GetContext(ctx, &job, query, id, entity.ComparisonJobRunning, entity.ComparisonJobQueued)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.ComparisonJob{}, err
+		}
+		slog.Error("error starting comparison job", "err", err, "job_id", id)
+		return entity.ComparisonJob{}, err
+	}
+	return job, nil
+}
+
+// RetryJob puts a running job back in the queued state, keeping the error of the failed attempt
+func (r *comparisonJobRepository) RetryJob(ctx context.Context, id, errMessage string) error {
+	query := `UPDATE comparison_jobs SET status = $2, error = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, entity.ComparisonJobQueued, errMessage)
+	if err != nil {
+		slog.Error("error scheduling comparison job retry", "err", err, "job_id", id)
+		return err
+	}
+	return nil
+}
+
+// FinishJob records the final status of a job with its result or error
+func (r *comparisonJobRepository) FinishJob(ctx context.Context, id string, status entity.ComparisonJobStatus, result []byte, errMessage string) error {
+	var resultArg, errArg *string
+	if result != nil {
+		s := string(result)
+		resultArg = &s
+	}
+	if errMessage != "" {
+		errArg = &errMessage
+	}
+	query := `UPDATE comparison_jobs SET status = $2, result = $3, error = $4, finished_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, status, resultArg, errArg)
+	if err != nil {
+		slog.Error("error finishing comparison job", "err", err, "job_id", id)
+		return err
+	}
+	return nil
+}
+
+// IsInputReferenced reports whether a queued or running job uses a stored input
+func (r *comparisonJobRepository) IsInputReferenced(ctx context.Context, fileKey string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM comparison_jobs
+	          WHERE status IN ($2, $3) AND inputs @> jsonb_build_array(jsonb_build_object('file_key', $1::text)))`
+	var referenced bool
+	err := r.db.GetContext(ctx, &referenced, query, fileKey, entity.ComparisonJobQueued, entity.ComparisonJobRunning)
+	if err != nil {
+		slog.Error("error checking comparison job input references", "err", err, "file_key", fileKey)
+		return false, err
+	}
+	return referenced, nil
+}
+
+func (r *comparisonJobRepository) SetWebhookDelivered(ctx context.Context, id string) error {
+	query := `UPDATE comparison_jobs SET webhook_delivered_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		slog.Error("error recording comparison job webhook delivery", "err", err, "job_id", id)
+		return err
+	}
+	return nil
+}
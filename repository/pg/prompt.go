@@ -0,0 +1,97 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+type PromptTemplateRepository interface {
+	CreatePromptTemplate(ctx context.Context, template *entity.PromptTemplate) error
+	GetPromptTemplateByID(ctx context.Context, id int) (entity.PromptTemplate, error)
+	GetPromptTemplatesByCompanyID(ctx context.Context, companyID int) ([]entity.PromptTemplate, error)
+	ResolvePromptTemplate(ctx context.Context, companyID int, documentType string) (entity.PromptTemplate, error)
+}
+
+type promptTemplateRepository struct {
+	db *sqlx.DB
+}
+
+func NewPromptTemplateRepository(db *sqlx.DB) PromptTemplateRepository {
+	return &promptTemplateRepository{db: db}
+}
+
+const promptTemplateColumns = `id, company_id, document_type, version, body, expected_fields::text AS expected_fields, created_by, created_at`
+
+// CreatePromptTemplate adds the next version of the template for the company and document type and
+// sets its ID, version and creation time
+func (r *promptTemplateRepository) CreatePromptTemplate(ctx context.Context, template *entity.PromptTemplate) error {
+	expectedFields := "[]"
+	if len(template.ExpectedFields) > 0 {
+		expectedFields = string(template.ExpectedFields)
+	}
+	query := `INSERT INTO prompt_templates (company_id, document_type, version, body, expected_fields, created_by)
+	          SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3, $4, $5
+	          FROM prompt_templates WHERE company_id IS NOT DISTINCT FROM $1 AND document_type = $2
+	          RETURNING id, version, created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		template.CompanyID, template.DocumentType, template.Body, expectedFields, template.CreatedBy).
+		Scan(&template.ID, &template.Version, &template.CreatedAt)
+	if err != nil {
+		slog.Error("error creating prompt template", "err", err, "document_type", template.DocumentType)
+		return err
+	}
+	template.ExpectedFields = []byte(expectedFields)
+	return nil
+}
+
+func (r *promptTemplateRepository) GetPromptTemplateByID(ctx context.Context, id int) (entity.PromptTemplate, error) {
+	query := `SELECT ` + promptTemplateColumns + ` FROM prompt_templates WHERE id = $1`
+	var template entity.PromptTemplate
+	err := r.db.GetContext(ctx, &template, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.PromptTemplate{}, err
+		}
+		slog.Error("error getting prompt template by id", "err", err, "prompt_template_id", id)
+		return entity.PromptTemplate{}, err
+	}
+	return template, nil
+}
+
+// GetPromptTemplatesByCompanyID lists every version of the company's templates and of the global ones
+func (r *promptTemplateRepository) GetPromptTemplatesByCompanyID(ctx context.Context, companyID int) ([]entity.PromptTemplate, error) {
+	query := `SELECT ` + promptTemplateColumns + ` FROM prompt_templates
+	          WHERE company_id = $1 OR company_id IS NULL
+	          ORDER BY company_id NULLS FIRST, document_type, version DESC`
+	var templates []entity.PromptTemplate
+	err := r.db.SelectContext(ctx, &templates, query, companyID)
+	if err != nil {
+		slog.Error("error getting prompt templates by company id", "err", err, "company_id", companyID)
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ResolvePromptTemplate returns the latest version of the most specific template for a document:
+// the company's template for the type, the company's default, the global template for the type and
+// the global default, in that order. It returns sql.ErrNoRows if none applies.
+func (r *promptTemplateRepository) ResolvePromptTemplate(ctx context.Context, companyID int, documentType string) (entity.PromptTemplate, error) {
+	query := `SELECT ` + promptTemplateColumns + ` FROM prompt_templates
+	          WHERE (company_id = $1 OR company_id IS NULL) AND document_type IN ($2, '')
+	          ORDER BY company_id IS NULL, document_type = '', version DESC
+	          LIMIT 1`
+	var template entity.PromptTemplate
+	err := r.db.GetContext(ctx, &template, query, companyID, documentType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.PromptTemplate{}, err
+		}
+		slog.Error("error resolving prompt template", "err", err, "company_id", companyID, "document_type", documentType)
+		return entity.PromptTemplate{}, err
+	}
+	return template, nil
+}
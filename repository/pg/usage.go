@@ -0,0 +1,76 @@
+package pg
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+type UsageRepository interface {
+	CreateUsageRecord(ctx context.Context, record *entity.UsageRecord) error
+	GetUsageSummaries(ctx context.Context, companyID int, filter entity.UsageFilter) ([]entity.UsageSummary, error)
+	GetTokensUsedSince(ctx context.Context, companyID int, since time.Time) (int64, error)
+}
+
+type usageRepository struct {
+	db *sqlx.DB
+}
+
+func NewUsageRepository(db *sqlx.DB) UsageRepository {
+	return &usageRepository{db: db}
+}
+
+func (r *usageRepository) CreateUsageRecord(ctx context.Context, record *entity.UsageRecord) error {
+	query := `INSERT INTO usage_records (company_id, user_id, document_id, operation, provider, model, requests,
+	              input_tokens, output_tokens, total_tokens, cost)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		record.CompanyID, record.UserID, record.DocumentID, record.Operation, record.Provider, record.Model, record.Requests,
+		record.InputTokens, record.OutputTokens, record.TotalTokens, record.Cost).
+		Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		slog.Error("error creating usage record", "err", err, "company_id", record.CompanyID)
+		return err
+	}
+	return nil
+}
+
+// GetUsageSummaries aggregates the usage of a company by filter.Period, in UTC. Periods without usage
+// are left out.
+func (r *usageRepository) GetUsageSummaries(ctx context.Context, companyID int, filter entity.UsageFilter) ([]entity.UsageSummary, error) {
+	query := `SELECT date_trunc($2, created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS period_start,
+	                 COUNT(*) FILTER (WHERE operation = 'comparison') AS comparisons,
+	                 COUNT(*) FILTER (WHERE operation = 'extraction') AS extractions,
+	                 COALESCE(SUM(requests), 0) AS requests,
+	                 COALESCE(SUM(input_tokens), 0) AS input_tokens,
+	                 COALESCE(SUM(output_tokens), 0) AS output_tokens,
+	                 COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	                 COALESCE(SUM(cost), 0)::DOUBLE PRECISION AS cost
+	          FROM usage_records
+	          WHERE company_id = $1 AND created_at >= $3 AND created_at < $4
+	            AND ($5 = 0 OR user_id = $5) AND ($6 = 0 OR document_id = $6)
+	          GROUP BY period_start
+	          ORDER BY period_start`
+	var summaries []entity.UsageSummary
+	err := r.db.SelectContext(ctx, &summaries, query, companyID, filter.Period, filter.From, filter.To, filter.UserID, filter.DocumentID)
+	if err != nil {
+		slog.Error("error getting usage summaries", "err", err, "company_id", companyID)
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// GetTokensUsedSince returns the tokens a company used since the given time
+func (r *usageRepository) GetTokensUsedSince(ctx context.Context, companyID int, since time.Time) (int64, error) {
+	query := `SELECT COALESCE(SUM(total_tokens), 0) FROM usage_records WHERE company_id = $1 AND created_at >= $2`
+	var used int64
+	err := r.db.QueryRowContext(ctx, query, companyID, since).Scan(&used)
+	if err != nil {
+		slog.Error("error getting used tokens", "err", err, "company_id", companyID)
+		return 0, err
+	}
+	return used, nil
+}
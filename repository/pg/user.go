@@ -96,6 +96,16 @@ func (r *userRepository) UpdateUser(ctx context.Context, id int, user *entity.Us
 		args = append(args, user.Email)
 		argPos++
 	}
+	if user.Role != "" {
+		updates = append(updates, fmt.Sprintf("role = $%d", argPos))
+		args = append(args, user.Role)
+		argPos++
+	}
+	if user.Password != "" {
+		updates = append(updates, fmt.Sprintf("password = $%d", argPos))
+		args = append(args, user.Password)
+		argPos++
+	}
 
 	if len(updates) == 0 {
 		return nil // Nothing to update
@@ -0,0 +1,80 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tasklineby/certify-backend/entity"
+)
+
+// DocumentVersionRepository reads document version snapshots.
+// Versions are written by DocumentRepository in the same transaction as the change they record and are never modified.
+type DocumentVersionRepository interface {
+	GetVersionsByDocumentID(ctx context.Context, documentID int) ([]entity.DocumentVersion, error)
+	GetVersion(ctx context.Context, documentID, version int) (entity.DocumentVersion, error)
+	GetLegacyFileData(ctx context.Context, documentID, version int) ([]byte, error)
+}
+
+type documentVersionRepository struct {
+	db *sqlx.DB
+}
+
+func NewDocumentVersionRepository(db *sqlx.DB) DocumentVersionRepository {
+	return &documentVersionRepository{db: db}
+}
+
+// GetVersionsByDocumentID returns all versions of a document, newest first
+func (r *documentVersionRepository) GetVersionsByDocumentID(ctx context.Context, documentID int) ([]entity.DocumentVersion, error) {
+	query := `SELECT id, document_id, version, type, name, summary, expiration_date, file_name, file_size, content_type, 
+	                 COALESCE(file_key, '') AS file_key, created_by, created_at 
+	          FROM document_versions WHERE document_id = $1 ORDER BY version DESC`
+	var versions []entity.DocumentVersion
+	err := r.db.SelectContext(ctx, &versions, query, documentID)
+	if err != nil {
+		slog.Error("error getting document versions", "err", err, "document_id", documentID)
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *documentVersionRepository) GetVersion(ctx context.Context, documentID, version int) (entity.DocumentVersion, error) {
+	query := `SELECT id, document_id, version, type, name, summary, expiration_date, file_name, file_size, content_type, 
+	                 COALESCE(file_key, ''), created_by, created_at 
+	          FROM document_versions WHERE document_id = $1 AND version = $2`
+	var v entity.DocumentVersion
+	err := r.db.QueryRowContext(ctx, query, documentID, version).Scan(
+		&v.ID, &v.DocumentID, &v.Version, &v.Type, &v.Name, &v.Summary, &v.ExpirationDate, &v.FileName, &v.FileSize, &v.ContentType, &v.FileKey, &v.CreatedBy, &v.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.DocumentVersion{}, err
+		}
+		slog.Error("error getting document version", "err", err, "document_id", documentID, "version", version)
+		return entity.DocumentVersion{}, err
+	}
+	return v, nil
+}
+
+// GetLegacyFileData returns the file of a version that has not been moved to the blob store yet
+func (r *documentVersionRepository) GetLegacyFileData(ctx context.Context, documentID, version int) ([]byte, error) {
+	query := `SELECT file_data FROM document_versions WHERE document_id = $1 AND version = $2 AND file_data IS NOT NULL`
+	var data []byte
+	err := r.db.QueryRowContext(ctx, query, documentID, version).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		slog.Error("error getting document version file data", "err", err, "document_id", documentID, "version", version)
+		return nil, err
+	}
+	return data, nil
+}
+
+// insertDocumentVersion snapshots the current state of a document as its current version
+func insertDocumentVersion(ctx context.Context, tx *sqlx.Tx, documentID int, createdBy *int) error {
+	query := `INSERT INTO document_versions (document_id, version, type, name, summary, expiration_date, file_name, content_type, file_key, file_size, file_data, created_by) 
+	          SELECT id, version, type, name, summary, expiration_date, file_name, content_type, file_key, file_size, file_data, $2 FROM documents WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, documentID, createdBy)
+	return err
+}
@@ -0,0 +1,100 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+// AnalysisCacheRepository keeps comparison results of documents for a while. The keys of the entries
+// of each document are indexed in a set, so that all of them can be deleted at once.
+type AnalysisCacheRepository interface {
+	GetAnalysis(ctx context.Context, documentID int, key string) (*entity.CachedAnalysis, error)
+	SetAnalysis(ctx context.Context, documentID int, key string, analysis entity.CachedAnalysis, ttl time.Duration) error
+	DeleteDocumentAnalyses(ctx context.Context, documentID int) (int, error)
+}
+
+type analysisCacheRepository struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+// deleteIndexedScript deletes the entries listed in an index set and the set itself
+var deleteIndexedScript = redis.NewScript(`
+local keys = redis.call('SMEMBERS', KEYS[1])
+local n = 0
+for _, key in ipairs(keys) do
+	n = n + redis.call('DEL', key)
+end
+redis.call('DEL', KEYS[1])
+return n
+`)
+
+func NewAnalysisCacheRepository(rdb *redis.Client) AnalysisCacheRepository {
+	return &analysisCacheRepository{
+		rdb:    rdb,
+		prefix: "analysis-cache:",
+	}
+}
+
+func (r *analysisCacheRepository) indexKey(documentID int) string {
+	return r.prefix + strconv.Itoa(documentID)
+}
+
+func (r *analysisCacheRepository) entryKey(documentID int, key string) string {
+	return r.indexKey(documentID) + ":" + key
+}
+
+// GetAnalysis returns the cached analysis stored under key, or nil when there is none
+func (r *analysisCacheRepository) GetAnalysis(ctx context.Context, documentID int, key string) (*entity.CachedAnalysis, error) {
+	data, err := r.rdb.Get(ctx, r.entryKey(documentID, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("error getting cached analysis", "err", err, "document_id", documentID)
+		return nil, errs.InternalError("error getting cached analysis", err)
+	}
+	var analysis entity.CachedAnalysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		slog.Error("error unmarshaling cached analysis", "err", err, "document_id", documentID)
+		return nil, errs.InternalError("error unmarshaling cached analysis", err)
+	}
+	return &analysis, nil
+}
+
+func (r *analysisCacheRepository) SetAnalysis(ctx context.Context, documentID int, key string, analysis entity.CachedAnalysis, ttl time.Duration) error {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		slog.Error("error marshaling cached analysis", "err", err, "document_id", documentID)
+		return errs.InternalError("error marshaling cached analysis", err)
+	}
+	entryKey := r.entryKey(documentID, key)
+	pipe := r.rdb.TxPipeline()
+	pipe.Set(ctx, entryKey, data, ttl)
+	pipe.SAdd(ctx, r.indexKey(documentID), entryKey)
+	// The index outlives the entries it lists, as every entry has the same TTL
+	pipe.Expire(ctx, r.indexKey(documentID), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("error caching analysis", "err", err, "document_id", documentID)
+		return errs.InternalError("error caching analysis", err)
+	}
+	return nil
+}
+
+// DeleteDocumentAnalyses deletes all cached analyses of a document and returns how many there were
+func (r *analysisCacheRepository) DeleteDocumentAnalyses(ctx context.Context, documentID int) (int, error) {
+	n, err := deleteIndexedScript.Run(ctx, r.rdb, []string{r.indexKey(documentID)}).Int()
+	if err != nil {
+		slog.Error("error deleting cached analyses", "err", err, "document_id", documentID)
+		return 0, errs.InternalError("error deleting cached analyses", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,111 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+// JobQueueRepository is a reliable job queue. Jobs wait in a sorted set scored by the time they become
+// ready, which also schedules retries. A claimed job is leased: it moves to a second sorted set scored
+// by the lease deadline and returns to the queue if the worker does not complete it in time.
+type JobQueueRepository interface {
+	Enqueue(ctx context.Context, id string, readyAt time.Time) error
+	Claim(ctx context.Context, lease time.Duration) (string, error)
+	Complete(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, readyAt time.Time) error
+	RequeueExpired(ctx context.Context) (int, error)
+}
+
+type jobQueueRepository struct {
+	rdb           *redis.Client
+	queueKey      string
+	processingKey string
+}
+
+// claimScript moves the first ready job from the queue to the processing set
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call('ZREM', KEYS[1], ids[1])
+redis.call('ZADD', KEYS[2], ARGV[2], ids[1])
+return ids[1]
+`)
+
+// requeueScript moves jobs whose lease expired back to the queue, ready immediately
+var requeueScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('ZADD', KEYS[1], ARGV[1], id)
+end
+return #ids
+`)
+
+func NewJobQueueRepository(rdb *redis.Client, name string) JobQueueRepository {
+	return &jobQueueRepository{
+		rdb:           rdb,
+		queueKey:      "jobs:" + name + ":queue",
+		processingKey: "jobs:" + name + ":processing",
+	}
+}
+
+func (r *jobQueueRepository) Enqueue(ctx context.Context, id string, readyAt time.Time) error {
+	if err := r.rdb.ZAdd(ctx, r.queueKey, redis.Z{Score: float64(readyAt.UnixMilli()), Member: id}).Err(); err != nil {
+		slog.Error("error enqueueing job", "err", err, "job_id", id)
+		return errs.InternalError("error enqueueing job", err)
+	}
+	return nil
+}
+
+// Claim leases the next ready job and returns its ID, or an empty string when no job is ready
+func (r *jobQueueRepository) Claim(ctx context.Context, lease time.Duration) (string, error) {
+	now := time.Now()
+	id, err := claimScript.Run(ctx, r.rdb, []string{r.queueKey, r.processingKey},
+		now.UnixMilli(), now.Add(lease).UnixMilli()).Text()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		slog.Error("error claiming job", "err", err)
+		return "", errs.InternalError("error claiming job", err)
+	}
+	return id, nil
+}
+
+// Complete releases the lease of a finished job
+func (r *jobQueueRepository) Complete(ctx context.Context, id string) error {
+	if err := r.rdb.ZRem(ctx, r.processingKey, id).Err(); err != nil {
+		slog.Error("error completing job", "err", err, "job_id", id)
+		return errs.InternalError("error completing job", err)
+	}
+	return nil
+}
+
+// Retry releases the lease of a job and queues it again at readyAt
+func (r *jobQueueRepository) Retry(ctx context.Context, id string, readyAt time.Time) error {
+	pipe := r.rdb.TxPipeline()
+	pipe.ZRem(ctx, r.processingKey, id)
+	pipe.ZAdd(ctx, r.queueKey, redis.Z{Score: float64(readyAt.UnixMilli()), Member: id})
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("error scheduling job retry", "err", err, "job_id", id)
+		return errs.InternalError("error scheduling job retry", err)
+	}
+	return nil
+}
+
+// RequeueExpired returns jobs whose lease expired, e.g. because their worker crashed, to the queue
+func (r *jobQueueRepository) RequeueExpired(ctx context.Context) (int, error) {
+	n, err := requeueScript.Run(ctx, r.rdb, []string{r.queueKey, r.processingKey}, time.Now().UnixMilli()).Int()
+	if err != nil {
+		slog.Error("error requeueing expired jobs", "err", err)
+		return 0, errs.InternalError("error requeueing expired jobs", err)
+	}
+	return n, nil
+}
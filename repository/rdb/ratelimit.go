@@ -0,0 +1,39 @@
+package rdb
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+type RateLimitRepository interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+type rateLimitRepository struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+func NewRateLimitRepository(rdb *redis.Client) RateLimitRepository {
+	return &rateLimitRepository{
+		rdb:    rdb,
+		prefix: "ratelimit:",
+	}
+}
+
+// Allow counts a hit for key in the current fixed window and reports whether it is within limit
+func (r *rateLimitRepository) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	fullKey := r.prefix + key
+	pipe := r.rdb.TxPipeline()
+	count := pipe.Incr(ctx, fullKey)
+	pipe.ExpireNX(ctx, fullKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("error updating rate limit counter", "err", err)
+		return false, errs.InternalError("error updating rate limit counter", err)
+	}
+	return count.Val() <= int64(limit), nil
+}
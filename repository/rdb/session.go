@@ -0,0 +1,218 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+// SessionRepository stores login sessions. Every session is found by its ID and by the hash of its
+// current refresh token; both keys expire with the session. The hashes of the tokens a session used
+// up are kept with it to detect their reuse. The sessions of each user are indexed in a set, which
+// may still list sessions that expired.
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session entity.Session) error
+	UpdateSession(ctx context.Context, session entity.Session) error
+	GetSession(ctx context.Context, id string) (entity.Session, error)
+	GetUserSessions(ctx context.Context, userID string) ([]entity.Session, error)
+	TakeSession(ctx context.Context, id, tokenHash string) (entity.Session, error)
+	DeleteSession(ctx context.Context, session entity.Session) error
+}
+
+type sessionRepository struct {
+	rdb           *redis.Client
+	sessionPrefix string
+	refreshPrefix string
+	usedPrefix    string
+	userPrefix    string
+}
+
+func NewSessionRepository(rdb *redis.Client) SessionRepository {
+	return &sessionRepository{
+		rdb:           rdb,
+		sessionPrefix: "session:",
+		refreshPrefix: "refresh:",
+		usedPrefix:    "session-used:",
+		userPrefix:    "user-sessions:",
+	}
+}
+
+// saveSessionScript stores a session under its ID and its refresh token hash, indexes it for its
+// user and keeps its used tokens as long as the session. With ARGV[4] set, the session is only
+// stored if it still exists, so that a refresh cannot bring back a session ended meanwhile.
+var saveSessionScript = redis.NewScript(`
+if ARGV[4] == '1' and redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+redis.call('SADD', KEYS[3], ARGV[2])
+-- Sessions last as long from their last refresh, so the index lasts as long as the latest one
+redis.call('PEXPIRE', KEYS[3], ARGV[3])
+redis.call('PEXPIRE', KEYS[4], ARGV[3])
+return 1
+`)
+
+// takeSessionScript invalidates the refresh token KEYS[1] of session KEYS[3], records it as used
+// in KEYS[2] and returns the session. Tokens used before are reported as reused.
+var takeSessionScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[2] then
+	if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+		return {'reused'}
+	end
+	return {'unknown'}
+end
+redis.call('DEL', KEYS[1])
+local ttl = redis.call('PTTL', KEYS[3])
+if ttl <= 0 then
+	return {'unknown'}
+end
+redis.call('SADD', KEYS[2], ARGV[1])
+redis.call('PEXPIRE', KEYS[2], ttl)
+return {'taken', redis.call('GET', KEYS[3])}
+`)
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session entity.Session) error {
+	_, err := r.saveSession(ctx, session, false)
+	return err
+}
+
+// UpdateSession stores a session with a new refresh token. It fails with not found if the session
+// ended.
+func (r *sessionRepository) UpdateSession(ctx context.Context, session entity.Session) error {
+	saved, err := r.saveSession(ctx, session, true)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return errs.NotFoundError("session", nil)
+	}
+	return nil
+}
+
+func (r *sessionRepository) saveSession(ctx context.Context, session entity.Session, existing bool) (bool, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		slog.Error("error marshaling session", "err", err, "session_id", session.ID)
+		return false, errs.InternalError("error marshaling session", err)
+	}
+	keys := []string{
+		r.sessionPrefix + session.ID,
+		r.refreshPrefix + session.TokenHash,
+		r.userPrefix + session.Payload.UserID,
+		r.usedPrefix + session.ID,
+	}
+	onlyExisting := "0"
+	if existing {
+		onlyExisting = "1"
+	}
+	saved, err := saveSessionScript.Run(ctx, r.rdb, keys, data, session.ID, time.Until(session.ExpiresAt).Milliseconds(), onlyExisting).Int()
+	if err != nil {
+		slog.Error("error saving session", "err", err, "session_id", session.ID)
+		return false, errs.InternalError("error saving session", err)
+	}
+	return saved == 1, nil
+}
+
+func (r *sessionRepository) GetSession(ctx context.Context, id string) (entity.Session, error) {
+	data, err := r.rdb.Get(ctx, r.sessionPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return entity.Session{}, errs.NotFoundError("session", err)
+	}
+	if err != nil {
+		slog.Error("error getting session", "err", err, "session_id", id)
+		return entity.Session{}, errs.InternalError("error getting session", err)
+	}
+	var session entity.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		slog.Error("error unmarshaling session", "err", err, "session_id", id)
+		return entity.Session{}, errs.InternalError("error unmarshaling session", err)
+	}
+	return session, nil
+}
+
+// GetUserSessions returns the active sessions of a user and drops expired ones from the index
+func (r *sessionRepository) GetUserSessions(ctx context.Context, userID string) ([]entity.Session, error) {
+	ids, err := r.rdb.SMembers(ctx, r.userPrefix+userID).Result()
+	if err != nil {
+		slog.Error("error getting user sessions", "err", err, "user_id", userID)
+		return nil, errs.InternalError("error getting user sessions", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.sessionPrefix + id
+	}
+	values, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		slog.Error("error getting user sessions", "err", err, "user_id", userID)
+		return nil, errs.InternalError("error getting user sessions", err)
+	}
+
+	sessions := make([]entity.Session, 0, len(ids))
+	var expired []any
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		var session entity.Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			slog.Error("error unmarshaling session", "err", err, "session_id", ids[i])
+			return nil, errs.InternalError("error unmarshaling session", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if len(expired) > 0 {
+		if err := r.rdb.SRem(ctx, r.userPrefix+userID, expired...).Err(); err != nil {
+			slog.Error("error removing expired sessions from index", "err", err, "user_id", userID)
+		}
+	}
+	return sessions, nil
+}
+
+// TakeSession returns a session by its current refresh token and invalidates the token, so that it
+// can be used only once even by concurrent requests. Tokens the session used up before fail with a
+// token reused error, other tokens with not found.
+func (r *sessionRepository) TakeSession(ctx context.Context, id, tokenHash string) (entity.Session, error) {
+	keys := []string{r.refreshPrefix + tokenHash, r.usedPrefix + id, r.sessionPrefix + id}
+	result, err := takeSessionScript.Run(ctx, r.rdb, keys, tokenHash, id).StringSlice()
+	if err != nil {
+		slog.Error("error taking refresh token", "err", err, "session_id", id)
+		return entity.Session{}, errs.InternalError("error taking refresh token", err)
+	}
+	switch result[0] {
+	case "reused":
+		return entity.Session{}, errs.TokenReusedError("refresh token reused", nil)
+	case "unknown":
+		return entity.Session{}, errs.NotFoundError("refresh token", nil)
+	}
+
+	var session entity.Session
+	if err := json.Unmarshal([]byte(result[1]), &session); err != nil {
+		slog.Error("error unmarshaling session", "err", err, "session_id", id)
+		return entity.Session{}, errs.InternalError("error unmarshaling session", err)
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) DeleteSession(ctx context.Context, session entity.Session) error {
+	pipe := r.rdb.TxPipeline()
+	pipe.Del(ctx, r.sessionPrefix+session.ID, r.refreshPrefix+session.TokenHash, r.usedPrefix+session.ID)
+	pipe.SRem(ctx, r.userPrefix+session.Payload.UserID, session.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("error deleting session", "err", err, "session_id", session.ID)
+		return errs.InternalError("error deleting session", err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package rdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+func newTestSessionRepository(t *testing.T) (SessionRepository, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewSessionRepository(client), mr
+}
+
+func testSession(id, tokenHash string, ttl time.Duration) entity.Session {
+	now := time.Now().UTC().Truncate(time.Second)
+	return entity.Session{
+		ID:         id,
+		TokenHash:  tokenHash,
+		Payload:    entity.TokenPayload{UserID: "7", Role: "employee", CompanyID: "3", SessionID: id},
+		Device:     "laptop",
+		IP:         "192.0.2.1",
+		UserAgent:  "test",
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+}
+
+func assertErrorType(t *testing.T, err error, want errs.ErrorType) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("got no error, want %s", want)
+	}
+	if got := errs.ErrorCast(err).Type; got != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+}
+
+func TestCreateSession(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestSessionRepository(t)
+	session := testSession("s1", "hash1", time.Hour)
+
+	if err := repo.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	got, err := repo.GetSession(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.ID != session.ID || got.TokenHash != session.TokenHash || got.Payload != session.Payload ||
+		!got.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Errorf("GetSession = %+v, want %+v", got, session)
+	}
+
+	sessions, err := repo.GetUserSessions(ctx, "7")
+	if err != nil {
+		t.Fatalf("GetUserSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "s1" {
+		t.Errorf("GetUserSessions = %+v, want session s1", sessions)
+	}
+
+	for _, key := range []string{"session:s1", "refresh:hash1", "user-sessions:7"} {
+		if ttl := mr.TTL(key); ttl <= 0 || ttl > time.Hour {
+			t.Errorf("TTL of %s = %s, want up to an hour", key, ttl)
+		}
+	}
+}
+
+func TestTakeSessionOnce(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestSessionRepository(t)
+	if err := repo.CreateSession(ctx, testSession("s1", "hash1", time.Hour)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	got, err := repo.TakeSession(ctx, "s1", "hash1")
+	if err != nil {
+		t.Fatalf("TakeSession: %v", err)
+	}
+	if got.ID != "s1" {
+		t.Errorf("TakeSession returned session %q, want s1", got.ID)
+	}
+
+	_, err = repo.TakeSession(ctx, "s1", "hash1")
+	assertErrorType(t, err, errs.ErrorTypeTokenReused)
+}
+
+func TestTakeSessionUnknownToken(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestSessionRepository(t)
+	if err := repo.CreateSession(ctx, testSession("s1", "hash1", time.Hour)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		id        string
+		tokenHash string
+	}{
+		{"unknown token", "s1", "other"},
+		{"token of another session", "s2", "hash1"},
+		{"unknown session", "s2", "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.TakeSession(ctx, tt.id, tt.tokenHash)
+			assertErrorType(t, err, errs.ErrorTypeNotFound)
+		})
+	}
+
+	// Failed attempts leave the current token usable
+	if _, err := repo.TakeSession(ctx, "s1", "hash1"); err != nil {
+		t.Fatalf("TakeSession after unknown tokens: %v", err)
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestSessionRepository(t)
+	if err := repo.CreateSession(ctx, testSession("s1", "hash1", time.Hour)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	session, err := repo.TakeSession(ctx, "s1", "hash1")
+	if err != nil {
+		t.Fatalf("TakeSession: %v", err)
+	}
+	session.TokenHash = "hash2"
+	session.ExpiresAt = time.Now().Add(2 * time.Hour)
+	if err := repo.UpdateSession(ctx, session); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	if mr.Exists("refresh:hash1") {
+		t.Error("refresh token used up is still stored")
+	}
+	if ttl := mr.TTL("session:s1"); ttl <= time.Hour {
+		t.Errorf("TTL of refreshed session = %s, want it extended past an hour", ttl)
+	}
+
+	if _, err := repo.TakeSession(ctx, "s1", "hash2"); err != nil {
+		t.Fatalf("TakeSession with new token: %v", err)
+	}
+	_, err = repo.TakeSession(ctx, "s1", "hash1")
+	assertErrorType(t, err, errs.ErrorTypeTokenReused)
+}
+
+func TestUpdateEndedSession(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestSessionRepository(t)
+	session := testSession("s1", "hash1", time.Hour)
+	if err := repo.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := repo.DeleteSession(ctx, session); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+
+	session.TokenHash = "hash2"
+	err := repo.UpdateSession(ctx, session)
+	assertErrorType(t, err, errs.ErrorTypeNotFound)
+	if mr.Exists("session:s1") || mr.Exists("refresh:hash2") {
+		t.Error("refresh brought back an ended session")
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestSessionRepository(t)
+	if err := repo.CreateSession(ctx, testSession("s1", "hash1", time.Minute)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := repo.CreateSession(ctx, testSession("s2", "hash2", time.Hour)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	mr.FastForward(2 * time.Minute)
+
+	_, err := repo.GetSession(ctx, "s1")
+	assertErrorType(t, err, errs.ErrorTypeNotFound)
+	_, err = repo.TakeSession(ctx, "s1", "hash1")
+	assertErrorType(t, err, errs.ErrorTypeNotFound)
+
+	sessions, err := repo.GetUserSessions(ctx, "7")
+	if err != nil {
+		t.Fatalf("GetUserSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "s2" {
+		t.Errorf("GetUserSessions = %+v, want only session s2", sessions)
+	}
+	if ok, _ := mr.SIsMember("user-sessions:7", "s1"); ok {
+		t.Error("expired session is still indexed")
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestSessionRepository(t)
+	session := testSession("s1", "hash1", time.Hour)
+	if err := repo.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	taken, err := repo.TakeSession(ctx, "s1", "hash1")
+	if err != nil {
+		t.Fatalf("TakeSession: %v", err)
+	}
+	taken.TokenHash = "hash2"
+	if err := repo.UpdateSession(ctx, taken); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+
+	if err := repo.DeleteSession(ctx, taken); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+
+	for _, key := range []string{"session:s1", "refresh:hash2", "session-used:s1"} {
+		if mr.Exists(key) {
+			t.Errorf("%s still exists", key)
+		}
+	}
+	if ok, _ := mr.SIsMember("user-sessions:7", "s1"); ok {
+		t.Error("deleted session is still indexed")
+	}
+	_, err = repo.TakeSession(ctx, "s1", "hash2")
+	assertErrorType(t, err, errs.ErrorTypeNotFound)
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamd streams at most this many bytes per INSTREAM chunk
+const clamdChunkSize = 64 << 10
+
+type clamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner returns a scanner backed by a ClamAV daemon.
+// address is tcp://host:port, unix:///path/to/clamd.sock or a bare host:port.
+func NewClamdScanner(address string, timeout time.Duration) (FileScanner, error) {
+	network, addr := "tcp", address
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		addr = strings.TrimPrefix(address, "tcp://")
+	}
+	if addr == "" {
+		return nil, errors.New("clamd address is required")
+	}
+
+	return &clamdScanner{
+		network: network,
+		address: addr,
+		timeout: timeout,
+	}, nil
+}
+
+// Scan sends the file with the INSTREAM command: length-prefixed chunks terminated by an empty chunk
+func (s *clamdScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("connecting to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if err := s.stream(conn, r); err != nil {
+		// clamd replies and closes the connection when the stream exceeds its StreamMaxLength
+		if reply, readErr := readReply(conn); readErr == nil {
+			return parseReply(reply)
+		}
+		return Result{}, fmt.Errorf("sending file to clamd: %w", err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("reading clamd reply: %w", err)
+	}
+	return parseReply(reply)
+}
+
+func (s *clamdScanner) stream(conn net.Conn, r io.Reader) error {
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := w.Write(size[:]); err != nil {
+				return err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// readReply reads a null-terminated reply of the z-prefixed command
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && (err != io.EOF || reply == "") {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// parseReply interprets "stream: OK", "stream: <signature> FOUND" and "<message> ERROR" replies
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{Clean: true}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return Result{}, fmt.Errorf("clamd: %s", strings.TrimSuffix(reply, " ERROR"))
+	default:
+		return Result{}, fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+}
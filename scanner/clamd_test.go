@@ -0,0 +1,199 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd is a clamd stand-in that answers INSTREAM commands with reply(file)
+type fakeClamd struct {
+	listener net.Listener
+	reply    func(file []byte) string
+	files    chan []byte
+}
+
+func newFakeClamd(t *testing.T, reply func(file []byte) string) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	d := &fakeClamd{listener: listener, reply: reply, files: make(chan []byte, 16)}
+	t.Cleanup(func() { listener.Close() })
+	go d.serve()
+	return d
+}
+
+func (d *fakeClamd) address() string {
+	return "tcp://" + d.listener.Addr().String()
+}
+
+func (d *fakeClamd) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var file bytes.Buffer
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n == 0 {
+			break
+		}
+		if _, err := io.CopyN(&file, r, int64(n)); err != nil {
+			return
+		}
+	}
+	d.files <- file.Bytes()
+	conn.Write([]byte(d.reply(file.Bytes()) + "\x00"))
+}
+
+func TestClamdScanner(t *testing.T) {
+	eicar := []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+	clamd := newFakeClamd(t, func(file []byte) string {
+		switch {
+		case bytes.Equal(file, eicar):
+			return "stream: Eicar-Test-Signature FOUND"
+		case len(file) == 0:
+			return "INSTREAM size limit exceeded. ERROR"
+		}
+		return "stream: OK"
+	})
+	s, err := NewClamdScanner(clamd.address(), time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+
+	large := bytes.Repeat([]byte("%PDF-1.7 "), 3*clamdChunkSize/8)
+	tests := []struct {
+		name    string
+		file    []byte
+		want    Result
+		wantErr bool
+	}{
+		{"clean", []byte("%PDF-1.7 clean"), Result{Clean: true}, false},
+		{"clean over several chunks", large, Result{Clean: true}, false},
+		{"infected", eicar, Result{Signature: "Eicar-Test-Signature"}, false},
+		{"scanner error", nil, Result{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Scan(context.Background(), bytes.NewReader(tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Scan = %+v, want %+v", got, tt.want)
+			}
+			if received := <-clamd.files; !bytes.Equal(received, tt.file) {
+				t.Errorf("clamd received %d bytes, want the %d of the file", len(received), len(tt.file))
+			}
+		})
+	}
+}
+
+func TestClamdScannerUnavailable(t *testing.T) {
+	// A closed listener leaves a port nobody answers on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	s, err := NewClamdScanner(address, time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	got, err := s.Scan(context.Background(), strings.NewReader("%PDF-1.7"))
+	if err == nil {
+		t.Fatal("Scan succeeded without clamd")
+	}
+	if got.Clean {
+		t.Error("file reported clean without clamd")
+	}
+}
+
+func TestClamdScannerTimeout(t *testing.T) {
+	// clamd accepts the connection but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	s, err := NewClamdScanner(listener.Addr().String(), 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	start := time.Now()
+	got, err := s.Scan(context.Background(), strings.NewReader("%PDF-1.7"))
+	if err == nil || got.Clean {
+		t.Fatalf("Scan = %+v, %v; want a timeout", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Scan returned after %s, want the timeout", elapsed)
+	}
+}
+
+func TestNewClamdScanner(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+		wantErr bool
+	}{
+		{"tcp://clamav:3310", "tcp", "clamav:3310", false},
+		{"clamav:3310", "tcp", "clamav:3310", false},
+		{"unix:///run/clamav/clamd.sock", "unix", "/run/clamav/clamd.sock", false},
+		{"", "", "", true},
+		{"unix://", "", "", true},
+	}
+	for _, tt := range tests {
+		s, err := NewClamdScanner(tt.address, time.Second)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewClamdScanner(%q) error = %v, want error %v", tt.address, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		c := s.(*clamdScanner)
+		if c.network != tt.network || c.address != tt.addr {
+			t.Errorf("NewClamdScanner(%q) = %s %s, want %s %s", tt.address, c.network, c.address, tt.network, tt.addr)
+		}
+	}
+}
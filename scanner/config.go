@@ -0,0 +1,20 @@
+package scanner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tasklineby/certify-backend/config"
+)
+
+// NewFileScanner returns the scanner of the configured backend
+func NewFileScanner(cfg config.ScannerConfig) (FileScanner, error) {
+	switch cfg.Backend {
+	case "none":
+		return NewNoopScanner(), nil
+	case "clamav":
+		return NewClamdScanner(cfg.ClamdAddress, time.Duration(cfg.ClamdTimeoutSeconds)*time.Second)
+	default:
+		return nil, fmt.Errorf("unknown scanner backend %q", cfg.Backend)
+	}
+}
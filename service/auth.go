@@ -13,28 +13,30 @@ import (
 )
 
 type AuthService interface {
-	Login(ctx context.Context, email, password string) (entity.TokenPair, error)
-	Register(ctx context.Context, req entity.RegisterEmployeeRequest) (entity.TokenPair, error)
-	Refresh(ctx context.Context, refreshToken string) (entity.TokenPair, error)
-	Logout(ctx context.Context, accessToken, refreshToken string) error
+	Login(ctx context.Context, email, password string, client entity.SessionClient) (entity.TokenPair, error)
+	Register(ctx context.Context, req entity.RegisterEmployeeRequest, client entity.SessionClient) (entity.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string, client entity.SessionClient) (entity.TokenPair, error)
+	Logout(ctx context.Context, accessToken, refreshToken string, client entity.SessionClient) error
 	ParseToken(ctx context.Context, token string) (entity.TokenPayload, error)
 }
 
 type authService struct {
 	userService UserService
+	sessions    SessionService
 	tokenRepo   rdb.TokenRepository
 	jwtService  JwtService
 }
 
-func NewAuthService(userService UserService, tokenRepo rdb.TokenRepository, jwtService JwtService) AuthService {
+func NewAuthService(userService UserService, sessions SessionService, tokenRepo rdb.TokenRepository, jwtService JwtService) AuthService {
 	return &authService{
 		userService: userService,
+		sessions:    sessions,
 		tokenRepo:   tokenRepo,
 		jwtService:  jwtService,
 	}
 }
 
-func (s *authService) Login(ctx context.Context, email, password string) (entity.TokenPair, error) {
+func (s *authService) Login(ctx context.Context, email, password string, client entity.SessionClient) (entity.TokenPair, error) {
 	user, err := s.userService.GetUserByEmail(ctx, email)
 	if err != nil {
 		slog.Error("error getting user by email", "err", err)
@@ -52,75 +54,21 @@ func (s *authService) Login(ctx context.Context, email, password string) (entity
 		CompanyID: strconv.Itoa(user.CompanyID),
 	}
 
-	accessToken, err := s.jwtService.GenerateAccessToken(ctx, tokenPayload)
-	if err != nil {
-		slog.Error("error generating access token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error generating access token", err)
-	}
-
-	refreshToken, err := s.jwtService.GenerateRefreshToken(ctx, tokenPayload)
-	if err != nil {
-		slog.Error("error generating refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error generating refresh token", err)
-	}
-
-	err = s.tokenRepo.SetRefreshToken(ctx, refreshToken)
-	if err != nil {
-		slog.Error("error setting refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error setting refresh token", err)
-	}
-
-	return entity.TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken.Token,
-	}, nil
+	return s.sessions.CreateSession(ctx, tokenPayload, client)
 }
 
-func (s *authService) Register(ctx context.Context, req entity.RegisterEmployeeRequest) (entity.TokenPair, error) {
-	return s.userService.RegisterEmployee(ctx, req, s.jwtService, s.tokenRepo)
+func (s *authService) Register(ctx context.Context, req entity.RegisterEmployeeRequest, client entity.SessionClient) (entity.TokenPair, error) {
+	return s.userService.RegisterEmployee(ctx, req, client)
 }
 
-func (s *authService) Refresh(ctx context.Context, refreshToken string) (entity.TokenPair, error) {
-	payload, err := s.tokenRepo.FetchUserFromRefreshToken(ctx, refreshToken)
-	if err != nil {
-		slog.Error("error fetching user from refresh token", "err", err)
-		return entity.TokenPair{}, err
-	}
-
-	err = s.tokenRepo.DeleteRefreshToken(ctx, refreshToken)
-	if err != nil {
-		slog.Error("error deleting refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error deleting refresh token", err)
-	}
-
-	accessToken, err := s.jwtService.GenerateAccessToken(ctx, payload)
-	if err != nil {
-		slog.Error("error generating access token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error generating access token", err)
-	}
-
-	newRefreshToken, err := s.jwtService.GenerateRefreshToken(ctx, payload)
-	if err != nil {
-		slog.Error("error generating refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error generating refresh token", err)
-	}
-
-	err = s.tokenRepo.SetRefreshToken(ctx, newRefreshToken)
-	if err != nil {
-		slog.Error("error setting refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error setting refresh token", err)
-	}
-
-	return entity.TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken.Token,
-	}, nil
+func (s *authService) Refresh(ctx context.Context, refreshToken string, client entity.SessionClient) (entity.TokenPair, error) {
+	return s.sessions.RefreshSession(ctx, refreshToken, client)
 }
 
-func (s *authService) Logout(ctx context.Context, accessToken, refreshToken string) error {
-	err := s.tokenRepo.DeleteRefreshToken(ctx, refreshToken)
+func (s *authService) Logout(ctx context.Context, accessToken, refreshToken string, client entity.SessionClient) error {
+	err := s.sessions.DeleteSession(ctx, refreshToken, client)
 	if err != nil {
-		slog.Error("error deleting refresh token", "err", err)
+		slog.Error("error deleting session", "err", err)
 		return err
 	}
 
@@ -143,8 +91,14 @@ func (s *authService) Logout(ctx context.Context, accessToken, refreshToken stri
 }
 
 func (s *authService) ParseToken(ctx context.Context, token string) (entity.TokenPayload, error) {
+	tokenPayload, _, err := s.jwtService.ParseAccessToken(ctx, token)
+	if err != nil {
+		slog.Error("error parsing access token", "err", err)
+		return entity.TokenPayload{}, errs.UnauthorizedError("invalid token", err)
+	}
+
 	tokenHash := SHA256Hex(token)
-	isBlacklisted, err := s.tokenRepo.IsAccessTokenBlacklisted(ctx, tokenHash)
+	isBlacklisted, err := s.tokenRepo.IsAccessTokenBlacklisted(ctx, tokenHash, tokenPayload.SessionID)
 	if err != nil {
 		slog.Error("error verifying access token", "err", err)
 		return entity.TokenPayload{}, errs.UnauthorizedError("invalid token", err)
@@ -153,11 +107,5 @@ func (s *authService) ParseToken(ctx context.Context, token string) (entity.Toke
 		return entity.TokenPayload{}, errs.UnauthorizedError("token has been revoked", nil)
 	}
 
-	tokenPayload, _, err := s.jwtService.ParseAccessToken(ctx, token)
-	if err != nil {
-		slog.Error("error parsing access token", "err", err)
-		return entity.TokenPayload{}, errs.UnauthorizedError("invalid token", err)
-	}
-
 	return tokenPayload, nil
 }
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+// compareDocument compares the provided files with the stored document, page by page when pages are
+// given. A comparison of the same evidence with the same document version, prompt and model is served
+// from the cache instead of asking the analyzer again; otherwise the tokens used are billed to the
+// document's company, even when the comparison failed.
+func (s *documentService) compareDocument(ctx context.Context, doc *entity.Document, userID int, provided []analyzer.File, pages []int) (*entity.DocumentAnalysisResult, error) {
+	fields, err := s.fieldRepo.GetDocumentFields(ctx, doc.ID)
+	if err != nil {
+		return nil, errs.InternalError("error getting document fields", err)
+	}
+	prompt, templateID, err := s.prompts.ResolvePrompt(ctx, doc, fieldValues(fields))
+	if err != nil {
+		return nil, err
+	}
+
+	key := analysisCacheKey(doc, provided, pages, prompt, templateID, s.analyzer.Name())
+	if cached := s.cachedAnalysis(ctx, doc.ID, key); cached != nil {
+		return cached, nil
+	}
+	if err := s.usage.CheckQuota(ctx, doc.CompanyID); err != nil {
+		return nil, err
+	}
+
+	var analysis *entity.DocumentAnalysisResult
+	if len(pages) > 0 {
+		analysis, err = s.analyzePages(ctx, doc, provided, pages, fields, prompt, templateID)
+	} else {
+		analysis, err = s.analyzeDocument(ctx, doc, provided, fields, prompt, templateID)
+	}
+	if err != nil {
+		recordFailedUsage(ctx, s.usage, err, entity.UsageOperationComparison, doc.CompanyID, userID, &doc.ID)
+		return nil, err
+	}
+	if analysis.Usage != nil {
+		s.usage.RecordUsage(ctx, newUsageRecord(entity.UsageOperationComparison, analysis.Provider, *analysis.Usage, doc.CompanyID, userID, &doc.ID))
+	}
+	s.cacheAnalysis(ctx, doc.ID, key, analysis)
+	return analysis, nil
+}
+
+// analysisCacheKey identifies a comparison by everything its result depends on: the document version
+// and file, the submitted evidence, the rendered prompt and the model
+func analysisCacheKey(doc *entity.Document, provided []analyzer.File, pages []int, prompt analyzer.Prompt, templateID *int, model string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "document:%d:%d:%s\n", doc.ID, doc.Version, doc.ContentHash)
+	fmt.Fprintf(h, "model:%s\n", model)
+	template := 0
+	if templateID != nil {
+		template = *templateID
+	}
+	fmt.Fprintf(h, "prompt:%s:%d:%x\n", prompt.Version, template, sha256.Sum256([]byte(prompt.Text)))
+	for _, file := range provided {
+		fmt.Fprintf(h, "file:%s:%x\n", file.MimeType, sha256.Sum256(file.Data))
+	}
+	fmt.Fprintf(h, "pages:%v\n", pages)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedAnalysis returns the cached result stored under key, or nil if there is none or caching is
+// disabled. Cache failures are logged and the comparison proceeds without the cache.
+func (s *documentService) cachedAnalysis(ctx context.Context, documentID int, key string) *entity.DocumentAnalysisResult {
+	if s.analysisCacheTTL <= 0 {
+		return nil
+	}
+	cached, err := s.analysisCache.GetAnalysis(ctx, documentID, key)
+	if err != nil || cached == nil {
+		return nil
+	}
+	slog.Info("analysis served from cache", "document_id", documentID, "cached_at", cached.CachedAt)
+
+	result := cached.Result
+	result.ID = 0
+	result.Prompt = cached.Prompt
+	result.Usage = nil
+	result.Cache = &entity.AnalysisCacheInfo{
+		Hit:       true,
+		CachedAt:  cached.CachedAt,
+		ExpiresAt: cached.CachedAt.Add(s.analysisCacheTTL),
+	}
+	return &result
+}
+
+// cacheAnalysis stores a fresh result under key. Repaired results are not cached, so that the next
+// comparison asks the analyzer again.
+func (s *documentService) cacheAnalysis(ctx context.Context, documentID int, key string, result *entity.DocumentAnalysisResult) {
+	if s.analysisCacheTTL <= 0 || result.Repaired {
+		return
+	}
+	now := time.Now().UTC()
+	cached := entity.CachedAnalysis{Result: *result, Prompt: result.Prompt, CachedAt: now}
+	if err := s.analysisCache.SetAnalysis(ctx, documentID, key, cached, s.analysisCacheTTL); err != nil {
+		return
+	}
+	result.Cache = &entity.AnalysisCacheInfo{CachedAt: now, ExpiresAt: now.Add(s.analysisCacheTTL)}
+}
+
+// InvalidateAnalysisCache deletes the cached comparison results of a document, e.g. when a cached result
+// turned out to be wrong. Only admins can invalidate the cache.
+func (s *documentService) InvalidateAnalysisCache(ctx context.Context, id int, requesterRole string, requesterCompanyID int) (*entity.AnalysisCacheInvalidationResponse, error) {
+	if requesterRole != "admin" {
+		return nil, errs.UnauthorizedError("only admins can invalidate the analysis cache", nil)
+	}
+
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := s.analysisCache.DeleteDocumentAnalyses(ctx, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("analysis cache invalidated", "document_id", doc.ID, "deleted", deleted)
+	return &entity.AnalysisCacheInvalidationResponse{DocumentID: doc.ID, Deleted: deleted}, nil
+}
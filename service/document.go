@@ -1,16 +1,26 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/tasklineby/certify-backend/analyzer"
 	"github.com/tasklineby/certify-backend/entity"
 	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/blob"
 	"github.com/tasklineby/certify-backend/repository/pg"
+	"github.com/tasklineby/certify-backend/repository/rdb"
+	"github.com/tasklineby/certify-backend/scanner"
 )
 
 const (
@@ -19,74 +29,278 @@ const (
 )
 
 type DocumentService interface {
-	CreateDocument(ctx context.Context, req entity.CreateDocumentRequest, companyID int, fileName string, fileData []byte) (string, error)
+	CreateDocument(ctx context.Context, req entity.CreateDocumentRequest, companyID, userID int, fileName string, file io.Reader) (string, error)
 	GetDocumentByID(ctx context.Context, id, requesterCompanyID int) (*entity.Document, error)
+	OpenDocumentFile(ctx context.Context, id, requesterCompanyID int) (*entity.Document, io.ReadSeekCloser, error)
 	GetDocumentsByCompanyID(ctx context.Context, companyID int) ([]entity.Document, error)
 	VerifyDocument(ctx context.Context, hash string, requesterCompanyID, userID int) (*entity.Document, entity.DocumentStatus, string, error)
-	CompareWithPhotos(ctx context.Context, hash string, userID, requesterCompanyID int, photos [][]byte) (*entity.Document, entity.DocumentStatus, string, *entity.DocumentAnalysisResult, error)
-	CompareWithPDF(ctx context.Context, hash string, userID, requesterCompanyID int, pdfData []byte) (*entity.Document, entity.DocumentStatus, string, *entity.DocumentAnalysisResult, error)
+	VerifyDocumentPublic(ctx context.Context, hash string) (*entity.PublicDocumentView, error)
+	SetPublicVerification(ctx context.Context, id, requesterCompanyID, userID int, enabled bool) error
+	UpdateDocument(ctx context.Context, id int, req entity.UpdateDocumentRequest, requesterCompanyID, userID int) (*entity.Document, error)
+	RevokeDocument(ctx context.Context, id, requesterCompanyID, userID int, reason string) error
+	SupersedeDocument(ctx context.Context, id, successorID, requesterCompanyID, userID int) error
+	DeleteDocument(ctx context.Context, id int, requesterRole string, requesterCompanyID, userID int) error
+	ScanDocument(ctx context.Context, id int, requesterRole string, requesterCompanyID, userID int) (*entity.DocumentScanResponse, error)
+	GetDocumentEvents(ctx context.Context, id, requesterCompanyID int) ([]entity.DocumentEvent, error)
+	ReplaceDocumentFile(ctx context.Context, id, requesterCompanyID, userID int, fileName string, file io.Reader) (*entity.Document, error)
+	GetDocumentVersions(ctx context.Context, id, requesterCompanyID int) ([]entity.DocumentVersion, error)
+	OpenDocumentVersionFile(ctx context.Context, id, version, requesterCompanyID int) (*entity.DocumentVersion, io.ReadSeekCloser, error)
+	GetDocumentQRCode(ctx context.Context, id, requesterCompanyID int, opts entity.QRCodeOptions) ([]byte, string, error)
+	GetCertifiedCopy(ctx context.Context, id, requesterCompanyID int, opts entity.CertifiedCopyOptions) (string, []byte, error)
+	StartComparison(ctx context.Context, hash string, userID, requesterCompanyID int, kind entity.EvidenceKind, files []analyzer.File) (*entity.CompareDocumentResponse, *int, error)
+	FinishComparison(ctx context.Context, documentID, userID int, historyID *int, files []analyzer.File, pages []int) (*entity.Document, *entity.DocumentAnalysisResult, error)
 	GetHistory(ctx context.Context, userID int) ([]entity.VerificationHistory, error)
+	GetHistoryAnalysis(ctx context.Context, historyID, requesterCompanyID int) (*entity.Analysis, error)
+	ExtractDocument(ctx context.Context, fileName string, file io.Reader, companyID, userID int) (*entity.DocumentExtraction, error)
+	GetDocumentFields(ctx context.Context, id, requesterCompanyID int) ([]entity.DocumentField, error)
+	UpdateDocumentFields(ctx context.Context, id int, req entity.UpdateDocumentFieldsRequest, requesterCompanyID, userID int) ([]entity.DocumentField, error)
+	InvalidateAnalysisCache(ctx context.Context, id int, requesterRole string, requesterCompanyID int) (*entity.AnalysisCacheInvalidationResponse, error)
 }
 
 type documentService struct {
 	documentRepo pg.DocumentRepository
+	versionRepo  pg.DocumentVersionRepository
 	historyRepo  pg.HistoryRepository
-	geminiClient *GeminiClient
+	analysisRepo pg.AnalysisRepository
+	fieldRepo    pg.DocumentFieldRepository
+	companyRepo  pg.CompanyRepository
+	blobStore    blob.BlobStore
+	uploads      UploadValidator
+	fileScanner  scanner.FileScanner
+	hashSigner   DocumentHashSigner
+	verifyURL    string
+	analyzer     analyzer.DocumentAnalyzer
+	prompts      PromptService
+	evidence     EvidenceService
+	usage        UsageService
+
+	analysisCache    rdb.AnalysisCacheRepository
+	analysisCacheTTL time.Duration // 0 disables the cache
 }
 
-func NewDocumentService(documentRepo pg.DocumentRepository, historyRepo pg.HistoryRepository, geminiAPIKey, geminiModel string) DocumentService {
-	var geminiClient *GeminiClient
-	if geminiAPIKey != "" {
-		geminiClient = NewGeminiClient(geminiAPIKey, geminiModel)
-		slog.Info("Gemini client initialized", "model", geminiModel)
-	} else {
-		slog.Warn("Gemini API key not provided, document comparison will use mock responses")
-	}
-
+func NewDocumentService(documentRepo pg.DocumentRepository, versionRepo pg.DocumentVersionRepository, historyRepo pg.HistoryRepository, analysisRepo pg.AnalysisRepository, fieldRepo pg.DocumentFieldRepository, companyRepo pg.CompanyRepository, blobStore blob.BlobStore, uploads UploadValidator, fileScanner scanner.FileScanner, hashSigner DocumentHashSigner, documentAnalyzer analyzer.DocumentAnalyzer, prompts PromptService, evidence EvidenceService, usage UsageService, analysisCache rdb.AnalysisCacheRepository, analysisCacheTTL time.Duration, verifyURL string) DocumentService {
 	return &documentService{
 		documentRepo: documentRepo,
+		versionRepo:  versionRepo,
 		historyRepo:  historyRepo,
-		geminiClient: geminiClient,
+		analysisRepo: analysisRepo,
+		fieldRepo:    fieldRepo,
+		companyRepo:  companyRepo,
+		blobStore:    blobStore,
+		uploads:      uploads,
+		fileScanner:  fileScanner,
+		hashSigner:   hashSigner,
+		verifyURL:    verifyURL,
+		analyzer:     documentAnalyzer,
+		prompts:      prompts,
+		evidence:     evidence,
+		usage:        usage,
+
+		analysisCache:    analysisCache,
+		analysisCacheTTL: analysisCacheTTL,
 	}
 }
 
-// CreateDocument creates a new document and returns its hash
-func (s *documentService) CreateDocument(ctx context.Context, req entity.CreateDocumentRequest, companyID int, fileName string, fileData []byte) (string, error) {
+// CreateDocument validates and scans the file and stores it in the blob store, creates a new document and returns its hash
+func (s *documentService) CreateDocument(ctx context.Context, req entity.CreateDocumentRequest, companyID, userID int, fileName string, file io.Reader) (string, error) {
+	upload, err := s.uploads.Validate(fileName, file)
+	if err != nil {
+		return "", err
+	}
+	defer upload.Close()
+
+	if err := s.scanUpload(ctx, upload); err != nil {
+		return "", err
+	}
+
+	if err := s.checkDuplicateFile(ctx, companyID, upload.Hash); err != nil {
+		return "", err
+	}
+
+	fields, fieldSource, err := s.completeDocumentRequest(ctx, &req, upload, companyID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := s.blobStore.Put(ctx, upload)
+	if err != nil {
+		slog.Error("error storing document file", "err", err)
+		return "", errs.InternalError("error storing document file", err)
+	}
+
 	doc := &entity.Document{
-		CompanyID:      companyID,
-		Type:           req.Type,
-		Name:           req.Name,
-		Summary:        req.Summary,
-		ExpirationDate: req.ExpirationDate,
-		FileName:       fileName,
-		FileData:       fileData,
+		CompanyID:          companyID,
+		Type:               req.Type,
+		Name:               req.Name,
+		Summary:            req.Summary,
+		ExpirationDate:     req.ExpirationDate,
+		PublicVerification: req.PublicVerification,
+		FileName:           upload.FileName,
+		FileKey:            info.Key,
+		FileSize:           info.Size,
+		ContentType:        upload.ContentType,
+		ContentHash:        info.Key,
 	}
 
-	err := s.documentRepo.CreateDocument(ctx, doc)
+	err = s.documentRepo.CreateDocument(ctx, doc, userID)
 	if err != nil {
 		slog.Error("error creating document", "err", err)
 		return "", errs.InternalError("error creating document", err)
 	}
 
-	// Create hash payload (id, company_id, type, name - no summary and expiration_date)
+	if len(fields) > 0 {
+		// The document is usable without its fields, they can be entered again
+		if err := s.fieldRepo.ReplaceDocumentFields(ctx, doc.ID, newDocumentFields(fields, fieldSource), nil); err != nil {
+			slog.Error("error storing document fields", "err", err, "document_id", doc.ID)
+		}
+	}
+
+	return s.documentHash(doc)
+}
+
+// completeDocumentRequest fills the details missing from req with those extracted from the upload and
+// returns the fields to store with the document: the reviewed fields of req, or else the extracted ones
+func (s *documentService) completeDocumentRequest(ctx context.Context, req *entity.CreateDocumentRequest, upload *UploadedFile, companyID, userID int) ([]entity.DocumentFieldValue, entity.DocumentFieldSource, error) {
+	fields, err := analyzer.NormalizeDocumentFields(req.Fields)
+	if err != nil {
+		return nil, "", errs.ValidationError(err.Error(), err)
+	}
+	source := entity.DocumentFieldManual
+
+	complete := req.Type != "" && req.Name != "" && req.Summary != "" && !req.ExpirationDate.IsZero()
+	if complete && !req.ExtractFields {
+		return fields, source, nil
+	}
+
+	extraction, err := s.extractUpload(ctx, upload, companyID, userID)
+	if err != nil {
+		if !complete {
+			return nil, "", err
+		}
+		// Only fields were asked for; the document can be created without them
+		slog.Warn("document fields not extracted", "err", err)
+		return fields, source, nil
+	}
+
+	if req.Type == "" {
+		req.Type = extraction.Type
+	}
+	if req.Name == "" {
+		req.Name = extraction.Name
+	}
+	if req.Summary == "" {
+		req.Summary = extraction.Summary
+	}
+	if req.ExpirationDate.IsZero() && extraction.ExpirationDate != nil {
+		req.ExpirationDate = *extraction.ExpirationDate
+	}
+	if req.Type == "" || req.Name == "" || req.Summary == "" || req.ExpirationDate.IsZero() {
+		return nil, "", errs.ValidationError("type, name, summary and expiration_date are required; they could not be extracted from the file", nil)
+	}
+
+	if len(fields) == 0 {
+		fields, source = extraction.Fields, entity.DocumentFieldExtracted
+	}
+	return fields, source, nil
+}
+
+// checkDuplicateFile reports an existing document of the company with the same file content
+func (s *documentService) checkDuplicateFile(ctx context.Context, companyID int, contentHash string) error {
+	existing, err := s.documentRepo.GetDocumentByContentHash(ctx, companyID, contentHash)
+	if err == nil {
+		return errs.AlreadyExistsError(fmt.Sprintf("document %d with the same file", existing.ID), nil)
+	}
+	if err != sql.ErrNoRows {
+		return errs.InternalError("error checking for duplicate documents", err)
+	}
+	return nil
+}
+
+// documentHash signs the hash payload (id, company_id, type, name, version - no summary and expiration_date) of a document
+func (s *documentService) documentHash(doc *entity.Document) (string, error) {
 	payload := entity.DocumentHashPayload{
 		ID:        doc.ID,
 		CompanyID: doc.CompanyID,
 		Type:      doc.Type,
 		Name:      doc.Name,
+		Version:   doc.Version,
 	}
 
-	// Encode payload to JSON and then to base64
-	payloadBytes, err := json.Marshal(payload)
+	hash, err := s.hashSigner.Sign(payload)
 	if err != nil {
-		slog.Error("error marshaling hash payload", "err", err)
+		slog.Error("error signing document hash", "err", err)
 		return "", errs.InternalError("error creating document hash", err)
 	}
-
-	hash := base64.URLEncoding.EncodeToString(payloadBytes)
 	return hash, nil
 }
 
+// verificationContent returns what a document QR code encodes: the public verification URL if configured, otherwise the bare hash
+func (s *documentService) verificationContent(doc *entity.Document) (string, error) {
+	hash, err := s.documentHash(doc)
+	if err != nil {
+		return "", err
+	}
+	if s.verifyURL == "" {
+		return hash, nil
+	}
+
+	u, err := url.Parse(s.verifyURL)
+	if err != nil {
+		return "", errs.InternalError("invalid public verification URL", err)
+	}
+	query := u.Query()
+	query.Set("hash", hash)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// GetDocumentQRCode renders the document verification QR code as PNG or SVG
+func (s *documentService) GetDocumentQRCode(ctx context.Context, id, requesterCompanyID int, opts entity.QRCodeOptions) ([]byte, string, error) {
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	content, err := s.verificationContent(doc)
+	if err != nil {
+		return nil, "", err
+	}
+	return renderQRCode(content, opts)
+}
+
+// GetCertifiedCopy returns the document PDF with its verification QR code stamped onto the first or last page
+func (s *documentService) GetCertifiedCopy(ctx context.Context, id, requesterCompanyID int, opts entity.CertifiedCopyOptions) (string, []byte, error) {
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return "", nil, err
+	}
+	if doc.State != entity.DocumentStateActive {
+		return "", nil, errs.ValidationError(fmt.Sprintf("cannot certify a %s document", doc.State), nil)
+	}
+	if doc.ContentType != ContentTypePDF {
+		return "", nil, errs.ValidationError("certified copies are only available for PDF documents", nil)
+	}
+
+	content, err := s.verificationContent(doc)
+	if err != nil {
+		return "", nil, err
+	}
+
+	fileData, err := s.readDocumentFile(ctx, doc)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stamped, err := stampQRCode(fileData, content, opts)
+	if err != nil {
+		slog.Error("error creating certified copy", "err", err, "document_id", doc.ID)
+		return "", nil, err
+	}
+
+	fileName := strings.TrimSuffix(doc.FileName, filepath.Ext(doc.FileName)) + "-certified.pdf"
+	return fileName, stamped, nil
+}
+
 // GetDocumentByID returns a document by its ID (only if requester belongs to the same company)
 func (s *documentService) GetDocumentByID(ctx context.Context, id, requesterCompanyID int) (*entity.Document, error) {
 	doc, err := s.documentRepo.GetDocumentByID(ctx, id)
@@ -118,59 +332,146 @@ func (s *documentService) GetDocumentsByCompanyID(ctx context.Context, companyID
 
 // VerifyDocument verifies a document by its hash and returns the full document with status
 func (s *documentService) VerifyDocument(ctx context.Context, hash string, requesterCompanyID, userID int) (*entity.Document, entity.DocumentStatus, string, error) {
-	// Decode hash
-	payloadBytes, err := base64.URLEncoding.DecodeString(hash)
-	if err != nil {
-		slog.Error("error decoding hash", "err", err)
-		return nil, entity.DocumentStatusRed, "Invalid document hash", errs.BadRequestError("invalid document hash", err)
-	}
+	doc, status, message, _, err := s.verifyDocument(ctx, hash, requesterCompanyID, userID)
+	return doc, status, message, err
+}
 
-	var payload entity.DocumentHashPayload
-	err = json.Unmarshal(payloadBytes, &payload)
+// verifyDocument implements VerifyDocument and also returns the ID of the recorded history entry,
+// or nil when no entry was recorded
+func (s *documentService) verifyDocument(ctx context.Context, hash string, requesterCompanyID, userID int) (*entity.Document, entity.DocumentStatus, string, *int, error) {
+	// Validate hash signature before trusting anything inside the payload
+	payload, err := s.hashSigner.Verify(hash)
 	if err != nil {
-		slog.Error("error unmarshaling hash payload", "err", err)
-		return nil, entity.DocumentStatusRed, "Invalid document hash format", errs.BadRequestError("invalid document hash format", err)
+		slog.Warn("document hash rejected", "err", err)
+		return nil, entity.DocumentStatusRed, "Invalid document hash", nil, err
 	}
 
 	// Check if requester belongs to the same company as the document
 	if payload.CompanyID != requesterCompanyID {
-		return nil, entity.DocumentStatusRed, "Access denied: you can only verify documents from your own company", nil
+		return nil, entity.DocumentStatusRed, "Access denied: you can only verify documents from your own company", nil, nil
 	}
 
 	// Fetch document from database verifying id, company_id, type and name match
 	doc, err := s.documentRepo.GetDocumentByID(ctx, payload.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, entity.DocumentStatusRed, "Document not found", nil
+			return nil, entity.DocumentStatusRed, "Document not found", nil, nil
 		}
 		slog.Error("error getting document", "err", err)
-		return nil, entity.DocumentStatusRed, "Error verifying document", errs.InternalError("error verifying document", err)
+		return nil, entity.DocumentStatusRed, "Error verifying document", nil, errs.InternalError("error verifying document", err)
+	}
+
+	if !matchesHashPayload(doc, payload) {
+		slog.Warn("document hash does not match stored document", "document_id", doc.ID, "legacy", payload.Legacy)
+		return nil, entity.DocumentStatusRed, "Document does not match hash", nil, nil
 	}
 
-	// Determine status based on expiration date
+	// Determine status based on lifecycle state, hash version and expiration date
 	now := time.Now()
-	status, message := s.getDocumentStatus(doc.ExpirationDate, now)
+	status, message := s.getVerificationStatus(doc, payload, now)
 
-	// Increment scan count
+	historyID := s.recordVerification(ctx, &doc, &userID, entity.VerificationSourceInternal, status, message)
+
+	return &doc, status, message, historyID, nil
+}
+
+// VerifyDocumentPublic verifies a document for an anonymous third party and returns a redacted view.
+// Documents that do not allow public verification are reported as not found.
+func (s *documentService) VerifyDocumentPublic(ctx context.Context, hash string) (*entity.PublicDocumentView, error) {
+	payload, err := s.hashSigner.Verify(hash)
+	if err != nil {
+		slog.Warn("public document hash rejected", "err", err)
+		return nil, err
+	}
+
+	doc, err := s.documentRepo.GetDocumentByID(ctx, payload.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFoundError("document", err)
+		}
+		slog.Error("error getting document", "err", err)
+		return nil, errs.InternalError("error verifying document", err)
+	}
+
+	if !matchesHashPayload(doc, payload) || !doc.PublicVerification {
+		return nil, errs.NotFoundError("document", nil)
+	}
+
+	company, err := s.companyRepo.GetCompanyByID(ctx, doc.CompanyID)
+	if err != nil {
+		slog.Error("error getting issuer company", "err", err)
+		return nil, errs.InternalError("error verifying document", err)
+	}
+
+	status, message := s.getVerificationStatus(doc, payload, time.Now())
+
+	s.recordVerification(ctx, &doc, nil, entity.VerificationSourcePublic, status, message)
+
+	return &entity.PublicDocumentView{
+		Type:           doc.Type,
+		Name:           doc.Name,
+		ExpirationDate: doc.ExpirationDate,
+		IssuerName:     company.Name,
+		Status:         status,
+		Message:        message,
+	}, nil
+}
+
+// SetPublicVerification allows or forbids unauthenticated verification of a document
+func (s *documentService) SetPublicVerification(ctx context.Context, id, requesterCompanyID, userID int, enabled bool) error {
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return err
+	}
+	if doc.PublicVerification == enabled {
+		return nil
+	}
+
+	event, err := newDocumentEvent(id, userID, entity.DocumentEventPublicVerification,
+		map[string]any{"public_verification": fieldChange{From: doc.PublicVerification, To: enabled}})
+	if err != nil {
+		return err
+	}
+	if err := s.documentRepo.SetPublicVerification(ctx, id, enabled, event); err != nil {
+		slog.Error("error setting public verification", "err", err)
+		return lifecycleError(err, "error updating document")
+	}
+	return nil
+}
+
+// recordVerification increments the scan count and records a verification history entry, returning
+// its ID. Failures are logged but never fail the verification itself.
+func (s *documentService) recordVerification(ctx context.Context, doc *entity.Document, userID *int, source entity.VerificationSource, status entity.DocumentStatus, message string) *int {
 	if err := s.documentRepo.IncrementScanCount(ctx, doc.ID); err != nil {
 		slog.Error("error incrementing scan count", "err", err)
-		// Don't fail the verification, just log the error
 	}
 	doc.ScanCount++ // Update local copy for response
 
-	// Record verification history
 	history := &entity.VerificationHistory{
 		UserID:     userID,
 		DocumentID: doc.ID,
 		Status:     status,
 		Message:    message,
+		Source:     source,
 	}
 	if err := s.historyRepo.CreateHistory(ctx, history); err != nil {
 		slog.Error("error creating verification history", "err", err)
-		// Don't fail the verification, just log the error
+		return nil
 	}
+	return &history.ID
+}
 
-	return &doc, status, message, nil
+// matchesHashPayload checks that the stored document matches the identity encoded in its hash.
+// Signed hashes only need to match the issuing company, as later changes to type or name are
+// reported through the hash version; unsigned legacy hashes must also match type and name.
+func matchesHashPayload(doc entity.Document, payload entity.DocumentHashPayload) bool {
+	if doc.CompanyID != payload.CompanyID || payload.Version > doc.Version {
+		return false
+	}
+	if payload.Legacy {
+		return doc.Type == payload.Type && doc.Name == payload.Name
+	}
+	return true
 }
 
 // GetHistory returns verification history for a user
@@ -183,8 +484,62 @@ func (s *documentService) GetHistory(ctx context.Context, userID int) ([]entity.
 	return history, nil
 }
 
-// getDocumentStatus determines the status and message based on expiration date
-func (s *documentService) getDocumentStatus(expirationDate, now time.Time) (entity.DocumentStatus, string) {
+// GetHistoryAnalysis returns the analysis stored for a verification history entry of the requester's company
+func (s *documentService) GetHistoryAnalysis(ctx context.Context, historyID, requesterCompanyID int) (*entity.Analysis, error) {
+	analysis, err := s.analysisRepo.GetAnalysisByHistoryID(ctx, historyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFoundError("analysis", err)
+		}
+		slog.Error("error getting analysis", "err", err)
+		return nil, errs.InternalError("error getting analysis", err)
+	}
+	if analysis.CompanyID != requesterCompanyID {
+		return nil, errs.NotFoundError("analysis", nil)
+	}
+	return &analysis, nil
+}
+
+// getVerificationStatus determines the status of a document scanned with a hash.
+// A hash issued for an earlier version reports the version that replaced it.
+func (s *documentService) getVerificationStatus(doc entity.Document, payload entity.DocumentHashPayload, now time.Time) (entity.DocumentStatus, string) {
+	status, message := s.getDocumentStatus(doc, now)
+	if status == entity.DocumentStatusRed {
+		return status, message
+	}
+
+	if hashVersion(payload) < doc.Version {
+		return entity.DocumentStatusYellow, fmt.Sprintf("Document has been superseded by version %d", doc.Version)
+	}
+	return status, message
+}
+
+// hashVersion returns the document version a hash was issued for
+func hashVersion(payload entity.DocumentHashPayload) int {
+	if payload.Version == 0 {
+		return 1 // Issued before versioning, when every document was at version 1
+	}
+	return payload.Version
+}
+
+// getDocumentStatus determines the status and message based on lifecycle state and expiration date
+func (s *documentService) getDocumentStatus(doc entity.Document, now time.Time) (entity.DocumentStatus, string) {
+	switch doc.State {
+	case entity.DocumentStateRevoked:
+		if doc.RevocationReason != nil && *doc.RevocationReason != "" {
+			return entity.DocumentStatusRed, "Document has been revoked: " + *doc.RevocationReason
+		}
+		return entity.DocumentStatusRed, "Document has been revoked"
+	case entity.DocumentStateSuperseded:
+		if doc.SupersededBy != nil {
+			return entity.DocumentStatusRed, fmt.Sprintf("Document has been superseded by document %d", *doc.SupersededBy)
+		}
+		return entity.DocumentStatusRed, "Document has been superseded"
+	case entity.DocumentStateQuarantined:
+		return entity.DocumentStatusRed, "Document is quarantined pending a malware review"
+	}
+
+	expirationDate := doc.ExpirationDate
 	if expirationDate.Before(now) {
 		return entity.DocumentStatusRed, "Document has expired"
 	}
@@ -197,96 +552,145 @@ func (s *documentService) getDocumentStatus(expirationDate, now time.Time) (enti
 	return entity.DocumentStatusGreen, "Document is valid"
 }
 
-// CompareWithPhotos compares a document with uploaded photos
-func (s *documentService) CompareWithPhotos(ctx context.Context, hash string, userID, requesterCompanyID int, photos [][]byte) (*entity.Document, entity.DocumentStatus, string, *entity.DocumentAnalysisResult, error) {
-	// Verify document first
-	doc, status, message, err := s.VerifyDocument(ctx, hash, requesterCompanyID, userID)
+// StartComparison verifies the document a comparison is made against, scans the submitted files and
+// retains them as evidence. It returns the verification result and the ID of its history entry; when
+// the document's status is red, the files are neither scanned nor compared.
+func (s *documentService) StartComparison(ctx context.Context, hash string, userID, requesterCompanyID int, kind entity.EvidenceKind, files []analyzer.File) (*entity.CompareDocumentResponse, *int, error) {
+	doc, status, message, historyID, err := s.verifyDocument(ctx, hash, requesterCompanyID, userID)
 	if err != nil {
-		return nil, entity.DocumentStatusRed, message, nil, err
+		return nil, nil, err
+	}
+	verification := &entity.CompareDocumentResponse{
+		Status:   status,
+		Message:  message,
+		Document: doc,
 	}
 
 	// If document status is red, don't send to external service
 	if status == entity.DocumentStatusRed {
-		return doc, status, message, nil, nil
+		return verification, historyID, nil
 	}
 
-	// Send to Gemini for analysis
-	var analysis *entity.DocumentAnalysisResult
-	if s.geminiClient != nil {
-		var geminiErr error
-		analysis, _, geminiErr = s.geminiClient.CompareDocumentsWithPhotos(ctx, doc.FileData, photos)
-		if geminiErr != nil {
-			slog.Error("error analyzing document with photos via Gemini", "err", geminiErr)
-			return nil, entity.DocumentStatusRed, "Error analyzing document", nil, errs.InternalError("error analyzing document", geminiErr)
+	for i, file := range files {
+		what := "file"
+		if kind == entity.EvidenceKindPhoto {
+			what = fmt.Sprintf("photo %d", i+1)
 		}
-	} else {
-		// Fallback to mock if Gemini not configured
-		analysis = &entity.DocumentAnalysisResult{
-			Score:       0.92,
-			IsAuthentic: true,
-			Confidence:  "high",
-			Differences: []entity.DocumentDifference{
-				{
-					Location:      "Footer section",
-					OriginalValue: "Page 1 of 2",
-					ProvidedValue: "Page 1",
-					Severity:      "minor",
-					Description:   "Page numbering format differs slightly",
-				},
-			},
-			Findings: []entity.AnalysisFinding{
-				{
-					Category:    "quality",
-					Description: "Photo quality is slightly lower than original PDF",
-					Severity:    "info",
-				},
-			},
-			Summary: "Documents match with 92% confidence. Minor differences detected in formatting. (Mock response - Gemini not configured)",
+		if err := s.scanFile(ctx, bytes.NewReader(file.Data), what); err != nil {
+			return nil, nil, err
 		}
 	}
+	s.evidence.RetainEvidence(ctx, doc, userID, historyID, kind, files)
 
-	return doc, status, message, analysis, nil
+	return verification, historyID, nil
 }
 
-// CompareWithPDF compares a document with an uploaded PDF
-func (s *documentService) CompareWithPDF(ctx context.Context, hash string, userID, requesterCompanyID int, pdfData []byte) (*entity.Document, entity.DocumentStatus, string, *entity.DocumentAnalysisResult, error) {
-	// Verify document first
-	doc, status, message, err := s.VerifyDocument(ctx, hash, requesterCompanyID, userID)
+// FinishComparison compares the files of a started comparison with its document and records the
+// analysis with the verification's history entry. If pages gives the page shown by each photo, the
+// document is compared page by page; otherwise the files are compared with the whole document.
+func (s *documentService) FinishComparison(ctx context.Context, documentID, userID int, historyID *int, files []analyzer.File, pages []int) (*entity.Document, *entity.DocumentAnalysisResult, error) {
+	doc, err := s.documentRepo.GetDocumentByID(ctx, documentID)
 	if err != nil {
-		return nil, entity.DocumentStatusRed, message, nil, err
+		if err == sql.ErrNoRows {
+			return nil, nil, errs.NotFoundError("document", err)
+		}
+		return nil, nil, errs.InternalError("error getting document", err)
 	}
 
-	// If document status is red, don't send to external service
-	if status == entity.DocumentStatusRed {
-		return doc, status, message, nil, nil
+	analysis, err := s.compareDocument(ctx, &doc, userID, files, pages)
+	if err != nil {
+		return nil, nil, err
 	}
+	s.recordAnalysis(ctx, &doc, userID, historyID, analysis)
 
-	// Send to Gemini for analysis
-	var analysis *entity.DocumentAnalysisResult
-	if s.geminiClient != nil {
-		var geminiErr error
-		analysis, _, geminiErr = s.geminiClient.CompareDocumentsWithPDF(ctx, doc.FileData, pdfData)
-		if geminiErr != nil {
-			slog.Error("error analyzing document with PDF via Gemini", "err", geminiErr)
-			return nil, entity.DocumentStatusRed, "Error analyzing document", nil, errs.InternalError("error analyzing document", geminiErr)
-		}
-	} else {
-		// Fallback to mock if Gemini not configured
-		analysis = &entity.DocumentAnalysisResult{
-			Score:       0.98,
-			IsAuthentic: true,
-			Confidence:  "high",
-			Differences: []entity.DocumentDifference{},
-			Findings: []entity.AnalysisFinding{
-				{
-					Category:    "quality",
-					Description: "Both documents are high-quality PDFs with matching content",
-					Severity:    "info",
-				},
-			},
-			Summary: "Documents match with 98% confidence. Documents are nearly identical. (Mock response - Gemini not configured)",
+	return &doc, analysis, nil
+}
+
+// analyzeDocument compares the stored document file with the provided files using the configured analyzer
+func (s *documentService) analyzeDocument(ctx context.Context, doc *entity.Document, provided []analyzer.File, fields []entity.DocumentField, prompt analyzer.Prompt, templateID *int) (*entity.DocumentAnalysisResult, error) {
+	fileData, err := s.readDocumentFile(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis, err := s.analyzer.Compare(ctx, prompt, analyzer.File{Data: fileData, MimeType: doc.ContentType}, provided)
+	if err != nil {
+		slog.Error("error analyzing document", "err", err, "provider", s.analyzer.Name(), "document_id", doc.ID)
+		return nil, analyzerError(err, "analysis", "error analyzing document")
+	}
+	if analysis.Provider == "" {
+		analysis.Provider = s.analyzer.Name()
+	}
+	if analysis.PromptVersion != "" {
+		// A model was asked; results decided locally carry no prompt
+		analysis.PromptTemplateID = templateID
+		analysis.Prompt = prompt.Text
+	}
+	linkDifferenceFields(analysis, fields)
+	return analysis, nil
+}
+
+// analyzerError maps an error of the document analyzer to the error returned to clients.
+// operation names what the analyzer was asked for, e.g. "analysis"; message describes other failures.
+func analyzerError(err error, operation, message string) error {
+	switch {
+	case errors.Is(err, analyzer.ErrProviderRateLimited):
+		return errs.RateLimitedError(fmt.Sprintf("document %s is rate limited, try again later", operation), err)
+	case errors.Is(err, analyzer.ErrInvalidResponse):
+		return errs.UnavailableError(fmt.Sprintf("document %s returned an invalid response, try again later", operation), err)
+	case errors.Is(err, analyzer.ErrProviderUnavailable):
+		return errs.UnavailableError(fmt.Sprintf("document %s is temporarily unavailable, try again later", operation), err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return errs.UnavailableError(fmt.Sprintf("document %s timed out, try again later", operation), err)
+	}
+	return errs.InternalError(message, err)
+}
+
+// recordAnalysis stores an analysis result linked to the verification it was made for and sets its ID.
+// Failures are logged but never fail the comparison itself.
+func (s *documentService) recordAnalysis(ctx context.Context, doc *entity.Document, userID int, historyID *int, result *entity.DocumentAnalysisResult) {
+	differences, err := json.Marshal(result.Differences)
+	if err != nil {
+		slog.Error("error encoding analysis differences", "err", err)
+		return
+	}
+	findings, err := json.Marshal(result.Findings)
+	if err != nil {
+		slog.Error("error encoding analysis findings", "err", err)
+		return
+	}
+	var pages json.RawMessage
+	if len(result.Pages) > 0 {
+		if pages, err = json.Marshal(result.Pages); err != nil {
+			slog.Error("error encoding analysis pages", "err", err)
+			return
 		}
 	}
 
-	return doc, status, message, analysis, nil
+	// Providers are named "provider:model", e.g. "gemini:gemini-1.5-flash"
+	provider, model, _ := strings.Cut(result.Provider, ":")
+	analysis := &entity.Analysis{
+		HistoryID:        historyID,
+		CompanyID:        doc.CompanyID,
+		DocumentID:       doc.ID,
+		UserID:           &userID,
+		Score:            result.Score,
+		IsAuthentic:      result.IsAuthentic,
+		Confidence:       result.Confidence,
+		Summary:          result.Summary,
+		Differences:      differences,
+		Findings:         findings,
+		Provider:         provider,
+		Model:            model,
+		PromptVersion:    result.PromptVersion,
+		PromptTemplateID: result.PromptTemplateID,
+		Prompt:           result.Prompt,
+		Repaired:         result.Repaired,
+		Pages:            pages,
+	}
+	if err := s.analysisRepo.CreateAnalysis(ctx, analysis); err != nil {
+		slog.Error("error creating analysis", "err", err)
+		return
+	}
+	result.ID = analysis.ID
 }
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/blob"
+	"github.com/tasklineby/certify-backend/repository/pg"
+)
+
+// evidencePurgeBatch is the number of expired entries deleted per query by the retention job
+const evidencePurgeBatch = 500
+
+// EvidenceService retains the files submitted for comparison for companies that opted in, so they
+// can be reviewed in fraud investigations, and purges them once their retention period ended
+type EvidenceService interface {
+	RetainEvidence(ctx context.Context, doc *entity.Document, userID int, historyID *int, kind entity.EvidenceKind, files []analyzer.File)
+	SetEvidenceRetention(ctx context.Context, requesterRole string, requesterCompanyID int, enabled bool) error
+	GetHistoryEvidence(ctx context.Context, historyID int, requesterRole string, requesterCompanyID int) ([]entity.Evidence, error)
+	OpenEvidenceFile(ctx context.Context, id int, requesterRole string, requesterCompanyID int) (*entity.Evidence, io.ReadSeekCloser, error)
+	// Run purges expired evidence every interval until ctx is canceled
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type evidenceService struct {
+	evidenceRepo  pg.EvidenceRepository
+	companyRepo   pg.CompanyRepository
+	evidenceStore blob.BlobStore
+	retention     time.Duration
+}
+
+func NewEvidenceService(evidenceRepo pg.EvidenceRepository, companyRepo pg.CompanyRepository, evidenceStore blob.BlobStore, retention time.Duration) EvidenceService {
+	return &evidenceService{
+		evidenceRepo:  evidenceRepo,
+		companyRepo:   companyRepo,
+		evidenceStore: evidenceStore,
+		retention:     retention,
+	}
+}
+
+// RetainEvidence stores the submitted files if the document's company opted in.
+// Failures are logged but never fail the comparison itself.
+func (s *evidenceService) RetainEvidence(ctx context.Context, doc *entity.Document, userID int, historyID *int, kind entity.EvidenceKind, files []analyzer.File) {
+	company, err := s.companyRepo.GetCompanyByID(ctx, doc.CompanyID)
+	if err != nil {
+		slog.Error("error getting company for evidence retention", "err", err, "company_id", doc.CompanyID)
+		return
+	}
+	if !company.RetainEvidence {
+		return
+	}
+
+	expiresAt := time.Now().Add(s.retention)
+	for i, file := range files {
+		info, err := s.evidenceStore.Put(ctx, bytes.NewReader(file.Data))
+		if err != nil {
+			slog.Error("error storing evidence file", "err", err, "document_id", doc.ID)
+			return
+		}
+		evidence := &entity.Evidence{
+			CompanyID:   doc.CompanyID,
+			DocumentID:  doc.ID,
+			HistoryID:   historyID,
+			UserID:      &userID,
+			Kind:        kind,
+			Position:    i + 1,
+			FileKey:     info.Key,
+			FileSize:    info.Size,
+			ContentType: file.MimeType,
+			ExpiresAt:   expiresAt,
+		}
+		if err := s.evidenceRepo.CreateEvidence(ctx, evidence); err != nil {
+			slog.Error("error creating evidence", "err", err, "document_id", doc.ID)
+			return
+		}
+	}
+}
+
+// SetEvidenceRetention opts the requester's company in or out of evidence retention. Only admins can
+// change it. Opting out keeps already retained files until they expire.
+func (s *evidenceService) SetEvidenceRetention(ctx context.Context, requesterRole string, requesterCompanyID int, enabled bool) error {
+	if requesterRole != "admin" {
+		return errs.UnauthorizedError("only admins can change evidence retention", nil)
+	}
+	if err := s.companyRepo.SetRetainEvidence(ctx, requesterCompanyID, enabled); err != nil {
+		return errs.InternalError("error updating evidence retention", err)
+	}
+	return nil
+}
+
+// GetHistoryEvidence lists the files retained for a verification history entry. Only admins can access evidence.
+func (s *evidenceService) GetHistoryEvidence(ctx context.Context, historyID int, requesterRole string, requesterCompanyID int) ([]entity.Evidence, error) {
+	if requesterRole != "admin" {
+		return nil, errs.UnauthorizedError("only admins can access evidence", nil)
+	}
+	evidence, err := s.evidenceRepo.GetEvidenceByHistoryID(ctx, historyID)
+	if err != nil {
+		return nil, errs.InternalError("error getting evidence", err)
+	}
+	// History entries of other companies have no evidence as far as the requester can tell
+	result := make([]entity.Evidence, 0, len(evidence))
+	for _, e := range evidence {
+		if e.CompanyID == requesterCompanyID {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// OpenEvidenceFile opens a retained file. Only admins of the company that submitted it can download it.
+func (s *evidenceService) OpenEvidenceFile(ctx context.Context, id int, requesterRole string, requesterCompanyID int) (*entity.Evidence, io.ReadSeekCloser, error) {
+	if requesterRole != "admin" {
+		return nil, nil, errs.UnauthorizedError("only admins can access evidence", nil)
+	}
+	evidence, err := s.evidenceRepo.GetEvidenceByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, errs.NotFoundError("evidence", err)
+		}
+		return nil, nil, errs.InternalError("error getting evidence", err)
+	}
+	if evidence.CompanyID != requesterCompanyID {
+		return nil, nil, errs.NotFoundError("evidence", nil)
+	}
+
+	file, err := s.evidenceStore.Get(ctx, evidence.FileKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return nil, nil, errs.NotFoundError("evidence file", err)
+		}
+		return nil, nil, errs.InternalError("error opening evidence file", err)
+	}
+	return &evidence, file, nil
+}
+
+func (s *evidenceService) Run(ctx context.Context, interval time.Duration) {
+	slog.Info("evidence retention job started", "retention", s.retention, "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.purgeExpired(ctx)
+		select {
+		case <-ctx.Done():
+			slog.Info("evidence retention job stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeExpired deletes expired evidence entries and then the files no other entry uses.
+// Files left behind by an interrupted purge stay in the store until the same content expires again.
+func (s *evidenceService) purgeExpired(ctx context.Context) {
+	var entries, files int
+	for ctx.Err() == nil {
+		keys, err := s.evidenceRepo.DeleteExpiredEvidence(ctx, time.Now(), evidencePurgeBatch)
+		if err != nil || len(keys) == 0 {
+			break
+		}
+		entries += len(keys)
+
+		seen := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			referenced, err := s.evidenceRepo.IsFileKeyReferenced(ctx, key)
+			if err != nil || referenced {
+				continue
+			}
+			if err := s.evidenceStore.Delete(ctx, key); err != nil {
+				continue
+			}
+			files++
+		}
+	}
+	if entries > 0 {
+		slog.Info("purged expired evidence", "entries", entries, "files", files)
+	}
+}
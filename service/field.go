@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+// minFieldValueMatch is the shortest field value looked up in the original values of differences
+const minFieldValueMatch = 3
+
+// ExtractDocument proposes the details and fields of an uploaded file for the issuer to review before
+// creating the document. Nothing is stored.
+func (s *documentService) ExtractDocument(ctx context.Context, fileName string, file io.Reader, companyID, userID int) (*entity.DocumentExtraction, error) {
+	upload, err := s.uploads.Validate(fileName, file)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+
+	if err := s.scanUpload(ctx, upload); err != nil {
+		return nil, err
+	}
+	return s.extractUpload(ctx, upload, companyID, userID)
+}
+
+// extractUpload runs the analyzer's extraction on a scanned upload, leaving it to be stored. The
+// tokens it used are billed to the company, even when the extraction failed.
+func (s *documentService) extractUpload(ctx context.Context, upload *UploadedFile, companyID, userID int) (*entity.DocumentExtraction, error) {
+	if err := s.usage.CheckQuota(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	data, err := upload.Bytes()
+	if err != nil {
+		slog.Error("error mapping uploaded file", "err", err)
+		return nil, errs.InternalError("error reading uploaded file", err)
+	}
+
+	extraction, err := s.analyzer.Extract(ctx, analyzer.File{Data: data, MimeType: upload.ContentType})
+	if err != nil {
+		slog.Error("error extracting document details", "err", err, "provider", s.analyzer.Name())
+		recordFailedUsage(ctx, s.usage, err, entity.UsageOperationExtraction, companyID, userID, nil)
+		return nil, analyzerError(err, "extraction", "error extracting document details")
+	}
+	if extraction.Provider == "" {
+		extraction.Provider = s.analyzer.Name()
+	}
+	if extraction.Usage != nil {
+		s.usage.RecordUsage(ctx, newUsageRecord(entity.UsageOperationExtraction, extraction.Provider, *extraction.Usage, companyID, userID, nil))
+	}
+	return extraction, nil
+}
+
+// GetDocumentFields returns the fields recorded for a document
+func (s *documentService) GetDocumentFields(ctx context.Context, id, requesterCompanyID int) ([]entity.DocumentField, error) {
+	if _, err := s.GetDocumentByID(ctx, id, requesterCompanyID); err != nil {
+		return nil, err
+	}
+	fields, err := s.fieldRepo.GetDocumentFields(ctx, id)
+	if err != nil {
+		return nil, errs.InternalError("error getting document fields", err)
+	}
+	if fields == nil {
+		fields = []entity.DocumentField{}
+	}
+	return fields, nil
+}
+
+// UpdateDocumentFields replaces the fields of an active document with fields entered by the issuer
+// and records the change in the document events
+func (s *documentService) UpdateDocumentFields(ctx context.Context, id int, req entity.UpdateDocumentFieldsRequest, requesterCompanyID, userID int) ([]entity.DocumentField, error) {
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.State != entity.DocumentStateActive {
+		return nil, errs.ValidationError(fmt.Sprintf("fields of a %s document cannot be updated", doc.State), nil)
+	}
+
+	values, err := analyzer.NormalizeDocumentFields(req.Fields)
+	if err != nil {
+		return nil, errs.ValidationError(err.Error(), err)
+	}
+	current, err := s.fieldRepo.GetDocumentFields(ctx, id)
+	if err != nil {
+		return nil, errs.InternalError("error getting document fields", err)
+	}
+
+	event, err := newDocumentEvent(id, userID, entity.DocumentEventFieldsUpdated,
+		map[string]any{"fields": fieldChange{From: fieldValues(current), To: values}})
+	if err != nil {
+		return nil, err
+	}
+	fields := newDocumentFields(values, entity.DocumentFieldManual)
+	if err := s.fieldRepo.ReplaceDocumentFields(ctx, id, fields, event); err != nil {
+		return nil, errs.InternalError("error updating document fields", err)
+	}
+	return fields, nil
+}
+
+func newDocumentFields(values []entity.DocumentFieldValue, source entity.DocumentFieldSource) []entity.DocumentField {
+	fields := make([]entity.DocumentField, len(values))
+	for i, v := range values {
+		fields[i] = entity.DocumentField{Name: v.Name, Kind: v.Kind, Value: v.Value, Source: source}
+	}
+	return fields
+}
+
+func fieldValues(fields []entity.DocumentField) []entity.DocumentFieldValue {
+	values := make([]entity.DocumentFieldValue, len(fields))
+	for i, f := range fields {
+		values[i] = entity.DocumentFieldValue{Name: f.Name, Kind: f.Kind, Value: f.Value}
+	}
+	return values
+}
+
+// linkDifferenceFields references the recorded field each difference concerns, using the field's name
+// as the location. A difference concerns the field the model named, or the field whose value the
+// original shows.
+func linkDifferenceFields(result *entity.DocumentAnalysisResult, fields []entity.DocumentField) {
+	for i := range result.Differences {
+		d := &result.Differences[i]
+		d.Field = differenceField(*d, fields)
+		switch {
+		case d.Field == "":
+		case d.Location == "" || analyzer.NormalizeFieldName(d.Location) == d.Field:
+			d.Location = d.Field
+		default:
+			d.Location = d.Field + ", " + d.Location
+		}
+	}
+}
+
+func differenceField(d entity.DocumentDifference, fields []entity.DocumentField) string {
+	for _, name := range []string{d.Field, d.Location} {
+		name = analyzer.NormalizeFieldName(name)
+		for _, f := range fields {
+			if name != "" && f.Name == name {
+				return f.Name
+			}
+		}
+	}
+
+	original := strings.ToLower(d.OriginalValue)
+	for _, f := range fields {
+		if len(f.Value) >= minFieldValueMatch && strings.Contains(original, strings.ToLower(f.Value)) {
+			return f.Name
+		}
+	}
+	return ""
+}
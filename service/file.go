@@ -0,0 +1,90 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/blob"
+)
+
+// bytesFile adapts a file read from Postgres to io.ReadSeekCloser
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (bytesFile) Close() error {
+	return nil
+}
+
+// OpenDocumentFile returns a document with its file opened for reading (only if requester belongs to the same company).
+// The caller must close the file.
+func (s *documentService) OpenDocumentFile(ctx context.Context, id, requesterCompanyID int) (*entity.Document, io.ReadSeekCloser, error) {
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := s.openDocumentFile(ctx, doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, file, nil
+}
+
+// errQuarantined is returned instead of the file of a quarantined document
+var errQuarantined = errs.FileRejectedError("document file is quarantined", nil)
+
+func (s *documentService) openDocumentFile(ctx context.Context, doc *entity.Document) (io.ReadSeekCloser, error) {
+	if doc.State == entity.DocumentStateQuarantined {
+		return nil, errQuarantined
+	}
+	return s.openFile(ctx, doc.FileKey, func(ctx context.Context) ([]byte, error) {
+		return s.documentRepo.GetLegacyFileData(ctx, doc.ID)
+	})
+}
+
+// readDocumentFile reads the whole document file, for processing that needs it in memory
+func (s *documentService) readDocumentFile(ctx context.Context, doc *entity.Document) ([]byte, error) {
+	file, err := s.openDocumentFile(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		slog.Error("error reading document file", "err", err, "document_id", doc.ID)
+		return nil, errs.InternalError("error reading document file", err)
+	}
+	return data, nil
+}
+
+// openFile opens a file from the blob store, or reads it from Postgres with legacy if it has not been moved there yet
+func (s *documentService) openFile(ctx context.Context, key string, legacy func(context.Context) ([]byte, error)) (io.ReadSeekCloser, error) {
+	if key == "" {
+		data, err := legacy(ctx)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errs.NotFoundError("document file", err)
+			}
+			return nil, errs.InternalError("error reading document file", err)
+		}
+		return bytesFile{bytes.NewReader(data)}, nil
+	}
+
+	file, err := s.blobStore.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			slog.Error("document file missing from blob store", "key", key)
+			return nil, errs.NotFoundError("document file", err)
+		}
+		return nil, errs.InternalError("error reading document file", err)
+	}
+	return file, nil
+}
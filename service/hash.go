@@ -0,0 +1,153 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+const (
+	// DocumentHashVersion is the current signed document hash format version
+	DocumentHashVersion = "v1"
+
+	// minDocumentHashKeyLength is the minimum accepted HMAC key length in bytes
+	minDocumentHashKeyLength = 32
+)
+
+// DocumentHashSigner issues and validates tamper-evident document hashes.
+//
+// Signed hashes have the form "v1.<key id>.<base64url payload>.<base64url signature>",
+// where the signature is HMAC-SHA256 over "v1.<key id>.<base64url payload>".
+type DocumentHashSigner interface {
+	Sign(payload entity.DocumentHashPayload) (string, error)
+	Verify(hash string) (entity.DocumentHashPayload, error)
+}
+
+type hmacDocumentHashSigner struct {
+	keys        map[string][]byte
+	activeKeyID string
+	allowLegacy bool
+}
+
+// NewDocumentHashSigner creates a signer using the given HMAC keys indexed by key ID.
+// The active key is used to sign new hashes, all keys are accepted for verification
+// so that keys can be rotated. When allowLegacy is set, unsigned base64 JSON hashes
+// issued before signing was introduced are still accepted.
+func NewDocumentHashSigner(keys map[string][]byte, activeKeyID string, allowLegacy bool) (DocumentHashSigner, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no document hash signing keys configured")
+	}
+	for kid, key := range keys {
+		if kid == "" || strings.ContainsAny(kid, ".:,") {
+			return nil, fmt.Errorf("invalid document hash key id %q", kid)
+		}
+		if len(key) < minDocumentHashKeyLength {
+			return nil, fmt.Errorf("document hash key %q must be at least %d bytes", kid, minDocumentHashKeyLength)
+		}
+	}
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active document hash key %q is not configured", activeKeyID)
+	}
+	if allowLegacy {
+		slog.Warn("Legacy unsigned document hashes are accepted, disable DOCUMENT_HASH_ALLOW_LEGACY once migrated")
+	}
+
+	return &hmacDocumentHashSigner{
+		keys:        keys,
+		activeKeyID: activeKeyID,
+		allowLegacy: allowLegacy,
+	}, nil
+}
+
+// Sign encodes the payload and signs it with the active key
+func (s *hmacDocumentHashSigner) Sign(payload entity.DocumentHashPayload) (string, error) {
+	if payload.IssuedAt == 0 {
+		payload.IssuedAt = time.Now().Unix()
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", errs.InternalError("error marshaling hash payload", err)
+	}
+
+	signingInput := DocumentHashVersion + "." + s.activeKeyID + "." + base64.RawURLEncoding.EncodeToString(payloadBytes)
+	signature := s.sign(s.keys[s.activeKeyID], signingInput)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks the hash signature and returns the decoded payload
+func (s *hmacDocumentHashSigner) Verify(hash string) (entity.DocumentHashPayload, error) {
+	parts := strings.Split(hash, ".")
+	if len(parts) == 1 {
+		return s.verifyLegacy(hash)
+	}
+	if len(parts) != 4 {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("invalid document hash format", nil)
+	}
+
+	version, kid, encodedPayload, encodedSignature := parts[0], parts[1], parts[2], parts[3]
+	if version != DocumentHashVersion {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("unsupported document hash version", nil)
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("unknown document hash key", nil)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("invalid document hash signature", err)
+	}
+
+	expected := s.sign(key, version+"."+kid+"."+encodedPayload)
+	if !hmac.Equal(signature, expected) {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("invalid document hash signature", nil)
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("invalid document hash", err)
+	}
+
+	var payload entity.DocumentHashPayload
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("invalid document hash format", err)
+	}
+	return payload, nil
+}
+
+// verifyLegacy decodes an unsigned base64 JSON hash if legacy hashes are allowed
+func (s *hmacDocumentHashSigner) verifyLegacy(hash string) (entity.DocumentHashPayload, error) {
+	if !s.allowLegacy {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("unsigned document hashes are no longer accepted", nil)
+	}
+
+	payloadBytes, err := base64.URLEncoding.DecodeString(hash)
+	if err != nil {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("invalid document hash", err)
+	}
+
+	var payload entity.DocumentHashPayload
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return entity.DocumentHashPayload{}, errs.BadRequestError("invalid document hash format", err)
+	}
+	payload.Legacy = true
+
+	slog.Warn("legacy unsigned document hash accepted", "document_id", payload.ID)
+	return payload, nil
+}
+
+func (s *hmacDocumentHashSigner) sign(key []byte, input string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(input))
+	return mac.Sum(nil)
+}
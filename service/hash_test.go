@@ -0,0 +1,223 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+var (
+	testHashKey1 = []byte("0123456789abcdef0123456789abcdef")
+	testHashKey2 = []byte("fedcba9876543210fedcba9876543210")
+)
+
+var testHashPayload = entity.DocumentHashPayload{
+	ID:        42,
+	CompanyID: 3,
+	Type:      "diploma",
+	Name:      "Diploma.pdf",
+	Version:   2,
+	IssuedAt:  1735689600,
+}
+
+func newTestHashSigner(t *testing.T, keys map[string][]byte, activeKeyID string, allowLegacy bool) DocumentHashSigner {
+	t.Helper()
+	signer, err := NewDocumentHashSigner(keys, activeKeyID, allowLegacy)
+	if err != nil {
+		t.Fatalf("NewDocumentHashSigner: %v", err)
+	}
+	return signer
+}
+
+func TestNewDocumentHashSigner(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        map[string][]byte
+		activeKeyID string
+		wantErr     bool
+	}{
+		{"one key", map[string][]byte{"k1": testHashKey1}, "k1", false},
+		{"several keys", map[string][]byte{"k1": testHashKey1, "k2": testHashKey2}, "k2", false},
+		{"no keys", nil, "k1", true},
+		{"key too short", map[string][]byte{"k1": testHashKey1[:31]}, "k1", true},
+		{"retired key too short", map[string][]byte{"k1": testHashKey1, "k2": []byte("short")}, "k1", true},
+		{"empty key id", map[string][]byte{"": testHashKey1}, "", true},
+		{"key id with dot", map[string][]byte{"k.1": testHashKey1}, "k.1", true},
+		{"key id with colon", map[string][]byte{"k:1": testHashKey1}, "k:1", true},
+		{"key id with comma", map[string][]byte{"k,1": testHashKey1}, "k,1", true},
+		{"active key missing", map[string][]byte{"k1": testHashKey1}, "k2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDocumentHashSigner(tt.keys, tt.activeKeyID, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewDocumentHashSigner error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDocumentHashRoundTrip(t *testing.T) {
+	signer := newTestHashSigner(t, map[string][]byte{"k1": testHashKey1}, "k1", false)
+
+	hash, err := signer.Sign(testHashPayload)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	parts := strings.Split(hash, ".")
+	if len(parts) != 4 || parts[0] != DocumentHashVersion || parts[1] != "k1" {
+		t.Fatalf("Sign = %q, want v1.k1.<payload>.<signature>", hash)
+	}
+
+	got, err := signer.Verify(hash)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got != testHashPayload {
+		t.Errorf("Verify = %+v, want %+v", got, testHashPayload)
+	}
+
+	// Signing stamps the issue time if it is missing
+	unstamped := testHashPayload
+	unstamped.IssuedAt = 0
+	hash, err = signer.Sign(unstamped)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if got, err := signer.Verify(hash); err != nil || got.IssuedAt == 0 {
+		t.Errorf("Verify = %+v, %v; want an issue time", got, err)
+	}
+}
+
+func TestDocumentHashTampered(t *testing.T) {
+	signer := newTestHashSigner(t, map[string][]byte{"k1": testHashKey1, "k2": testHashKey2}, "k1", true)
+	hash, err := signer.Sign(testHashPayload)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	parts := strings.Split(hash, ".")
+
+	forged := testHashPayload
+	forged.CompanyID = 4
+	forgedBytes, _ := json.Marshal(forged)
+	forgedPayload := base64.RawURLEncoding.EncodeToString(forgedBytes)
+
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[3])
+	signature[0] ^= 1
+	flippedSignature := base64.RawURLEncoding.EncodeToString(signature)
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"payload replaced", strings.Join([]string{parts[0], parts[1], forgedPayload, parts[3]}, ".")},
+		{"signature flipped", strings.Join([]string{parts[0], parts[1], parts[2], flippedSignature}, ".")},
+		{"signature removed", strings.Join([]string{parts[0], parts[1], parts[2], ""}, ".")},
+		{"signature not base64", strings.Join([]string{parts[0], parts[1], parts[2], "!!!"}, ".")},
+		{"signed with other key", strings.Join([]string{parts[0], "k2", parts[2], parts[3]}, ".")},
+		{"unknown key", strings.Join([]string{parts[0], "k3", parts[2], parts[3]}, ".")},
+		{"other version", strings.Join([]string{"v2", parts[1], parts[2], parts[3]}, ".")},
+		{"extra part", hash + ".x"},
+		{"missing part", strings.Join(parts[:3], ".")},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := signer.Verify(tt.hash)
+			if err == nil {
+				t.Fatal("Verify accepted a tampered hash")
+			}
+			if got := errs.ErrorCast(err).Type; got != errs.ErrorTypeBadRequest {
+				t.Errorf("Verify error = %v, want %s", err, errs.ErrorTypeBadRequest)
+			}
+		})
+	}
+}
+
+func TestDocumentHashKeyRotation(t *testing.T) {
+	before := newTestHashSigner(t, map[string][]byte{"k1": testHashKey1}, "k1", false)
+	oldHash, err := before.Sign(testHashPayload)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// k2 signs new hashes, k1 stays configured for the hashes it signed
+	rotated := newTestHashSigner(t, map[string][]byte{"k1": testHashKey1, "k2": testHashKey2}, "k2", false)
+	if got, err := rotated.Verify(oldHash); err != nil || got != testHashPayload {
+		t.Errorf("Verify old hash after rotation = %+v, %v", got, err)
+	}
+	newHash, err := rotated.Sign(testHashPayload)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !strings.HasPrefix(newHash, DocumentHashVersion+".k2.") {
+		t.Errorf("Sign after rotation = %q, want it signed with k2", newHash)
+	}
+
+	// Once k1 is retired, its hashes no longer verify
+	retired := newTestHashSigner(t, map[string][]byte{"k2": testHashKey2}, "k2", false)
+	if _, err := retired.Verify(oldHash); err == nil {
+		t.Error("Verify accepted a hash of a retired key")
+	}
+	if _, err := retired.Verify(newHash); err != nil {
+		t.Errorf("Verify new hash: %v", err)
+	}
+
+	// A key ID names the key, another key under the same ID does not verify its hashes
+	replaced := newTestHashSigner(t, map[string][]byte{"k1": testHashKey2}, "k1", false)
+	if _, err := replaced.Verify(oldHash); err == nil {
+		t.Error("Verify accepted a hash signed with another key of the same ID")
+	}
+}
+
+func TestDocumentHashLegacy(t *testing.T) {
+	legacyPayload := entity.DocumentHashPayload{ID: 7, CompanyID: 3, Type: "certificate", Name: "Certificate.pdf"}
+	data, _ := json.Marshal(legacyPayload)
+	legacyHash := base64.URLEncoding.EncodeToString(data)
+	keys := map[string][]byte{"k1": testHashKey1}
+
+	tests := []struct {
+		name        string
+		allowLegacy bool
+		hash        string
+		want        entity.DocumentHashPayload
+		wantErr     bool
+	}{
+		{"accepted when allowed", true, legacyHash, entity.DocumentHashPayload{ID: 7, CompanyID: 3, Type: "certificate", Name: "Certificate.pdf", Legacy: true}, false},
+		{"rejected when not allowed", false, legacyHash, entity.DocumentHashPayload{}, true},
+		{"not base64", true, "not-base64!", entity.DocumentHashPayload{}, true},
+		{"not JSON", true, base64.URLEncoding.EncodeToString([]byte("plain text")), entity.DocumentHashPayload{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newTestHashSigner(t, keys, "k1", tt.allowLegacy)
+			got, err := signer.Verify(tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if typ := errs.ErrorCast(err).Type; typ != errs.ErrorTypeBadRequest {
+					t.Errorf("Verify error = %v, want %s", err, errs.ErrorTypeBadRequest)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Verify = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Signed hashes are never marked legacy
+	signer := newTestHashSigner(t, keys, "k1", true)
+	hash, err := signer.Sign(testHashPayload)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if got, err := signer.Verify(hash); err != nil || got.Legacy {
+		t.Errorf("Verify signed hash = %+v, %v; want it not legacy", got, err)
+	}
+}
@@ -0,0 +1,512 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/blob"
+	"github.com/tasklineby/certify-backend/repository/pg"
+	"github.com/tasklineby/certify-backend/repository/rdb"
+)
+
+const (
+	// jobPollInterval is how long an idle worker waits before looking for ready jobs again
+	jobPollInterval = time.Second
+	// jobLeaseMargin is added to the attempt timeout to get the lease of a claimed job
+	jobLeaseMargin = 30 * time.Second
+	// jobRetryDelay is the delay before the first retry, doubled for every further attempt
+	jobRetryDelay    = 5 * time.Second
+	maxJobRetryDelay = 5 * time.Minute
+	// MaxComparisonPhotos is the number of photos a document can be compared with at once
+	MaxComparisonPhotos = 20
+)
+
+// ComparisonJobService runs document comparisons in the background. The document is verified and the
+// submitted files scanned when the job is submitted. Until the job finished, its files are kept in the
+// job store and the job in Postgres, while the Redis queue only holds job IDs.
+type ComparisonJobService interface {
+	EnqueueComparison(ctx context.Context, kind entity.ComparisonJobKind, hash string, userID, requesterCompanyID int, files [][]byte, pages []int, webhookURL string) (*entity.ComparisonJobResponse, error)
+	GetJob(ctx context.Context, id string, requesterCompanyID int) (*entity.ComparisonJobResponse, error)
+	// Run processes jobs with the configured number of workers until ctx is canceled,
+	// then waits for the jobs in progress to finish
+	Run(ctx context.Context)
+}
+
+type comparisonJobService struct {
+	jobRepo         pg.ComparisonJobRepository
+	queue           rdb.JobQueueRepository
+	jobStore        blob.BlobStore
+	documentService DocumentService
+	photos          PhotoPreprocessor
+	usage           UsageService
+	webhooks        *webhookSender
+	workers         int
+	maxAttempts     int
+	timeout         time.Duration
+}
+
+func NewComparisonJobService(jobRepo pg.ComparisonJobRepository, queue rdb.JobQueueRepository, jobStore blob.BlobStore, documentService DocumentService, photos PhotoPreprocessor, usage UsageService, workers, maxAttempts int, timeout time.Duration, webhookSecret string) ComparisonJobService {
+	return &comparisonJobService{
+		jobRepo:         jobRepo,
+		queue:           queue,
+		jobStore:        jobStore,
+		documentService: documentService,
+		photos:          photos,
+		usage:           usage,
+		webhooks:        newWebhookSender(webhookSecret),
+		workers:         workers,
+		maxAttempts:     maxAttempts,
+		timeout:         timeout,
+	}
+}
+
+// EnqueueComparison verifies the document identified by hash, scans and stores the submitted files
+// and queues their comparison with the document. pages optionally gives the page of the document each
+// photo shows. The webhook URL, if any, is called once the job succeeded or failed.
+func (s *comparisonJobService) EnqueueComparison(ctx context.Context, kind entity.ComparisonJobKind, hash string, userID, requesterCompanyID int, files [][]byte, pages []int, webhookURL string) (*entity.ComparisonJobResponse, error) {
+	switch {
+	case kind == entity.ComparisonJobPDF && len(files) != 1:
+		return nil, errs.ValidationError("exactly one PDF file is required", nil)
+	case kind == entity.ComparisonJobPhotos && len(files) == 0:
+		return nil, errs.ValidationError("at least one photo is required", nil)
+	case kind == entity.ComparisonJobPhotos && len(files) > MaxComparisonPhotos:
+		return nil, errs.ValidationError(fmt.Sprintf("at most %d photos can be compared at once", MaxComparisonPhotos), nil)
+	case kind != entity.ComparisonJobPDF && kind != entity.ComparisonJobPhotos:
+		return nil, errs.ValidationError(fmt.Sprintf("unknown comparison kind %q", kind), nil)
+	}
+	if err := validatePhotoPages(kind, len(files), pages); err != nil {
+		return nil, err
+	}
+	// Checked again when the job runs; rejecting the request now spares storing its files
+	if err := s.usage.CheckQuota(ctx, requesterCompanyID); err != nil {
+		return nil, err
+	}
+
+	job := entity.ComparisonJob{
+		CompanyID:    requesterCompanyID,
+		UserID:       userID,
+		Kind:         kind,
+		Status:       entity.ComparisonJobQueued,
+		DocumentHash: hash,
+	}
+	if webhookURL != "" {
+		if err := validateWebhookURL(ctx, webhookURL); err != nil {
+			return nil, err
+		}
+		job.WebhookURL = &webhookURL
+	}
+
+	// Photos are prepared before they are stored, so unusable ones are rejected right away
+	prepared := []analyzer.File{{Data: files[0], MimeType: ContentTypePDF}}
+	evidenceKind := entity.EvidenceKindPDF
+	if kind == entity.ComparisonJobPhotos {
+		var err error
+		if prepared, err = s.photos.Prepare(files); err != nil {
+			return nil, err
+		}
+		evidenceKind = entity.EvidenceKindPhoto
+	}
+
+	// The document is verified and the files scanned once, before anything is stored; retries of the
+	// job only repeat the analysis
+	verification, historyID, err := s.documentService.StartComparison(ctx, hash, userID, requesterCompanyID, evidenceKind, prepared)
+	if err != nil {
+		return nil, err
+	}
+	encodedVerification, err := json.Marshal(verification)
+	if err != nil {
+		return nil, errs.InternalError("error encoding comparison verification", err)
+	}
+	verificationJSON := string(encodedVerification)
+	job.Verification = &verificationJSON
+	job.HistoryID = historyID
+	if verification.Document != nil {
+		job.DocumentID = &verification.Document.ID
+	}
+
+	// Files of documents with red status are not compared, so they are not stored either
+	inputs := []entity.ComparisonJobInput{}
+	if verification.Status != entity.DocumentStatusRed {
+		inputs = comparisonInputs(prepared, pages)
+	}
+	encoded, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, errs.InternalError("error encoding comparison inputs", err)
+	}
+	job.Inputs = string(encoded)
+
+	job.ID, err = newJobID()
+	if err != nil {
+		return nil, errs.InternalError("error generating job id", err)
+	}
+	// The job is recorded before its files are stored: a job with the same content finishing meanwhile
+	// sees the reference and keeps the shared file. Workers only see the job once it is queued.
+	if err := s.jobRepo.CreateJob(ctx, &job); err != nil {
+		return nil, errs.InternalError("error creating comparison job", err)
+	}
+	if err := s.storeInputs(ctx, prepared, inputs); err != nil {
+		if finishErr := s.jobRepo.FinishJob(ctx, job.ID, entity.ComparisonJobFailed, nil, "job inputs could not be stored"); finishErr != nil {
+			slog.Error("error failing comparison job without inputs", "err", finishErr, "job_id", job.ID)
+			return nil, err
+		}
+		s.deleteInputs(ctx, job.ID, inputs)
+		return nil, err
+	}
+	if err := s.queue.Enqueue(ctx, job.ID, time.Now()); err != nil {
+		if finishErr := s.jobRepo.FinishJob(ctx, job.ID, entity.ComparisonJobFailed, nil, "job could not be queued"); finishErr != nil {
+			slog.Error("error failing unqueued comparison job", "err", finishErr, "job_id", job.ID)
+			return nil, err
+		}
+		s.deleteInputs(ctx, job.ID, inputs)
+		return nil, err
+	}
+
+	slog.Info("comparison job queued", "job_id", job.ID, "kind", kind, "files", len(files))
+	return toJobResponse(job)
+}
+
+// GetJob returns a job of the requester's company; jobs of other companies are reported as not found
+func (s *comparisonJobService) GetJob(ctx context.Context, id string, requesterCompanyID int) (*entity.ComparisonJobResponse, error) {
+	job, err := s.jobRepo.GetJobByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFoundError("job", err)
+		}
+		return nil, errs.InternalError("error getting job", err)
+	}
+	if job.CompanyID != requesterCompanyID {
+		return nil, errs.NotFoundError("job", nil)
+	}
+	return toJobResponse(job)
+}
+
+func (s *comparisonJobService) Run(ctx context.Context) {
+	slog.Info("comparison job workers started", "workers", s.workers)
+	var wg sync.WaitGroup
+	for range s.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Wait()
+	slog.Info("comparison job workers stopped")
+}
+
+func (s *comparisonJobService) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		if n, err := s.queue.RequeueExpired(ctx); err == nil && n > 0 {
+			slog.Warn("requeued comparison jobs with expired leases", "count", n)
+		}
+
+		id, err := s.queue.Claim(ctx, s.timeout+jobLeaseMargin)
+		if err != nil || id == "" {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(jobPollInterval):
+			}
+			continue
+		}
+
+		// A claimed job is finished even during shutdown; its lease covers the attempt timeout
+		s.process(context.WithoutCancel(ctx), id)
+	}
+}
+
+// process runs one attempt of a job. When the job state cannot be recorded, the job stays leased and
+// is retried after the lease expired.
+func (s *comparisonJobService) process(ctx context.Context, id string) {
+	job, err := s.jobRepo.StartJob(ctx, id)
+	if err == sql.ErrNoRows {
+		// finished already, e.g. by a worker whose lease had expired
+		_ = s.queue.Complete(ctx, id)
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	response, err := s.runComparison(attemptCtx, job)
+	cancel()
+	if err != nil {
+		s.fail(ctx, job, err)
+		return
+	}
+
+	result, err := json.Marshal(response)
+	if err != nil {
+		s.fail(ctx, job, errs.InternalError("error encoding comparison result", err))
+		return
+	}
+	if err := s.jobRepo.FinishJob(ctx, id, entity.ComparisonJobSucceeded, result, ""); err != nil {
+		return
+	}
+	_ = s.queue.Complete(ctx, id)
+	slog.Info("comparison job succeeded", "job_id", id, "attempt", job.Attempts)
+	s.deleteJobInputs(ctx, job)
+	s.notify(ctx, id)
+}
+
+// fail retries an attempt that failed with a temporary error, such as an unavailable or rate limited
+// analyzer or a timeout, until the job runs out of attempts. Other errors, e.g. an exceeded quota or a
+// deleted document, fail the job right away.
+func (s *comparisonJobService) fail(ctx context.Context, job entity.ComparisonJob, err error) {
+	errCast := errs.ErrorCast(err)
+	message := errCast.Message
+	if errors.Is(errCast.Err, context.DeadlineExceeded) {
+		message = fmt.Sprintf("comparison did not finish within %s", s.timeout)
+	}
+
+	temporary := errCast.StatusCode() >= 500 || errCast.Type == errs.ErrorTypeRateLimited
+	if temporary && job.Attempts < s.maxAttempts {
+		delay := retryDelay(job.Attempts)
+		slog.Warn("comparison job attempt failed, retrying", "err", err, "job_id", job.ID, "attempt", job.Attempts, "delay", delay)
+		if err := s.jobRepo.RetryJob(ctx, job.ID, message); err != nil {
+			return
+		}
+		_ = s.queue.Retry(ctx, job.ID, time.Now().Add(delay))
+		return
+	}
+
+	slog.Error("comparison job failed", "err", err, "job_id", job.ID, "attempt", job.Attempts)
+	if err := s.jobRepo.FinishJob(ctx, job.ID, entity.ComparisonJobFailed, nil, message); err != nil {
+		return
+	}
+	_ = s.queue.Complete(ctx, job.ID)
+	s.deleteJobInputs(ctx, job)
+	s.notify(ctx, job.ID)
+}
+
+// runComparison loads the submitted files of a job and compares them with the verified document.
+// Jobs for documents with red status end with their verification.
+func (s *comparisonJobService) runComparison(ctx context.Context, job entity.ComparisonJob) (*entity.CompareDocumentResponse, error) {
+	if job.Verification == nil {
+		return nil, errs.ValidationError("comparison job was queued without verification, submit it again", nil)
+	}
+	var response entity.CompareDocumentResponse
+	if err := json.Unmarshal([]byte(*job.Verification), &response); err != nil {
+		return nil, errs.InternalError("error decoding comparison verification", err)
+	}
+	if response.Status == entity.DocumentStatusRed {
+		return &response, nil
+	}
+	if job.DocumentID == nil {
+		return nil, errs.NotFoundError("document", nil)
+	}
+
+	inputs, err := decodeInputs(job)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]analyzer.File, len(inputs))
+	var pages []int
+	for i, input := range inputs {
+		if input.Page > 0 {
+			pages = append(pages, input.Page)
+		}
+		data, err := s.readInput(ctx, input.FileKey)
+		if err != nil {
+			slog.Error("error reading comparison input", "err", err, "job_id", job.ID, "file_key", input.FileKey)
+			return nil, errs.InternalError("error reading comparison input", err)
+		}
+		files[i] = analyzer.File{Data: data, MimeType: input.ContentType}
+	}
+
+	doc, analysis, err := s.documentService.FinishComparison(ctx, *job.DocumentID, job.UserID, job.HistoryID, files, pages)
+	if err != nil {
+		return nil, err
+	}
+	response.Document = doc
+	response.Analysis = analysis
+	return &response, nil
+}
+
+// comparisonInputs describes the files of a job before they are stored. The job store is
+// content-addressed, so the key of every file is known up front.
+func comparisonInputs(files []analyzer.File, pages []int) []entity.ComparisonJobInput {
+	inputs := make([]entity.ComparisonJobInput, len(files))
+	for i, file := range files {
+		sum := sha256.Sum256(file.Data)
+		inputs[i] = entity.ComparisonJobInput{FileKey: hex.EncodeToString(sum[:]), ContentType: file.MimeType}
+		if len(pages) > 0 {
+			inputs[i].Page = pages[i]
+		}
+	}
+	return inputs
+}
+
+// storeInputs keeps the submitted files in the job store until their job finished
+func (s *comparisonJobService) storeInputs(ctx context.Context, files []analyzer.File, inputs []entity.ComparisonJobInput) error {
+	for i, input := range inputs {
+		info, err := s.jobStore.Put(ctx, bytes.NewReader(files[i].Data))
+		if err != nil {
+			slog.Error("error storing comparison input", "err", err)
+			return errs.InternalError("error storing comparison input", err)
+		}
+		if info.Key != input.FileKey {
+			return errs.InternalError("error storing comparison input", fmt.Errorf("stored under key %s, want %s", info.Key, input.FileKey))
+		}
+	}
+	return nil
+}
+
+func (s *comparisonJobService) readInput(ctx context.Context, key string) ([]byte, error) {
+	rc, err := s.jobStore.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// deleteJobInputs deletes the stored files of a job that succeeded or failed for good
+func (s *comparisonJobService) deleteJobInputs(ctx context.Context, job entity.ComparisonJob) {
+	inputs, err := decodeInputs(job)
+	if err != nil {
+		slog.Error("error decoding inputs of finished comparison job", "err", err, "job_id", job.ID)
+		return
+	}
+	s.deleteInputs(ctx, job.ID, inputs)
+}
+
+// deleteInputs deletes stored files that no queued or running job uses. Jobs submitting the same
+// content share its file, as the store is content-addressed; a job is recorded before it stores its
+// files, so a file being submitted again is not deleted. Files left behind by a failed delete stay
+// in the store until a later job with the same content finished.
+func (s *comparisonJobService) deleteInputs(ctx context.Context, jobID string, inputs []entity.ComparisonJobInput) {
+	seen := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		if seen[input.FileKey] {
+			continue
+		}
+		seen[input.FileKey] = true
+
+		referenced, err := s.jobRepo.IsInputReferenced(ctx, input.FileKey)
+		if err != nil || referenced {
+			continue
+		}
+		if err := s.jobStore.Delete(ctx, input.FileKey); err != nil {
+			slog.Error("error deleting comparison input", "err", err, "job_id", jobID, "file_key", input.FileKey)
+		}
+	}
+}
+
+func decodeInputs(job entity.ComparisonJob) ([]entity.ComparisonJobInput, error) {
+	var inputs []entity.ComparisonJobInput
+	if err := json.Unmarshal([]byte(job.Inputs), &inputs); err != nil {
+		return nil, errs.InternalError("error decoding comparison inputs", err)
+	}
+	return inputs, nil
+}
+
+// notify posts the finished job to its webhook URL
+func (s *comparisonJobService) notify(ctx context.Context, id string) {
+	job, err := s.jobRepo.GetJobByID(ctx, id)
+	if err != nil || job.WebhookURL == nil {
+		return
+	}
+	response, err := toJobResponse(job)
+	if err != nil {
+		slog.Error("error building comparison job webhook payload", "err", err, "job_id", id)
+		return
+	}
+	payload, err := json.Marshal(response)
+	if err != nil {
+		slog.Error("error encoding comparison job webhook payload", "err", err, "job_id", id)
+		return
+	}
+	if err := s.webhooks.deliver(ctx, *job.WebhookURL, "comparison_job.finished", payload); err != nil {
+		slog.Warn("comparison job webhook not delivered", "err", err, "job_id", id)
+		return
+	}
+	_ = s.jobRepo.SetWebhookDelivered(ctx, id)
+}
+
+func toJobResponse(job entity.ComparisonJob) (*entity.ComparisonJobResponse, error) {
+	response := &entity.ComparisonJobResponse{
+		ID:         job.ID,
+		Kind:       job.Kind,
+		Status:     job.Status,
+		Attempts:   job.Attempts,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	if job.Error != nil {
+		response.Error = *job.Error
+	}
+	if job.Result != nil {
+		response.Result = &entity.CompareDocumentResponse{}
+		if err := json.Unmarshal([]byte(*job.Result), response.Result); err != nil {
+			return nil, errs.InternalError("error decoding comparison result", err)
+		}
+	}
+	return response, nil
+}
+
+// retryDelay returns the backoff before the attempt following attempt
+func retryDelay(attempt int) time.Duration {
+	delay := jobRetryDelay
+	for i := 1; i < attempt && delay < maxJobRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxJobRetryDelay)
+}
+
+// newJobID returns a random, unguessable job ID
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validatePhotoPages checks that pages, if given, holds the page number of every photo
+func validatePhotoPages(kind entity.ComparisonJobKind, photos int, pages []int) error {
+	if len(pages) == 0 {
+		return nil
+	}
+	if kind != entity.ComparisonJobPhotos {
+		return errs.ValidationError("pages can only be given for photos", nil)
+	}
+	if len(pages) != photos {
+		return errs.ValidationError(fmt.Sprintf("pages must give the page of each of the %d photos", photos), nil)
+	}
+	for i, page := range pages {
+		if page < 1 {
+			return errs.ValidationError(fmt.Sprintf("page of photo %d must be at least 1", i+1), nil)
+		}
+	}
+	return nil
+}
+
+// validateWebhookURL checks that a webhook URL is an absolute http or https URL of a public host
+func validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errs.ValidationError("webhook_url must be an absolute http or https URL", err)
+	}
+	if err := checkWebhookHost(ctx, u.Hostname()); err != nil {
+		return errs.ValidationError("webhook_url must point to a public host", err)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -27,6 +28,18 @@ func secureRandomBase64() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
 
+// refreshTokenSeparator separates the family ID of a refresh token from its secret
+const refreshTokenSeparator = "."
+
+// refreshTokenFamily returns the family ID a refresh token carries, or "" if it has none
+func refreshTokenFamily(token string) string {
+	familyID, _, found := strings.Cut(token, refreshTokenSeparator)
+	if !found {
+		return ""
+	}
+	return familyID
+}
+
 type AccessToken struct {
 	entity.TokenPayload
 	jwt.RegisteredClaims
@@ -34,7 +47,7 @@ type AccessToken struct {
 
 type JwtService interface {
 	GenerateAccessToken(ctx context.Context, payload entity.TokenPayload) (string, error)
-	GenerateRefreshToken(ctx context.Context, payload entity.TokenPayload) (entity.RefreshToken, error)
+	GenerateRefreshToken(ctx context.Context, familyID string) (entity.RefreshToken, error)
 	ParseAccessToken(ctx context.Context, token string) (entity.TokenPayload, time.Time, error)
 }
 
@@ -65,16 +78,17 @@ func (s *jwtService) GenerateAccessToken(ctx context.Context, payload entity.Tok
 	return token.SignedString([]byte(s.accessTokenSecret))
 }
 
-func (s *jwtService) GenerateRefreshToken(ctx context.Context, payload entity.TokenPayload) (entity.RefreshToken, error) {
+func (s *jwtService) GenerateRefreshToken(ctx context.Context, familyID string) (entity.RefreshToken, error) {
 	str, err := secureRandomBase64()
 	if err != nil {
 		slog.Error("error generating refresh token", "err", err)
 		return entity.RefreshToken{}, errs.InternalError("error generating refresh token", err)
 	}
-	hash := SHA256Hex(str)
+	str = familyID + refreshTokenSeparator + str
 	token := entity.RefreshToken{
-		UserID:    payload.UserID,
-		Token:     hash,
+		Token:     str,
+		Hash:      SHA256Hex(str),
+		FamilyID:  familyID,
 		ExpiresIn: s.refreshTokenTTL,
 	}
 	return token, nil
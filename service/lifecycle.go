@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+// fieldChange is recorded in event details for every updated field
+type fieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// newDocumentEvent builds an audit event for a change made by userID
+func newDocumentEvent(documentID, userID int, action entity.DocumentEventAction, details map[string]any) (*entity.DocumentEvent, error) {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return nil, errs.InternalError("error encoding document event", err)
+	}
+	return &entity.DocumentEvent{
+		DocumentID: documentID,
+		UserID:     &userID,
+		Action:     action,
+		Details:    data,
+	}, nil
+}
+
+// lifecycleError maps repository errors of a document change to service errors
+func lifecycleError(err error, message string) error {
+	if err == sql.ErrNoRows {
+		return errs.NotFoundError("document", err)
+	}
+	return errs.InternalError(message, err)
+}
+
+// activeChangeError maps repository errors of a change to an active document to service errors. The
+// change matches no document when the document stopped being active after it was checked.
+func activeChangeError(err error, message string) error {
+	if err == sql.ErrNoRows {
+		return errs.ValidationError("document is no longer active", err)
+	}
+	return errs.InternalError(message, err)
+}
+
+// UpdateDocument corrects document details and returns the updated document as a new version.
+// Only fields that actually change are written and recorded in the document events.
+func (s *documentService) UpdateDocument(ctx context.Context, id int, req entity.UpdateDocumentRequest, requesterCompanyID, userID int) (*entity.Document, error) {
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.State != entity.DocumentStateActive {
+		return nil, errs.ValidationError(fmt.Sprintf("%s document cannot be updated", doc.State), nil)
+	}
+
+	changes := map[string]any{}
+	var update entity.UpdateDocumentRequest
+	for _, field := range []struct {
+		name    string
+		value   *string
+		current string
+		target  **string
+	}{
+		{"type", req.Type, doc.Type, &update.Type},
+		{"name", req.Name, doc.Name, &update.Name},
+		{"summary", req.Summary, doc.Summary, &update.Summary},
+	} {
+		if field.value == nil {
+			continue
+		}
+		value := strings.TrimSpace(*field.value)
+		if value == "" {
+			return nil, errs.ValidationError(field.name+" cannot be empty", nil)
+		}
+		if value != field.current {
+			*field.target = &value
+			changes[field.name] = fieldChange{From: field.current, To: value}
+		}
+	}
+	if req.ExpirationDate != nil && !req.ExpirationDate.Equal(doc.ExpirationDate) {
+		update.ExpirationDate = req.ExpirationDate
+		changes["expiration_date"] = fieldChange{From: doc.ExpirationDate, To: *req.ExpirationDate}
+	}
+
+	if len(changes) == 0 {
+		return doc, nil
+	}
+	changes["version"] = fieldChange{From: doc.Version, To: doc.Version + 1}
+
+	event, err := newDocumentEvent(id, userID, entity.DocumentEventUpdated, changes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.documentRepo.UpdateDocument(ctx, id, update, event); err != nil {
+		return nil, activeChangeError(err, "error updating document")
+	}
+
+	return s.GetDocumentByID(ctx, id, requesterCompanyID)
+}
+
+// RevokeDocument withdraws an active document. Verification of a revoked document reports red with the reason.
+func (s *documentService) RevokeDocument(ctx context.Context, id, requesterCompanyID, userID int, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errs.ValidationError("revocation reason is required", nil)
+	}
+
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return err
+	}
+	if doc.State != entity.DocumentStateActive {
+		return errs.ValidationError(fmt.Sprintf("document is already %s", doc.State), nil)
+	}
+
+	event, err := newDocumentEvent(id, userID, entity.DocumentEventRevoked, map[string]any{"reason": reason})
+	if err != nil {
+		return err
+	}
+	if err := s.documentRepo.RevokeDocument(ctx, id, reason, event); err != nil {
+		return activeChangeError(err, "error revoking document")
+	}
+	return nil
+}
+
+// SupersedeDocument marks an active document as replaced by another active document of the same company.
+// Verification of a superseded document reports red and points at the successor.
+func (s *documentService) SupersedeDocument(ctx context.Context, id, successorID, requesterCompanyID, userID int) error {
+	if id == successorID {
+		return errs.ValidationError("document cannot supersede itself", nil)
+	}
+
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return err
+	}
+	if doc.State != entity.DocumentStateActive {
+		return errs.ValidationError(fmt.Sprintf("document is already %s", doc.State), nil)
+	}
+
+	successor, err := s.GetDocumentByID(ctx, successorID, requesterCompanyID)
+	if err != nil {
+		return err
+	}
+	if successor.State != entity.DocumentStateActive {
+		return errs.ValidationError(fmt.Sprintf("successor document is %s", successor.State), nil)
+	}
+
+	event, err := newDocumentEvent(id, userID, entity.DocumentEventSuperseded, map[string]any{"superseded_by": successorID})
+	if err != nil {
+		return err
+	}
+	if err := s.documentRepo.SupersedeDocument(ctx, id, successorID, event); err != nil {
+		return activeChangeError(err, "error superseding document")
+	}
+	return nil
+}
+
+// DeleteDocument removes a document from the company's documents. Only admins can delete documents.
+// The document is soft-deleted so verification history and events are kept; verification reports it as not found.
+func (s *documentService) DeleteDocument(ctx context.Context, id int, requesterRole string, requesterCompanyID, userID int) error {
+	if requesterRole != "admin" {
+		return errs.UnauthorizedError("only admins can delete documents", nil)
+	}
+
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return err
+	}
+
+	event, err := newDocumentEvent(id, userID, entity.DocumentEventDeleted, map[string]any{"name": doc.Name})
+	if err != nil {
+		return err
+	}
+	if err := s.documentRepo.DeleteDocument(ctx, id, event); err != nil {
+		return lifecycleError(err, "error deleting document")
+	}
+	return nil
+}
+
+// GetDocumentEvents returns the change history of a document, newest first
+func (s *documentService) GetDocumentEvents(ctx context.Context, id, requesterCompanyID int) ([]entity.DocumentEvent, error) {
+	if _, err := s.GetDocumentByID(ctx, id, requesterCompanyID); err != nil {
+		return nil, err
+	}
+
+	events, err := s.documentRepo.GetEventsByDocumentID(ctx, id)
+	if err != nil {
+		slog.Error("error getting document events", "err", err)
+		return nil, errs.InternalError("error getting document events", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/pdf"
+)
+
+// confidenceRank orders the confidence levels of analysis results
+var confidenceRank = map[string]int{"low": 0, "medium": 1, "high": 2}
+
+// analyzePages compares every page of the stored document with the photos showing it; pages[i] is
+// the page shown by provided[i]. Pages without photos are reported missing and photos of pages the
+// document does not have as extra. Either keeps the document from being confirmed authentic.
+func (s *documentService) analyzePages(ctx context.Context, doc *entity.Document, provided []analyzer.File, pages []int, fields []entity.DocumentField, prompt analyzer.Prompt, templateID *int) (*entity.DocumentAnalysisResult, error) {
+	fileData, err := s.readDocumentFile(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	original, err := openDocumentPages(analyzer.File{Data: fileData, MimeType: doc.ContentType})
+	if err != nil {
+		slog.Error("error splitting document pages", "err", err, "document_id", doc.ID)
+		return nil, errs.InternalError("error reading document pages", err)
+	}
+
+	photos := make(map[int][]int) // page to the indexes of its photos
+	for i, page := range pages {
+		photos[page] = append(photos[page], i)
+	}
+
+	var results []pageResult
+	for page := 1; page <= original.count; page++ {
+		if len(photos[page]) == 0 {
+			results = append(results, pageResult{page: page, status: entity.PageAnalysisMissing})
+			continue
+		}
+		originalPage, err := original.page(page)
+		if err != nil {
+			slog.Error("error extracting document page", "err", err, "document_id", doc.ID, "page", page)
+			return nil, errs.InternalError("error reading document pages", err)
+		}
+		files := make([]analyzer.File, len(photos[page]))
+		for i, photo := range photos[page] {
+			files[i] = provided[photo]
+		}
+
+		analysis, err := s.analyzer.Compare(ctx, prompt, originalPage, files)
+		if err != nil {
+			slog.Error("error analyzing document page", "err", err, "provider", s.analyzer.Name(), "document_id", doc.ID, "page", page)
+			return nil, analyzerError(withPageUsage(err, s.analyzer.Name(), results), "analysis", "error analyzing document")
+		}
+		linkDifferenceFields(analysis, fields)
+		results = append(results, pageResult{page: page, status: entity.PageAnalysisCompared, photos: photos[page], analysis: analysis})
+	}
+
+	for page, indexes := range photos {
+		if page > original.count {
+			results = append(results, pageResult{page: page, status: entity.PageAnalysisExtra, photos: indexes})
+		}
+	}
+	slices.SortFunc(results, func(a, b pageResult) int { return a.page - b.page })
+
+	result := aggregatePages(results, original.count)
+	if result.Provider == "" {
+		result.Provider = s.analyzer.Name()
+	}
+	if result.PromptVersion != "" {
+		result.PromptTemplateID = templateID
+		result.Prompt = prompt.Text
+	}
+	return result, nil
+}
+
+// withPageUsage adds the tokens billed for the pages compared before a page failed to its error, so
+// that they are accounted with the tokens of the failed page
+func withPageUsage(err error, provider string, results []pageResult) error {
+	var usage entity.TokenUsage
+	var usageErr *analyzer.UsageError
+	if errors.As(err, &usageErr) {
+		usage, provider = usageErr.Usage, usageErr.Provider
+	}
+	for _, r := range results {
+		if r.analysis != nil && r.analysis.Usage != nil {
+			usage.Add(*r.analysis.Usage)
+		}
+	}
+	if usage.Requests == 0 {
+		return err
+	}
+	return &analyzer.UsageError{Err: err, Provider: provider, Usage: usage}
+}
+
+// documentPages gives access to the pages of a stored document file. Image files are a single page.
+type documentPages struct {
+	file  analyzer.File
+	doc   *pdf.Document
+	count int
+}
+
+func openDocumentPages(file analyzer.File) (*documentPages, error) {
+	if file.MimeType != ContentTypePDF {
+		return &documentPages{file: file, count: 1}, nil
+	}
+	doc, err := pdf.Open(file.Data)
+	if err != nil {
+		return nil, err
+	}
+	count, err := doc.NumPages()
+	if err != nil {
+		return nil, err
+	}
+	return &documentPages{file: file, doc: doc, count: count}, nil
+}
+
+// page returns page n (1-based) as a file of its own
+func (p *documentPages) page(n int) (analyzer.File, error) {
+	if p.doc == nil || p.count == 1 {
+		return p.file, nil
+	}
+	data, err := p.doc.ExtractPage(n - 1)
+	if err != nil {
+		return analyzer.File{}, err
+	}
+	return analyzer.File{Data: data, MimeType: ContentTypePDF}, nil
+}
+
+// pageResult is the outcome for one page before it is aggregated
+type pageResult struct {
+	page     int
+	status   entity.PageAnalysisStatus
+	photos   []int // indexes of the provided photos
+	analysis *entity.DocumentAnalysisResult
+}
+
+// aggregatePages combines the results of the pages of a document. The lowest page score and
+// confidence carry over, as one altered page makes the whole document suspect.
+func aggregatePages(results []pageResult, pageCount int) *entity.DocumentAnalysisResult {
+	result := &entity.DocumentAnalysisResult{
+		Score:       1,
+		IsAuthentic: true,
+		Confidence:  "high",
+		Pages:       make([]entity.PageAnalysis, 0, len(results)),
+	}
+
+	var compared, missing, extra []string
+	var summaries []string
+	for _, r := range results {
+		page := entity.PageAnalysis{Page: r.page, Status: r.status}
+		for _, i := range r.photos {
+			page.Photos = append(page.Photos, i+1)
+		}
+
+		switch r.status {
+		case entity.PageAnalysisMissing:
+			missing = append(missing, strconv.Itoa(r.page))
+		case entity.PageAnalysisExtra:
+			extra = append(extra, strconv.Itoa(r.page))
+		case entity.PageAnalysisCompared:
+			compared = append(compared, strconv.Itoa(r.page))
+			a := r.analysis
+			page.Score, page.IsAuthentic, page.Confidence, page.Summary = a.Score, a.IsAuthentic, a.Confidence, a.Summary
+
+			result.Score = min(result.Score, a.Score)
+			result.IsAuthentic = result.IsAuthentic && a.IsAuthentic
+			if confidenceRank[a.Confidence] < confidenceRank[result.Confidence] {
+				result.Confidence = a.Confidence
+			}
+			if result.Provider == "" {
+				result.Provider = a.Provider
+			}
+			if result.PromptVersion == "" {
+				result.PromptVersion = a.PromptVersion
+			}
+			result.Repaired = result.Repaired || a.Repaired
+			if a.Usage != nil {
+				if result.Usage == nil {
+					result.Usage = &entity.TokenUsage{}
+				}
+				result.Usage.Add(*a.Usage)
+			}
+
+			for _, d := range a.Differences {
+				d.Page = r.page
+				d.Location = strings.TrimSuffix(fmt.Sprintf("page %d, %s", r.page, d.Location), ", ")
+				result.Differences = append(result.Differences, d)
+			}
+			for _, f := range a.Findings {
+				f.Description = fmt.Sprintf("Page %d: %s", r.page, f.Description)
+				result.Findings = append(result.Findings, f)
+			}
+			summaries = append(summaries, fmt.Sprintf("Page %d: %s", r.page, a.Summary))
+		}
+		result.Pages = append(result.Pages, page)
+	}
+
+	if len(compared) == 0 {
+		result.Score, result.IsAuthentic, result.Confidence = 0, false, "low"
+	}
+	if len(missing) > 0 {
+		result.IsAuthentic = false
+		result.Findings = append(result.Findings, entity.AnalysisFinding{
+			Category:    "layout",
+			Description: fmt.Sprintf("No photo of page %s was provided, so the document could only be verified in part", strings.Join(missing, ", ")),
+			Severity:    "warning",
+		})
+	}
+	if len(extra) > 0 {
+		result.IsAuthentic = false
+		result.Findings = append(result.Findings, entity.AnalysisFinding{
+			Category:    "layout",
+			Description: fmt.Sprintf("Photos show page %s, but the original document has %d pages", strings.Join(extra, ", "), pageCount),
+			Severity:    "critical",
+		})
+	}
+
+	summary := fmt.Sprintf("Compared %d of %d pages.", len(compared), pageCount)
+	if len(missing) > 0 {
+		summary += fmt.Sprintf(" Missing pages: %s.", strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		summary += fmt.Sprintf(" Pages not in the original: %s.", strings.Join(extra, ", "))
+	}
+	result.Summary = strings.Join(append([]string{summary}, summaries...), " ")
+	return result
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/analyzer/analyzertest"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/pdf"
+	"github.com/tasklineby/certify-backend/repository/blob"
+)
+
+// newTestPagesService returns a service comparing with a stored two page document
+func newTestPagesService(t *testing.T, a analyzer.DocumentAnalyzer) (*documentService, *entity.Document) {
+	t.Helper()
+	store, err := blob.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore: %v", err)
+	}
+	file := testPDF("",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 595 842] >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+	)
+	info, err := store.Put(context.Background(), bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	doc := &entity.Document{ID: 1, FileKey: info.Key, ContentType: ContentTypePDF, State: entity.DocumentStateActive}
+	return &documentService{blobStore: store, analyzer: a}, doc
+}
+
+func testPhotos(n int) []analyzer.File {
+	photos := make([]analyzer.File, n)
+	for i := range photos {
+		photos[i] = analyzer.File{Data: []byte{0xff, 0xd8, 0xff, byte(i)}, MimeType: ContentTypeJPEG}
+	}
+	return photos
+}
+
+func TestAnalyzePages(t *testing.T) {
+	a := analyzertest.NewMatching()
+	s, doc := newTestPagesService(t, a)
+	prompt := analyzer.Prompt{Text: "Compare the documents", Version: "v1"}
+	templateID := 7
+
+	// Two photos of page 1, none of page 2 and one of a page the document does not have
+	result, err := s.analyzePages(context.Background(), doc, testPhotos(3), []int{1, 1, 3}, nil, prompt, &templateID)
+	if err != nil {
+		t.Fatalf("analyzePages: %v", err)
+	}
+
+	if len(a.Calls) != 1 {
+		t.Fatalf("analyzer called %d times, want once for page 1", len(a.Calls))
+	}
+	call := a.Calls[0]
+	if call.Prompt != prompt || len(call.Provided) != 2 {
+		t.Errorf("Compare called with prompt %+v and %d photos, want %+v and 2 photos", call.Prompt, len(call.Provided), prompt)
+	}
+	page, err := pdf.Open(call.Original.Data)
+	if err != nil {
+		t.Fatalf("opening the compared page: %v", err)
+	}
+	if n, err := page.NumPages(); err != nil || n != 1 {
+		t.Errorf("compared page has %d pages, %v; want the page on its own", n, err)
+	}
+
+	wantPages := []entity.PageAnalysis{
+		{Page: 1, Status: entity.PageAnalysisCompared, Photos: []int{1, 2}, Score: 0.98, IsAuthentic: true, Confidence: "high", Summary: a.Result.Summary},
+		{Page: 2, Status: entity.PageAnalysisMissing},
+		{Page: 3, Status: entity.PageAnalysisExtra, Photos: []int{3}},
+	}
+	if !reflect.DeepEqual(result.Pages, wantPages) {
+		t.Errorf("Pages = %+v, want %+v", result.Pages, wantPages)
+	}
+	if result.IsAuthentic || result.Score != 0.98 || result.Provider != "test" || result.PromptVersion != "v1" || result.Prompt != prompt.Text || result.PromptTemplateID != &templateID {
+		t.Errorf("analyzePages = %+v, want a partial, unconfirmed match of the test provider and prompt", result)
+	}
+
+	// Photos of every page confirm the document
+	a.Calls = nil
+	result, err = s.analyzePages(context.Background(), doc, testPhotos(2), []int{2, 1}, nil, prompt, nil)
+	if err != nil {
+		t.Fatalf("analyzePages: %v", err)
+	}
+	if len(a.Calls) != 2 || !result.IsAuthentic || result.Confidence != "high" {
+		t.Errorf("analyzePages = %+v after %d calls, want an authentic match after 2", result, len(a.Calls))
+	}
+}
+
+func TestAnalyzePagesError(t *testing.T) {
+	tests := []struct {
+		name     string
+		analyzer *analyzertest.Analyzer
+		want     errs.ErrorType
+	}{
+		{"rate limited", &analyzertest.Analyzer{Err: analyzer.ErrProviderRateLimited}, errs.ErrorTypeRateLimited},
+		{"unavailable", &analyzertest.Analyzer{Err: analyzer.ErrProviderUnavailable}, errs.ErrorTypeUnavailable},
+		{"invalid response", &analyzertest.Analyzer{Err: analyzer.ErrInvalidResponse}, errs.ErrorTypeUnavailable},
+		{"no result", &analyzertest.Analyzer{}, errs.ErrorTypeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, doc := newTestPagesService(t, tt.analyzer)
+			_, err := s.analyzePages(context.Background(), doc, testPhotos(2), []int{1, 2}, nil, analyzer.Prompt{}, nil)
+			if err == nil {
+				t.Fatal("analyzePages succeeded, want an error")
+			}
+			if got := errs.ErrorCast(err).Type; got != tt.want {
+				t.Errorf("analyzePages error = %v, want %s", err, tt.want)
+			}
+			// The comparison stops at the first failed page
+			if len(tt.analyzer.Calls) != 1 {
+				t.Errorf("analyzer called %d times, want once", len(tt.analyzer.Calls))
+			}
+		})
+	}
+}
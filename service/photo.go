@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/imaging"
+)
+
+// PhotoPreprocessor prepares photos of documents before they are stored and sent for comparison
+type PhotoPreprocessor interface {
+	// Prepare turns every photo upright, scales it down and strips its metadata. A photo that cannot
+	// be compared, e.g. because it is blurry or too dark, is reported with a validation error asking
+	// to retake it.
+	Prepare(photos [][]byte) ([]analyzer.File, error)
+}
+
+type photoPreprocessor struct {
+	opts imaging.Options
+}
+
+func NewPhotoPreprocessor(opts imaging.Options) PhotoPreprocessor {
+	return &photoPreprocessor{opts: opts}
+}
+
+func (p *photoPreprocessor) Prepare(photos [][]byte) ([]analyzer.File, error) {
+	files := make([]analyzer.File, len(photos))
+	for i, data := range photos {
+		photo, err := imaging.Process(data, p.opts)
+		if err != nil {
+			slog.Warn("photo rejected", "err", err, "photo", i+1)
+			return nil, photoError(i+1, err)
+		}
+		slog.Info("photo prepared", "photo", i+1, "width", photo.Width, "height", photo.Height,
+			"orientation", photo.Orientation, "downscaled", photo.Downscaled, "gps_stripped", photo.GPSStripped,
+			"brightness", photo.Quality.Brightness, "sharpness", photo.Quality.Sharpness)
+		files[i] = analyzer.File{Data: photo.Data, MimeType: photo.MimeType}
+	}
+	return files, nil
+}
+
+// photoError tells the client why photo number n was rejected and how to take a usable one
+func photoError(n int, err error) error {
+	switch {
+	case errors.Is(err, imaging.ErrHEIF):
+		return errs.ValidationError(fmt.Sprintf(`photo %d is a HEIC or AVIF image, which is not supported; convert it to JPEG or set the camera to capture JPEG ("Most Compatible") and retake the photo`, n), err)
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return errs.ValidationError(fmt.Sprintf("photo %d is not a JPEG, PNG, GIF or WEBP image", n), err)
+	case errors.Is(err, imaging.ErrInvalidImage):
+		return errs.ValidationError(fmt.Sprintf("photo %d is not a valid image", n), err)
+	case errors.Is(err, imaging.ErrTooLarge):
+		return errs.ValidationError(fmt.Sprintf("photo %d exceeds %d megapixels", n, imaging.MaxPixels/1_000_000), err)
+	case errors.Is(err, imaging.ErrTooDark):
+		return errs.ValidationError(fmt.Sprintf("photo %d is too dark, retake the photo in better light", n), err)
+	case errors.Is(err, imaging.ErrTooBlurry):
+		return errs.ValidationError(fmt.Sprintf("photo %d is too blurry, retake the photo holding the camera steady and in focus", n), err)
+	}
+	return errs.InternalError("error processing photo", err)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/pg"
+)
+
+// PromptService manages the versioned comparison prompt templates of companies and picks the one
+// used for a document
+type PromptService interface {
+	CreatePromptTemplate(ctx context.Context, req entity.CreatePromptTemplateRequest, requesterRole string, requesterCompanyID, userID int) (*entity.PromptTemplate, error)
+	GetPromptTemplates(ctx context.Context, requesterCompanyID int) ([]entity.PromptTemplate, error)
+	GetPromptTemplate(ctx context.Context, id, requesterCompanyID int) (*entity.PromptTemplate, error)
+	// ResolvePrompt renders the prompt for comparing doc and returns the ID of the template it was
+	// rendered from, nil for the built-in prompt. fields are the fields recorded for doc.
+	ResolvePrompt(ctx context.Context, doc *entity.Document, fields []entity.DocumentFieldValue) (analyzer.Prompt, *int, error)
+}
+
+type promptService struct {
+	promptRepo pg.PromptTemplateRepository
+}
+
+func NewPromptService(promptRepo pg.PromptTemplateRepository) PromptService {
+	return &promptService{promptRepo: promptRepo}
+}
+
+// CreatePromptTemplate adds a new version of the company's template for the requested document type.
+// Only admins can change prompts. Earlier versions are kept so past analyses stay reproducible.
+func (s *promptService) CreatePromptTemplate(ctx context.Context, req entity.CreatePromptTemplateRequest, requesterRole string, requesterCompanyID, userID int) (*entity.PromptTemplate, error) {
+	if requesterRole != "admin" {
+		return nil, errs.UnauthorizedError("only admins can change prompt templates", nil)
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, errs.ValidationError("body must not be empty", nil)
+	}
+	if err := analyzer.ParsePromptTemplate(req.Body); err != nil {
+		return nil, errs.ValidationError(err.Error(), err)
+	}
+
+	expectedFields := make([]string, 0, len(req.ExpectedFields))
+	for _, field := range req.ExpectedFields {
+		if field = strings.TrimSpace(field); field != "" {
+			expectedFields = append(expectedFields, field)
+		}
+	}
+	expectedFieldsJSON, err := json.Marshal(expectedFields)
+	if err != nil {
+		return nil, errs.InternalError("error encoding expected fields", err)
+	}
+
+	template := &entity.PromptTemplate{
+		CompanyID:      &requesterCompanyID,
+		DocumentType:   strings.TrimSpace(req.DocumentType),
+		Body:           req.Body,
+		ExpectedFields: expectedFieldsJSON,
+		CreatedBy:      &userID,
+	}
+	if err := s.promptRepo.CreatePromptTemplate(ctx, template); err != nil {
+		if isUniqueConstraintError(err) {
+			// Another version was added at the same time
+			return nil, errs.AlreadyExistsError("prompt template version", err)
+		}
+		return nil, errs.InternalError("error creating prompt template", err)
+	}
+	return template, nil
+}
+
+// GetPromptTemplates lists every version of the company's templates and of the global templates
+func (s *promptService) GetPromptTemplates(ctx context.Context, requesterCompanyID int) ([]entity.PromptTemplate, error) {
+	templates, err := s.promptRepo.GetPromptTemplatesByCompanyID(ctx, requesterCompanyID)
+	if err != nil {
+		return nil, errs.InternalError("error getting prompt templates", err)
+	}
+	if templates == nil {
+		templates = []entity.PromptTemplate{}
+	}
+	return templates, nil
+}
+
+// GetPromptTemplate returns a template of the requester's company or a global one
+func (s *promptService) GetPromptTemplate(ctx context.Context, id, requesterCompanyID int) (*entity.PromptTemplate, error) {
+	template, err := s.promptRepo.GetPromptTemplateByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFoundError("prompt template", err)
+		}
+		return nil, errs.InternalError("error getting prompt template", err)
+	}
+	if template.CompanyID != nil && *template.CompanyID != requesterCompanyID {
+		return nil, errs.NotFoundError("prompt template", nil)
+	}
+	return &template, nil
+}
+
+func (s *promptService) ResolvePrompt(ctx context.Context, doc *entity.Document, fields []entity.DocumentFieldValue) (analyzer.Prompt, *int, error) {
+	vars := analyzer.PromptVars{
+		DocumentType:    doc.Type,
+		DocumentName:    doc.Name,
+		DocumentSummary: doc.Summary,
+		Fields:          fields,
+	}
+
+	template, err := s.promptRepo.ResolvePromptTemplate(ctx, doc.CompanyID, doc.Type)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return analyzer.DefaultPrompt(vars), nil, nil
+		}
+		return analyzer.Prompt{}, nil, errs.InternalError("error resolving prompt template", err)
+	}
+
+	if err := json.Unmarshal(template.ExpectedFields, &vars.ExpectedFields); err != nil {
+		return analyzer.Prompt{}, nil, errs.InternalError("error decoding expected fields", err)
+	}
+	text, err := analyzer.RenderPrompt(template.Body, vars)
+	if err != nil {
+		return analyzer.Prompt{}, nil, errs.InternalError("error rendering prompt template", err)
+	}
+	return analyzer.Prompt{Text: text, Version: strconv.Itoa(template.Version)}, &template.ID, nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/pdf"
+)
+
+const (
+	QRCodeFormatPNG = "png"
+	QRCodeFormatSVG = "svg"
+
+	// QR code image size limits in pixels
+	defaultQRCodeSize = 256
+	minQRCodeSize     = 64
+	maxQRCodeSize     = 2048
+
+	// QR code stamp size limits in PDF points (1/72 inch)
+	defaultStampSize = 96
+	minStampSize     = 48
+	maxStampSize     = 288
+	stampMargin      = 24
+
+	StampPageFirst = "first"
+	StampPageLast  = "last"
+)
+
+// newQRCode encodes content with the requested error correction level (L, M, Q or H)
+func newQRCode(content, errorCorrection string) (*qrcode.QRCode, error) {
+	var level qrcode.RecoveryLevel
+	switch strings.ToUpper(errorCorrection) {
+	case "L":
+		level = qrcode.Low
+	case "", "M":
+		level = qrcode.Medium
+	case "Q":
+		level = qrcode.High
+	case "H":
+		level = qrcode.Highest
+	default:
+		return nil, errs.ValidationError("error correction must be one of L, M, Q, H", nil)
+	}
+
+	q, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, errs.InternalError("error encoding QR code", err)
+	}
+	return q, nil
+}
+
+// renderQRCode renders content as a PNG or SVG image and returns it with its content type
+func renderQRCode(content string, opts entity.QRCodeOptions) ([]byte, string, error) {
+	size := opts.Size
+	if size == 0 {
+		size = defaultQRCodeSize
+	}
+	if size < minQRCodeSize || size > maxQRCodeSize {
+		return nil, "", errs.ValidationError(fmt.Sprintf("size must be between %d and %d", minQRCodeSize, maxQRCodeSize), nil)
+	}
+
+	q, err := newQRCode(content, opts.ErrorCorrection)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch strings.ToLower(opts.Format) {
+	case "", QRCodeFormatPNG:
+		data, err := q.PNG(size)
+		if err != nil {
+			return nil, "", errs.InternalError("error rendering QR code", err)
+		}
+		return data, "image/png", nil
+	case QRCodeFormatSVG:
+		return renderQRCodeSVG(q.Bitmap(), size), "image/svg+xml", nil
+	default:
+		return nil, "", errs.ValidationError("format must be png or svg", nil)
+	}
+}
+
+// renderQRCodeSVG draws the module bitmap as a single SVG path, one unit per module
+func renderQRCodeSVG(bitmap [][]bool, size int) []byte {
+	n := len(bitmap)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", size, size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", n, n)
+	buf.WriteString(`<path fill="#000000" d="`)
+	forEachModuleRun(bitmap, func(x, y, width int) {
+		fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x, y, width, width)
+	})
+	buf.WriteString(`"/>` + "\n</svg>\n")
+	return buf.Bytes()
+}
+
+// qrStampContent builds a PDF content stream drawing the QR code in the bottom right corner of box
+func qrStampContent(bitmap [][]bool, box pdf.Rectangle, size float64) []byte {
+	n := len(bitmap)
+	module := size / float64(n)
+	x0 := box.URX - stampMargin - size
+	y0 := box.LLY + stampMargin
+
+	var buf bytes.Buffer
+	buf.WriteString("q\n1 g\n")
+	fmt.Fprintf(&buf, "%s %s %s %s re f\n0 g\n", pdfNumber(x0), pdfNumber(y0), pdfNumber(size), pdfNumber(size))
+	forEachModuleRun(bitmap, func(x, y, width int) {
+		// PDF user space grows upwards, bitmap rows grow downwards
+		fmt.Fprintf(&buf, "%s %s %s %s re\n",
+			pdfNumber(x0+float64(x)*module),
+			pdfNumber(y0+float64(n-1-y)*module),
+			pdfNumber(float64(width)*module),
+			pdfNumber(module))
+	})
+	buf.WriteString("f\nQ\n")
+	return buf.Bytes()
+}
+
+// forEachModuleRun calls fn for every horizontal run of dark modules
+func forEachModuleRun(bitmap [][]bool, fn func(x, y, width int)) {
+	for y, row := range bitmap {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fn(start, y, x-start)
+		}
+	}
+}
+
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
+
+// stampQRCode draws a QR code encoding content onto the first or last page of a PDF
+func stampQRCode(fileData []byte, content string, opts entity.CertifiedCopyOptions) ([]byte, error) {
+	size := opts.Size
+	if size == 0 {
+		size = defaultStampSize
+	}
+	if size < minStampSize || size > maxStampSize {
+		return nil, errs.ValidationError(fmt.Sprintf("size must be between %d and %d points", minStampSize, maxStampSize), nil)
+	}
+
+	page := strings.ToLower(opts.Page)
+	if page == "" {
+		page = StampPageLast
+	}
+	if page != StampPageFirst && page != StampPageLast {
+		return nil, errs.ValidationError("page must be first or last", nil)
+	}
+
+	q, err := newQRCode(content, opts.ErrorCorrection)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := pdf.Open(fileData)
+	if err != nil {
+		return nil, errs.ValidationError("stored document file is not a valid PDF", err)
+	}
+	if doc.IsEncrypted() {
+		return nil, errs.ValidationError("cannot stamp an encrypted PDF", nil)
+	}
+
+	pages, err := doc.Pages()
+	if err != nil || len(pages) == 0 {
+		return nil, errs.ValidationError("stored document file has no readable pages", err)
+	}
+
+	index := 0
+	if page == StampPageLast {
+		index = len(pages) - 1
+	}
+
+	stamp := qrStampContent(q.Bitmap(), pages[index].CropBox, float64(size))
+	stamped, err := doc.OverlayPage(index, stamp)
+	if err != nil {
+		return nil, errs.InternalError("error stamping QR code", err)
+	}
+	return stamped, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+// scanFile rejects a file flagged by the malware scanner; what names the file in the error message.
+// Files that cannot be scanned are rejected too.
+func (s *documentService) scanFile(ctx context.Context, r io.Reader, what string) error {
+	result, err := s.fileScanner.Scan(ctx, r)
+	if err != nil {
+		slog.Error("error scanning file", "err", err)
+		return errs.InternalError("error scanning file", err)
+	}
+	if !result.Clean {
+		slog.Warn("file rejected by scanner", "signature", result.Signature)
+		return errs.FileRejectedError(fmt.Sprintf("%s rejected by scanner: %s", what, result.Signature), nil)
+	}
+	return nil
+}
+
+// scanUpload scans a validated upload and rewinds it to be stored
+func (s *documentService) scanUpload(ctx context.Context, upload *UploadedFile) error {
+	if err := s.scanFile(ctx, upload, "file"); err != nil {
+		return err
+	}
+	if _, err := upload.Seek(0, io.SeekStart); err != nil {
+		return errs.InternalError("error reading uploaded file", err)
+	}
+	return nil
+}
+
+// ScanDocument rescans the stored file of a document, e.g. after the scanner signatures were updated.
+// A flagged active document is quarantined; a quarantined document whose file is now clean is released.
+// Only admins can rescan documents.
+func (s *documentService) ScanDocument(ctx context.Context, id int, requesterRole string, requesterCompanyID, userID int) (*entity.DocumentScanResponse, error) {
+	if requesterRole != "admin" {
+		return nil, errs.UnauthorizedError("only admins can scan documents", nil)
+	}
+
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Opened directly, quarantined files are not served through openDocumentFile
+	file, err := s.openFile(ctx, doc.FileKey, func(ctx context.Context) ([]byte, error) {
+		return s.documentRepo.GetLegacyFileData(ctx, doc.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result, err := s.fileScanner.Scan(ctx, file)
+	if err != nil {
+		slog.Error("error scanning document file", "err", err, "document_id", id)
+		return nil, errs.InternalError("error scanning document file", err)
+	}
+
+	response := &entity.DocumentScanResponse{
+		DocumentID: id,
+		Clean:      result.Clean,
+		Signature:  result.Signature,
+		State:      doc.State,
+	}
+
+	switch {
+	case !result.Clean && doc.State == entity.DocumentStateActive:
+		slog.Warn("document file flagged by scanner, quarantining", "document_id", id, "signature", result.Signature)
+		event, err := newDocumentEvent(id, userID, entity.DocumentEventQuarantined, map[string]any{"signature": result.Signature})
+		if err != nil {
+			return nil, err
+		}
+		if err := s.documentRepo.SetQuarantine(ctx, id, true, event); err != nil {
+			return nil, lifecycleError(err, "error quarantining document")
+		}
+		response.State = entity.DocumentStateQuarantined
+	case !result.Clean:
+		slog.Warn("document file flagged by scanner", "document_id", id, "state", doc.State, "signature", result.Signature)
+	case doc.State == entity.DocumentStateQuarantined:
+		event, err := newDocumentEvent(id, userID, entity.DocumentEventReleased, map[string]any{})
+		if err != nil {
+			return nil, err
+		}
+		if err := s.documentRepo.SetQuarantine(ctx, id, false, event); err != nil {
+			return nil, lifecycleError(err, "error releasing document from quarantine")
+		}
+		response.State = entity.DocumentStateActive
+	}
+
+	return response, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/scanner"
+)
+
+type stubScanner struct {
+	result scanner.Result
+	err    error
+}
+
+func (s stubScanner) Scan(ctx context.Context, r io.Reader) (scanner.Result, error) {
+	io.Copy(io.Discard, r)
+	return s.result, s.err
+}
+
+func TestScanFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		scanner stubScanner
+		want    errs.ErrorType
+	}{
+		{"clean", stubScanner{result: scanner.Result{Clean: true}}, ""},
+		{"infected", stubScanner{result: scanner.Result{Signature: "Eicar-Test-Signature"}}, errs.ErrorTypeFileRejected},
+		{"scanner unavailable", stubScanner{err: errors.New("connecting to clamd: connection refused")}, errs.ErrorTypeInternal},
+		// A failed scan rejects the file even if the scanner also reported it clean
+		{"scanner error", stubScanner{result: scanner.Result{Clean: true}, err: errors.New("clamd: size limit exceeded")}, errs.ErrorTypeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &documentService{fileScanner: tt.scanner}
+			err := s.scanFile(context.Background(), strings.NewReader("%PDF-1.7"), "file")
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("scanFile: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("scanFile accepted the file, want %s", tt.want)
+			}
+			if got := errs.ErrorCast(err).Type; got != tt.want {
+				t.Errorf("scanFile error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
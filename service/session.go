@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/rdb"
+)
+
+// SessionService keeps the login sessions behind refresh tokens. Clients get the refresh token
+// itself, the server only stores its hash. Refresh tokens are rotated on every use and carry the ID
+// of their session as family ID; reusing a rotated token ends the session, as one of its tokens
+// must have been stolen. Access tokens name their session, so that ending a session revokes them
+// too.
+type SessionService interface {
+	CreateSession(ctx context.Context, payload entity.TokenPayload, client entity.SessionClient) (entity.TokenPair, error)
+	RefreshSession(ctx context.Context, refreshToken string, client entity.SessionClient) (entity.TokenPair, error)
+	DeleteSession(ctx context.Context, refreshToken string, client entity.SessionClient) error
+	GetUserSessions(ctx context.Context, userID int, currentSessionID string) ([]entity.SessionInfo, error)
+	RevokeSession(ctx context.Context, userID int, sessionID string) error
+	RevokeUserSessions(ctx context.Context, userID int) (int, error)
+}
+
+type sessionService struct {
+	sessionRepo    rdb.SessionRepository
+	tokenRepo      rdb.TokenRepository
+	jwtService     JwtService
+	accessTokenTTL time.Duration
+}
+
+func NewSessionService(sessionRepo rdb.SessionRepository, tokenRepo rdb.TokenRepository, jwtService JwtService, accessTokenTTL time.Duration) SessionService {
+	return &sessionService{
+		sessionRepo:    sessionRepo,
+		tokenRepo:      tokenRepo,
+		jwtService:     jwtService,
+		accessTokenTTL: accessTokenTTL,
+	}
+}
+
+// CreateSession starts a session for a user who just logged in or registered
+func (s *sessionService) CreateSession(ctx context.Context, payload entity.TokenPayload, client entity.SessionClient) (entity.TokenPair, error) {
+	id, err := newSessionID()
+	if err != nil {
+		slog.Error("error generating session id", "err", err)
+		return entity.TokenPair{}, errs.InternalError("error generating session id", err)
+	}
+	payload.SessionID = id
+	now := time.Now().UTC()
+	session := entity.Session{
+		ID:         id,
+		Payload:    payload,
+		Device:     client.Device,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	tokens, err := s.issueTokens(ctx, &session)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return entity.TokenPair{}, err
+	}
+	return tokens, nil
+}
+
+// RefreshSession exchanges a refresh token for a new token pair of the same session. The token can
+// be used only once.
+func (s *sessionService) RefreshSession(ctx context.Context, refreshToken string, client entity.SessionClient) (entity.TokenPair, error) {
+	session, err := s.takeSession(ctx, refreshToken, client)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+	taken := session
+	session.LastUsedAt = time.Now().UTC()
+	tokens, err := s.issueTokens(ctx, &session)
+	if err != nil {
+		s.restoreSession(ctx, taken)
+		return entity.TokenPair{}, err
+	}
+	if err := s.sessionRepo.UpdateSession(ctx, session); err != nil {
+		if errs.ErrorCast(err).Type == errs.ErrorTypeNotFound {
+			return entity.TokenPair{}, errs.TokenExpiredError("session has ended", err)
+		}
+		s.restoreSession(ctx, taken)
+		return entity.TokenPair{}, err
+	}
+	return tokens, nil
+}
+
+// restoreSession stores a taken session back with the refresh token it was taken by, so that a
+// refresh failing after the token was used up can be retried with it. Sessions ended meanwhile stay
+// ended.
+func (s *sessionService) restoreSession(ctx context.Context, session entity.Session) {
+	if err := s.sessionRepo.UpdateSession(ctx, session); err != nil && errs.ErrorCast(err).Type != errs.ErrorTypeNotFound {
+		slog.Error("error restoring refresh token", "err", err, "session_id", session.ID)
+	}
+}
+
+// takeSession returns the session of a refresh token and uses the token up
+func (s *sessionService) takeSession(ctx context.Context, refreshToken string, client entity.SessionClient) (entity.Session, error) {
+	familyID := refreshTokenFamily(refreshToken)
+	if familyID == "" {
+		return entity.Session{}, errs.TokenExpiredError("refresh token is invalid or has expired", nil)
+	}
+
+	session, err := s.sessionRepo.TakeSession(ctx, familyID, SHA256Hex(refreshToken))
+	if err != nil {
+		switch errs.ErrorCast(err).Type {
+		case errs.ErrorTypeNotFound:
+			return entity.Session{}, errs.TokenExpiredError("refresh token is invalid or has expired", err)
+		case errs.ErrorTypeTokenReused:
+			s.endReusedSession(ctx, familyID, client)
+			return entity.Session{}, errs.TokenReusedError("refresh token was already used, the session has been ended", err)
+		}
+		return entity.Session{}, err
+	}
+	return session, nil
+}
+
+// endReusedSession ends the session of a reused refresh token. Either the client that reused it or
+// the one holding the current token is not the user, so neither may stay logged in.
+func (s *sessionService) endReusedSession(ctx context.Context, id string, client entity.SessionClient) {
+	session, err := s.sessionRepo.GetSession(ctx, id)
+	if err != nil {
+		slog.Warn("security event: refresh token reused", "event", "refresh_token_reuse", "session_id", id,
+			"ip", client.IP, "user_agent", client.UserAgent, "err", err)
+		return
+	}
+	slog.Warn("security event: refresh token reused, session ended", "event", "refresh_token_reuse", "session_id", id,
+		"user_id", session.Payload.UserID, "ip", client.IP, "user_agent", client.UserAgent,
+		"session_ip", session.IP, "session_user_agent", session.UserAgent)
+	if err := s.endSession(ctx, session); err != nil {
+		slog.Error("error ending session of reused refresh token", "err", err, "session_id", id)
+	}
+}
+
+// issueTokens gives a session a new refresh token, extending it by the refresh token TTL
+func (s *sessionService) issueTokens(ctx context.Context, session *entity.Session) (entity.TokenPair, error) {
+	accessToken, err := s.jwtService.GenerateAccessToken(ctx, session.Payload)
+	if err != nil {
+		slog.Error("error generating access token", "err", err)
+		return entity.TokenPair{}, errs.InternalError("error generating access token", err)
+	}
+
+	refreshToken, err := s.jwtService.GenerateRefreshToken(ctx, session.ID)
+	if err != nil {
+		slog.Error("error generating refresh token", "err", err)
+		return entity.TokenPair{}, errs.InternalError("error generating refresh token", err)
+	}
+	session.TokenHash = refreshToken.Hash
+	session.ExpiresAt = session.LastUsedAt.Add(refreshToken.ExpiresIn)
+
+	return entity.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken.Token,
+	}, nil
+}
+
+// DeleteSession ends the session of a refresh token. Unknown or expired tokens are ignored, reused
+// ones end their session all the same.
+func (s *sessionService) DeleteSession(ctx context.Context, refreshToken string, client entity.SessionClient) error {
+	session, err := s.takeSession(ctx, refreshToken, client)
+	if err != nil {
+		switch errs.ErrorCast(err).Type {
+		case errs.ErrorTypeTokenExpired, errs.ErrorTypeTokenReused:
+			return nil
+		}
+		return err
+	}
+	return s.endSession(ctx, session)
+}
+
+// GetUserSessions lists the active sessions of a user, most recently used first
+func (s *sessionService) GetUserSessions(ctx context.Context, userID int, currentSessionID string) ([]entity.SessionInfo, error) {
+	sessions, err := s.sessionRepo.GetUserSessions(ctx, strconv.Itoa(userID))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(sessions, func(a, b entity.Session) int { return b.LastUsedAt.Compare(a.LastUsedAt) })
+
+	infos := make([]entity.SessionInfo, len(sessions))
+	for i, session := range sessions {
+		infos[i] = entity.SessionInfo{
+			ID:         session.ID,
+			Device:     session.Device,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == currentSessionID,
+		}
+	}
+	return infos, nil
+}
+
+// RevokeSession ends a session of a user
+func (s *sessionService) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	session, err := s.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Payload.UserID != strconv.Itoa(userID) {
+		return errs.NotFoundError("session", nil)
+	}
+	return s.endSession(ctx, session)
+}
+
+// RevokeUserSessions ends all sessions of a user, e.g. when the user is deleted or their role or
+// password changes, and returns how many there were
+func (s *sessionService) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	sessions, err := s.sessionRepo.GetUserSessions(ctx, strconv.Itoa(userID))
+	if err != nil {
+		return 0, err
+	}
+	for _, session := range sessions {
+		if err := s.endSession(ctx, session); err != nil {
+			return 0, err
+		}
+	}
+	slog.Info("user sessions revoked", "user_id", userID, "sessions", len(sessions))
+	return len(sessions), nil
+}
+
+// endSession deletes a session and revokes the access tokens issued for it
+func (s *sessionService) endSession(ctx context.Context, session entity.Session) error {
+	if err := s.tokenRepo.BlacklistSession(ctx, session.ID, s.accessTokenTTL); err != nil {
+		return err
+	}
+	return s.sessionRepo.DeleteSession(ctx, session)
+}
+
+// newSessionID returns a random, unguessable session ID
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
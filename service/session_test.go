@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/rdb"
+)
+
+const testAccessTokenTTL = 15 * time.Minute
+
+var testClient = entity.SessionClient{Device: "laptop", IP: "192.0.2.1", UserAgent: "test"}
+
+func newTestSessionService(t *testing.T) (SessionService, JwtService, rdb.TokenRepository, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	jwtService := NewJwtService("test-secret", testAccessTokenTTL, 24*time.Hour)
+	tokenRepo := rdb.NewTokenRepository(client)
+	sessions := NewSessionService(rdb.NewSessionRepository(client), tokenRepo, jwtService, testAccessTokenTTL)
+	return sessions, jwtService, tokenRepo, mr
+}
+
+func TestCreateSession(t *testing.T) {
+	ctx := context.Background()
+	sessions, jwtService, _, mr := newTestSessionService(t)
+
+	tokens, err := sessions.CreateSession(ctx, entity.TokenPayload{UserID: "7", Role: "admin", CompanyID: "3"}, testClient)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	payload, _, err := jwtService.ParseAccessToken(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if payload.UserID != "7" || payload.SessionID == "" {
+		t.Errorf("access token payload = %+v, want user 7 with a session", payload)
+	}
+	if family := refreshTokenFamily(tokens.RefreshToken); family != payload.SessionID {
+		t.Errorf("refresh token family = %q, want session %q", family, payload.SessionID)
+	}
+	if !mr.Exists("refresh:" + SHA256Hex(tokens.RefreshToken)) {
+		t.Error("refresh token is not stored by its hash")
+	}
+	if data, _ := mr.Get("session:" + payload.SessionID); data == "" || strings.Contains(data, tokens.RefreshToken) {
+		t.Error("session is missing or stores the raw refresh token")
+	}
+
+	infos, err := sessions.GetUserSessions(ctx, 7, payload.SessionID)
+	if err != nil {
+		t.Fatalf("GetUserSessions: %v", err)
+	}
+	if len(infos) != 1 || !infos[0].Current || infos[0].Device != "laptop" || infos[0].IP != "192.0.2.1" {
+		t.Errorf("GetUserSessions = %+v, want the current session", infos)
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	ctx := context.Background()
+	sessions, jwtService, _, _ := newTestSessionService(t)
+	first, err := sessions.CreateSession(ctx, entity.TokenPayload{UserID: "7", Role: "admin", CompanyID: "3"}, testClient)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	second, err := sessions.RefreshSession(ctx, first.RefreshToken, testClient)
+	if err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if refreshTokenFamily(second.RefreshToken) != refreshTokenFamily(first.RefreshToken) {
+		t.Error("rotated refresh token left its family")
+	}
+
+	payload, _, err := jwtService.ParseAccessToken(ctx, second.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if payload.SessionID != refreshTokenFamily(first.RefreshToken) {
+		t.Errorf("refreshed access token names session %q, want %q", payload.SessionID, refreshTokenFamily(first.RefreshToken))
+	}
+
+	if _, err := sessions.RefreshSession(ctx, second.RefreshToken, testClient); err != nil {
+		t.Fatalf("RefreshSession with rotated token: %v", err)
+	}
+}
+
+// flakySessionRepository fails the first updates of sessions
+type flakySessionRepository struct {
+	rdb.SessionRepository
+	updateFailures int
+}
+
+func (r *flakySessionRepository) UpdateSession(ctx context.Context, session entity.Session) error {
+	if r.updateFailures > 0 {
+		r.updateFailures--
+		return errs.InternalError("error saving session", nil)
+	}
+	return r.SessionRepository.UpdateSession(ctx, session)
+}
+
+func TestRefreshSessionUpdateFailure(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := &flakySessionRepository{SessionRepository: rdb.NewSessionRepository(client)}
+	sessions := NewSessionService(repo, rdb.NewTokenRepository(client), NewJwtService("test-secret", testAccessTokenTTL, 24*time.Hour), testAccessTokenTTL)
+
+	tokens, err := sessions.CreateSession(ctx, entity.TokenPayload{UserID: "7", Role: "admin", CompanyID: "3"}, testClient)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	repo.updateFailures = 1
+	_, err = sessions.RefreshSession(ctx, tokens.RefreshToken, testClient)
+	if got := errs.ErrorCast(err).Type; got != errs.ErrorTypeInternal {
+		t.Fatalf("RefreshSession with a failing update: got %v, want %s", err, errs.ErrorTypeInternal)
+	}
+	if !mr.Exists("refresh:" + SHA256Hex(tokens.RefreshToken)) {
+		t.Error("refresh token was not restored after the failed refresh")
+	}
+
+	// The client retries with the same token
+	second, err := sessions.RefreshSession(ctx, tokens.RefreshToken, testClient)
+	if err != nil {
+		t.Fatalf("RefreshSession retry: %v", err)
+	}
+	if _, err := sessions.RefreshSession(ctx, second.RefreshToken, testClient); err != nil {
+		t.Fatalf("RefreshSession with rotated token: %v", err)
+	}
+	_, err = sessions.RefreshSession(ctx, tokens.RefreshToken, testClient)
+	if got := errs.ErrorCast(err).Type; got != errs.ErrorTypeTokenReused {
+		t.Fatalf("RefreshSession with restored token after rotation: got %v, want %s", err, errs.ErrorTypeTokenReused)
+	}
+}
+
+func TestRefreshExpiredSession(t *testing.T) {
+	ctx := context.Background()
+	sessions, _, _, mr := newTestSessionService(t)
+	tokens, err := sessions.CreateSession(ctx, entity.TokenPayload{UserID: "7", Role: "admin", CompanyID: "3"}, testClient)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	mr.FastForward(25 * time.Hour)
+
+	_, err = sessions.RefreshSession(ctx, tokens.RefreshToken, testClient)
+	if got := errs.ErrorCast(err).Type; got != errs.ErrorTypeTokenExpired {
+		t.Fatalf("RefreshSession after expiry: got %v, want %s", err, errs.ErrorTypeTokenExpired)
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	ctx := context.Background()
+	sessions, jwtService, tokenRepo, _ := newTestSessionService(t)
+	tokens, err := sessions.CreateSession(ctx, entity.TokenPayload{UserID: "7", Role: "admin", CompanyID: "3"}, testClient)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if err := sessions.DeleteSession(ctx, tokens.RefreshToken, testClient); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+
+	_, err = sessions.RefreshSession(ctx, tokens.RefreshToken, testClient)
+	if got := errs.ErrorCast(err).Type; err == nil || got == errs.ErrorTypeInternal {
+		t.Fatalf("RefreshSession after logout: got %v, want the token rejected", err)
+	}
+	assertAccessTokenRevoked(t, jwtService, tokenRepo, tokens.AccessToken)
+
+	// Logging out again is not an error
+	if err := sessions.DeleteSession(ctx, tokens.RefreshToken, testClient); err != nil {
+		t.Errorf("DeleteSession again: %v", err)
+	}
+}
+
+func assertAccessTokenRevoked(t *testing.T, jwtService JwtService, tokenRepo rdb.TokenRepository, accessToken string) {
+	t.Helper()
+	ctx := context.Background()
+	payload, _, err := jwtService.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	blacklisted, err := tokenRepo.IsAccessTokenBlacklisted(ctx, SHA256Hex(accessToken), payload.SessionID)
+	if err != nil {
+		t.Fatalf("IsAccessTokenBlacklisted: %v", err)
+	}
+	if !blacklisted {
+		t.Error("access token of the ended session is not revoked")
+	}
+}
+
+func TestRefreshSessionReusedToken(t *testing.T) {
+	ctx := context.Background()
+	sessions, jwtService, tokenRepo, mr := newTestSessionService(t)
+	first, err := sessions.CreateSession(ctx, entity.TokenPayload{UserID: "7", Role: "admin", CompanyID: "3"}, testClient)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	second, err := sessions.RefreshSession(ctx, first.RefreshToken, testClient)
+	if err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	familyID := refreshTokenFamily(first.RefreshToken)
+
+	_, err = sessions.RefreshSession(ctx, first.RefreshToken, entity.SessionClient{IP: "198.51.100.9", UserAgent: "attacker"})
+	if got := errs.ErrorCast(err).Type; got != errs.ErrorTypeTokenReused {
+		t.Fatalf("RefreshSession with rotated token: got %v, want %s", err, errs.ErrorTypeTokenReused)
+	}
+
+	for _, key := range []string{"session:" + familyID, "refresh:" + SHA256Hex(second.RefreshToken), "session-used:" + familyID} {
+		if mr.Exists(key) {
+			t.Errorf("%s still exists after reuse", key)
+		}
+	}
+	if ok, _ := mr.SIsMember("user-sessions:7", familyID); ok {
+		t.Error("ended session is still indexed")
+	}
+	assertAccessTokenRevoked(t, jwtService, tokenRepo, first.AccessToken)
+	assertAccessTokenRevoked(t, jwtService, tokenRepo, second.AccessToken)
+
+	// The current token of the family is no longer usable either
+	_, err = sessions.RefreshSession(ctx, second.RefreshToken, testClient)
+	if got := errs.ErrorCast(err).Type; got != errs.ErrorTypeTokenExpired {
+		t.Fatalf("RefreshSession with current token: got %v, want %s", err, errs.ErrorTypeTokenExpired)
+	}
+}
+
+func TestRefreshSessionUnknownToken(t *testing.T) {
+	ctx := context.Background()
+	sessions, _, _, _ := newTestSessionService(t)
+	tokens, err := sessions.CreateSession(ctx, entity.TokenPayload{UserID: "7", Role: "admin", CompanyID: "3"}, testClient)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	familyID := refreshTokenFamily(tokens.RefreshToken)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no family", "not-a-refresh-token"},
+		{"unknown family", "0123456789abcdef0123456789abcdef.secret"},
+		{"unknown secret", familyID + ".secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sessions.RefreshSession(ctx, tt.token, testClient)
+			if got := errs.ErrorCast(err).Type; got != errs.ErrorTypeTokenExpired {
+				t.Fatalf("RefreshSession: got %v, want %s", err, errs.ErrorTypeTokenExpired)
+			}
+		})
+	}
+
+	// Unknown tokens do not end the session
+	if _, err := sessions.RefreshSession(ctx, tokens.RefreshToken, testClient); err != nil {
+		t.Fatalf("RefreshSession after unknown tokens: %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/pdf"
+)
+
+const (
+	ContentTypePDF  = "application/pdf"
+	ContentTypePNG  = "image/png"
+	ContentTypeJPEG = "image/jpeg"
+
+	// PDF readers accept the header anywhere in the first kilobyte
+	sniffLength       = 1024
+	maxFileNameLength = 200
+)
+
+var fileExtensions = map[string][]string{
+	ContentTypePDF:  {".pdf"},
+	ContentTypePNG:  {".png"},
+	ContentTypeJPEG: {".jpg", ".jpeg"},
+}
+
+// UploadedFile is an upload that passed validation, spooled to a temporary file.
+// Close removes the temporary file.
+type UploadedFile struct {
+	FileName    string // sanitised file name
+	ContentType string
+	Size        int64
+	Hash        string // hex SHA-256 of the content
+	file        *os.File
+	mapped      []byte
+}
+
+func (f *UploadedFile) Read(p []byte) (int, error) {
+	return f.file.Read(p)
+}
+
+func (f *UploadedFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+// Bytes returns the content of the upload mapped into memory instead of read onto the heap, for
+// parsers and analyzers that need all of it at once. The slice must not be modified and is only
+// valid until the upload is closed.
+func (f *UploadedFile) Bytes() ([]byte, error) {
+	if f.mapped == nil {
+		data, err := mapFile(f.file, f.Size)
+		if err != nil {
+			return nil, err
+		}
+		f.mapped = data
+	}
+	return f.mapped, nil
+}
+
+func (f *UploadedFile) Close() error {
+	if f.mapped != nil {
+		unmapFile(f.mapped)
+		f.mapped = nil
+	}
+	err := f.file.Close()
+	os.Remove(f.file.Name())
+	return err
+}
+
+// UploadValidator checks document files before they are stored
+type UploadValidator interface {
+	// Validate reads the whole upload and returns it ready to be stored, or a validation error
+	Validate(fileName string, r io.Reader) (*UploadedFile, error)
+}
+
+type uploadValidator struct {
+	maxSize     int64
+	allowImages bool
+}
+
+func NewUploadValidator(maxSize int64, allowImages bool) UploadValidator {
+	return &uploadValidator{
+		maxSize:     maxSize,
+		allowImages: allowImages,
+	}
+}
+
+func (v *uploadValidator) Validate(fileName string, r io.Reader) (*UploadedFile, error) {
+	f, err := os.CreateTemp("", "certify-upload-*")
+	if err != nil {
+		slog.Error("error creating upload temp file", "err", err)
+		return nil, errs.InternalError("error receiving file", err)
+	}
+	upload := &UploadedFile{file: f}
+
+	if err := v.validate(upload, fileName, r); err != nil {
+		upload.Close()
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (v *uploadValidator) validate(upload *UploadedFile, fileName string, r io.Reader) error {
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(upload.file, hasher), io.LimitReader(r, v.maxSize+1))
+	if err != nil {
+		return errs.BadRequestError("error reading uploaded file", err)
+	}
+	if size > v.maxSize {
+		return errs.ValidationError(fmt.Sprintf("file exceeds the maximum size of %d MB", v.maxSize>>20), nil)
+	}
+	if size == 0 {
+		return errs.ValidationError("file is empty", nil)
+	}
+	upload.Size = size
+	upload.Hash = hex.EncodeToString(hasher.Sum(nil))
+
+	head := make([]byte, min(size, sniffLength))
+	if _, err := upload.file.ReadAt(head, 0); err != nil {
+		return errs.InternalError("error reading uploaded file", err)
+	}
+
+	upload.ContentType = sniffContentType(head)
+	switch upload.ContentType {
+	case ContentTypePDF:
+		data, err := upload.Bytes()
+		if err != nil {
+			slog.Error("error mapping uploaded file", "err", err)
+			return errs.InternalError("error reading uploaded file", err)
+		}
+		if err := validatePDF(data); err != nil {
+			return err
+		}
+	case ContentTypePNG, ContentTypeJPEG:
+		if !v.allowImages {
+			return errs.ValidationError("only PDF files are accepted", nil)
+		}
+		if _, _, err := image.DecodeConfig(io.NewSectionReader(upload.file, 0, size)); err != nil {
+			return errs.ValidationError("file is not a valid image", err)
+		}
+	default:
+		if v.allowImages {
+			return errs.ValidationError("only PDF, PNG and JPEG files are accepted", nil)
+		}
+		return errs.ValidationError("only PDF files are accepted", nil)
+	}
+
+	upload.FileName = sanitizeFileName(fileName, upload.ContentType)
+
+	if _, err := upload.file.Seek(0, io.SeekStart); err != nil {
+		return errs.InternalError("error reading uploaded file", err)
+	}
+	return nil
+}
+
+// sniffContentType detects the file type from its magic bytes, ignoring the declared type and extension
+func sniffContentType(head []byte) string {
+	switch {
+	case bytes.Contains(head, []byte("%PDF-")):
+		return ContentTypePDF
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return ContentTypePNG
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return ContentTypeJPEG
+	default:
+		return ""
+	}
+}
+
+// validatePDF rejects files that cannot be parsed, are encrypted or have no pages. Only the
+// cross-reference data, the trailer and the page tree are read.
+func validatePDF(data []byte) error {
+	doc, err := pdf.Open(data)
+	if err != nil {
+		return errs.ValidationError("file is not a valid PDF", err)
+	}
+	if doc.IsEncrypted() {
+		return errs.ValidationError("encrypted PDF files are not accepted", nil)
+	}
+	n, err := doc.NumPages()
+	if err != nil || n == 0 {
+		return errs.ValidationError("PDF file has no readable pages", err)
+	}
+	return nil
+}
+
+// sanitizeFileName strips directories and unsafe characters from a client supplied file name
+// and makes sure its extension matches the detected content type
+func sanitizeFileName(name, contentType string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError || unicode.IsControl(r):
+			return -1
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(".-_()", r):
+			return r
+		case unicode.IsSpace(r):
+			return ' '
+		default:
+			return '_'
+		}
+	}, name)
+
+	extensions := fileExtensions[contentType]
+	ext := strings.ToLower(path.Ext(name))
+	base := strings.TrimSuffix(name, path.Ext(name))
+	if !slices.Contains(extensions, ext) {
+		ext = extensions[0]
+		if !validExtension(path.Ext(name)) {
+			// Keep the text after a dot that is not an extension, e.g. "v1.2 final"
+			base = name
+		}
+	}
+
+	base = strings.Join(strings.Fields(base), " ")
+	base = strings.Trim(base, " ._")
+	if runes := []rune(base); len(runes) > maxFileNameLength {
+		base = strings.TrimRight(string(runes[:maxFileNameLength]), " ._")
+	}
+	if base == "" {
+		base = "document"
+	}
+	return base + ext
+}
+
+// validExtension reports whether ext looks like a file extension rather than part of the name
+func validExtension(ext string) bool {
+	if len(ext) < 2 || len(ext) > 5 {
+		return false
+	}
+	for _, r := range ext[1:] {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
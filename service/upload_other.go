@@ -0,0 +1,17 @@
+//go:build !unix
+
+package service
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of a file, as memory mapping is only used on Unix systems
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	return io.ReadAll(io.NewSectionReader(f, 0, size))
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/pdf"
+)
+
+// testPDF returns a PDF file holding the objects numbered from 1, with object 1 as the catalog and
+// extra trailer entries
+func testPDF(trailer string, objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	startxref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, startxref)
+	return buf.Bytes()
+}
+
+// onePagePDF is a valid document with a single empty page
+func onePagePDF(trailer string) []byte {
+	return testPDF(trailer,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] >>",
+	)
+}
+
+// bombPDF is a small file whose cross-reference stream inflates to more data than a document may
+// decode
+func bombPDF() []byte {
+	var compressed bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+	zw.Write(make([]byte, 65<<20))
+	zw.Close()
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	startxref := buf.Len()
+	fmt.Fprintf(&buf, "1 0 obj\n<< /Type /XRef /Size 2 /W [1 2 1] /Root 1 0 R /Filter /FlateDecode /Length %d >>\nstream\n", compressed.Len())
+	buf.Write(compressed.Bytes())
+	fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", startxref)
+	return buf.Bytes()
+}
+
+func testPNG() []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)))
+	return buf.Bytes()
+}
+
+func TestValidateUpload(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowImages     bool
+		fileName        string
+		content         []byte
+		wantContentType string
+		wantFileName    string
+		wantErr         bool
+	}{
+		{"pdf", false, "diploma.pdf", onePagePDF(""), ContentTypePDF, "diploma.pdf", false},
+		{"pdf with another extension", false, "diploma.png", onePagePDF(""), ContentTypePDF, "diploma.pdf", false},
+		{"png", true, "scan.png", testPNG(), ContentTypePNG, "scan.png", false},
+		{"png not allowed", false, "scan.png", testPNG(), "", "", true},
+		{"empty", false, "empty.pdf", nil, "", "", true},
+		{"too large", false, "large.pdf", append(onePagePDF(""), make([]byte, 1<<20)...), "", "", true},
+		{"unknown type", true, "notes.txt", []byte("plain text"), "", "", true},
+		{"broken image", true, "scan.png", testPNG()[:20], "", "", true},
+		{"truncated pdf", false, "diploma.pdf", onePagePDF("")[:40], "", "", true},
+		{"encrypted pdf", false, "diploma.pdf", onePagePDF("/Encrypt << /Filter /Standard >>"), "", "", true},
+		{"no pages", false, "diploma.pdf", testPDF("", "<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [] /Count 0 >>"), "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewUploadValidator(1<<20, tt.allowImages)
+			upload, err := v.Validate(tt.fileName, bytes.NewReader(tt.content))
+			if tt.wantErr {
+				if err == nil {
+					upload.Close()
+					t.Fatal("Validate accepted the file")
+				}
+				if got := errs.ErrorCast(err).Type; got != errs.ErrorTypeValidation {
+					t.Errorf("Validate error = %v, want %s", err, errs.ErrorTypeValidation)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			defer upload.Close()
+			if upload.ContentType != tt.wantContentType || upload.FileName != tt.wantFileName || upload.Size != int64(len(tt.content)) {
+				t.Errorf("Validate = %s %q %d bytes, want %s %q %d bytes",
+					upload.ContentType, upload.FileName, upload.Size, tt.wantContentType, tt.wantFileName, len(tt.content))
+			}
+			// The upload is rewound for storing
+			if data, err := io.ReadAll(upload); err != nil || !bytes.Equal(data, tt.content) {
+				t.Errorf("reading the upload = %d bytes, %v; want the content", len(data), err)
+			}
+		})
+	}
+}
+
+func TestValidateUploadDecompressionBomb(t *testing.T) {
+	bomb := bombPDF()
+	if len(bomb) > 1<<20 {
+		t.Fatalf("bomb is %d bytes, want a small file", len(bomb))
+	}
+
+	v := NewUploadValidator(1<<20, false)
+	upload, err := v.Validate("bomb.pdf", bytes.NewReader(bomb))
+	if err == nil {
+		upload.Close()
+		t.Fatal("Validate accepted a decompression bomb")
+	}
+	e := errs.ErrorCast(err)
+	if e.Type != errs.ErrorTypeValidation || !errors.Is(e.Err, pdf.ErrMalformed) {
+		t.Errorf("Validate error = %v (%v), want %s wrapping %v", err, e.Err, errs.ErrorTypeValidation, pdf.ErrMalformed)
+	}
+	if !strings.Contains(err.Error(), "decoded size limit") {
+		t.Errorf("Validate error = %v, want the decoded size limit exceeded", err)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		contentType string
+		want        string
+	}{
+		{"plain", "diploma.pdf", ContentTypePDF, "diploma.pdf"},
+		{"upper case extension", "Diploma.PDF", ContentTypePDF, "Diploma.pdf"},
+		{"unicode letters", "Диплом 2024.pdf", ContentTypePDF, "Диплом 2024.pdf"},
+		{"path traversal", "../../etc/passwd", ContentTypePDF, "passwd.pdf"},
+		{"windows path", `..\..\Windows\System32\report.pdf`, ContentTypePDF, "report.pdf"},
+		{"absolute path", "/var/uploads/report.pdf", ContentTypePDF, "report.pdf"},
+		{"directory only", "uploads/", ContentTypePDF, "uploads.pdf"},
+		{"control characters", "re\x00po\nrt\x1b.pdf", ContentTypePDF, "report.pdf"},
+		{"invalid utf-8", "re\xffport.pdf", ContentTypePDF, "report.pdf"},
+		{"unicode spaces", "final   version.pdf", ContentTypePDF, "final version.pdf"},
+		{"shell characters", "in<voice>|*?.pdf", ContentTypePDF, "in_voice.pdf"},
+		{"mismatched extension", "photo.png", ContentTypeJPEG, "photo.jpg"},
+		{"alternative extension", "photo.jpeg", ContentTypeJPEG, "photo.jpeg"},
+		{"double extension", "invoice.pdf.exe", ContentTypePDF, "invoice.pdf.pdf"},
+		{"dot in name", "v1.2 final", ContentTypePDF, "v1.2 final.pdf"},
+		{"empty", "", ContentTypePDF, "document.pdf"},
+		{"dot", ".", ContentTypePDF, "document.pdf"},
+		{"dot dot", "..", ContentTypePDF, "document.pdf"},
+		{"extension only", ".pdf", ContentTypePDF, "document.pdf"},
+		{"hidden file", ".htaccess", ContentTypePDF, "htaccess.pdf"},
+		{"root", "/", ContentTypePDF, "document.pdf"},
+		{"only unsafe characters", "***.pdf", ContentTypePDF, "document.pdf"},
+		{"long name", strings.Repeat("a", 300) + ".pdf", ContentTypePDF, strings.Repeat("a", maxFileNameLength) + ".pdf"},
+		{"long unicode name", strings.Repeat("д", 300) + ".pdf", ContentTypePDF, strings.Repeat("д", maxFileNameLength) + ".pdf"},
+		{"cut before a separator", strings.Repeat("a", maxFileNameLength-1) + " b.pdf", ContentTypePDF, strings.Repeat("a", maxFileNameLength-1) + ".pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFileName(tt.fileName, tt.contentType); got != tt.want {
+				t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.fileName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3"), ContentTypePDF},
+		{"pdf after leading bytes", append(bytes.Repeat([]byte{' '}, 500), "%PDF-1.4"...), ContentTypePDF},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ContentTypePNG},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), ContentTypeJPEG},
+		{"pdf marker without version", []byte("%PDF"), ""},
+		{"truncated png signature", []byte("\x89PNG"), ""},
+		{"gif", []byte("GIF89a"), ""},
+		{"html", []byte("<!DOCTYPE html><html>"), ""},
+		{"zip", []byte("PK\x03\x04"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffContentType(tt.head); got != tt.want {
+				t.Errorf("sniffContentType(%q) = %q, want %q", tt.head, got, tt.want)
+			}
+		})
+	}
+}
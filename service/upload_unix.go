@@ -0,0 +1,18 @@
+//go:build unix
+
+package service
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of a file read-only into memory. Its pages are loaded when they
+// are read and can be dropped again by the kernel, so the file is not held on the heap.
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/tasklineby/certify-backend/analyzer"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/pg"
+)
+
+const (
+	// maxUsageDays and maxUsageMonths bound the range of a usage report
+	maxUsageDays   = 366
+	maxUsageMonths = 36
+)
+
+// UsageService accounts for the tokens AI providers bill for comparisons and extractions, so that
+// companies can be billed by usage, and enforces their monthly token quotas
+type UsageService interface {
+	// CheckQuota fails with a quota exceeded error when the company used up its tokens for the month.
+	// It is checked before calls, so concurrent calls can overrun the quota by their own usage.
+	CheckQuota(ctx context.Context, companyID int) error
+	// RecordUsage stores the usage of one call with its cost. Failures are logged but never fail the call.
+	RecordUsage(ctx context.Context, record entity.UsageRecord)
+	GetUsage(ctx context.Context, requesterRole string, requesterCompanyID int, filter entity.UsageFilter) (*entity.UsageReport, error)
+}
+
+// UsagePrices are what AI providers charge per million tokens
+type UsagePrices struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+type usageService struct {
+	usageRepo    pg.UsageRepository
+	companyRepo  pg.CompanyRepository
+	monthlyQuota int64 // tokens, for companies without a quota of their own; 0 for no limit
+	prices       UsagePrices
+}
+
+func NewUsageService(usageRepo pg.UsageRepository, companyRepo pg.CompanyRepository, monthlyQuota int64, prices UsagePrices) UsageService {
+	return &usageService{
+		usageRepo:    usageRepo,
+		companyRepo:  companyRepo,
+		monthlyQuota: monthlyQuota,
+		prices:       prices,
+	}
+}
+
+func (s *usageService) CheckQuota(ctx context.Context, companyID int) error {
+	quota, err := s.quota(ctx, companyID, time.Now())
+	if err != nil {
+		return err
+	}
+	if quota.MonthlyTokens > 0 && quota.UsedTokens >= quota.MonthlyTokens {
+		slog.Warn("monthly token quota exceeded", "company_id", companyID, "quota", quota.MonthlyTokens, "used", quota.UsedTokens)
+		return errs.QuotaExceededError(fmt.Sprintf("monthly AI usage quota of %d tokens exceeded, it resets on %s",
+			quota.MonthlyTokens, quota.ResetsAt.Format(time.DateOnly)), nil)
+	}
+	return nil
+}
+
+// quota returns the monthly quota of a company and its usage in the month of now
+func (s *usageService) quota(ctx context.Context, companyID int, now time.Time) (entity.UsageQuota, error) {
+	company, err := s.companyRepo.GetCompanyByID(ctx, companyID)
+	if err != nil {
+		return entity.UsageQuota{}, errs.InternalError("error getting company", err)
+	}
+	monthStart := startOfMonth(now)
+	quota := entity.UsageQuota{MonthlyTokens: s.monthlyQuota, ResetsAt: monthStart.AddDate(0, 1, 0)}
+	if company.MonthlyTokenQuota != nil {
+		quota.MonthlyTokens = *company.MonthlyTokenQuota
+	}
+
+	quota.UsedTokens, err = s.usageRepo.GetTokensUsedSince(ctx, companyID, monthStart)
+	if err != nil {
+		return entity.UsageQuota{}, errs.InternalError("error getting token usage", err)
+	}
+	if quota.MonthlyTokens > 0 {
+		remaining := max(quota.MonthlyTokens-quota.UsedTokens, 0)
+		quota.RemainingTokens = &remaining
+	}
+	return quota, nil
+}
+
+func (s *usageService) RecordUsage(ctx context.Context, record entity.UsageRecord) {
+	// Providers are named "provider:model", e.g. "gemini:gemini-1.5-flash"
+	record.Provider, record.Model, _ = strings.Cut(record.Provider, ":")
+	record.Cost = (float64(record.InputTokens)*s.prices.InputPerMillion + float64(record.OutputTokens)*s.prices.OutputPerMillion) / 1_000_000
+	if err := s.usageRepo.CreateUsageRecord(ctx, &record); err != nil {
+		slog.Error("error recording usage", "err", err, "company_id", record.CompanyID, "total_tokens", record.TotalTokens)
+	}
+}
+
+// GetUsage aggregates the usage of the requester's company by day or month. Only admins can see it.
+// Without a range, the current month is reported by day and the last twelve months by month.
+func (s *usageService) GetUsage(ctx context.Context, requesterRole string, requesterCompanyID int, filter entity.UsageFilter) (*entity.UsageReport, error) {
+	if requesterRole != "admin" {
+		return nil, errs.UnauthorizedError("only admins can view usage", nil)
+	}
+
+	now := time.Now().UTC()
+	switch filter.Period {
+	case "":
+		filter.Period = entity.UsagePeriodDay
+	case entity.UsagePeriodDay, entity.UsagePeriodMonth:
+	default:
+		return nil, errs.ValidationError("period must be day or month", nil)
+	}
+	if filter.To.IsZero() {
+		filter.To = startOfDay(now).AddDate(0, 0, 1)
+	}
+	if filter.From.IsZero() {
+		filter.From = startOfMonth(now)
+		if filter.Period == entity.UsagePeriodMonth {
+			filter.From = filter.From.AddDate(0, -11, 0)
+		}
+	}
+	if filter.Period == entity.UsagePeriodMonth {
+		// Months are reported whole
+		filter.From = startOfMonth(filter.From)
+		if to := startOfMonth(filter.To); to.Before(filter.To) {
+			filter.To = to.AddDate(0, 1, 0)
+		}
+	}
+	if !filter.From.Before(filter.To) {
+		return nil, errs.ValidationError("from must be before to", nil)
+	}
+	if filter.Period == entity.UsagePeriodDay && filter.To.After(filter.From.AddDate(0, 0, maxUsageDays)) {
+		return nil, errs.ValidationError(fmt.Sprintf("a daily report covers at most %d days", maxUsageDays), nil)
+	}
+	if filter.Period == entity.UsagePeriodMonth && filter.To.After(filter.From.AddDate(0, maxUsageMonths, 0)) {
+		return nil, errs.ValidationError(fmt.Sprintf("a monthly report covers at most %d months", maxUsageMonths), nil)
+	}
+
+	summaries, err := s.usageRepo.GetUsageSummaries(ctx, requesterCompanyID, filter)
+	if err != nil {
+		return nil, errs.InternalError("error getting usage", err)
+	}
+	if summaries == nil {
+		summaries = []entity.UsageSummary{}
+	}
+
+	total := entity.UsageSummary{PeriodStart: filter.From}
+	for _, summary := range summaries {
+		total.Comparisons += summary.Comparisons
+		total.Extractions += summary.Extractions
+		total.Requests += summary.Requests
+		total.InputTokens += summary.InputTokens
+		total.OutputTokens += summary.OutputTokens
+		total.TotalTokens += summary.TotalTokens
+		total.Cost += summary.Cost
+	}
+
+	quota, err := s.quota(ctx, requesterCompanyID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.UsageReport{
+		Period:  filter.Period,
+		From:    filter.From,
+		To:      filter.To,
+		Total:   total,
+		Periods: summaries,
+		Quota:   quota,
+	}, nil
+}
+
+// newUsageRecord returns the record of a call to provider on behalf of a user of a company
+func newUsageRecord(operation entity.UsageOperation, provider string, usage entity.TokenUsage, companyID, userID int, documentID *int) entity.UsageRecord {
+	return entity.UsageRecord{
+		CompanyID:    companyID,
+		UserID:       &userID,
+		DocumentID:   documentID,
+		Operation:    operation,
+		Provider:     provider,
+		Requests:     usage.Requests,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
+// recordFailedUsage stores the tokens an AI provider billed for a comparison or extraction that
+// failed afterwards, e.g. because none of its answers passed validation. Analyzer errors may already
+// be wrapped in an errs.Error.
+func recordFailedUsage(ctx context.Context, usage UsageService, err error, operation entity.UsageOperation, companyID, userID int, documentID *int) {
+	var usageErr *analyzer.UsageError
+	if !errors.As(errs.ErrorCast(err).Err, &usageErr) {
+		return
+	}
+	usage.RecordUsage(ctx, newUsageRecord(operation, usageErr.Provider, usageErr.Usage, companyID, userID, documentID))
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
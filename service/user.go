@@ -11,7 +11,6 @@ import (
 	"github.com/tasklineby/certify-backend/entity"
 	"github.com/tasklineby/certify-backend/errs"
 	"github.com/tasklineby/certify-backend/repository/pg"
-	"github.com/tasklineby/certify-backend/repository/rdb"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -32,28 +31,31 @@ func isUniqueConstraintError(err error) bool {
 }
 
 type UserService interface {
-	CreateCompanyWithAdmin(ctx context.Context, req entity.CreateCompanyRequest, jwtService JwtService, tokenRepo rdb.TokenRepository) (entity.TokenPair, error)
-	RegisterEmployee(ctx context.Context, req entity.RegisterEmployeeRequest, jwtService JwtService, tokenRepo rdb.TokenRepository) (entity.TokenPair, error)
+	CreateCompanyWithAdmin(ctx context.Context, req entity.CreateCompanyRequest, client entity.SessionClient) (entity.TokenPair, error)
+	RegisterEmployee(ctx context.Context, req entity.RegisterEmployeeRequest, client entity.SessionClient) (entity.TokenPair, error)
 	GetUserByID(ctx context.Context, id int) (entity.User, error)
 	GetUserByEmail(ctx context.Context, email string) (entity.User, error)
 	UpdateUser(ctx context.Context, id int, req entity.UpdateUserRequest, requesterRole string, requesterCompanyID int, requesterID int) error
 	DeleteUser(ctx context.Context, id int, requesterRole string, requesterCompanyID int) error
+	RevokeUserSessions(ctx context.Context, id int, requesterRole string, requesterCompanyID int) (*entity.SessionRevocationResponse, error)
 	GetUsersByCompanyID(ctx context.Context, companyID int) ([]entity.User, error)
 }
 
 type userService struct {
 	userRepo    pg.UserRepository
 	companyRepo pg.CompanyRepository
+	sessions    SessionService
 }
 
-func NewUserService(userRepo pg.UserRepository, companyRepo pg.CompanyRepository) UserService {
+func NewUserService(userRepo pg.UserRepository, companyRepo pg.CompanyRepository, sessions SessionService) UserService {
 	return &userService{
 		userRepo:    userRepo,
 		companyRepo: companyRepo,
+		sessions:    sessions,
 	}
 }
 
-func (s *userService) CreateCompanyWithAdmin(ctx context.Context, req entity.CreateCompanyRequest, jwtService JwtService, tokenRepo rdb.TokenRepository) (entity.TokenPair, error) {
+func (s *userService) CreateCompanyWithAdmin(ctx context.Context, req entity.CreateCompanyRequest, client entity.SessionClient) (entity.TokenPair, error) {
 	company := &entity.Company{
 		Name: req.CompanyName,
 	}
@@ -100,31 +102,10 @@ func (s *userService) CreateCompanyWithAdmin(ctx context.Context, req entity.Cre
 		CompanyID: strconv.Itoa(company.ID),
 	}
 
-	accessToken, err := jwtService.GenerateAccessToken(ctx, tokenPayload)
-	if err != nil {
-		slog.Error("error generating access token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error generating access token", err)
-	}
-
-	refreshToken, err := jwtService.GenerateRefreshToken(ctx, tokenPayload)
-	if err != nil {
-		slog.Error("error generating refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error generating refresh token", err)
-	}
-
-	err = tokenRepo.SetRefreshToken(ctx, refreshToken)
-	if err != nil {
-		slog.Error("error setting refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error setting refresh token", err)
-	}
-
-	return entity.TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken.Token,
-	}, nil
+	return s.sessions.CreateSession(ctx, tokenPayload, client)
 }
 
-func (s *userService) RegisterEmployee(ctx context.Context, req entity.RegisterEmployeeRequest, jwtService JwtService, tokenRepo rdb.TokenRepository) (entity.TokenPair, error) {
+func (s *userService) RegisterEmployee(ctx context.Context, req entity.RegisterEmployeeRequest, client entity.SessionClient) (entity.TokenPair, error) {
 	// Verify company exists
 	_, err := s.companyRepo.GetCompanyByID(ctx, req.CompanyID)
 	if err != nil {
@@ -166,28 +147,7 @@ func (s *userService) RegisterEmployee(ctx context.Context, req entity.RegisterE
 		CompanyID: strconv.Itoa(user.CompanyID),
 	}
 
-	accessToken, err := jwtService.GenerateAccessToken(ctx, tokenPayload)
-	if err != nil {
-		slog.Error("error generating access token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error generating access token", err)
-	}
-
-	refreshToken, err := jwtService.GenerateRefreshToken(ctx, tokenPayload)
-	if err != nil {
-		slog.Error("error generating refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error generating refresh token", err)
-	}
-
-	err = tokenRepo.SetRefreshToken(ctx, refreshToken)
-	if err != nil {
-		slog.Error("error setting refresh token", "err", err)
-		return entity.TokenPair{}, errs.InternalError("error setting refresh token", err)
-	}
-
-	return entity.TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken.Token,
-	}, nil
+	return s.sessions.CreateSession(ctx, tokenPayload, client)
 }
 
 func (s *userService) GetUserByID(ctx context.Context, id int) (entity.User, error) {
@@ -265,6 +225,30 @@ func (s *userService) UpdateUser(ctx context.Context, id int, req entity.UpdateU
 		}
 		user.Email = *req.Email
 	}
+	if req.Role != nil && *req.Role != targetUser.Role {
+		if requesterRole != "admin" {
+			return errs.UnauthorizedError("only admins can change roles", nil)
+		}
+		// Keeps companies from losing their last admin by mistake
+		if id == requesterID {
+			return errs.ValidationError("admins cannot change their own role", nil)
+		}
+		user.Role = *req.Role
+	}
+	if req.Password != nil {
+		// Admins may reset the passwords of others, users must confirm their own
+		if id == requesterID {
+			if req.CurrentPassword == nil || bcrypt.CompareHashAndPassword([]byte(targetUser.Password), []byte(*req.CurrentPassword)) != nil {
+				return errs.UnauthorizedError("current password is incorrect", nil)
+			}
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			slog.Error("error hashing password", "err", err)
+			return errs.InternalError("error hashing password", err)
+		}
+		user.Password = string(hashedPassword)
+	}
 
 	err = s.userRepo.UpdateUser(ctx, id, user)
 	if err != nil {
@@ -274,6 +258,13 @@ func (s *userService) UpdateUser(ctx context.Context, id int, req entity.UpdateU
 		slog.Error("error updating user", "err", err)
 		return errs.InternalError("error updating user", err)
 	}
+
+	// Sessions carry the old role and were opened with the old password
+	if user.Role != "" || user.Password != "" {
+		if _, err := s.sessions.RevokeUserSessions(ctx, id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -298,6 +289,11 @@ func (s *userService) DeleteUser(ctx context.Context, id int, requesterRole stri
 		return errs.UnauthorizedError("can only delete users from the same company", nil)
 	}
 
+	// Sessions are ended first, so that a failure leaves the user logged out rather than logged in
+	if _, err := s.sessions.RevokeUserSessions(ctx, id); err != nil {
+		return err
+	}
+
 	err = s.userRepo.DeleteUser(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -309,6 +305,32 @@ func (s *userService) DeleteUser(ctx context.Context, id int, requesterRole stri
 	return nil
 }
 
+// RevokeUserSessions logs a user out everywhere, e.g. when their credentials were compromised. Only
+// admins of the user's company can do it.
+func (s *userService) RevokeUserSessions(ctx context.Context, id int, requesterRole string, requesterCompanyID int) (*entity.SessionRevocationResponse, error) {
+	if requesterRole != "admin" {
+		return nil, errs.UnauthorizedError("only admins can end the sessions of users", nil)
+	}
+
+	targetUser, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFoundError("user", err)
+		}
+		slog.Error("error getting user", "err", err)
+		return nil, errs.InternalError("error getting user", err)
+	}
+	if targetUser.CompanyID != requesterCompanyID {
+		return nil, errs.UnauthorizedError("can only end the sessions of users from the same company", nil)
+	}
+
+	revoked, err := s.sessions.RevokeUserSessions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.SessionRevocationResponse{Revoked: revoked}, nil
+}
+
 func (s *userService) GetUsersByCompanyID(ctx context.Context, companyID int) ([]entity.User, error) {
 	// Verify company exists
 	_, err := s.companyRepo.GetCompanyByID(ctx, companyID)
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+)
+
+// ReplaceDocumentFile stores a new file for an active document and returns the document at its new version.
+// QR codes issued for earlier versions report the new version on verification.
+func (s *documentService) ReplaceDocumentFile(ctx context.Context, id, requesterCompanyID, userID int, fileName string, file io.Reader) (*entity.Document, error) {
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.State != entity.DocumentStateActive {
+		return nil, errs.ValidationError(fmt.Sprintf("%s document cannot be updated", doc.State), nil)
+	}
+
+	upload, err := s.uploads.Validate(fileName, file)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+
+	if err := s.scanUpload(ctx, upload); err != nil {
+		return nil, err
+	}
+	if upload.Hash == doc.ContentHash {
+		return nil, errs.ValidationError("file is identical to the current version", nil)
+	}
+	if err := s.checkDuplicateFile(ctx, requesterCompanyID, upload.Hash); err != nil {
+		return nil, err
+	}
+
+	info, err := s.blobStore.Put(ctx, upload)
+	if err != nil {
+		slog.Error("error storing document file", "err", err)
+		return nil, errs.InternalError("error storing document file", err)
+	}
+
+	event, err := newDocumentEvent(id, userID, entity.DocumentEventFileReplaced, map[string]any{
+		"file_name": fieldChange{From: doc.FileName, To: upload.FileName},
+		"version":   fieldChange{From: doc.Version, To: doc.Version + 1},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.documentRepo.ReplaceFile(ctx, id, upload.FileName, upload.ContentType, info.Key, info.Size, event); err != nil {
+		return nil, activeChangeError(err, "error replacing document file")
+	}
+
+	return s.GetDocumentByID(ctx, id, requesterCompanyID)
+}
+
+// GetDocumentVersions returns all versions of a document, newest first
+func (s *documentService) GetDocumentVersions(ctx context.Context, id, requesterCompanyID int) ([]entity.DocumentVersion, error) {
+	if _, err := s.GetDocumentByID(ctx, id, requesterCompanyID); err != nil {
+		return nil, err
+	}
+
+	versions, err := s.versionRepo.GetVersionsByDocumentID(ctx, id)
+	if err != nil {
+		slog.Error("error getting document versions", "err", err)
+		return nil, errs.InternalError("error getting document versions", err)
+	}
+	return versions, nil
+}
+
+// OpenDocumentVersionFile returns a specific version of a document with its file opened for reading.
+// The caller must close the file.
+func (s *documentService) OpenDocumentVersionFile(ctx context.Context, id, version, requesterCompanyID int) (*entity.DocumentVersion, io.ReadSeekCloser, error) {
+	doc, err := s.GetDocumentByID(ctx, id, requesterCompanyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if doc.State == entity.DocumentStateQuarantined {
+		return nil, nil, errQuarantined
+	}
+
+	v, err := s.versionRepo.GetVersion(ctx, id, version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, errs.NotFoundError("document version", err)
+		}
+		slog.Error("error getting document version", "err", err)
+		return nil, nil, errs.InternalError("error getting document version", err)
+	}
+
+	file, err := s.openFile(ctx, v.FileKey, func(ctx context.Context) ([]byte, error) {
+		return s.versionRepo.GetLegacyFileData(ctx, id, version)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &v, file, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 3
+)
+
+// errWebhookAddress is returned for webhook hosts that resolve to an address of the server's own
+// network, which clients must not be able to reach through the server
+var errWebhookAddress = errors.New("webhook address is not public")
+
+// blockedWebhookPrefixes are the special purpose ranges not covered by the netip.Addr checks in
+// isPublicAddr
+var blockedWebhookPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, may embed any IPv4 address
+}
+
+// webhookSender posts JSON notifications to client supplied URLs. When a secret is configured, the
+// X-Certify-Signature header carries "sha256=" and the hex HMAC-SHA256 of the body, so receivers can
+// check that the notification came from this service. Only public addresses are connected to; the
+// address is checked when connecting, so a host that resolves differently after its URL was
+// accepted cannot reach internal services either.
+type webhookSender struct {
+	secret     []byte
+	httpClient *http.Client
+}
+
+func newWebhookSender(secret string) *webhookSender {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !isPublicAddr(addr) {
+				return errWebhookAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect in our place, bypassing the address check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &webhookSender{
+		secret:     []byte(secret),
+		httpClient: &http.Client{Timeout: webhookTimeout, Transport: transport},
+	}
+}
+
+// checkWebhookHost resolves a webhook host and rejects it unless all its addresses are public
+func checkWebhookHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve webhook host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return errWebhookAddress
+		}
+	}
+	return nil
+}
+
+// isPublicAddr reports whether an address may receive webhooks. Loopback, private, link-local
+// (including cloud metadata endpoints such as 169.254.169.254), multicast, unspecified and other
+// special purpose addresses may not.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedWebhookPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// deliver posts payload, retrying failed attempts with a growing pause. Any 2xx response counts as delivered.
+func (w *webhookSender) deliver(ctx context.Context, url, event string, payload []byte) error {
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if err = w.post(ctx, url, event, payload); err == nil {
+			return nil
+		}
+		if attempt < webhookAttempts {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+	}
+	return err
+}
+
+func (w *webhookSender) post(ctx context.Context, url, event string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Certify-Event", event)
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(payload)
+		req.Header.Set("X-Certify-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook receiver responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -11,16 +11,29 @@ import (
 )
 
 type AuthHandler struct {
-	authService service.AuthService
+	authService    service.AuthService
+	sessionService service.SessionService
 }
 
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService service.AuthService, sessionService service.SessionService) *AuthHandler {
+	return &AuthHandler{
+		authService:    authService,
+		sessionService: sessionService,
+	}
+}
+
+// sessionClient describes the client of a request for the session it logs in to
+func sessionClient(c *gin.Context, device string) entity.SessionClient {
+	return entity.SessionClient{
+		Device:    device,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
 }
 
 // Login godoc
 // @Summary      Login user
-// @Description  Authenticate user with email and password, returns access and refresh tokens
+// @Description  Authenticate user with email and password, returns access and refresh tokens of a new session. An optional device name identifies the session.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -36,7 +49,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	tokenPair, err := h.authService.Login(c.Request.Context(), req.Email, req.Password)
+	tokenPair, err := h.authService.Login(c.Request.Context(), req.Email, req.Password, sessionClient(c, req.Device))
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
@@ -65,7 +78,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	tokenPair, err := h.authService.Register(c.Request.Context(), req)
+	tokenPair, err := h.authService.Register(c.Request.Context(), req, sessionClient(c, ""))
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
@@ -77,14 +90,14 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 // Refresh godoc
 // @Summary      Refresh access token
-// @Description  Exchange refresh token for new access and refresh token pair
+// @Description  Exchange refresh token for new access and refresh token pair of the same session. Each refresh token can be used once: an expired or unknown token fails with TOKEN_EXPIRED, while reusing a token that was already exchanged ends the whole session and fails with TOKEN_REUSED.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request   body      entity.RefreshRequest  true  "Refresh token"
 // @Success      200       {object}  entity.TokenPair        "New token pair"
 // @Failure      400       {object}  errs.Error             "Invalid request"
-// @Failure      401       {object}  errs.Error             "Invalid or expired refresh token"
+// @Failure      401       {object}  errs.Error             "Expired (TOKEN_EXPIRED) or reused (TOKEN_REUSED) refresh token"
 // @Router       /auth/refresh [post]
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	var req entity.RefreshRequest
@@ -93,7 +106,7 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		return
 	}
 
-	tokenPair, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	tokenPair, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken, sessionClient(c, ""))
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
@@ -105,7 +118,7 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 
 // Logout godoc
 // @Summary      Logout user
-// @Description  Invalidate access and refresh tokens
+// @Description  Invalidate access and refresh tokens and end the session
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -130,7 +143,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	err := h.authService.Logout(c.Request.Context(), accessToken, req.RefreshToken)
+	err := h.authService.Logout(c.Request.Context(), accessToken, req.RefreshToken, sessionClient(c, ""))
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
@@ -139,3 +152,86 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
+
+// GetSessions godoc
+// @Summary      List sessions
+// @Description  List the active sessions of the authenticated user, most recently used first. The session of the request is marked current.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200       {array}   entity.SessionInfo  "Active sessions"
+// @Failure      401       {object}  errs.Error          "Unauthorized"
+// @Router       /auth/sessions [get]
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	sessions, err := h.sessionService.GetUserSessions(c.Request.Context(), userID, c.GetString("session_id"))
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary      Revoke session
+// @Description  End a session of the authenticated user and revoke its access tokens, e.g. of a lost device
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      string             true  "Session ID"
+// @Success      200       {object}  map[string]string  "Session ended"
+// @Failure      401       {object}  errs.Error         "Unauthorized"
+// @Failure      404       {object}  errs.Error         "Session not found"
+// @Router       /auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	err = h.sessionService.RevokeSession(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended successfully"})
+}
+
+// RevokeAllSessions godoc
+// @Summary      Log out everywhere
+// @Description  End all sessions of the authenticated user, including the current one, and revoke their access tokens
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200       {object}  entity.SessionRevocationResponse  "Sessions ended"
+// @Failure      401       {object}  errs.Error                        "Unauthorized"
+// @Router       /auth/sessions [delete]
+func (h *AuthHandler) RevokeAllSessions(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	revoked, err := h.sessionService.RevokeUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, entity.SessionRevocationResponse{Revoked: revoked})
+}
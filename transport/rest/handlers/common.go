@@ -12,7 +12,13 @@ func InitRoutes(
 	userHandler *UserHandler,
 	authHandler *AuthHandler,
 	documentHandler *DocumentHandler,
+	jobHandler *JobHandler,
+	evidenceHandler *EvidenceHandler,
+	promptHandler *PromptHandler,
+	usageHandler *UsageHandler,
 	authService service.AuthService,
+	publicVerifyLimiter gin.HandlerFunc,
+	uploadSizeLimit gin.HandlerFunc,
 ) *gin.Engine {
 	router := gin.New()
 	router.Use(gin.Logger(), gin.Recovery())
@@ -31,10 +37,20 @@ func InitRoutes(
 	userApi := api.Group("/user")
 	userApi.POST("/company", userHandler.CreateCompanyWithAdmin)
 
+	// Public document verification for third parties (rate-limited)
+	publicApi := api.Group("/public")
+	publicApi.GET("/verify", publicVerifyLimiter, documentHandler.PublicVerifyDocument)
+
 	// Protected routes
 	protected := api.Group("")
 	protected.Use(middleware.AuthMiddleware(authService))
 
+	// Session routes (protected)
+	protectedAuthApi := protected.Group("/auth")
+	protectedAuthApi.GET("/sessions", authHandler.GetSessions)
+	protectedAuthApi.DELETE("/sessions", authHandler.RevokeAllSessions)
+	protectedAuthApi.DELETE("/sessions/:id", authHandler.RevokeSession)
+
 	// User routes (protected)
 	protectedUserApi := protected.Group("/user")
 	protectedUserApi.GET("/me", userHandler.GetMe)
@@ -42,20 +58,56 @@ func InitRoutes(
 	protectedUserApi.GET("/:id", userHandler.GetUser)
 	protectedUserApi.PUT("/:id", userHandler.UpdateUser)
 	protectedUserApi.DELETE("/:id", userHandler.DeleteUser)
+	protectedUserApi.DELETE("/:id/sessions", userHandler.RevokeUserSessions)
 	protectedUserApi.GET("/company", userHandler.GetUsersByCompany)
+	protectedUserApi.PUT("/company/evidence-retention", evidenceHandler.SetEvidenceRetention)
 
 	// Document routes (protected - only company employees can access)
 	protectedDocumentApi := protected.Group("/documents")
 	protectedDocumentApi.GET("", documentHandler.GetCompanyDocuments)
-	protectedDocumentApi.POST("", documentHandler.CreateDocument)
+	protectedDocumentApi.POST("", uploadSizeLimit, documentHandler.CreateDocument)
+	protectedDocumentApi.POST("/extract", uploadSizeLimit, documentHandler.ExtractDocument)
 	protectedDocumentApi.GET("/verify", documentHandler.VerifyDocument)
-	protectedDocumentApi.POST("/compare/photos", documentHandler.CompareWithPhotos)
-	protectedDocumentApi.POST("/compare/pdf", documentHandler.CompareWithPDF)
+	protectedDocumentApi.POST("/compare/photos", uploadSizeLimit, jobHandler.CompareWithPhotos)
+	protectedDocumentApi.POST("/compare/pdf", uploadSizeLimit, jobHandler.CompareWithPDF)
 	protectedDocumentApi.GET("/:id", documentHandler.GetDocument)
+	protectedDocumentApi.PATCH("/:id", documentHandler.UpdateDocument)
+	protectedDocumentApi.DELETE("/:id", documentHandler.DeleteDocument)
+	protectedDocumentApi.POST("/:id/revoke", documentHandler.RevokeDocument)
+	protectedDocumentApi.POST("/:id/supersede", documentHandler.SupersedeDocument)
+	protectedDocumentApi.POST("/:id/rescan", documentHandler.RescanDocument)
+	protectedDocumentApi.DELETE("/:id/analysis-cache", documentHandler.InvalidateAnalysisCache)
+	protectedDocumentApi.GET("/:id/events", documentHandler.GetDocumentEvents)
+	protectedDocumentApi.GET("/:id/fields", documentHandler.GetDocumentFields)
+	protectedDocumentApi.PUT("/:id/fields", documentHandler.UpdateDocumentFields)
 	protectedDocumentApi.GET("/:id/file", documentHandler.DownloadFile)
+	protectedDocumentApi.HEAD("/:id/file", documentHandler.DownloadFile)
+	protectedDocumentApi.PUT("/:id/file", uploadSizeLimit, documentHandler.ReplaceFile)
+	protectedDocumentApi.GET("/:id/versions", documentHandler.GetDocumentVersions)
+	protectedDocumentApi.GET("/:id/versions/:version/file", documentHandler.DownloadVersionFile)
+	protectedDocumentApi.GET("/:id/qr", documentHandler.GetQRCode)
+	protectedDocumentApi.GET("/:id/certified-copy", documentHandler.DownloadCertifiedCopy)
+	protectedDocumentApi.PUT("/:id/public-verification", documentHandler.SetPublicVerification)
+
+	// Comparison job routes (protected)
+	protected.GET("/jobs/:id", jobHandler.GetJob)
 
 	// History routes (protected)
 	protected.GET("/history", documentHandler.GetHistory)
+	protected.GET("/history/:id/analysis", documentHandler.GetHistoryAnalysis)
+	protected.GET("/history/:id/evidence", evidenceHandler.GetHistoryEvidence)
+
+	// Evidence routes (protected - admins only)
+	protected.GET("/evidence/:id/file", evidenceHandler.DownloadEvidenceFile)
+	protected.HEAD("/evidence/:id/file", evidenceHandler.DownloadEvidenceFile)
+
+	// Prompt template routes (protected - only admins can add versions)
+	protected.GET("/prompts", promptHandler.GetPromptTemplates)
+	protected.POST("/prompts", promptHandler.CreatePromptTemplate)
+	protected.GET("/prompts/:id", promptHandler.GetPromptTemplate)
+
+	// Usage routes (protected - admins only)
+	protected.GET("/usage", usageHandler.GetUsage)
 
 	// Swagger documentation - accessible at /swagger/index.html
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
@@ -1,8 +1,10 @@
 package handlers
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
@@ -46,22 +48,49 @@ func getUserIDFromContext(c *gin.Context) (int, error) {
 	return userID.(int), nil
 }
 
+// getUserRoleFromContext extracts user_role from the gin context (set by auth middleware)
+func getUserRoleFromContext(c *gin.Context) (string, error) {
+	role, exists := c.Get("user_role")
+	if !exists {
+		return "", errs.UnauthorizedError("user role not found in context", nil)
+	}
+
+	return role.(string), nil
+}
+
+// getOptionalIntQuery parses an optional integer query parameter, returning 0 if it is absent
+func getOptionalIntQuery(c *gin.Context, key string) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
 // CreateDocument godoc
 // @Summary      Create a document
-// @Description  Create a new document for the authenticated user's company with PDF file attachment
+// @Description  Create a new document for the authenticated user's company with PDF file attachment. The file type is detected from its content; encrypted or malformed PDFs and files over the size limit are rejected, and PNG or JPEG scans are accepted if enabled. Uploading a file that another document of the company already has is rejected. Details left out are extracted from the file together with its fields (parties, dates, identifiers); reviewed fields, e.g. from /documents/extract, are stored instead of extracted ones.
 // @Tags         documents
 // @Accept       multipart/form-data
 // @Produce      json
 // @Security     BearerAuth
-// @Param        type            formData  string  true  "Document type"
-// @Param        name            formData  string  true  "Document name"
-// @Param        summary         formData  string  true  "Document summary"
-// @Param        expiration_date formData  string  true  "Expiration date (RFC3339 format)"
+// @Param        type            formData  string  false  "Document type, extracted if empty"
+// @Param        name            formData  string  false  "Document name, extracted if empty"
+// @Param        summary         formData  string  false  "Document summary, extracted if empty"
+// @Param        expiration_date formData  string  false  "Expiration date (RFC3339 format), extracted if empty"
+// @Param        public_verification formData  bool  false  "Allow unauthenticated third parties to verify the document"
+// @Param        fields          formData  string  false  "Reviewed fields as a JSON array of {name, kind, value}"
+// @Param        extract_fields  formData  bool    false  "Extract and store fields even if every detail is given"
 // @Param        file            formData  file    true  "PDF file"
 // @Success      201       {object}  entity.CreateDocumentResponse  "Document created successfully"
-// @Failure      400       {object}  errs.Error                     "Invalid request"
+// @Failure      400       {object}  errs.Error                     "Invalid request, rejected file or details that could not be extracted"
 // @Failure      401       {object}  errs.Error                     "Unauthorized"
+// @Failure      402       {object}  errs.Error                     "Monthly AI usage quota exceeded"
+// @Failure      409       {object}  errs.Error                     "Company already has a document with the same file"
+// @Failure      422       {object}  errs.Error                     "File rejected by the malware scanner"
+// @Failure      429       {object}  errs.Error                     "Extraction is rate limited"
 // @Failure      500       {object}  errs.Error                     "Internal server error"
+// @Failure      503       {object}  errs.Error                     "Extraction is unavailable"
 // @Router       /documents [post]
 func (h *DocumentHandler) CreateDocument(c *gin.Context) {
 	companyID, err := getCompanyIDFromContext(c)
@@ -71,67 +100,176 @@ func (h *DocumentHandler) CreateDocument(c *gin.Context) {
 		return
 	}
 
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	// Handle mandatory file upload first, so a body over the size limit is reported as such
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		errCast := formFileError(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+	defer file.Close()
+
 	// Parse form data
+	// Details left out are extracted from the file
 	docType := c.PostForm("type")
 	name := c.PostForm("name")
 	summary := c.PostForm("summary")
-	expirationDateStr := c.PostForm("expiration_date")
 
-	if docType == "" || name == "" || summary == "" || expirationDateStr == "" {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("type, name, summary and expiration_date are required", nil))
-		return
+	var expirationDate time.Time
+	if expirationDateStr := c.PostForm("expiration_date"); expirationDateStr != "" {
+		expirationDate, err = time.Parse(time.RFC3339, expirationDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid expiration_date format, use RFC3339", err))
+			return
+		}
 	}
 
-	expirationDate, err := time.Parse(time.RFC3339, expirationDateStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid expiration_date format, use RFC3339", err))
-		return
+	var fields []entity.DocumentFieldValue
+	if fieldsStr := c.PostForm("fields"); fieldsStr != "" {
+		if err := json.Unmarshal([]byte(fieldsStr), &fields); err != nil {
+			c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid fields value, use a JSON array", err))
+			return
+		}
+	}
+
+	extractFields := false
+	if extractFieldsStr := c.PostForm("extract_fields"); extractFieldsStr != "" {
+		extractFields, err = strconv.ParseBool(extractFieldsStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid extract_fields value", err))
+			return
+		}
+	}
+
+	publicVerification := false
+	if publicVerificationStr := c.PostForm("public_verification"); publicVerificationStr != "" {
+		publicVerification, err = strconv.ParseBool(publicVerificationStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid public_verification value", err))
+			return
+		}
 	}
 
 	req := entity.CreateDocumentRequest{
-		Type:           docType,
-		Name:           name,
-		Summary:        summary,
-		ExpirationDate: expirationDate,
+		Type:               docType,
+		Name:               name,
+		Summary:            summary,
+		ExpirationDate:     expirationDate,
+		PublicVerification: publicVerification,
+		Fields:             fields,
+		ExtractFields:      extractFields,
 	}
 
-	// Handle mandatory file upload
-	file, header, err := c.Request.FormFile("file")
+	hash, err := h.documentService.CreateDocument(c.Request.Context(), req, companyID, userID, header.Filename, file)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("file is required", err))
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entity.CreateDocumentResponse{Hash: hash})
+}
+
+// ExtractDocument godoc
+// @Summary      Extract document details
+// @Description  Propose the type, name, summary, expiration date and fields (parties, dates, identifiers) of a file for review before creating the document. The file is validated and scanned like an uploaded document but not stored.
+// @Tags         documents
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        file      formData  file  true  "PDF file"
+// @Success      200       {object}  entity.DocumentExtraction  "Proposed details"
+// @Failure      400       {object}  errs.Error                 "Invalid request or rejected file"
+// @Failure      401       {object}  errs.Error                 "Unauthorized"
+// @Failure      402       {object}  errs.Error                 "Monthly AI usage quota exceeded"
+// @Failure      422       {object}  errs.Error                 "File rejected by the malware scanner"
+// @Failure      429       {object}  errs.Error                 "Extraction is rate limited"
+// @Failure      500       {object}  errs.Error                 "Internal server error"
+// @Failure      503       {object}  errs.Error                 "Extraction is unavailable"
+// @Router       /documents/extract [post]
+func (h *DocumentHandler) ExtractDocument(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
-	defer file.Close()
 
-	fileData, err := io.ReadAll(file)
+	userID, err := getUserIDFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errs.InternalError("failed to read file", err))
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	fileName := header.Filename
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		errCast := formFileError(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+	defer file.Close()
 
-	hash, err := h.documentService.CreateDocument(c.Request.Context(), req, companyID, fileName, fileData)
+	extraction, err := h.documentService.ExtractDocument(c.Request.Context(), header.Filename, file, companyID, userID)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	c.JSON(http.StatusCreated, entity.CreateDocumentResponse{Hash: hash})
+	c.JSON(http.StatusOK, extraction)
+}
+
+// formFileError reports a missing file, or a request body cut off by the upload size limit
+func formFileError(err error) errs.Error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errs.ValidationError("file exceeds the maximum upload size", err)
+	}
+	return errs.BadRequestError("file is required", err)
+}
+
+// attachmentDisposition builds a Content-Disposition header, encoding non-ASCII file names per RFC 2231
+func attachmentDisposition(fileName string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+}
+
+// serveDocumentFile streams a document file with support for range and conditional requests.
+// Stored files are content-addressed, so their blob key is used as a strong ETag.
+func serveDocumentFile(c *gin.Context, fileName, contentType, fileKey string, file io.ReadSeeker) {
+	if fileKey != "" {
+		c.Header("ETag", `"`+fileKey+`"`)
+	}
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Content-Disposition", attachmentDisposition(fileName))
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, fileName, time.Time{}, file)
 }
 
 // DownloadFile godoc
 // @Summary      Download document file
-// @Description  Download the PDF file attached to a document. Only employees from the same company can access.
+// @Description  Download the PDF file attached to a document. Supports Range requests and conditional requests with the ETag (If-None-Match, If-Range). Only employees from the same company can access.
 // @Tags         documents
 // @Produce      application/pdf
 // @Security     BearerAuth
-// @Param        id        path      int  true  "Document ID"
+// @Param        id             path      int     true   "Document ID"
+// @Param        Range          header    string  false  "Byte range, e.g. bytes=0-1023"
+// @Param        If-None-Match  header    string  false  "ETag of a cached copy"
 // @Success      200       {file}    binary           "PDF file"
+// @Success      206       {file}    binary           "Requested byte range of the PDF file"
+// @Success      304       "Cached copy is current"
 // @Failure      400       {object}  errs.Error       "Invalid document ID"
 // @Failure      401       {object}  errs.Error       "Unauthorized"
 // @Failure      404       {object}  errs.Error       "Document or file not found"
+// @Failure      416       "Requested range not satisfiable"
+// @Failure      422       {object}  errs.Error       "Document is quarantined"
 // @Failure      500       {object}  errs.Error       "Internal server error"
 // @Router       /documents/{id}/file [get]
 func (h *DocumentHandler) DownloadFile(c *gin.Context) {
@@ -149,29 +287,35 @@ func (h *DocumentHandler) DownloadFile(c *gin.Context) {
 		return
 	}
 
-	doc, err := h.documentService.GetDocumentByID(c.Request.Context(), id, companyID)
+	doc, file, err := h.documentService.OpenDocumentFile(c.Request.Context(), id, companyID)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
+	defer file.Close()
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", doc.FileName))
-	c.Header("Content-Type", "application/pdf")
-	c.Data(http.StatusOK, "application/pdf", doc.FileData)
+	serveDocumentFile(c, doc.FileName, doc.ContentType, doc.FileKey, file)
 }
 
-// GetCompanyDocuments godoc
-// @Summary      Get all company documents
-// @Description  Get all documents for the authenticated user's company
+// ReplaceFile godoc
+// @Summary      Replace document file
+// @Description  Upload a new PDF file for an active document. The file goes through the same validation as on creation. The document moves to a new version and the previous file is kept in its version history; QR codes issued for earlier versions report the new version on verification. Only employees from the same company can replace.
 // @Tags         documents
+// @Accept       multipart/form-data
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200       {array}   entity.Document  "List of company documents"
+// @Param        id        path      int   true  "Document ID"
+// @Param        file      formData  file  true  "PDF file"
+// @Success      200       {object}  entity.Document  "Updated document"
+// @Failure      400       {object}  errs.Error       "Invalid request, rejected file or document is revoked or superseded"
 // @Failure      401       {object}  errs.Error       "Unauthorized"
+// @Failure      404       {object}  errs.Error       "Document not found"
+// @Failure      409       {object}  errs.Error       "Company already has a document with the same file"
+// @Failure      422       {object}  errs.Error       "File rejected by the malware scanner"
 // @Failure      500       {object}  errs.Error       "Internal server error"
-// @Router       /documents [get]
-func (h *DocumentHandler) GetCompanyDocuments(c *gin.Context) {
+// @Router       /documents/{id}/file [put]
+func (h *DocumentHandler) ReplaceFile(c *gin.Context) {
 	companyID, err := getCompanyIDFromContext(c)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
@@ -179,30 +323,51 @@ func (h *DocumentHandler) GetCompanyDocuments(c *gin.Context) {
 		return
 	}
 
-	docs, err := h.documentService.GetDocumentsByCompanyID(c.Request.Context(), companyID)
+	userID, err := getUserIDFromContext(c)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	c.JSON(http.StatusOK, docs)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		errCast := formFileError(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+	defer file.Close()
+
+	doc, err := h.documentService.ReplaceDocumentFile(c.Request.Context(), id, companyID, userID, header.Filename, file)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, doc)
 }
 
-// GetDocument godoc
-// @Summary      Get document by ID
-// @Description  Get a document by its ID. Only employees from the same company can access.
+// GetDocumentVersions godoc
+// @Summary      Get document versions
+// @Description  Get all versions of a document, newest first. Each change to the document details or file creates a new version. Only employees from the same company can access.
 // @Tags         documents
 // @Produce      json
 // @Security     BearerAuth
 // @Param        id        path      int  true  "Document ID"
-// @Success      200       {object}  entity.Document  "Document details"
-// @Failure      400       {object}  errs.Error       "Invalid document ID"
-// @Failure      401       {object}  errs.Error       "Unauthorized"
-// @Failure      404       {object}  errs.Error       "Document not found"
-// @Failure      500       {object}  errs.Error       "Internal server error"
-// @Router       /documents/{id} [get]
-func (h *DocumentHandler) GetDocument(c *gin.Context) {
+// @Success      200       {array}   entity.DocumentVersion  "Document versions"
+// @Failure      400       {object}  errs.Error              "Invalid document ID"
+// @Failure      401       {object}  errs.Error              "Unauthorized"
+// @Failure      404       {object}  errs.Error              "Document not found"
+// @Failure      500       {object}  errs.Error              "Internal server error"
+// @Router       /documents/{id}/versions [get]
+func (h *DocumentHandler) GetDocumentVersions(c *gin.Context) {
 	companyID, err := getCompanyIDFromContext(c)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
@@ -210,36 +375,38 @@ func (h *DocumentHandler) GetDocument(c *gin.Context) {
 		return
 	}
 
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
 		return
 	}
 
-	doc, err := h.documentService.GetDocumentByID(c.Request.Context(), id, companyID)
+	versions, err := h.documentService.GetDocumentVersions(c.Request.Context(), id, companyID)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	c.JSON(http.StatusOK, doc)
+	c.JSON(http.StatusOK, versions)
 }
 
-// VerifyDocument godoc
-// @Summary      Verify a document by hash
-// @Description  Verify a document using its hash from query parameter and get full details with expiration status. Only employees from the same company can verify. Each verification is recorded in history.
+// DownloadVersionFile godoc
+// @Summary      Download document version file
+// @Description  Download the PDF file of a specific document version. Supports Range requests and conditional requests with the ETag. Only employees from the same company can access.
 // @Tags         documents
-// @Produce      json
+// @Produce      application/pdf
 // @Security     BearerAuth
-// @Param        hash      query     string  true  "Document hash"
-// @Success      200       {object}  entity.VerifyDocumentResponse  "Document verification result"
-// @Failure      400       {object}  errs.Error                     "Invalid request or hash"
-// @Failure      401       {object}  errs.Error                     "Unauthorized"
-// @Failure      500       {object}  errs.Error                     "Internal server error"
-// @Router       /documents/verify [get]
-func (h *DocumentHandler) VerifyDocument(c *gin.Context) {
+// @Param        id        path      int  true  "Document ID"
+// @Param        version   path      int  true  "Document version"
+// @Success      200       {file}    binary           "PDF file"
+// @Failure      400       {object}  errs.Error       "Invalid document ID or version"
+// @Failure      401       {object}  errs.Error       "Unauthorized"
+// @Failure      404       {object}  errs.Error       "Document or version not found"
+// @Failure      422       {object}  errs.Error       "Document is quarantined"
+// @Failure      500       {object}  errs.Error       "Internal server error"
+// @Router       /documents/{id}/versions/{version}/file [get]
+func (h *DocumentHandler) DownloadVersionFile(c *gin.Context) {
 	companyID, err := getCompanyIDFromContext(c)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
@@ -247,77 +414,99 @@ func (h *DocumentHandler) VerifyDocument(c *gin.Context) {
 		return
 	}
 
-	userID, err := getUserIDFromContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		errCast := errs.ErrorCast(err)
-		c.JSON(errCast.StatusCode(), errCast)
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
 		return
 	}
 
-	hash := c.Query("hash")
-	if hash == "" {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("hash query parameter is required", nil))
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document version", err))
 		return
 	}
 
-	doc, status, message, err := h.documentService.VerifyDocument(c.Request.Context(), hash, companyID, userID)
+	v, file, err := h.documentService.OpenDocumentVersionFile(c.Request.Context(), id, version, companyID)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
+	defer file.Close()
 
-	c.JSON(http.StatusOK, entity.VerifyDocumentResponse{
-		Document: doc,
-		Status:   status,
-		Message:  message,
-	})
+	serveDocumentFile(c, v.FileName, v.ContentType, v.FileKey, file)
 }
 
-// GetHistory godoc
-// @Summary      Get verification history
-// @Description  Get the authenticated user's document verification history
+// GetQRCode godoc
+// @Summary      Get document QR code
+// @Description  Render the document verification QR code (public verification URL or hash) as PNG or SVG. Only employees from the same company can access.
 // @Tags         documents
-// @Produce      json
+// @Produce      image/png
+// @Produce      image/svg+xml
 // @Security     BearerAuth
-// @Success      200       {array}   entity.VerificationHistory  "Verification history"
-// @Failure      401       {object}  errs.Error                  "Unauthorized"
-// @Failure      500       {object}  errs.Error                  "Internal server error"
-// @Router       /history [get]
-func (h *DocumentHandler) GetHistory(c *gin.Context) {
-	userID, err := getUserIDFromContext(c)
+// @Param        id        path      int     true   "Document ID"
+// @Param        format    query     string  false  "Image format: png or svg (default png)"
+// @Param        size      query     int     false  "Image size in pixels, 64-2048 (default 256)"
+// @Param        ec        query     string  false  "Error correction level: L, M, Q or H (default M)"
+// @Success      200       {file}    binary           "QR code image"
+// @Failure      400       {object}  errs.Error       "Invalid request"
+// @Failure      401       {object}  errs.Error       "Unauthorized"
+// @Failure      404       {object}  errs.Error       "Document not found"
+// @Failure      500       {object}  errs.Error       "Internal server error"
+// @Router       /documents/{id}/qr [get]
+func (h *DocumentHandler) GetQRCode(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	history, err := h.documentService.GetHistory(c.Request.Context(), userID)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	size, err := getOptionalIntQuery(c, "size")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid size", err))
+		return
+	}
+
+	opts := entity.QRCodeOptions{
+		Format:          c.Query("format"),
+		Size:            size,
+		ErrorCorrection: c.Query("ec"),
+	}
+
+	data, contentType, err := h.documentService.GetDocumentQRCode(c.Request.Context(), id, companyID, opts)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	c.JSON(http.StatusOK, history)
+	c.Data(http.StatusOK, contentType, data)
 }
 
-// CompareWithPhotos godoc
-// @Summary      Compare document with photos
-// @Description  Compare a document with uploaded photos. Sends original document and photos to analysis server.
+// DownloadCertifiedCopy godoc
+// @Summary      Download certified copy
+// @Description  Download the document PDF with its verification QR code stamped onto the first or last page. Only employees from the same company can access.
 // @Tags         documents
-// @Accept       multipart/form-data
-// @Produce      json
+// @Produce      application/pdf
 // @Security     BearerAuth
-// @Param        hash      formData  string  true  "Document hash"
-// @Param        photos    formData  file    true  "Photos of the document (multiple files allowed)"
-// @Success      200       {object}  entity.CompareDocumentResponse  "Comparison result"
-// @Failure      400       {object}  errs.Error                      "Invalid request"
-// @Failure      401       {object}  errs.Error                      "Unauthorized"
-// @Failure      404       {object}  errs.Error                      "Document not found"
-// @Failure      500       {object}  errs.Error                      "Internal server error"
-// @Router       /documents/compare/photos [post]
-func (h *DocumentHandler) CompareWithPhotos(c *gin.Context) {
+// @Param        id        path      int     true   "Document ID"
+// @Param        page      query     string  false  "Page to stamp: first or last (default last)"
+// @Param        size      query     int     false  "QR code size in points, 48-288 (default 96)"
+// @Param        ec        query     string  false  "Error correction level: L, M, Q or H (default M)"
+// @Success      200       {file}    binary           "Certified PDF copy"
+// @Failure      400       {object}  errs.Error       "Invalid request or unsupported PDF"
+// @Failure      401       {object}  errs.Error       "Unauthorized"
+// @Failure      404       {object}  errs.Error       "Document not found"
+// @Failure      500       {object}  errs.Error       "Internal server error"
+// @Router       /documents/{id}/certified-copy [get]
+func (h *DocumentHandler) DownloadCertifiedCopy(c *gin.Context) {
 	companyID, err := getCompanyIDFromContext(c)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
@@ -325,81 +514,46 @@ func (h *DocumentHandler) CompareWithPhotos(c *gin.Context) {
 		return
 	}
 
-	userID, err := getUserIDFromContext(c)
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		errCast := errs.ErrorCast(err)
-		c.JSON(errCast.StatusCode(), errCast)
-		return
-	}
-
-	hash := c.PostForm("hash")
-	if hash == "" {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("hash is required", nil))
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
 		return
 	}
 
-	// Get multiple photos from form
-	form, err := c.MultipartForm()
+	size, err := getOptionalIntQuery(c, "size")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid multipart form", err))
-		return
-	}
-
-	files := form.File["photos"]
-	if len(files) == 0 {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("at least one photo is required", nil))
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid size", err))
 		return
 	}
 
-	var photos [][]byte
-	for _, fileHeader := range files {
-		file, err := fileHeader.Open()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, errs.InternalError("failed to open photo", err))
-			return
-		}
-
-		data, err := io.ReadAll(file)
-		file.Close()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, errs.InternalError("failed to read photo", err))
-			return
-		}
-
-		photos = append(photos, data)
+	opts := entity.CertifiedCopyOptions{
+		Page:            c.Query("page"),
+		Size:            size,
+		ErrorCorrection: c.Query("ec"),
 	}
 
-	doc, status, message, analysis, err := h.documentService.CompareWithPhotos(c.Request.Context(), hash, userID, companyID, photos)
+	fileName, data, err := h.documentService.GetCertifiedCopy(c.Request.Context(), id, companyID, opts)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	c.JSON(http.StatusOK, entity.CompareDocumentResponse{
-		Status:   status,
-		Message:  message,
-		Document: doc,
-		Analysis: analysis,
-	})
+	c.Header("Content-Disposition", attachmentDisposition(fileName))
+	c.Data(http.StatusOK, "application/pdf", data)
 }
 
-// CompareWithPDF godoc
-// @Summary      Compare document with PDF
-// @Description  Compare a document with an uploaded PDF file. Sends both documents to analysis server.
+// GetCompanyDocuments godoc
+// @Summary      Get all company documents
+// @Description  Get all documents for the authenticated user's company
 // @Tags         documents
-// @Accept       multipart/form-data
 // @Produce      json
 // @Security     BearerAuth
-// @Param        hash      formData  string  true  "Document hash"
-// @Param        file      formData  file    true  "PDF file to compare"
-// @Success      200       {object}  entity.CompareDocumentResponse  "Comparison result"
-// @Failure      400       {object}  errs.Error                      "Invalid request"
-// @Failure      401       {object}  errs.Error                      "Unauthorized"
-// @Failure      404       {object}  errs.Error                      "Document not found"
-// @Failure      500       {object}  errs.Error                      "Internal server error"
-// @Router       /documents/compare/pdf [post]
-func (h *DocumentHandler) CompareWithPDF(c *gin.Context) {
+// @Success      200       {array}   entity.Document  "List of company documents"
+// @Failure      401       {object}  errs.Error       "Unauthorized"
+// @Failure      500       {object}  errs.Error       "Internal server error"
+// @Router       /documents [get]
+func (h *DocumentHandler) GetCompanyDocuments(c *gin.Context) {
 	companyID, err := getCompanyIDFromContext(c)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
@@ -407,43 +561,667 @@ func (h *DocumentHandler) CompareWithPDF(c *gin.Context) {
 		return
 	}
 
-	userID, err := getUserIDFromContext(c)
+	docs, err := h.documentService.GetDocumentsByCompanyID(c.Request.Context(), companyID)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	hash := c.PostForm("hash")
-	if hash == "" {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("hash is required", nil))
-		return
-	}
+	c.JSON(http.StatusOK, docs)
+}
 
-	file, _, err := c.Request.FormFile("file")
+// GetDocument godoc
+// @Summary      Get document by ID
+// @Description  Get a document by its ID. Only employees from the same company can access.
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int  true  "Document ID"
+// @Success      200       {object}  entity.Document  "Document details"
+// @Failure      400       {object}  errs.Error       "Invalid document ID"
+// @Failure      401       {object}  errs.Error       "Unauthorized"
+// @Failure      404       {object}  errs.Error       "Document not found"
+// @Failure      500       {object}  errs.Error       "Internal server error"
+// @Router       /documents/{id} [get]
+func (h *DocumentHandler) GetDocument(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errs.BadRequestError("PDF file is required", err))
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
-	defer file.Close()
 
-	pdfData, err := io.ReadAll(file)
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errs.InternalError("failed to read PDF file", err))
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
 		return
 	}
 
-	doc, status, message, analysis, err := h.documentService.CompareWithPDF(c.Request.Context(), hash, userID, companyID, pdfData)
+	doc, err := h.documentService.GetDocumentByID(c.Request.Context(), id, companyID)
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
 		return
 	}
 
-	c.JSON(http.StatusOK, entity.CompareDocumentResponse{
-		Status:   status,
-		Message:  message,
-		Document: doc,
-		Analysis: analysis,
-	})
+	c.JSON(http.StatusOK, doc)
+}
+
+// VerifyDocument godoc
+// @Summary      Verify a document by hash
+// @Description  Verify a document using its signed hash from query parameter and get full details with expiration status. The hash signature is validated before any lookup. Only employees from the same company can verify. Each verification is recorded in history.
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        hash      query     string  true  "Signed document hash"
+// @Success      200       {object}  entity.VerifyDocumentResponse  "Document verification result"
+// @Failure      400       {object}  errs.Error                     "Invalid request or hash"
+// @Failure      401       {object}  errs.Error                     "Unauthorized"
+// @Failure      500       {object}  errs.Error                     "Internal server error"
+// @Router       /documents/verify [get]
+func (h *DocumentHandler) VerifyDocument(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	hash := c.Query("hash")
+	if hash == "" {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("hash query parameter is required", nil))
+		return
+	}
+
+	doc, status, message, err := h.documentService.VerifyDocument(c.Request.Context(), hash, companyID, userID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, entity.VerifyDocumentResponse{
+		Document: doc,
+		Status:   status,
+		Message:  message,
+	})
+}
+
+// PublicVerifyDocument godoc
+// @Summary      Publicly verify a document by hash
+// @Description  Verify a document without authentication. Returns a redacted view (no file, no summary) if the issuing company allowed public verification for the document. Each verification is recorded anonymously in history. Requests are rate-limited per IP.
+// @Tags         public
+// @Produce      json
+// @Param        hash      query     string  true  "Signed document hash"
+// @Success      200       {object}  entity.PublicDocumentView  "Redacted document verification result"
+// @Failure      400       {object}  errs.Error                 "Invalid request or hash"
+// @Failure      404       {object}  errs.Error                 "Document not found or not publicly verifiable"
+// @Failure      429       {object}  errs.Error                 "Too many requests"
+// @Failure      500       {object}  errs.Error                 "Internal server error"
+// @Router       /public/verify [get]
+func (h *DocumentHandler) PublicVerifyDocument(c *gin.Context) {
+	hash := c.Query("hash")
+	if hash == "" {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("hash query parameter is required", nil))
+		return
+	}
+
+	view, err := h.documentService.VerifyDocumentPublic(c.Request.Context(), hash)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// SetPublicVerification godoc
+// @Summary      Toggle public verification
+// @Description  Allow or forbid unauthenticated third parties to verify a document. Only employees from the same company can change it.
+// @Tags         documents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                                     true  "Document ID"
+// @Param        request   body      entity.UpdatePublicVerificationRequest  true  "Public verification setting"
+// @Success      200       {object}  map[string]string  "Setting updated"
+// @Failure      400       {object}  errs.Error         "Invalid request"
+// @Failure      401       {object}  errs.Error         "Unauthorized"
+// @Failure      404       {object}  errs.Error         "Document not found"
+// @Failure      500       {object}  errs.Error         "Internal server error"
+// @Router       /documents/{id}/public-verification [put]
+func (h *DocumentHandler) SetPublicVerification(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	var req entity.UpdatePublicVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid request body", err))
+		return
+	}
+
+	if err := h.documentService.SetPublicVerification(c.Request.Context(), id, companyID, userID, *req.Enabled); err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Public verification updated successfully"})
+}
+
+// UpdateDocument godoc
+// @Summary      Update document details
+// @Description  Correct the type, name, summary or expiration date of an active document. Only employees from the same company can update. Changes are recorded in the document events.
+// @Tags         documents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                           true  "Document ID"
+// @Param        request   body      entity.UpdateDocumentRequest  true  "Document update data"
+// @Success      200       {object}  entity.Document  "Updated document"
+// @Failure      400       {object}  errs.Error       "Invalid request or document is revoked or superseded"
+// @Failure      401       {object}  errs.Error       "Unauthorized"
+// @Failure      404       {object}  errs.Error       "Document not found"
+// @Failure      500       {object}  errs.Error       "Internal server error"
+// @Router       /documents/{id} [patch]
+func (h *DocumentHandler) UpdateDocument(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	var req entity.UpdateDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid request body", err))
+		return
+	}
+
+	doc, err := h.documentService.UpdateDocument(c.Request.Context(), id, req, companyID, userID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// RevokeDocument godoc
+// @Summary      Revoke a document
+// @Description  Withdraw an active document. Verification of a revoked document returns red status with the revocation reason. Only employees from the same company can revoke.
+// @Tags         documents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                           true  "Document ID"
+// @Param        request   body      entity.RevokeDocumentRequest  true  "Revocation reason"
+// @Success      200       {object}  map[string]string  "Document revoked"
+// @Failure      400       {object}  errs.Error         "Invalid request or document is already revoked or superseded"
+// @Failure      401       {object}  errs.Error         "Unauthorized"
+// @Failure      404       {object}  errs.Error         "Document not found"
+// @Failure      500       {object}  errs.Error         "Internal server error"
+// @Router       /documents/{id}/revoke [post]
+func (h *DocumentHandler) RevokeDocument(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	var req entity.RevokeDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid request body", err))
+		return
+	}
+
+	if err := h.documentService.RevokeDocument(c.Request.Context(), id, companyID, userID, req.Reason); err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document revoked successfully"})
+}
+
+// SupersedeDocument godoc
+// @Summary      Supersede a document
+// @Description  Mark an active document as replaced by another active document of the same company. Verification of a superseded document returns red status pointing at the successor. Only employees from the same company can supersede.
+// @Tags         documents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                              true  "Document ID"
+// @Param        request   body      entity.SupersedeDocumentRequest  true  "Replacement document"
+// @Success      200       {object}  map[string]string  "Document superseded"
+// @Failure      400       {object}  errs.Error         "Invalid request or document or successor is not active"
+// @Failure      401       {object}  errs.Error         "Unauthorized"
+// @Failure      404       {object}  errs.Error         "Document or successor not found"
+// @Failure      500       {object}  errs.Error         "Internal server error"
+// @Router       /documents/{id}/supersede [post]
+func (h *DocumentHandler) SupersedeDocument(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	var req entity.SupersedeDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid request body", err))
+		return
+	}
+
+	if err := h.documentService.SupersedeDocument(c.Request.Context(), id, req.SuccessorID, companyID, userID); err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document superseded successfully"})
+}
+
+// DeleteDocument godoc
+// @Summary      Delete a document
+// @Description  Delete a document. Only admins can delete documents from the same company. Verification history and document events are kept, and verification reports the document as not found.
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                true  "Document ID"
+// @Success      200       {object}  map[string]string  "Document deleted"
+// @Failure      400       {object}  errs.Error         "Invalid document ID"
+// @Failure      401       {object}  errs.Error         "Unauthorized - only admins can delete documents"
+// @Failure      404       {object}  errs.Error         "Document not found"
+// @Failure      500       {object}  errs.Error         "Internal server error"
+// @Router       /documents/{id} [delete]
+func (h *DocumentHandler) DeleteDocument(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	if err := h.documentService.DeleteDocument(c.Request.Context(), id, role, companyID, userID); err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted successfully"})
+}
+
+// RescanDocument godoc
+// @Summary      Rescan document file
+// @Description  Scan the stored document file for malware again, e.g. after the scanner signatures were updated. A flagged active document is quarantined: its file is no longer served and verification reports red. A quarantined document whose file is now clean is released. Only admins can rescan documents from the same company.
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                          true  "Document ID"
+// @Success      200       {object}  entity.DocumentScanResponse  "Scan verdict and resulting document state"
+// @Failure      400       {object}  errs.Error                   "Invalid document ID"
+// @Failure      401       {object}  errs.Error                   "Unauthorized - only admins can rescan documents"
+// @Failure      404       {object}  errs.Error                   "Document not found"
+// @Failure      500       {object}  errs.Error                   "Internal server error or scanner unavailable"
+// @Router       /documents/{id}/rescan [post]
+func (h *DocumentHandler) RescanDocument(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	result, err := h.documentService.ScanDocument(c.Request.Context(), id, role, companyID, userID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// InvalidateAnalysisCache godoc
+// @Summary      Invalidate cached comparisons
+// @Description  Delete the cached comparison results of a document, so that the next comparison of the same evidence asks the analyzer again. Results are cached per document version, evidence, prompt and model. Only admins can invalidate the cache of documents from the same company.
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                                        true  "Document ID"
+// @Success      200       {object}  entity.AnalysisCacheInvalidationResponse  "Number of cached results deleted"
+// @Failure      400       {object}  errs.Error                                 "Invalid document ID"
+// @Failure      401       {object}  errs.Error                                 "Unauthorized - only admins can invalidate the cache"
+// @Failure      404       {object}  errs.Error                                 "Document not found"
+// @Failure      500       {object}  errs.Error                                 "Internal server error"
+// @Router       /documents/{id}/analysis-cache [delete]
+func (h *DocumentHandler) InvalidateAnalysisCache(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	result, err := h.documentService.InvalidateAnalysisCache(c.Request.Context(), id, role, companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetDocumentEvents godoc
+// @Summary      Get document events
+// @Description  Get the change history of a document (updates, revocation, supersession), newest first. Only employees from the same company can access.
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int  true  "Document ID"
+// @Success      200       {array}   entity.DocumentEvent  "Document events"
+// @Failure      400       {object}  errs.Error            "Invalid document ID"
+// @Failure      401       {object}  errs.Error            "Unauthorized"
+// @Failure      404       {object}  errs.Error            "Document not found"
+// @Failure      500       {object}  errs.Error            "Internal server error"
+// @Router       /documents/{id}/events [get]
+func (h *DocumentHandler) GetDocumentEvents(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	events, err := h.documentService.GetDocumentEvents(c.Request.Context(), id, companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// GetDocumentFields godoc
+// @Summary      Get document fields
+// @Description  Get the fields recorded for a document (parties, dates, identifiers). Comparisons check them and reference them in differences. Only employees from the same company can access.
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int  true  "Document ID"
+// @Success      200       {array}   entity.DocumentField  "Document fields"
+// @Failure      400       {object}  errs.Error            "Invalid document ID"
+// @Failure      401       {object}  errs.Error            "Unauthorized"
+// @Failure      404       {object}  errs.Error            "Document not found"
+// @Failure      500       {object}  errs.Error            "Internal server error"
+// @Router       /documents/{id}/fields [get]
+func (h *DocumentHandler) GetDocumentFields(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	fields, err := h.documentService.GetDocumentFields(c.Request.Context(), id, companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, fields)
+}
+
+// UpdateDocumentFields godoc
+// @Summary      Update document fields
+// @Description  Replace the fields of an active document, e.g. to correct extracted values. The change is recorded in the document events. Only employees from the same company can update.
+// @Tags         documents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                                 true  "Document ID"
+// @Param        request   body      entity.UpdateDocumentFieldsRequest  true  "Document fields"
+// @Success      200       {array}   entity.DocumentField  "Updated fields"
+// @Failure      400       {object}  errs.Error            "Invalid request or document is revoked or superseded"
+// @Failure      401       {object}  errs.Error            "Unauthorized"
+// @Failure      404       {object}  errs.Error            "Document not found"
+// @Failure      500       {object}  errs.Error            "Internal server error"
+// @Router       /documents/{id}/fields [put]
+func (h *DocumentHandler) UpdateDocumentFields(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	var req entity.UpdateDocumentFieldsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid request body", err))
+		return
+	}
+
+	fields, err := h.documentService.UpdateDocumentFields(c.Request.Context(), id, req, companyID, userID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, fields)
+}
+
+// GetHistory godoc
+// @Summary      Get verification history
+// @Description  Get the authenticated user's document verification history
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200       {array}   entity.VerificationHistory  "Verification history"
+// @Failure      401       {object}  errs.Error                  "Unauthorized"
+// @Failure      500       {object}  errs.Error                  "Internal server error"
+// @Router       /history [get]
+func (h *DocumentHandler) GetHistory(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	history, err := h.documentService.GetHistory(c.Request.Context(), userID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetHistoryAnalysis godoc
+// @Summary      Get analysis of a verification
+// @Description  Get the comparison analysis stored for a verification history entry, including the provider, model and prompt version that produced it
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "History entry ID"
+// @Success      200  {object}  entity.Analysis  "Stored analysis"
+// @Failure      400  {object}  errs.Error       "Invalid history ID"
+// @Failure      401  {object}  errs.Error       "Unauthorized"
+// @Failure      404  {object}  errs.Error       "No analysis stored for the entry"
+// @Failure      500  {object}  errs.Error       "Internal server error"
+// @Router       /history/{id}/analysis [get]
+func (h *DocumentHandler) GetHistoryAnalysis(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid history ID", err))
+		return
+	}
+
+	analysis, err := h.documentService.GetHistoryAnalysis(c.Request.Context(), id, companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, analysis)
 }
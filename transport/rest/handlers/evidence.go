@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/service"
+)
+
+type EvidenceHandler struct {
+	evidenceService service.EvidenceService
+}
+
+func NewEvidenceHandler(evidenceService service.EvidenceService) *EvidenceHandler {
+	return &EvidenceHandler{evidenceService: evidenceService}
+}
+
+// evidenceExtensions maps the content types of retained files to file name extensions for downloads
+var evidenceExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+}
+
+// SetEvidenceRetention godoc
+// @Summary      Set evidence retention
+// @Description  Opt the company in or out of keeping the photos and PDFs submitted for comparison. Retained files are linked to the verification history entry and purged automatically when the configured retention period ends. Opting out keeps files retained so far until they expire. Only admins can change the setting.
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      entity.UpdateEvidenceRetentionRequest  true  "Evidence retention setting"
+// @Success      200      {object}  map[string]string                      "Evidence retention updated"
+// @Failure      400      {object}  errs.Error                             "Invalid request"
+// @Failure      401      {object}  errs.Error                             "Unauthorized - only admins can change evidence retention"
+// @Failure      500      {object}  errs.Error                             "Internal server error"
+// @Router       /user/company/evidence-retention [put]
+func (h *EvidenceHandler) SetEvidenceRetention(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	var req entity.UpdateEvidenceRetentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid request body", err))
+		return
+	}
+
+	if err := h.evidenceService.SetEvidenceRetention(c.Request.Context(), role, companyID, *req.Enabled); err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Evidence retention updated successfully"})
+}
+
+// GetHistoryEvidence godoc
+// @Summary      List evidence of a verification
+// @Description  List the photos or PDF retained for a verification history entry. The list is empty when the company did not retain evidence at the time or the retention period ended. Only admins can access evidence.
+// @Tags         documents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "History entry ID"
+// @Success      200  {array}   entity.Evidence  "Retained files"
+// @Failure      400  {object}  errs.Error       "Invalid history ID"
+// @Failure      401  {object}  errs.Error       "Unauthorized - only admins can access evidence"
+// @Failure      500  {object}  errs.Error       "Internal server error"
+// @Router       /history/{id}/evidence [get]
+func (h *EvidenceHandler) GetHistoryEvidence(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid history ID", err))
+		return
+	}
+
+	evidence, err := h.evidenceService.GetHistoryEvidence(c.Request.Context(), id, role, companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, evidence)
+}
+
+// DownloadEvidenceFile godoc
+// @Summary      Download evidence file
+// @Description  Download a retained photo or PDF. Supports Range requests and conditional requests with the ETag. Only admins of the company that submitted the file can download it.
+// @Tags         documents
+// @Produce      application/octet-stream
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Evidence ID"
+// @Success      200  {file}    binary      "Retained file"
+// @Success      206  {file}    binary      "Requested byte range of the file"
+// @Success      304  "Cached copy is current"
+// @Failure      400  {object}  errs.Error  "Invalid evidence ID"
+// @Failure      401  {object}  errs.Error  "Unauthorized - only admins can access evidence"
+// @Failure      404  {object}  errs.Error  "Evidence not found or already purged"
+// @Failure      500  {object}  errs.Error  "Internal server error"
+// @Router       /evidence/{id}/file [get]
+func (h *EvidenceHandler) DownloadEvidenceFile(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid evidence ID", err))
+		return
+	}
+
+	evidence, file, err := h.evidenceService.OpenEvidenceFile(c.Request.Context(), id, role, companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+	defer file.Close()
+
+	fileName := fmt.Sprintf("evidence-%d-%d%s", evidence.DocumentID, evidence.ID, evidenceExtensions[evidence.ContentType])
+	serveDocumentFile(c, fileName, evidence.ContentType, evidence.FileKey, file)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/service"
+)
+
+type JobHandler struct {
+	jobService service.ComparisonJobService
+}
+
+func NewJobHandler(jobService service.ComparisonJobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// CompareWithPhotos godoc
+// @Summary      Compare document with photos
+// @Description  Verify a document and queue its comparison with uploaded photos. The photos are scanned for malware before they are stored. Poll the returned job at /jobs/{id} or pass a webhook_url to be notified when it finished. Photos (JPEG, PNG, GIF or WEBP) are turned upright according to their EXIF orientation, scaled down and stripped of their metadata, including GPS coordinates, before they are stored or sent for analysis. HEIC photos and photos that are too dark or blurry are rejected with a message asking to retake them.
+// @Tags         documents
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        hash         formData  string  true   "Document hash"
+// @Param        photos       formData  file    true   "Photos of the document (up to 20 files, together no larger than the upload size limit)"
+// @Param        pages        formData  string  false  "Page of the document each photo shows, in the order of the photos, e.g. 1,2,2,3. Compares the document page by page and reports missing and extra pages."
+// @Param        webhook_url  formData  string  false  "Public http or https URL notified with the finished job"
+// @Success      202          {object}  entity.ComparisonJobResponse  "Comparison job queued"
+// @Header       202          {string}  Location                      "URL of the job"
+// @Failure      400          {object}  errs.Error                    "Invalid request, too many or too large photos or a photo that has to be retaken"
+// @Failure      401          {object}  errs.Error                    "Unauthorized"
+// @Failure      402          {object}  errs.Error                    "Monthly AI usage quota exceeded"
+// @Failure      422          {object}  errs.Error                    "File rejected by the malware scanner"
+// @Failure      500          {object}  errs.Error                    "Internal server error"
+// @Router       /documents/compare/photos [post]
+func (h *JobHandler) CompareWithPhotos(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	hash := c.PostForm("hash")
+	if hash == "" {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("hash is required", nil))
+		return
+	}
+
+	// Get multiple photos from form
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid multipart form", err))
+		return
+	}
+
+	files := form.File["photos"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("at least one photo is required", nil))
+		return
+	}
+	if len(files) > service.MaxComparisonPhotos {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError(fmt.Sprintf("at most %d photos can be compared at once", service.MaxComparisonPhotos), nil))
+		return
+	}
+
+	pages, err := parsePages(form.Value["pages"])
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid pages value, use page numbers separated by commas", err))
+		return
+	}
+
+	var photos [][]byte
+	for _, fileHeader := range files {
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errs.InternalError("failed to open photo", err))
+			return
+		}
+
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errs.InternalError("failed to read photo", err))
+			return
+		}
+
+		photos = append(photos, data)
+	}
+
+	job, err := h.jobService.EnqueueComparison(c.Request.Context(), entity.ComparisonJobPhotos, hash, userID, companyID, photos, pages, c.PostForm("webhook_url"))
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.Header("Location", "/api/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// parsePages reads page numbers given as repeated values, comma separated values or both
+func parsePages(values []string) ([]int, error) {
+	var pages []int
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			page, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, err
+			}
+			pages = append(pages, page)
+		}
+	}
+	return pages, nil
+}
+
+// CompareWithPDF godoc
+// @Summary      Compare document with PDF
+// @Description  Verify a document and queue its comparison with an uploaded PDF file. The file is scanned for malware before it is stored. Poll the returned job at /jobs/{id} or pass a webhook_url to be notified when it finished. Comparing the same file with the same document version again returns the cached result, flagged in analysis.cache.
+// @Tags         documents
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        hash         formData  string  true   "Document hash"
+// @Param        file         formData  file    true   "PDF file to compare"
+// @Param        webhook_url  formData  string  false  "Public http or https URL notified with the finished job"
+// @Success      202          {object}  entity.ComparisonJobResponse  "Comparison job queued"
+// @Header       202          {string}  Location                      "URL of the job"
+// @Failure      400          {object}  errs.Error                    "Invalid request"
+// @Failure      401          {object}  errs.Error                    "Unauthorized"
+// @Failure      402          {object}  errs.Error                    "Monthly AI usage quota exceeded"
+// @Failure      422          {object}  errs.Error                    "File rejected by the malware scanner"
+// @Failure      500          {object}  errs.Error                    "Internal server error"
+// @Router       /documents/compare/pdf [post]
+func (h *JobHandler) CompareWithPDF(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	hash := c.PostForm("hash")
+	if hash == "" {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("hash is required", nil))
+		return
+	}
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("PDF file is required", err))
+		return
+	}
+	defer file.Close()
+
+	pdfData, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errs.InternalError("failed to read PDF file", err))
+		return
+	}
+
+	job, err := h.jobService.EnqueueComparison(c.Request.Context(), entity.ComparisonJobPDF, hash, userID, companyID, [][]byte{pdfData}, nil, c.PostForm("webhook_url"))
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.Header("Location", "/api/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetJob godoc
+// @Summary      Get comparison job
+// @Description  Get the status of a comparison job and, once it succeeded, the comparison result. Failed jobs carry the error of their last attempt.
+// @Tags         jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Job ID"
+// @Success      200  {object}  entity.ComparisonJobResponse  "Comparison job"
+// @Failure      401  {object}  errs.Error                    "Unauthorized"
+// @Failure      404  {object}  errs.Error                    "Job not found"
+// @Failure      500  {object}  errs.Error                    "Internal server error"
+// @Router       /jobs/{id} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	job, err := h.jobService.GetJob(c.Request.Context(), c.Param("id"), companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
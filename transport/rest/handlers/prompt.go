@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/service"
+)
+
+type PromptHandler struct {
+	promptService service.PromptService
+}
+
+func NewPromptHandler(promptService service.PromptService) *PromptHandler {
+	return &PromptHandler{promptService: promptService}
+}
+
+// CreatePromptTemplate godoc
+// @Summary      Add prompt template version
+// @Description  Add a new version of the company's comparison prompt for a document type, or for all types when document_type is empty. Comparisons use the latest version of the most specific template: the company's template for the document type, the company's default, the global template for the type, the global default and finally the built-in prompt. Only admins can change prompts.
+// @Tags         prompts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      entity.CreatePromptTemplateRequest  true  "Prompt template"
+// @Success      201      {object}  entity.PromptTemplate               "Created template version"
+// @Failure      400      {object}  errs.Error                          "Invalid request or template"
+// @Failure      401      {object}  errs.Error                          "Unauthorized - only admins can change prompt templates"
+// @Failure      409      {object}  errs.Error                          "Another version was added at the same time"
+// @Failure      500      {object}  errs.Error                          "Internal server error"
+// @Router       /prompts [post]
+func (h *PromptHandler) CreatePromptTemplate(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	var req entity.CreatePromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid request body", err))
+		return
+	}
+
+	template, err := h.promptService.CreatePromptTemplate(c.Request.Context(), req, role, companyID, userID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// GetPromptTemplates godoc
+// @Summary      List prompt templates
+// @Description  List every version of the company's comparison prompt templates and of the global templates
+// @Tags         prompts
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   entity.PromptTemplate  "Prompt templates"
+// @Failure      401  {object}  errs.Error             "Unauthorized"
+// @Failure      500  {object}  errs.Error             "Internal server error"
+// @Router       /prompts [get]
+func (h *PromptHandler) GetPromptTemplates(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	templates, err := h.promptService.GetPromptTemplates(c.Request.Context(), companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetPromptTemplate godoc
+// @Summary      Get prompt template
+// @Description  Get a version of a comparison prompt template, e.g. the one referenced by a stored analysis
+// @Tags         prompts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Prompt template ID"
+// @Success      200  {object}  entity.PromptTemplate  "Prompt template"
+// @Failure      400  {object}  errs.Error             "Invalid prompt template ID"
+// @Failure      401  {object}  errs.Error             "Unauthorized"
+// @Failure      404  {object}  errs.Error             "Prompt template not found"
+// @Failure      500  {object}  errs.Error             "Internal server error"
+// @Router       /prompts/{id} [get]
+func (h *PromptHandler) GetPromptTemplate(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid prompt template ID", err))
+		return
+	}
+
+	template, err := h.promptService.GetPromptTemplate(c.Request.Context(), id, companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
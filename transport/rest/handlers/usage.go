@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tasklineby/certify-backend/entity"
+	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/service"
+)
+
+type UsageHandler struct {
+	usageService service.UsageService
+}
+
+func NewUsageHandler(usageService service.UsageService) *UsageHandler {
+	return &UsageHandler{usageService: usageService}
+}
+
+// GetUsage godoc
+// @Summary      Get AI usage
+// @Description  Get the tokens AI providers billed for the company's comparisons and extractions, aggregated by day or month in UTC, with their cost and the company's monthly token quota. Comparisons served from the cache use no tokens. Without a range, the current month is reported by day and the last twelve months by month. Only admins can view usage.
+// @Tags         usage
+// @Produce      json
+// @Security     BearerAuth
+// @Param        period       query     string  false  "Aggregation period: day (default) or month"
+// @Param        from         query     string  false  "First day of the report (YYYY-MM-DD)"
+// @Param        to           query     string  false  "Last day of the report (YYYY-MM-DD), inclusive"
+// @Param        user_id      query     int     false  "Only count usage of this user"
+// @Param        document_id  query     int     false  "Only count comparisons of this document"
+// @Success      200          {object}  entity.UsageReport  "Usage report"
+// @Failure      400          {object}  errs.Error          "Invalid period, date or range"
+// @Failure      401          {object}  errs.Error          "Unauthorized - only admins can view usage"
+// @Failure      500          {object}  errs.Error          "Internal server error"
+// @Router       /usage [get]
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	filter := entity.UsageFilter{Period: entity.UsagePeriod(c.Query("period"))}
+	if filter.From, err = getOptionalDateQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("from must be formatted YYYY-MM-DD", err))
+		return
+	}
+	if filter.To, err = getOptionalDateQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("to must be formatted YYYY-MM-DD", err))
+		return
+	}
+	if !filter.To.IsZero() {
+		// The report includes the last day
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	if filter.UserID, err = getOptionalIntQuery(c, "user_id"); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid user ID", err))
+		return
+	}
+	if filter.DocumentID, err = getOptionalIntQuery(c, "document_id"); err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid document ID", err))
+		return
+	}
+
+	report, err := h.usageService.GetUsage(c.Request.Context(), role, companyID, filter)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// getOptionalDateQuery parses an optional YYYY-MM-DD query parameter as midnight UTC, returning the
+// zero time if it is absent
+func getOptionalDateQuery(c *gin.Context, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
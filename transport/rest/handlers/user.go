@@ -7,21 +7,16 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/tasklineby/certify-backend/entity"
 	"github.com/tasklineby/certify-backend/errs"
-	"github.com/tasklineby/certify-backend/repository/rdb"
 	"github.com/tasklineby/certify-backend/service"
 )
 
 type UserHandler struct {
 	userService service.UserService
-	jwtService  service.JwtService
-	tokenRepo   rdb.TokenRepository
 }
 
-func NewUserHandler(userService service.UserService, jwtService service.JwtService, tokenRepo rdb.TokenRepository) *UserHandler {
+func NewUserHandler(userService service.UserService) *UserHandler {
 	return &UserHandler{
 		userService: userService,
-		jwtService:  jwtService,
-		tokenRepo:   tokenRepo,
 	}
 }
 
@@ -43,7 +38,7 @@ func (h *UserHandler) CreateCompanyWithAdmin(c *gin.Context) {
 		return
 	}
 
-	tokenPair, err := h.userService.CreateCompanyWithAdmin(c.Request.Context(), req, h.jwtService, h.tokenRepo)
+	tokenPair, err := h.userService.CreateCompanyWithAdmin(c.Request.Context(), req, sessionClient(c, ""))
 	if err != nil {
 		errCast := errs.ErrorCast(err)
 		c.JSON(errCast.StatusCode(), errCast)
@@ -114,7 +109,7 @@ func (h *UserHandler) GetMe(c *gin.Context) {
 
 // UpdateUser godoc
 // @Summary      Update user by ID
-// @Description  Update user profile information. Only admins can update other users from the same company.
+// @Description  Update user profile information. Only admins can update other users from the same company and change roles. Changing the role or password ends all sessions of the user.
 // @Tags         user
 // @Accept       json
 // @Produce      json
@@ -172,7 +167,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 
 // UpdateMe godoc
 // @Summary      Update current user
-// @Description  Update the authenticated user's profile information
+// @Description  Update the authenticated user's profile information. Changing the password requires the current password and ends all sessions.
 // @Tags         user
 // @Accept       json
 // @Produce      json
@@ -228,7 +223,7 @@ func (h *UserHandler) UpdateMe(c *gin.Context) {
 
 // DeleteUser godoc
 // @Summary      Delete user by ID
-// @Description  Delete a user. Only admins can delete users from the same company.
+// @Description  Delete a user and end all of their sessions. Only admins can delete users from the same company.
 // @Tags         user
 // @Accept       json
 // @Produce      json
@@ -276,6 +271,49 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// RevokeUserSessions godoc
+// @Summary      Log user out everywhere
+// @Description  End all sessions of a user and revoke their access tokens, e.g. when the user's credentials were compromised. Only admins can end the sessions of users from the same company.
+// @Tags         user
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                               true  "User ID"
+// @Success      200       {object}  entity.SessionRevocationResponse  "Sessions ended"
+// @Failure      400       {object}  errs.Error                        "Invalid user ID"
+// @Failure      401       {object}  errs.Error                        "Unauthorized - only admins can end sessions of users"
+// @Failure      404       {object}  errs.Error                        "User not found"
+// @Router       /user/{id}/sessions [delete]
+func (h *UserHandler) RevokeUserSessions(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errs.BadRequestError("invalid user ID", err))
+		return
+	}
+
+	companyID, err := getCompanyIDFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	role, err := getUserRoleFromContext(c)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	response, err := h.userService.RevokeUserSessions(c.Request.Context(), userID, role, companyID)
+	if err != nil {
+		errCast := errs.ErrorCast(err)
+		c.JSON(errCast.StatusCode(), errCast)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // GetUsersByCompany godoc
 // @Summary      Get users by company
 // @Description  Get all users from the authenticated user's company
@@ -1,12 +1,15 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tasklineby/certify-backend/errs"
+	"github.com/tasklineby/certify-backend/repository/rdb"
 	"github.com/tasklineby/certify-backend/service"
 )
 
@@ -38,6 +41,41 @@ func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
 		c.Set("user_id", userID)
 		c.Set("user_role", tokenPayload.Role)
 		c.Set("company_id", tokenPayload.CompanyID)
+		c.Set("session_id", tokenPayload.SessionID)
+		c.Next()
+	}
+}
+
+// RateLimitMiddleware limits requests per client IP to limit per window.
+// Requests are let through if the limiter backend is unavailable.
+func RateLimitMiddleware(limiter rdb.RateLimitRepository, name string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, err := limiter.Allow(c.Request.Context(), name+":"+c.ClientIP(), limit, window)
+		if err != nil {
+			slog.Error("rate limiter unavailable", "err", err, "limiter", name)
+			c.Next()
+			return
+		}
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(window.Seconds())))
+			c.JSON(http.StatusTooManyRequests, errs.RateLimitedError("too many requests, try again later", nil))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// BodySizeLimitMiddleware rejects request bodies larger than limit bytes.
+// Bodies without a declared length are cut off once they exceed the limit.
+func BodySizeLimitMiddleware(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.JSON(http.StatusBadRequest, errs.ValidationError("request body is too large", nil))
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
 		c.Next()
 	}
 }